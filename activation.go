@@ -0,0 +1,103 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Activation describes the device-mapper device created by activating a device.
+type Activation struct {
+	// Name is the name the device was activated with.
+	Name string
+	// MapperPath is the path of the activated device's node, usually in /dev/mapper.
+	MapperPath string
+	// Major is the major number of the device-mapper device.
+	Major uint32
+	// Minor is the minor number of the device-mapper device.
+	Minor uint32
+	// Keyslot is the number of the keyslot that was unlocked.
+	Keyslot int
+}
+
+// ActivateByPassphraseEx is like ActivateByPassphrase, but also returns the resulting mapper path and device number,
+// so follow-up mount logic doesn't need to construct paths by convention, and the keyslot that was unlocked.
+// If 'deviceName' is empty, only the passphrase is checked, and the activation holds the keyslot alone.
+// If the device number cannot be found once activated, the mapping is kept, and the activation holds its conventional
+// mapper path with a zero device number.
+// With WithUdevWait, the mapper node is waited for, see ActivateEx.
+// Returns the activation on success, or an error otherwise.
+func (device *Device) ActivateByPassphraseEx(deviceName string, keyslot int, passphrase string, flags int, optionFuncs ...Option) (Activation, error) {
+	unlocked, err := device.ActivateByPassphraseKeyslot(deviceName, keyslot, passphrase, flags)
+	if err != nil {
+		return Activation{}, err
+	}
+
+	return device.completeActivation(deviceName, unlocked, newOptions(optionFuncs))
+}
+
+// ActivateEx activates a device using 'credential', like ActivateWithRetry does for a single attempt,
+// and returns the resulting mapper path and device number. The keyslot is reported as CRYPT_ANY_SLOT,
+// since credentials don't report the keyslot they unlocked: use ActivateByPassphraseEx when it is needed.
+// With WithUdevWait, the mapper node is waited for: if it doesn't appear in time, the device is deactivated again,
+// so no mapping is left behind, and ErrTimeout is returned.
+// Returns the activation on success, or an error otherwise.
+func (device *Device) ActivateEx(deviceName string, credential Credential, flags int, optionFuncs ...Option) (Activation, error) {
+	if err := credential.Activate(device, deviceName, flags); err != nil {
+		return Activation{}, err
+	}
+
+	return device.completeActivation(deviceName, CRYPT_ANY_SLOT, newOptions(optionFuncs))
+}
+
+// completeActivation returns the activation of the mapping named 'deviceName', in which 'keyslot' was unlocked,
+// once udev has created its node if WithUdevWait was given.
+func (device *Device) completeActivation(deviceName string, keyslot int, options options) (Activation, error) {
+	if deviceName == "" {
+		return Activation{Keyslot: keyslot}, nil
+	}
+
+	if options.udevWait > 0 {
+		if _, err := WaitForMapperNode(deviceName, options.udevWait); err != nil {
+			device.Deactivate(deviceName)
+			return Activation{}, err
+		}
+	}
+
+	activation, _ := lookupActivation(deviceName)
+	activation.Keyslot = keyslot
+	return activation, nil
+}
+
+// lookupActivation finds the device number of the active mapping named 'deviceName',
+// from its device node if udev created it, or from sysfs otherwise.
+func lookupActivation(deviceName string) (Activation, error) {
+	activation := Activation{Name: deviceName, MapperPath: MapperNodePath(deviceName)}
+
+	var stat syscall.Stat_t
+	if err := syscall.Stat(activation.MapperPath, &stat); err == nil && stat.Mode&syscall.S_IFMT == syscall.S_IFBLK {
+		activation.Major, activation.Minor = splitDeviceNumber(stat.Rdev)
+		return activation, nil
+	}
+
+	names, _ := filepath.Glob(filepath.Join(sysfsPath, "block", "dm-*", "dm", "name"))
+	for _, name := range names {
+		content, err := ioutil.ReadFile(name)
+		if err != nil || strings.TrimSpace(string(content)) != deviceName {
+			continue
+		}
+
+		dev, err := ioutil.ReadFile(filepath.Join(filepath.Dir(filepath.Dir(name)), "dev"))
+		if err != nil {
+			return activation, err
+		}
+		if _, err := fmt.Sscanf(strings.TrimSpace(string(dev)), "%d:%d", &activation.Major, &activation.Minor); err != nil {
+			return activation, err
+		}
+		return activation, nil
+	}
+
+	return activation, fmt.Errorf("active mapping '%s' not found", deviceName)
+}
@@ -0,0 +1,92 @@
+package cryptsetup
+
+import (
+	"testing"
+)
+
+func Test_Activation_ActivateByPassphraseEx(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	activation, err := device.ActivateByPassphraseEx(DeviceName, 0, PassKey, CRYPT_ACTIVATE_READONLY)
+	testWrapper.AssertNoError(err)
+
+	if activation.Name != DeviceName || activation.MapperPath != MapperNodePath(DeviceName) {
+		test.Errorf("Unexpected activation: %+v", activation)
+	}
+
+	if activation.Major == 0 {
+		test.Errorf("Activation should have a device-mapper major number: %+v", activation)
+	}
+
+	err = device.Deactivate(DeviceName)
+	testWrapper.AssertNoError(err)
+}
+
+func Test_Activation_ActivateByPassphraseEx_Fails_If_Device_Has_No_Type(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	_, err = device.ActivateByPassphraseEx(DeviceName, 0, PassKey, CRYPT_ACTIVATE_READONLY)
+	testWrapper.AssertError(err)
+}
+
+func Test_Activation_ActivateByPassphraseEx_Without_Name_Only_Checks(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(2, "", "testPassphrase"))
+
+	activation, err := device.ActivateByPassphraseEx("", CRYPT_ANY_SLOT, "testPassphrase", 0)
+	testWrapper.AssertNoError(err)
+	if activation != (Activation{Keyslot: 2}) {
+		test.Errorf("Checking the passphrase should only have returned the keyslot, but got: %+v", activation)
+	}
+}
+
+func Test_Activation_LookupActivation_Fails_If_Device_Is_Not_Active(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := lookupActivation("nonExistingDeviceName")
+	testWrapper.AssertError(err)
+}
+
+func Test_Device_ActivateByPassphraseKeyslot(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	pbkdfType := &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK}
+	err = device.Format(LUKS2{SectorSize: 512, PBKDFType: pbkdfType}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "firstPassphrase"))
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(3, "", "secondPassphrase"))
+
+	keyslot, err := device.ActivateByPassphraseKeyslot("", CRYPT_ANY_SLOT, "secondPassphrase", 0)
+	testWrapper.AssertNoError(err)
+	if keyslot != 3 {
+		test.Errorf("Passphrase should have unlocked keyslot 3, but unlocked %d.", keyslot)
+	}
+
+	_, err = device.ActivateByPassphraseKeyslot("", CRYPT_ANY_SLOT, "wrongPassphrase", 0)
+	testWrapper.AssertErrorCodeEquals(err, int(EPERM))
+}
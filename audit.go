@@ -0,0 +1,132 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+import "C"
+import (
+	"fmt"
+	"strings"
+)
+
+// AuditSeverity ranks an AuditFinding.
+type AuditSeverity string
+
+const (
+	// AuditSeverityWarning flags a configuration below current recommendations.
+	AuditSeverityWarning AuditSeverity = "warning"
+	// AuditSeverityCritical flags a configuration that does not protect the data as expected.
+	AuditSeverityCritical AuditSeverity = "critical"
+)
+
+// Codes of the findings reported by AuditHeader. They are stable, so compliance scanners can match on them.
+const (
+	AuditLUKS1Format        = "luks1-format"
+	AuditPBKDF2Iterations   = "pbkdf2-low-iterations"
+	AuditLegacyHash         = "legacy-hash"
+	AuditSmallVolumeKey     = "small-volume-key"
+	AuditSmallKeyslotKey    = "small-keyslot-key"
+	AuditNonXTSMode         = "non-xts-mode"
+	AuditInsecureCipherMode = "insecure-cipher-mode"
+	AuditUnreadableKeyslot  = "unreadable-keyslot"
+)
+
+// auditNoKeyslot is the Keyslot of findings about the whole header. The thresholds follow current NIST and OWASP guidance.
+const (
+	auditNoKeyslot           = -1
+	auditMinPBKDF2Iterations = 600000
+	auditMinXTSKeySize       = 512 / 8
+	auditMinKeySize          = 256 / 8
+)
+
+// auditLegacyHashes are the hashes no longer considered collision resistant.
+var auditLegacyHashes = map[string]bool{"md5": true, "sha1": true, "ripemd160": true}
+
+// AuditFinding is a weak configuration reported by AuditHeader.
+// Its JSON encoding has stable field names, so compliance scanners can consume it.
+type AuditFinding struct {
+	// Code identifies the kind of finding, such as AuditPBKDF2Iterations.
+	Code     string        `json:"code"`
+	Severity AuditSeverity `json:"severity"`
+	// Keyslot is the keyslot the finding applies to, or -1 if it applies to the whole header.
+	Keyslot int `json:"keyslot"`
+	// Message describes the finding for humans, such as "keyslot 0 uses 1000 PBKDF2 iterations, below 600000".
+	Message string `json:"message"`
+}
+
+// AuditHeader inspects the LUKS header of the device, so compliance scanners can flag weak configurations without
+// parsing `cryptsetup luksDump`: the LUKS1 format, PBKDF2 keyslots below 600000 iterations, hashes no longer
+// considered secure (md5, sha1 and ripemd160), volume keys below 256 bits, or below 512 bits for XTS, which splits the key in two,
+// and cipher modes other than XTS, ECB being reported as critical since it leaks data patterns.
+// The device must have been loaded. A header without findings returns an empty slice.
+// Returns the findings on success, or an error otherwise.
+func (device *Device) AuditHeader() ([]AuditFinding, error) {
+	if err := device.checkUsable(); err != nil {
+		return nil, err
+	}
+
+	deviceType := device.Type()
+	if deviceType != TypeLUKS1 && deviceType != TypeLUKS2 {
+		return nil, fmt.Errorf("device '%s' is not a LUKS device, and cannot be audited", device.DevicePath())
+	}
+
+	findings := []AuditFinding{}
+	add := func(code string, severity AuditSeverity, keyslot int, format string, args ...interface{}) {
+		findings = append(findings, AuditFinding{Code: code, Severity: severity, Keyslot: keyslot, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if deviceType == TypeLUKS1 {
+		add(AuditLUKS1Format, AuditSeverityWarning, auditNoKeyslot,
+			"the header uses the LUKS1 format, which only supports PBKDF2 and has no metadata checksums")
+	}
+
+	cipherMode := C.GoString(C.crypt_get_cipher_mode(device.cryptDevice))
+	minKeySize := auditMinKeySize
+	mode := strings.SplitN(cipherMode, "-", 2)[0]
+	switch mode {
+	case "xts":
+		minKeySize = auditMinXTSKeySize
+	case "ecb":
+		add(AuditInsecureCipherMode, AuditSeverityCritical, auditNoKeyslot,
+			"the cipher mode '%s' encrypts identical blocks identically, leaking data patterns", cipherMode)
+	default:
+		add(AuditNonXTSMode, AuditSeverityWarning, auditNoKeyslot, "the cipher mode '%s' is not XTS", cipherMode)
+	}
+	if parts := strings.SplitN(cipherMode, ":", 2); len(parts) == 2 && auditLegacyHashes[parts[1]] {
+		add(AuditLegacyHash, AuditSeverityWarning, auditNoKeyslot, "the IV generator of cipher mode '%s' uses the legacy hash '%s'", cipherMode, parts[1])
+	}
+
+	if keySize := device.VolumeKeySize(); keySize < minKeySize {
+		add(AuditSmallVolumeKey, AuditSeverityWarning, auditNoKeyslot,
+			"the volume key is %d bits, below %d bits for cipher mode '%s'", keySize*8, minKeySize*8, cipherMode)
+	}
+
+	for keyslot := 0; keyslot < device.KeyslotMax(); keyslot++ {
+		switch device.KeyslotStatus(keyslot) {
+		case CRYPT_SLOT_ACTIVE, CRYPT_SLOT_ACTIVE_LAST, CRYPT_SLOT_UNBOUND:
+		default:
+			continue
+		}
+
+		info, err := device.KeyslotPBKDFInfo(keyslot)
+		if err != nil {
+			add(AuditUnreadableKeyslot, AuditSeverityWarning, keyslot, "the PBKDF parameters of keyslot %d cannot be read: %v", keyslot, err)
+			continue
+		}
+		if info.Type == CRYPT_KDF_PBKDF2 && info.Iterations < auditMinPBKDF2Iterations {
+			add(AuditPBKDF2Iterations, AuditSeverityWarning, keyslot,
+				"keyslot %d uses %d PBKDF2 iterations, below %d", keyslot, info.Iterations, auditMinPBKDF2Iterations)
+		}
+		if auditLegacyHashes[info.Hash] {
+			add(AuditLegacyHash, AuditSeverityWarning, keyslot, "keyslot %d uses the legacy hash '%s'", keyslot, info.Hash)
+		}
+
+		if deviceType == TypeLUKS2 {
+			if keySize, err := device.KeyslotKeySize(keyslot); err == nil && keySize < minKeySize {
+				add(AuditSmallKeyslotKey, AuditSeverityWarning, keyslot,
+					"keyslot %d stores a %d bit key, below %d bits", keyslot, keySize*8, minKeySize*8)
+			}
+		}
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,85 @@
+package cryptsetup
+
+import (
+	"testing"
+)
+
+func auditCodes(findings []AuditFinding) map[string][]int {
+	codes := map[string][]int{}
+	for _, finding := range findings {
+		codes[finding.Code] = append(codes[finding.Code], finding.Keyslot)
+	}
+	return codes
+}
+
+func Test_Device_AuditHeader_Flags_Weak_LUKS1_Header(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha1"}, GenericParams{Cipher: "aes", CipherMode: "cbc-essiv:sha256", VolumeKeySize: 128 / 8})
+	testWrapper.AssertNoError(err)
+	device.restorePBKDFType(&PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha1", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK})
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "testPassphrase"))
+
+	findings, err := device.AuditHeader()
+	testWrapper.AssertNoError(err)
+
+	codes := auditCodes(findings)
+	expected := map[string][]int{
+		AuditLUKS1Format:      {-1},
+		AuditNonXTSMode:       {-1},
+		AuditSmallVolumeKey:   {-1},
+		AuditPBKDF2Iterations: {0},
+		AuditLegacyHash:       {0},
+	}
+	for code, keyslots := range expected {
+		if len(codes[code]) != len(keyslots) || codes[code][0] != keyslots[0] {
+			test.Errorf("Expected finding %s for keyslot %v, got: %+v", code, keyslots, findings)
+		}
+	}
+	if len(findings) != len(expected) {
+		test.Errorf("Unexpected findings: %+v", findings)
+	}
+}
+
+func Test_Device_AuditHeader_Accepts_Strong_LUKS2_Header(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	device.restorePBKDFType(&PbkdfType{Type: CRYPT_KDF_ARGON2ID, Hash: "sha256", Iterations: 4, MaxMemoryKb: 32, ParallelThreads: 1, Flags: CRYPT_PBKDF_NO_BENCHMARK})
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "testPassphrase"))
+
+	findings, err := device.AuditHeader()
+	testWrapper.AssertNoError(err)
+	if len(findings) != 0 {
+		test.Errorf("Unexpected findings: %+v", findings)
+	}
+
+	device.restorePBKDFType(&PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK})
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(1, "", "otherPassphrase"))
+
+	findings, err = device.AuditHeader()
+	testWrapper.AssertNoError(err)
+	if codes := auditCodes(findings); len(findings) != 1 || len(codes[AuditPBKDF2Iterations]) != 1 || codes[AuditPBKDF2Iterations][0] != 1 {
+		test.Errorf("Expected a single PBKDF2 finding for keyslot 1, got: %+v", findings)
+	}
+}
+
+func Test_Device_AuditHeader_Fails_If_Device_Is_Not_LUKS(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	_, err = device.AuditHeader()
+	testWrapper.AssertError(err)
+}
@@ -0,0 +1,181 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// directIOAlignment is the buffer alignment required by O_DIRECT on common block devices.
+const directIOAlignment = 4096
+
+// ThroughputResult is the outcome of reading or writing a device for a fixed duration.
+type ThroughputResult struct {
+	// Bytes is the number of bytes transferred.
+	Bytes uint64
+	// Operations is the number of reads or writes performed.
+	Operations int
+	// Duration is the time spent transferring data.
+	Duration time.Duration
+	// MeanLatency and MaxLatency are the mean and the maximum time taken by a single read or write.
+	MeanLatency time.Duration
+	MaxLatency  time.Duration
+}
+
+// BytesPerSecond returns the throughput in bytes per second.
+func (result ThroughputResult) BytesPerSecond() float64 {
+	if result.Duration <= 0 {
+		return 0
+	}
+	return float64(result.Bytes) / result.Duration.Seconds()
+}
+
+// MappingBenchmark compares the throughput of an active mapping with the one of its raw backing device.
+type MappingBenchmark struct {
+	MappingRead  ThroughputResult
+	MappingWrite ThroughputResult
+	RawRead      ThroughputResult
+	RawWrite     ThroughputResult
+}
+
+// ReadOverhead returns the fraction of the raw device read throughput lost through the dm-crypt layer.
+func (benchmark MappingBenchmark) ReadOverhead() float64 {
+	return overhead(benchmark.RawRead, benchmark.MappingRead)
+}
+
+// WriteOverhead returns the fraction of the raw device write throughput lost through the dm-crypt layer.
+func (benchmark MappingBenchmark) WriteOverhead() float64 {
+	return overhead(benchmark.RawWrite, benchmark.MappingWrite)
+}
+
+func overhead(raw ThroughputResult, mapping ThroughputResult) float64 {
+	if raw.BytesPerSecond() == 0 {
+		return 0
+	}
+	return 1 - mapping.BytesPerSecond()/raw.BytesPerSecond()
+}
+
+// BenchmarkMapping measures sequential read and write throughput and latency through the active mapping named 'name',
+// and through the raw device backing it, using 'blockSize' byte operations for 'duration' each.
+// Data is read and written with O_DIRECT, bypassing the page cache. Writes store back the blocks just read,
+// so the data is preserved, but the mapping must not be in use, such as by a mounted file system, while benchmarking.
+// Returns the benchmark on success, or an error otherwise.
+func BenchmarkMapping(name string, blockSize int, duration time.Duration) (MappingBenchmark, error) {
+	var benchmark MappingBenchmark
+
+	if blockSize <= 0 || blockSize%512 != 0 {
+		return benchmark, fmt.Errorf("block size %d is not a positive multiple of 512", blockSize)
+	}
+
+	device, err := InitByName(name)
+	if err != nil {
+		return benchmark, err
+	}
+	defer device.Free()
+
+	mappingPath := MapperNodePath(name)
+	rawPath := device.DevicePath()
+	rawOffset := int64(device.DataOffset() * 512)
+
+	if benchmark.MappingRead, err = benchmarkDevice(mappingPath, 0, blockSize, duration, false, syscall.O_DIRECT); err != nil {
+		return benchmark, err
+	}
+	if benchmark.MappingWrite, err = benchmarkDevice(mappingPath, 0, blockSize, duration, true, syscall.O_DIRECT); err != nil {
+		return benchmark, err
+	}
+	if benchmark.RawRead, err = benchmarkDevice(rawPath, rawOffset, blockSize, duration, false, syscall.O_DIRECT); err != nil {
+		return benchmark, err
+	}
+	if benchmark.RawWrite, err = benchmarkDevice(rawPath, rawOffset, blockSize, duration, true, syscall.O_DIRECT); err != nil {
+		return benchmark, err
+	}
+
+	return benchmark, nil
+}
+
+// benchmarkDevice sequentially reads the device in 'path' starting at 'offset', wrapping around at its end, for 'duration'.
+// If 'write' is set, every block read is written back in place, and only the writes are measured.
+func benchmarkDevice(path string, offset int64, blockSize int, duration time.Duration, write bool, openFlags int) (ThroughputResult, error) {
+	var result ThroughputResult
+
+	file, err := os.OpenFile(path, os.O_RDWR|openFlags, 0)
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return result, err
+	}
+	if size-offset < int64(blockSize) {
+		return result, fmt.Errorf("device '%s' is smaller than a single %d byte block", path, blockSize)
+	}
+	blocks := (size - offset) / int64(blockSize)
+
+	buffer := alignedBuffer(blockSize)
+	for block := int64(0); result.Duration < duration; block = (block + 1) % blocks {
+		position := offset + block*int64(blockSize)
+
+		start := time.Now()
+		if _, err := file.ReadAt(buffer, position); err != nil {
+			return result, err
+		}
+		if write {
+			start = time.Now()
+			if _, err := file.WriteAt(buffer, position); err != nil {
+				return result, err
+			}
+		}
+		latency := time.Since(start)
+
+		result.Bytes += uint64(blockSize)
+		result.Operations++
+		result.Duration += latency
+		if latency > result.MaxLatency {
+			result.MaxLatency = latency
+		}
+	}
+
+	result.MeanLatency = result.Duration / time.Duration(result.Operations)
+	return result, nil
+}
+
+// alignedBuffer returns a buffer of 'size' bytes suitable for O_DIRECT transfers.
+func alignedBuffer(size int) []byte {
+	buffer := make([]byte, size+directIOAlignment)
+	shift := 0
+	if remainder := int(uintptr(unsafe.Pointer(&buffer[0])) % directIOAlignment); remainder != 0 {
+		shift = directIOAlignment - remainder
+	}
+	return buffer[shift : shift+size]
+}
+
+// MeasureUnlockTime measures the wall-clock time 'credential' takes to open 'keyslot', without activating the device,
+// so administrators can confirm the keyslot's PBKDF parameters take the intended time on the actual hardware.
+// 'credential' is restricted to 'keyslot': it must be a credential unlocking keyslots, such as Passphrase, PassphraseBytes,
+// Keyfile, KeyringKey or PassphraseFd, as others, such as VolumeKey and Token, run no key derivation for the keyslot.
+// The time includes decrypting the keyslot and verifying the volume key digest, which are negligible next to the key derivation.
+// Returns the measured time on success, or an error if the credential doesn't open the keyslot.
+func (device *Device) MeasureUnlockTime(keyslot int, credential Credential, optionFuncs ...Option) (time.Duration, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
+	}
+
+	options := newOptions(optionFuncs)
+
+	restrictedCredential, restricted := keyslotCredential(credential, keyslot)
+	if !restricted {
+		return 0, fmt.Errorf("%T cannot be restricted to keyslot %d", credential, keyslot)
+	}
+
+	start := options.clock.Now()
+	if err := restrictedCredential.Activate(device, "", 0); err != nil {
+		return 0, err
+	}
+
+	return options.clock.Now().Sub(start), nil
+}
@@ -0,0 +1,88 @@
+package cryptsetup
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func Test_BenchmarkMapping_Fails_If_Mapping_Is_Not_Active(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := BenchmarkMapping("nonExistingDeviceName", 4096, time.Millisecond)
+	testWrapper.AssertError(err)
+
+	_, err = BenchmarkMapping("nonExistingDeviceName", 1000, time.Millisecond)
+	testWrapper.AssertError(err)
+}
+
+func Test_benchmarkDevice_Preserves_Data(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	checksum := getFileMD5(DevicePath, test)
+
+	result, err := benchmarkDevice(DevicePath, 4096, 4096, 10*time.Millisecond, true, 0)
+	testWrapper.AssertNoError(err)
+
+	if result.Operations == 0 || result.Bytes != uint64(result.Operations)*4096 || result.BytesPerSecond() <= 0 {
+		test.Errorf("Unexpected result: %+v", result)
+	}
+
+	if result.MaxLatency < result.MeanLatency {
+		test.Errorf("Maximum latency should not be lower than the mean latency: %+v", result)
+	}
+
+	if getFileMD5(DevicePath, test) != checksum {
+		test.Error("Benchmarking writes should have preserved the device's data.")
+	}
+}
+
+func Test_alignedBuffer(test *testing.T) {
+	buffer := alignedBuffer(512)
+	if len(buffer) != 512 || uintptrOf(buffer)%directIOAlignment != 0 {
+		test.Errorf("Buffer should have been 512 bytes long and aligned, but was %d bytes long at %#x.", len(buffer), uintptrOf(buffer))
+	}
+}
+
+func uintptrOf(buffer []byte) uintptr {
+	return uintptr(unsafe.Pointer(&buffer[0]))
+}
+
+func Test_Device_MeasureUnlockTime(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	device.restorePBKDFType(&PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK})
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "fastPassphrase"))
+	device.restorePBKDFType(&PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", TimeMs: 500})
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(1, "", "slowPassphrase"))
+
+	fast, err := device.MeasureUnlockTime(0, Passphrase{Passphrase: "fastPassphrase"})
+	testWrapper.AssertNoError(err)
+	slow, err := device.MeasureUnlockTime(1, Passphrase{Passphrase: "slowPassphrase"})
+	testWrapper.AssertNoError(err)
+	if slow < 100*time.Millisecond || slow <= fast {
+		test.Errorf("Keyslot 1 should take about 500ms to open, and longer than keyslot 0, but took %v against %v.", slow, fast)
+	}
+
+	_, err = device.MeasureUnlockTime(0, Passphrase{Passphrase: "slowPassphrase"})
+	testWrapper.AssertError(err)
+
+	_, err = device.MeasureUnlockTime(1, PassphraseBytes{Passphrase: []byte("slowPassphrase")})
+	testWrapper.AssertNoError(err)
+	_, err = device.MeasureUnlockTime(0, PassphraseBytes{Passphrase: []byte("slowPassphrase")})
+	testWrapper.AssertError(err)
+
+	volumeKey, _, err := device.VolumeKeyGet(0, "fastPassphrase")
+	testWrapper.AssertNoError(err)
+	for _, credential := range []Credential{VolumeKey{VolumeKey: string(volumeKey)}, Token{Token: CRYPT_ANY_TOKEN}} {
+		_, err = device.MeasureUnlockTime(1, credential)
+		testWrapper.AssertError(err)
+	}
+}
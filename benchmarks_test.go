@@ -0,0 +1,119 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchmarkDeviceSize is the apparent size of the sparse files backing benchmark devices.
+const benchmarkDeviceSize = 64 * 1024 * 1024
+
+// benchmarkVolumeKey is the volume key of benchmark devices, so activating them doesn't require running a PBKDF.
+const benchmarkVolumeKey = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// newBenchmarkDevice creates a sparse file, which libcryptsetup attaches to a loop device when activating it.
+// Returns the file's path and a function removing it.
+func newBenchmarkDevice(b *testing.B) (string, func()) {
+	directory, err := ioutil.TempDir("", "go-cryptsetup-benchmark")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	path := filepath.Join(directory, "device")
+	file, err := os.Create(path)
+	if err != nil {
+		os.RemoveAll(directory)
+		b.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := file.Truncate(benchmarkDeviceSize); err != nil {
+		os.RemoveAll(directory)
+		b.Fatal(err)
+	}
+
+	return path, func() { os.RemoveAll(directory) }
+}
+
+// formatBenchmarkDevice formats the device at 'path' as LUKS2, using benchmarkVolumeKey.
+func formatBenchmarkDevice(b *testing.B, path string, luks2 LUKS2) *Device {
+	device, err := Init(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	genericParams := GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKey: benchmarkVolumeKey, VolumeKeySize: len(benchmarkVolumeKey)}
+	if err := device.Format(luks2, genericParams); err != nil {
+		device.Free()
+		b.Fatal(err)
+	}
+
+	return device
+}
+
+func BenchmarkFormatLUKS2(b *testing.B) {
+	path, cleanup := newBenchmarkDevice(b)
+	defer cleanup()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for index := 0; index < b.N; index++ {
+		formatBenchmarkDevice(b, path, LUKS2{SectorSize: 512}).Free()
+	}
+}
+
+func BenchmarkActivate(b *testing.B) {
+	path, cleanup := newBenchmarkDevice(b)
+	defer cleanup()
+
+	device := formatBenchmarkDevice(b, path, LUKS2{SectorSize: 512})
+	defer device.Free()
+
+	if err := device.ActivateByVolumeKey(DeviceName, benchmarkVolumeKey, len(benchmarkVolumeKey), 0); err != nil {
+		b.Skipf("Activation is not possible in this environment: %v", err)
+	}
+	if err := device.Deactivate(DeviceName); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for index := 0; index < b.N; index++ {
+		if err := device.ActivateByVolumeKey(DeviceName, benchmarkVolumeKey, len(benchmarkVolumeKey), 0); err != nil {
+			b.Fatal(err)
+		}
+		if err := device.Deactivate(DeviceName); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAddKeyslotArgon2(b *testing.B) {
+	path, cleanup := newBenchmarkDevice(b)
+	defer cleanup()
+
+	// The cheapest Argon2id parameters, without benchmarking them, so the cgo layer's overhead isn't lost in the PBKDF's cost.
+	pbkdfType := &PbkdfType{Type: CRYPT_KDF_ARGON2ID, Iterations: 4, MaxMemoryKb: 32, ParallelThreads: 1, Flags: CRYPT_PBKDF_NO_BENCHMARK}
+	device := formatBenchmarkDevice(b, path, LUKS2{SectorSize: 512, PBKDFType: pbkdfType})
+	defer device.Free()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for index := 0; index < b.N; index++ {
+		keyslot, err := device.keyslotAddByVolumeKey(CRYPT_ANY_SLOT, benchmarkVolumeKey, "benchmarkPassphrase")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.StopTimer()
+		if err := device.KeyslotDestroy(keyslot); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+}
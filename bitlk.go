@@ -0,0 +1,264 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	// bitlkSuperblockOffset is the offset of the superblock, holding the offsets of the FVE metadata blocks, in the volume header.
+	// BitLocker To Go volumes, which start with a FAT boot sector, store it further.
+	bitlkSuperblockOffset     = 160
+	bitlkToGoSuperblockOffset = 424
+	// bitlkBlockHeaderSize and bitlkMetadataHeaderSize are the sizes of the headers starting each FVE metadata block.
+	bitlkBlockHeaderSize    = 64
+	bitlkMetadataHeaderSize = 48
+	bitlkEntryHeaderSize    = 8
+
+	bitlkEntryTypeVMK         = 0x0002
+	bitlkEntryTypeDescription = 0x0007
+	bitlkValueTypeString      = 0x0002
+	bitlkValueTypeVMK         = 0x0008
+)
+
+var (
+	bitlkSignature     = []byte("-FVE-FS-")
+	bitlkToGoSignature = []byte("MSWIN4.1")
+)
+
+// BITLKProtection is the type of a BitLocker key protector, deciding which credential unlocks its VMK.
+type BITLKProtection uint16
+
+const (
+	BITLKProtectionClearKey           BITLKProtection = 0x0000
+	BITLKProtectionTPM                BITLKProtection = 0x0100
+	BITLKProtectionStartupKey         BITLKProtection = 0x0200
+	BITLKProtectionTPMPIN             BITLKProtection = 0x0500
+	BITLKProtectionRecoveryPassphrase BITLKProtection = 0x0800
+	BITLKProtectionSmartCard          BITLKProtection = 0x1000
+	BITLKProtectionPassphrase         BITLKProtection = 0x2000
+)
+
+func (protection BITLKProtection) String() string {
+	switch protection {
+	case BITLKProtectionClearKey:
+		return "clear key"
+	case BITLKProtectionTPM:
+		return "TPM"
+	case BITLKProtectionStartupKey:
+		return "startup key"
+	case BITLKProtectionTPMPIN:
+		return "TPM and PIN"
+	case BITLKProtectionRecoveryPassphrase:
+		return "recovery passphrase"
+	case BITLKProtectionSmartCard:
+		return "smart card"
+	case BITLKProtectionPassphrase:
+		return "passphrase"
+	default:
+		return fmt.Sprintf("unknown (%#04x)", uint16(protection))
+	}
+}
+
+// BITLKKeyProtector describes a volume master key protector of a BitLocker device.
+type BITLKKeyProtector struct {
+	// GUID identifies the protector, as shown by `manage-bde -protectors -get`.
+	GUID       string
+	Protection BITLKProtection
+}
+
+// BITLKInfo describes the metadata of a BitLocker device, as read from its first FVE metadata block.
+type BITLKInfo struct {
+	// GUID is the volume GUID.
+	GUID string
+	// ToGo reports whether the device is a BitLocker To Go volume, such as a removable drive.
+	ToGo bool
+	// Version is the version of the FVE metadata, 2 for the volumes of Windows 7 and later.
+	Version uint16
+	// EncryptionMethod is the cipher of the volume, such as 0x8004 for AES-128 in XTS mode. See EncryptionMethodName.
+	EncryptionMethod uint16
+	// State and NextState are the encryption states recorded in the metadata, which differ while the volume is being
+	// encrypted or decrypted. See Converting.
+	State     uint16
+	NextState uint16
+	// EncryptedSize is the size of the encrypted volume, in bytes.
+	EncryptedSize uint64
+	Created       time.Time
+	// Description is set by Windows, such as "DESKTOP-1234 C: 1/2/2023".
+	Description   string
+	KeyProtectors []BITLKKeyProtector
+}
+
+// EncryptionMethodName names the volume's cipher, like `cryptsetup bitlkDump` does.
+func (info BITLKInfo) EncryptionMethodName() string {
+	switch info.EncryptionMethod {
+	case 0x8000:
+		return "aes-cbc-elephant, 128 bit"
+	case 0x8001:
+		return "aes-cbc-elephant, 256 bit"
+	case 0x8002:
+		return "aes-cbc, 128 bit"
+	case 0x8003:
+		return "aes-cbc, 256 bit"
+	case 0x8004:
+		return "aes-xts, 128 bit"
+	case 0x8005:
+		return "aes-xts, 256 bit"
+	default:
+		return fmt.Sprintf("unknown (%#04x)", info.EncryptionMethod)
+	}
+}
+
+// Converting reports whether the volume's encryption or decryption was started, but not completed,
+// in which case libcryptsetup refuses to activate it.
+// Notice the metadata doesn't tell volumes encrypted in "used space only" mode apart from fully encrypted ones.
+func (info BITLKInfo) Converting() bool {
+	return info.State != info.NextState
+}
+
+// HasProtection reports whether one of the volume's key protectors has the type 'protection',
+// so tooling can tell whether a recovery key or a passphrase unlocks the volume.
+func (info BITLKInfo) HasProtection(protection BITLKProtection) bool {
+	for _, protector := range info.KeyProtectors {
+		if protector.Protection == protection {
+			return true
+		}
+	}
+	return false
+}
+
+// BITLKInfo reads the metadata of the BitLocker device, which libcryptsetup only prints through Dump,
+// such as its volume GUID and the types of its key protectors. The device doesn't need to be loaded first.
+// Returns the metadata on success, or an error otherwise.
+func (device *Device) BITLKInfo() (BITLKInfo, error) {
+	if err := device.checkUsable(); err != nil {
+		return BITLKInfo{}, err
+	}
+
+	file, err := os.Open(device.MetadataDevicePath())
+	if err != nil {
+		return BITLKInfo{}, err
+	}
+	defer file.Close()
+
+	return readBITLKInfo(file)
+}
+
+// readBITLKInfo parses the volume header and the first FVE metadata block of a BitLocker device.
+func readBITLKInfo(reader io.ReaderAt) (BITLKInfo, error) {
+	var info BITLKInfo
+
+	header := make([]byte, 512)
+	if _, err := reader.ReadAt(header, 0); err != nil {
+		return info, err
+	}
+
+	superblockOffset := bitlkSuperblockOffset
+	switch {
+	case bytes.Equal(header[3:11], bitlkSignature):
+	case bytes.Equal(header[3:11], bitlkToGoSignature):
+		info.ToGo = true
+		superblockOffset = bitlkToGoSuperblockOffset
+	default:
+		return info, fmt.Errorf("no BITLK signature found")
+	}
+
+	metadataOffset := int64(binary.LittleEndian.Uint64(header[superblockOffset+16:]))
+
+	headers := make([]byte, bitlkBlockHeaderSize+bitlkMetadataHeaderSize)
+	if _, err := reader.ReadAt(headers, metadataOffset); err != nil {
+		return info, err
+	}
+	if !bytes.Equal(headers[:8], bitlkSignature) {
+		return info, fmt.Errorf("no FVE metadata block found at offset %d", metadataOffset)
+	}
+
+	info.Version = binary.LittleEndian.Uint16(headers[10:])
+	info.State = binary.LittleEndian.Uint16(headers[12:])
+	info.NextState = binary.LittleEndian.Uint16(headers[14:])
+	info.EncryptedSize = binary.LittleEndian.Uint64(headers[16:])
+
+	metadata := headers[bitlkBlockHeaderSize:]
+	metadataSize := binary.LittleEndian.Uint32(metadata[0:])
+	info.GUID = bitlkGUID(metadata[16:32])
+	info.EncryptionMethod = binary.LittleEndian.Uint16(metadata[36:])
+	info.Created = filetimeToTime(binary.LittleEndian.Uint64(metadata[40:]))
+
+	if metadataSize < bitlkMetadataHeaderSize {
+		return info, fmt.Errorf("invalid FVE metadata size %d", metadataSize)
+	}
+	entries := make([]byte, metadataSize-bitlkMetadataHeaderSize)
+	if _, err := reader.ReadAt(entries, metadataOffset+bitlkBlockHeaderSize+bitlkMetadataHeaderSize); err != nil {
+		return info, err
+	}
+
+	if err := info.parseEntries(entries); err != nil {
+		return info, err
+	}
+
+	return info, nil
+}
+
+// parseEntries reads the key protectors and the description out of the FVE metadata entries.
+func (info *BITLKInfo) parseEntries(entries []byte) error {
+	for len(entries) >= bitlkEntryHeaderSize {
+		size := int(binary.LittleEndian.Uint16(entries[0:]))
+		if size == 0 {
+			break
+		}
+		if size < bitlkEntryHeaderSize || size > len(entries) {
+			return fmt.Errorf("invalid FVE metadata entry size %d", size)
+		}
+
+		entryType := binary.LittleEndian.Uint16(entries[2:])
+		valueType := binary.LittleEndian.Uint16(entries[4:])
+		value := entries[bitlkEntryHeaderSize:size]
+
+		switch {
+		case entryType == bitlkEntryTypeVMK && valueType == bitlkValueTypeVMK && len(value) >= 28:
+			info.KeyProtectors = append(info.KeyProtectors, BITLKKeyProtector{
+				GUID:       bitlkGUID(value[0:16]),
+				Protection: BITLKProtection(binary.LittleEndian.Uint16(value[26:])),
+			})
+		case entryType == bitlkEntryTypeDescription && valueType == bitlkValueTypeString:
+			info.Description = utf16String(value)
+		}
+
+		entries = entries[size:]
+	}
+
+	return nil
+}
+
+// bitlkGUID formats a GUID stored in the Windows mixed-endian layout.
+func bitlkGUID(guid []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(guid[0:]), binary.LittleEndian.Uint16(guid[4:]),
+		binary.LittleEndian.Uint16(guid[6:]), guid[8:10], guid[10:16])
+}
+
+// utf16String decodes a NUL terminated UTF-16LE string.
+func utf16String(value []byte) string {
+	units := make([]uint16, 0, len(value)/2)
+	for index := 0; index+1 < len(value); index += 2 {
+		unit := binary.LittleEndian.Uint16(value[index:])
+		if unit == 0 {
+			break
+		}
+		units = append(units, unit)
+	}
+	return string(utf16.Decode(units))
+}
+
+// filetimeToTime converts a Windows FILETIME, counting 100ns intervals since 1601, to a time.Time.
+func filetimeToTime(filetime uint64) time.Time {
+	const unixEpochFiletime = 116444736000000000
+	if filetime < unixEpochFiletime {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(filetime-unixEpochFiletime)*100).UTC()
+}
@@ -0,0 +1,114 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// bitlkEntry encodes an FVE metadata entry.
+func bitlkEntry(entryType uint16, valueType uint16, value []byte) []byte {
+	entry := make([]byte, bitlkEntryHeaderSize, bitlkEntryHeaderSize+len(value))
+	binary.LittleEndian.PutUint16(entry[0:], uint16(bitlkEntryHeaderSize+len(value)))
+	binary.LittleEndian.PutUint16(entry[2:], entryType)
+	binary.LittleEndian.PutUint16(entry[4:], valueType)
+	binary.LittleEndian.PutUint16(entry[6:], 1)
+	return append(entry, value...)
+}
+
+// bitlkVMKEntry encodes a VMK entry protected by 'protection', whose GUID starts with 'guidPrefix'.
+func bitlkVMKEntry(guidPrefix byte, protection BITLKProtection) []byte {
+	value := make([]byte, 28)
+	value[0] = guidPrefix
+	binary.LittleEndian.PutUint16(value[26:], uint16(protection))
+	return bitlkEntry(bitlkEntryTypeVMK, bitlkValueTypeVMK, value)
+}
+
+// bitlkImage builds the volume header and the first FVE metadata block of a BitLocker device.
+func bitlkImage(entries ...[]byte) []byte {
+	const metadataOffset = 4096
+	image := make([]byte, 64*1024)
+
+	copy(image[3:], bitlkSignature)
+	binary.LittleEndian.PutUint64(image[bitlkSuperblockOffset+16:], metadataOffset)
+
+	block := image[metadataOffset:]
+	copy(block, bitlkSignature)
+	binary.LittleEndian.PutUint16(block[10:], 2)
+	binary.LittleEndian.PutUint16(block[12:], 4)
+	binary.LittleEndian.PutUint16(block[14:], 4)
+	binary.LittleEndian.PutUint64(block[16:], 32*1024*1024)
+
+	encodedEntries := bytes.Join(entries, nil)
+	metadata := block[bitlkBlockHeaderSize:]
+	binary.LittleEndian.PutUint32(metadata[0:], uint32(bitlkMetadataHeaderSize+len(encodedEntries)))
+	copy(metadata[16:32], []byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x78, 0x56, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78})
+	binary.LittleEndian.PutUint16(metadata[36:], 0x8004)
+	binary.LittleEndian.PutUint64(metadata[40:], 116444736000000000+uint64(time.Hour/100))
+	copy(metadata[bitlkMetadataHeaderSize:], encodedEntries)
+
+	return image
+}
+
+func Test_Device_BITLKInfo(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	description := make([]byte, 0)
+	for _, unit := range utf16.Encode([]rune("HOST C: 1/2/2023\x00")) {
+		description = append(description, byte(unit), byte(unit>>8))
+	}
+	image := bitlkImage(
+		bitlkEntry(bitlkEntryTypeDescription, bitlkValueTypeString, description),
+		bitlkVMKEntry(1, BITLKProtectionRecoveryPassphrase),
+		bitlkVMKEntry(2, BITLKProtectionTPM),
+	)
+
+	file, err := ioutil.TempFile("", "bitlk")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(image)
+	testWrapper.AssertNoError(err)
+	file.Close()
+
+	device, err := Init(file.Name())
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	info, err := device.BITLKInfo()
+	testWrapper.AssertNoError(err)
+
+	if info.GUID != "12345678-1234-5678-9abc-def012345678" || info.Version != 2 || info.ToGo {
+		test.Errorf("Unexpected volume: %+v", info)
+	}
+	if info.EncryptionMethodName() != "aes-xts, 128 bit" || info.EncryptedSize != 32*1024*1024 || info.Converting() {
+		test.Errorf("Unexpected encryption: %+v", info)
+	}
+	if !info.Created.Equal(time.Unix(3600, 0)) || info.Description != "HOST C: 1/2/2023" {
+		test.Errorf("Unexpected creation: %v, %q", info.Created, info.Description)
+	}
+
+	if len(info.KeyProtectors) != 2 || info.KeyProtectors[0].GUID != "00000001-0000-0000-0000-000000000000" {
+		test.Fatalf("Unexpected key protectors: %+v", info.KeyProtectors)
+	}
+	if !info.HasProtection(BITLKProtectionRecoveryPassphrase) || !info.HasProtection(BITLKProtectionTPM) || info.HasProtection(BITLKProtectionPassphrase) {
+		test.Errorf("Unexpected key protector types: %v, %v", info.KeyProtectors[0].Protection, info.KeyProtectors[1].Protection)
+	}
+}
+
+func Test_readBITLKInfo_Fails_Without_Signature(test *testing.T) {
+	_, err := readBITLKInfo(bytes.NewReader(make([]byte, 64*1024)))
+	if err == nil {
+		test.Error("Reading a device without a BITLK signature should have failed.")
+	}
+
+	image := bitlkImage(bitlkEntry(bitlkEntryTypeVMK, bitlkValueTypeVMK, make([]byte, 64*1024)))
+	if _, err := readBITLKInfo(bytes.NewReader(image)); err == nil {
+		test.Error("Reading metadata past the end of the device should have failed.")
+	}
+}
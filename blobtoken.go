@@ -0,0 +1,126 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SmallBlobTokenType is the LUKS2 token type used to store SmallBlobTokens.
+const SmallBlobTokenType = "go-cryptsetup-blob"
+
+// SmallBlobTokenMaxSize is the maximum size of the data held by a SmallBlobToken, in bytes,
+// keeping it well within the default LUKS2 metadata area.
+const SmallBlobTokenMaxSize = 4096
+
+// ErrSmallBlobTokenNotFound is returned when no SmallBlobToken with the requested name is stored in the header.
+var ErrSmallBlobTokenNotFound = errors.New("small blob token not found")
+
+// SmallBlobToken is arbitrary application data stored in a LUKS2 token, such as machine-binding metadata.
+// The data is stored along with its SHA-256 checksum, which is verified when it is read back.
+type SmallBlobToken struct {
+	// Name identifies the blob among the other SmallBlobTokens stored in the header.
+	Name string
+	Data []byte
+}
+
+// smallBlobTokenJSON is the JSON representation of a SmallBlobToken.
+type smallBlobTokenJSON struct {
+	Type     string   `json:"type"`
+	Keyslots []string `json:"keyslots"`
+	Name     string   `json:"name"`
+	Data     []byte   `json:"data"`
+	SHA256   string   `json:"sha256"`
+}
+
+// SetSmallBlobToken stores 'blob' in the header, replacing any SmallBlobToken with the same name.
+// Returns the number of the token slot that was used on success, or an error otherwise.
+func (device *Device) SetSmallBlobToken(blob SmallBlobToken) (int, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
+	}
+
+	if len(blob.Data) > SmallBlobTokenMaxSize {
+		return 0, fmt.Errorf("small blob token data is %d bytes long, exceeding the maximum of %d bytes", len(blob.Data), SmallBlobTokenMaxSize)
+	}
+
+	checksum := sha256.Sum256(blob.Data)
+	tokenJSON, err := json.Marshal(smallBlobTokenJSON{
+		Type:     SmallBlobTokenType,
+		Keyslots: []string{},
+		Name:     blob.Name,
+		Data:     blob.Data,
+		SHA256:   hex.EncodeToString(checksum[:]),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// crypt_token_json_set replaces an occupied token slot in place, so the existing blob is never lost in between.
+	token := CRYPT_ANY_TOKEN
+	if _, existing, err := device.findSmallBlobToken(blob.Name); err == nil {
+		token = existing
+	} else if err != ErrSmallBlobTokenNotFound {
+		return 0, err
+	}
+
+	return device.TokenJSONSet(token, string(tokenJSON))
+}
+
+// SmallBlobToken reads the SmallBlobToken named 'name' from the header, verifying its checksum.
+// Returns the blob on success, ErrSmallBlobTokenNotFound if there is none with that name, or an error otherwise.
+func (device *Device) SmallBlobToken(name string) (SmallBlobToken, error) {
+	blob, _, err := device.findSmallBlobToken(name)
+	return blob, err
+}
+
+// RemoveSmallBlobToken removes the SmallBlobToken named 'name' from the header.
+// Returns nil on success, ErrSmallBlobTokenNotFound if there is none with that name, or an error otherwise.
+func (device *Device) RemoveSmallBlobToken(name string) error {
+	_, token, err := device.findSmallBlobToken(name)
+	if err != nil {
+		return err
+	}
+
+	return device.TokenRemove(token)
+}
+
+// findSmallBlobToken looks up the SmallBlobToken named 'name', returning it along with its token slot.
+func (device *Device) findSmallBlobToken(name string) (SmallBlobToken, int, error) {
+	tokens, err := device.Tokens()
+	if err != nil {
+		return SmallBlobToken{}, 0, err
+	}
+
+	for _, token := range tokens {
+		if token.Type != SmallBlobTokenType {
+			continue
+		}
+
+		tokenJSON, err := device.TokenJSONGet(token.ID)
+		if err != nil {
+			return SmallBlobToken{}, 0, err
+		}
+
+		var stored smallBlobTokenJSON
+		if err := json.Unmarshal([]byte(tokenJSON), &stored); err != nil {
+			return SmallBlobToken{}, 0, fmt.Errorf("invalid small blob token %d: %v", token.ID, err)
+		}
+		if stored.Name != name {
+			continue
+		}
+
+		checksum := sha256.Sum256(stored.Data)
+		expected, err := hex.DecodeString(stored.SHA256)
+		if err != nil || !bytes.Equal(checksum[:], expected) {
+			return SmallBlobToken{}, 0, fmt.Errorf("checksum mismatch for small blob token '%s'", name)
+		}
+
+		return SmallBlobToken{Name: stored.Name, Data: stored.Data}, token.ID, nil
+	}
+
+	return SmallBlobToken{}, 0, ErrSmallBlobTokenNotFound
+}
@@ -0,0 +1,80 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_SmallBlobToken_Set_Get_Remove(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	first, err := device.SetSmallBlobToken(SmallBlobToken{Name: "machine-id", Data: []byte("first")})
+	testWrapper.AssertNoError(err)
+
+	_, err = device.SetSmallBlobToken(SmallBlobToken{Name: "other", Data: []byte{0, 1, 2}})
+	testWrapper.AssertNoError(err)
+
+	replaced, err := device.SetSmallBlobToken(SmallBlobToken{Name: "machine-id", Data: []byte("second")})
+	testWrapper.AssertNoError(err)
+	if replaced != first {
+		test.Errorf("Replaced blob should have kept token slot %d, but used: %d", first, replaced)
+	}
+
+	blob, err := device.SmallBlobToken("machine-id")
+	testWrapper.AssertNoError(err)
+	if !bytes.Equal(blob.Data, []byte("second")) {
+		test.Errorf("Unexpected blob data: %q", blob.Data)
+	}
+
+	err = device.RemoveSmallBlobToken("machine-id")
+	testWrapper.AssertNoError(err)
+
+	_, err = device.SmallBlobToken("machine-id")
+	if err != ErrSmallBlobTokenNotFound {
+		test.Errorf("Expected ErrSmallBlobTokenNotFound, but got: %v", err)
+	}
+
+	blob, err = device.SmallBlobToken("other")
+	testWrapper.AssertNoError(err)
+	if !bytes.Equal(blob.Data, []byte{0, 1, 2}) {
+		test.Errorf("Unexpected blob data: %v", blob.Data)
+	}
+}
+
+func Test_SmallBlobToken_Detects_Checksum_Mismatch(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	_, err = device.TokenJSONSet(CRYPT_ANY_TOKEN, `{"type":"go-cryptsetup-blob","keyslots":[],"name":"tampered","data":"AAEC","sha256":"00"}`)
+	testWrapper.AssertNoError(err)
+
+	_, err = device.SmallBlobToken("tampered")
+	testWrapper.AssertError(err)
+}
+
+func Test_SmallBlobToken_Fails_If_Data_Is_Too_Large(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	_, err = device.SetSmallBlobToken(SmallBlobToken{Name: "large", Data: make([]byte, SmallBlobTokenMaxSize+1)})
+	testWrapper.AssertError(err)
+}
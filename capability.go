@@ -0,0 +1,221 @@
+package cryptsetup
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <sys/ioctl.h>
+#include <linux/dm-ioctl.h>
+
+static int dm_list_versions(int fd, struct dm_ioctl *io) {
+	return ioctl(fd, DM_LIST_VERSIONS, io);
+}
+*/
+import "C"
+import (
+	"os"
+	"unsafe"
+)
+
+// dmVersion is a device-mapper version, of the ioctl interface or of a target.
+type dmVersion [3]uint32
+
+// atLeast reports whether the version is 'minimum' or newer.
+func (version dmVersion) atLeast(minimum dmVersion) bool {
+	for index := range version {
+		if version[index] != minimum[index] {
+			return version[index] > minimum[index]
+		}
+	}
+	return true
+}
+
+// flagRequirement is the device-mapper target version the kernel must provide to honor a flag.
+// An empty target means the flag only depends on libcryptsetup, or on the device-mapper ioctl interface when 'ioctl' is set.
+type flagRequirement struct {
+	target  string
+	version dmVersion
+	ioctl   dmVersion
+}
+
+// flagRequirementEntry pairs a flag with the kernel support it needs.
+type flagRequirementEntry struct {
+	flag        int
+	requirement flagRequirement
+}
+
+// flagRequirements indexes 'entries' by flag. Flags the libcryptsetup the package was built against doesn't define are 0,
+// and are left out, so they are reported unsupported.
+func flagRequirements(entries []flagRequirementEntry) map[int]flagRequirement {
+	requirements := make(map[int]flagRequirement, len(entries))
+	for _, entry := range entries {
+		if entry.flag != 0 {
+			requirements[entry.flag] = entry.requirement
+		}
+	}
+	return requirements
+}
+
+// activationFlagRequirements maps the activation flags to the kernel support they need, after libcryptsetup's own checks.
+var activationFlagRequirements = flagRequirements([]flagRequirementEntry{
+	{CRYPT_ACTIVATE_READONLY, flagRequirement{}},
+	{CRYPT_ACTIVATE_NO_UUID, flagRequirement{}},
+	{CRYPT_ACTIVATE_SHARED, flagRequirement{}},
+	{CRYPT_ACTIVATE_PRIVATE, flagRequirement{}},
+	{CRYPT_ACTIVATE_IGNORE_PERSISTENT, flagRequirement{}},
+	{CRYPT_ACTIVATE_ALLOW_DISCARDS, flagRequirement{target: "crypt", version: dmVersion{1, 11, 0}}},
+	{CRYPT_ACTIVATE_SAME_CPU_CRYPT, flagRequirement{target: "crypt", version: dmVersion{1, 14, 0}}},
+	{CRYPT_ACTIVATE_SUBMIT_FROM_CRYPT_CPUS, flagRequirement{target: "crypt", version: dmVersion{1, 14, 0}}},
+	{CRYPT_ACTIVATE_KEYRING_KEY, flagRequirement{target: "crypt", version: dmVersion{1, 15, 0}}},
+	{CRYPT_ACTIVATE_IV_LARGE_SECTORS, flagRequirement{target: "crypt", version: dmVersion{1, 17, 0}}},
+	{CRYPT_ACTIVATE_NO_READ_WORKQUEUE, flagRequirement{target: "crypt", version: dmVersion{1, 22, 0}}},
+	{CRYPT_ACTIVATE_NO_WRITE_WORKQUEUE, flagRequirement{target: "crypt", version: dmVersion{1, 22, 0}}},
+	{CRYPT_ACTIVATE_IGNORE_CORRUPTION, flagRequirement{target: "verity", version: dmVersion{1, 3, 0}}},
+	{CRYPT_ACTIVATE_RESTART_ON_CORRUPTION, flagRequirement{target: "verity", version: dmVersion{1, 3, 0}}},
+	{CRYPT_ACTIVATE_IGNORE_ZERO_BLOCKS, flagRequirement{target: "verity", version: dmVersion{1, 3, 0}}},
+	{CRYPT_ACTIVATE_CHECK_AT_MOST_ONCE, flagRequirement{target: "verity", version: dmVersion{1, 4, 0}}},
+	{CRYPT_ACTIVATE_PANIC_ON_CORRUPTION, flagRequirement{target: "verity", version: dmVersion{1, 7, 0}}},
+	{CRYPT_ACTIVATE_NO_JOURNAL, flagRequirement{target: "integrity", version: dmVersion{1, 0, 0}}},
+	{CRYPT_ACTIVATE_RECOVERY, flagRequirement{target: "integrity", version: dmVersion{1, 0, 0}}},
+	{CRYPT_ACTIVATE_RECALCULATE, flagRequirement{target: "integrity", version: dmVersion{1, 2, 0}}},
+	{CRYPT_ACTIVATE_NO_JOURNAL_BITMAP, flagRequirement{target: "integrity", version: dmVersion{1, 3, 0}}},
+	{CRYPT_ACTIVATE_RECALCULATE_RESET, flagRequirement{target: "integrity", version: dmVersion{1, 7, 0}}},
+})
+
+// deactivationFlagRequirements maps the deactivation flags to the kernel support they need.
+var deactivationFlagRequirements = flagRequirements([]flagRequirementEntry{
+	{CRYPT_DEACTIVATE_DEFERRED, flagRequirement{ioctl: dmVersion{4, 27, 0}}},
+	{CRYPT_DEACTIVATE_FORCE, flagRequirement{}},
+	{CRYPT_DEACTIVATE_DEFERRED_CANCEL, flagRequirement{ioctl: dmVersion{4, 27, 0}}},
+})
+
+// SupportsFlag reports whether the kernel supports every CRYPT_ACTIVATE_* flag set in 'flags', according to the versions
+// of the device-mapper targets it provides, so callers can leave out unsupported flags instead of failing with EINVAL.
+// Flags that are output only, or unknown to this package, are reported as unsupported, and so are flags added
+// after the version of libcryptsetup the package was built against, which are 0.
+// Notice targets are only listed once their kernel module is loaded, which happens on their first use:
+// flags of targets that were never used may be reported as unsupported.
+func SupportsFlag(flags int) bool {
+	return flagsSupported(flags, activationFlagRequirements)
+}
+
+// SupportsDeactivationFlag is like SupportsFlag, for the CRYPT_DEACTIVATE_* flags.
+func SupportsDeactivationFlag(flags int) bool {
+	return flagsSupported(flags, deactivationFlagRequirements)
+}
+
+// flagsSupported checks the kernel support of 'flags' against 'requirements'.
+func flagsSupported(flags int, requirements map[int]flagRequirement) bool {
+	if flags == 0 {
+		return false
+	}
+
+	var ioctlVersion dmVersion
+	var targetVersions map[string]dmVersion
+	listed := false
+
+	for bit := 1; bit != 0 && bit <= flags; bit <<= 1 {
+		if flags&bit == 0 {
+			continue
+		}
+
+		requirement, known := requirements[bit]
+		if !known {
+			return false
+		}
+		if requirement.target == "" && requirement.ioctl == (dmVersion{}) {
+			continue
+		}
+
+		if !listed {
+			var err error
+			ioctlVersion, targetVersions, err = dmTargetVersions()
+			if err != nil {
+				return false
+			}
+			listed = true
+		}
+
+		if !requirementMet(requirement, ioctlVersion, targetVersions) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// requirementMet reports whether the device-mapper versions satisfy 'requirement'.
+func requirementMet(requirement flagRequirement, ioctlVersion dmVersion, targetVersions map[string]dmVersion) bool {
+	if !ioctlVersion.atLeast(requirement.ioctl) {
+		return false
+	}
+	if requirement.target == "" {
+		return true
+	}
+
+	version, found := targetVersions[requirement.target]
+	return found && version.atLeast(requirement.version)
+}
+
+// dmTargetVersions lists the device-mapper targets provided by the kernel through the DM_LIST_VERSIONS ioctl.
+// Returns the version of the ioctl interface, and the versions of the targets by name.
+func dmTargetVersions() (dmVersion, map[string]dmVersion, error) {
+	var ioctlVersion dmVersion
+
+	control, err := os.OpenFile(dmControlPath, os.O_RDWR, 0)
+	if err != nil {
+		return ioctlVersion, nil, err
+	}
+	defer control.Close()
+
+	for size := C.size_t(16 * 1024); ; size *= 2 {
+		buffer := C.calloc(1, size)
+		if buffer == nil {
+			return ioctlVersion, nil, os.ErrInvalid
+		}
+
+		io := (*C.struct_dm_ioctl)(buffer)
+		io.version[0] = C.DM_VERSION_MAJOR
+		io.data_size = C.__u32(size)
+		io.data_start = C.__u32(C.sizeof_struct_dm_ioctl)
+
+		if result, err := C.dm_list_versions(C.int(control.Fd()), io); result < 0 {
+			C.free(buffer)
+			return ioctlVersion, nil, os.NewSyscallError("ioctl", err)
+		}
+
+		if io.flags&C.DM_BUFFER_FULL_FLAG != 0 {
+			C.free(buffer)
+			continue
+		}
+
+		ioctlVersion = dmVersion{uint32(io.version[0]), uint32(io.version[1]), uint32(io.version[2])}
+		data := C.GoBytes(buffer, C.int(io.data_size))
+		start := int(io.data_start)
+		C.free(buffer)
+
+		return ioctlVersion, parseDMTargetVersions(data, start), nil
+	}
+}
+
+// parseDMTargetVersions parses the dm_target_versions structures starting at 'start' in 'data'.
+func parseDMTargetVersions(data []byte, start int) map[string]dmVersion {
+	versions := make(map[string]dmVersion)
+
+	for offset := start; offset+C.sizeof_struct_dm_target_versions <= len(data); {
+		entry := (*C.struct_dm_target_versions)(unsafe.Pointer(&data[offset]))
+
+		nameStart := offset + C.sizeof_struct_dm_target_versions
+		nameEnd := nameStart
+		for nameEnd < len(data) && data[nameEnd] != 0 {
+			nameEnd++
+		}
+		versions[string(data[nameStart:nameEnd])] = dmVersion{uint32(entry.version[0]), uint32(entry.version[1]), uint32(entry.version[2])}
+
+		if entry.next == 0 {
+			break
+		}
+		offset += int(entry.next)
+	}
+
+	return versions
+}
@@ -0,0 +1,48 @@
+package cryptsetup
+
+import "testing"
+
+func Test_SupportsFlag_Without_Kernel_Requirement(test *testing.T) {
+	if !SupportsFlag(CRYPT_ACTIVATE_READONLY | CRYPT_ACTIVATE_SHARED) {
+		test.Error("Flags only handled by libcryptsetup should always be supported.")
+	}
+	if SupportsFlag(CRYPT_ACTIVATE_CORRUPTED) {
+		test.Error("Output only flags should not be supported.")
+	}
+	if !SupportsDeactivationFlag(CRYPT_DEACTIVATE_FORCE) {
+		test.Error("CRYPT_DEACTIVATE_FORCE should always be supported.")
+	}
+	if SupportsFlag(0) {
+		test.Error("Flags undefined by libcryptsetup, which are 0, should not be supported.")
+	}
+}
+
+func Test_requirementMet(test *testing.T) {
+	ioctlVersion := dmVersion{4, 45, 0}
+	targetVersions := map[string]dmVersion{"crypt": {1, 21, 0}, "verity": {1, 8, 0}}
+
+	for _, testCase := range []struct {
+		flag     int
+		expected bool
+	}{
+		{CRYPT_ACTIVATE_ALLOW_DISCARDS, true},
+		{CRYPT_ACTIVATE_NO_READ_WORKQUEUE, false},
+		{CRYPT_ACTIVATE_PANIC_ON_CORRUPTION, true},
+		{CRYPT_ACTIVATE_RECALCULATE, false},
+	} {
+		if testCase.flag == 0 {
+			// not defined by the libcryptsetup the package was built against
+			continue
+		}
+		if met := requirementMet(activationFlagRequirements[testCase.flag], ioctlVersion, targetVersions); met != testCase.expected {
+			test.Errorf("Expected support of flag %#x to be %v, but got %v", testCase.flag, testCase.expected, met)
+		}
+	}
+
+	if !requirementMet(deactivationFlagRequirements[CRYPT_DEACTIVATE_DEFERRED], ioctlVersion, targetVersions) {
+		test.Error("Deferred deactivation should have been supported.")
+	}
+	if requirementMet(deactivationFlagRequirements[CRYPT_DEACTIVATE_DEFERRED], dmVersion{4, 26, 0}, targetVersions) {
+		test.Error("Deferred deactivation should not have been supported.")
+	}
+}
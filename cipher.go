@@ -0,0 +1,162 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// cipherKeySizes maps the ciphers commonly used with dm-crypt to their valid key sizes, in bytes.
+var cipherKeySizes = map[string][]int{
+	"aes":      {16, 24, 32},
+	"serpent":  {16, 24, 32},
+	"twofish":  {16, 24, 32},
+	"camellia": {16, 24, 32},
+	"cast6":    {16, 24, 32},
+	"sm4":      {16},
+}
+
+// chainModeKeyCounts maps the chaining modes supported by dm-crypt to the number of cipher keys they consume.
+var chainModeKeyCounts = map[string]int{
+	"adiantum": 1,
+	"cbc":      1,
+	"ctr":      1,
+	"ecb":      1,
+	"pcbc":     1,
+	"lrw":      2,
+	"xts":      2,
+}
+
+// CipherKeySizes returns the valid volume key sizes, in bytes, for 'cipher' used in 'cipherMode' (e.g. "aes" and "xts-plain64").
+// Sizes are returned in ascending order and may be used as GenericParams.VolumeKeySize.
+// Returns an error if the cipher or its chaining mode is not known.
+func CipherKeySizes(cipher string, cipherMode string) ([]int, error) {
+	keySizes, found := cipherKeySizes[strings.ToLower(cipher)]
+	if !found {
+		return nil, fmt.Errorf("unknown cipher '%s'", cipher)
+	}
+
+	chainMode := strings.ToLower(strings.SplitN(cipherMode, "-", 2)[0])
+	keyCount, found := chainModeKeyCounts[chainMode]
+	if !found {
+		return nil, fmt.Errorf("unknown chaining mode '%s'", chainMode)
+	}
+
+	result := make([]int, len(keySizes))
+	for index, keySize := range keySizes {
+		result[index] = keySize * keyCount
+	}
+
+	return result, nil
+}
+
+// procCryptoPath lists the algorithms available in the kernel crypto API.
+var procCryptoPath = "/proc/crypto"
+
+// ivGenerators are the IV generators supported by dm-crypt, and whether they require an option, such as essiv's hash.
+var ivGenerators = map[string]bool{
+	"plain":     false,
+	"plain64":   false,
+	"plain64be": false,
+	"essiv":     true,
+	"benbi":     false,
+	"null":      false,
+	"lmk":       false,
+	"tcw":       false,
+	"random":    false,
+	"eboiv":     false,
+	"elephant":  true,
+}
+
+// ValidateCipherSpec checks the syntax of a cipher specification: that 'cipherMode' names a known chaining mode and IV generator
+// (e.g. "xts-plain64" or "cbc-essiv:sha256"). The ecb chaining mode may omit the IV generator, as in "cipher_null" and "ecb".
+// Ciphers given through the kernel crypto API, such as "capi:xts(aes)", are followed by the IV generator alone.
+// Whether the algorithms are available in the kernel is not checked: Format checks it for PLAIN devices, and so does Preflight.
+// Returns nil if the specification is valid, or an error describing the problem otherwise.
+func ValidateCipherSpec(cipher string, cipherMode string) error {
+	ivGenerator := cipherMode
+	if !strings.HasPrefix(strings.ToLower(cipher), "capi:") {
+		modeParts := strings.SplitN(cipherMode, "-", 2)
+		chainMode := strings.ToLower(modeParts[0])
+		if _, found := chainModeKeyCounts[chainMode]; !found {
+			return fmt.Errorf("unknown chaining mode '%s'", modeParts[0])
+		}
+		if len(modeParts) != 2 || modeParts[1] == "" {
+			if chainMode == "ecb" {
+				return nil
+			}
+			return fmt.Errorf("cipher mode '%s' has no IV generator", cipherMode)
+		}
+		ivGenerator = modeParts[1]
+	}
+
+	ivParts := strings.SplitN(ivGenerator, ":", 2)
+	requiresOption, found := ivGenerators[ivParts[0]]
+	if !found {
+		return fmt.Errorf("unknown IV generator '%s'", ivParts[0])
+	}
+	if requiresOption != (len(ivParts) == 2 && ivParts[1] != "") {
+		if requiresOption {
+			return fmt.Errorf("IV generator '%s' requires an option, such as '%s:sha256'", ivParts[0], ivParts[0])
+		}
+		return fmt.Errorf("IV generator '%s' takes no option", ivParts[0])
+	}
+
+	return nil
+}
+
+// WithCipherSyntaxOnly makes Format and DryRun.Format only check the syntax of the cipher specification of PLAIN devices,
+// instead of also requiring its algorithms to be listed in /proc/crypto. The kernel loads most algorithm modules on first use,
+// so it is needed for algorithms that haven't been used since boot.
+func WithCipherSyntaxOnly() Option {
+	return func(options *options) {
+		options.cipherSyntaxOnly = true
+	}
+}
+
+// checkCipherAvailable checks that the algorithms of a valid cipher specification, such as the cipher and essiv's hash,
+// are listed in /proc/crypto. Ciphers given through the kernel crypto API are not checked.
+// Returns nil if they are all listed, or an error naming the first missing one otherwise.
+func checkCipherAvailable(cipher string, cipherMode string) error {
+	if strings.HasPrefix(strings.ToLower(cipher), "capi:") {
+		return nil
+	}
+
+	algorithms, err := kernelCryptoAlgorithms()
+	if err != nil {
+		return err
+	}
+
+	// Ciphers combining several algorithms, such as adiantum's "xchacha12,aes", list them separated by commas.
+	for _, name := range strings.Split(strings.ToLower(cipher), ",") {
+		if !algorithms[name] {
+			return fmt.Errorf("cipher '%s' is not available in the kernel", name)
+		}
+	}
+
+	if modeParts := strings.SplitN(cipherMode, "-", 2); len(modeParts) == 2 {
+		if ivParts := strings.SplitN(modeParts[1], ":", 2); ivParts[0] == "essiv" && len(ivParts) == 2 && !algorithms[strings.ToLower(ivParts[1])] {
+			return fmt.Errorf("hash '%s' is not available in the kernel", ivParts[1])
+		}
+	}
+
+	return nil
+}
+
+// kernelCryptoAlgorithms returns the names of the algorithms listed in /proc/crypto.
+func kernelCryptoAlgorithms() (map[string]bool, error) {
+	content, err := ioutil.ReadFile(procCryptoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithms := make(map[string]bool)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) == 2 && strings.TrimSpace(fields[0]) == "name" {
+			algorithms[strings.TrimSpace(fields[1])] = true
+		}
+	}
+
+	return algorithms, nil
+}
@@ -0,0 +1,93 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_CipherKeySizes(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	keySizes, err := CipherKeySizes("aes", "xts-plain64")
+	testWrapper.AssertNoError(err)
+	if !reflect.DeepEqual(keySizes, []int{32, 48, 64}) {
+		test.Errorf("Unexpected key sizes for aes-xts-plain64: %v", keySizes)
+	}
+
+	keySizes, err = CipherKeySizes("aes", "cbc-essiv:sha256")
+	testWrapper.AssertNoError(err)
+	if !reflect.DeepEqual(keySizes, []int{16, 24, 32}) {
+		test.Errorf("Unexpected key sizes for aes-cbc-essiv:sha256: %v", keySizes)
+	}
+}
+
+func Test_CipherKeySizes_Are_Accepted_By_Format(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	keySizes, err := CipherKeySizes("aes", "xts-plain64")
+	testWrapper.AssertNoError(err)
+
+	for _, keySize := range keySizes {
+		device, err := Init(DevicePath)
+		testWrapper.AssertNoError(err)
+
+		err = device.Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: keySize})
+		testWrapper.AssertNoError(err)
+
+		device.Free()
+	}
+}
+
+func Test_CipherKeySizes_Fails_For_Unknown_Cipher(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := CipherKeySizes("rot13", "xts-plain64")
+	testWrapper.AssertError(err)
+
+	_, err = CipherKeySizes("aes", "unknown-plain64")
+	testWrapper.AssertError(err)
+}
+
+func Test_ValidateCipherSpec(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	testWrapper.AssertNoError(ValidateCipherSpec("aes", "xts-plain64"))
+	testWrapper.AssertNoError(ValidateCipherSpec("aes", "cbc-essiv:sha256"))
+	testWrapper.AssertNoError(ValidateCipherSpec("aes", "cbc-benbi"))
+	testWrapper.AssertNoError(ValidateCipherSpec("aes", "ecb"))
+	testWrapper.AssertNoError(ValidateCipherSpec("cipher_null", "ecb"))
+	testWrapper.AssertNoError(ValidateCipherSpec("xchacha12,aes", "adiantum-plain64"))
+	testWrapper.AssertNoError(ValidateCipherSpec("capi:xts(aes)", "plain64"))
+	// Algorithms are not required to be loaded yet.
+	testWrapper.AssertNoError(ValidateCipherSpec("nonExistingCipher", "xts-plain64"))
+	testWrapper.AssertNoError(ValidateCipherSpec("aes", "cbc-essiv:nonExistingHash"))
+
+	testWrapper.AssertError(ValidateCipherSpec("aes", "xts"))
+	testWrapper.AssertError(ValidateCipherSpec("aes", "xts-plain46"))
+	testWrapper.AssertError(ValidateCipherSpec("aes", "cbc-essiv"))
+	testWrapper.AssertError(ValidateCipherSpec("aes", "xts-plain64:sha256"))
+	testWrapper.AssertError(ValidateCipherSpec("capi:xts(aes)", "plain46"))
+}
+
+func Test_checkCipherAvailable(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	directory, err := ioutil.TempDir("", "cipher")
+	testWrapper.AssertNoError(err)
+	defer os.RemoveAll(directory)
+
+	previousProcCryptoPath := procCryptoPath
+	procCryptoPath = filepath.Join(directory, "crypto")
+	defer func() { procCryptoPath = previousProcCryptoPath }()
+	testWrapper.AssertNoError(ioutil.WriteFile(procCryptoPath, []byte("name         : aes\n\nname         : sha256\n\nname         : xchacha12\n"), 0644))
+
+	testWrapper.AssertNoError(checkCipherAvailable("aes", "cbc-essiv:sha256"))
+	testWrapper.AssertNoError(checkCipherAvailable("xchacha12,aes", "adiantum-plain64"))
+	testWrapper.AssertNoError(checkCipherAvailable("capi:xts(serpent)", "plain64"))
+
+	testWrapper.AssertError(checkCipherAvailable("serpent", "xts-plain64"))
+	testWrapper.AssertError(checkCipherAvailable("aes", "cbc-essiv:sha512"))
+}
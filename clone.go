@@ -0,0 +1,108 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// ErrVolumeKeyInKeyring is matched, through errors.Is, by the *VolumeKeyInKeyringError returned by Clone
+// when the volume key of the mapping to clone is held in the kernel keyring.
+var ErrVolumeKeyInKeyring = errors.New("volume key is held in the kernel keyring")
+
+// VolumeKeyInKeyringError is returned by Clone when the dm-crypt table of the mapping to clone refers to a kernel keyring key
+// instead of holding the volume key, as LUKS2 mappings do by default. libcryptsetup drops the key from its keyring once the
+// mapping is activated, so the key can neither be read back nor reused, and the mapping must be activated again with a credential.
+type VolumeKeyInKeyringError struct {
+	name           string
+	keyDescription string
+}
+
+func (e *VolumeKeyInKeyringError) Error() string {
+	return fmt.Sprintf("cryptsetup: volume key of mapping '%s' is held in the kernel keyring as '%s', and cannot be read back", e.name, e.keyDescription)
+}
+
+// Is reports whether 'target' is ErrVolumeKeyInKeyring.
+func (e *VolumeKeyInKeyringError) Is(target error) bool {
+	return target == ErrVolumeKeyInKeyring
+}
+
+// KeyDescription returns the description of the kernel keyring key the mapping refers to, such as "cryptsetup:<UUID>-d0".
+func (e *VolumeKeyInKeyringError) KeyDescription() string {
+	return e.keyDescription
+}
+
+// Clone activates a second mapping named 'newName' over the same data and volume key as the active mapping named 'name',
+// so a read-only snapshot for backup, activated with CRYPT_ACTIVATE_READONLY, can run alongside the live mapping.
+// The device must have been loaded, and the volume key is read back from the kernel's dm-crypt table of 'name'.
+// CRYPT_ACTIVATE_SHARED is always added to 'flags', as both mappings use the same device.
+// Returns nil on success, a *VolumeKeyInKeyringError if the mapping holds its key in the kernel keyring, as LUKS2 mappings do
+// unless activated after SetVolumeKeyKeyring(false), or another error otherwise.
+// C equivalent: crypt_activate_by_volume_key
+func (device *Device) Clone(name string, newName string, flags int) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	var cVolumeKey *C.char = nil
+	var volumeKeySize int
+
+	err := dmTableStatus(name, func(targets []DMTarget) error {
+		if len(targets) != 1 || targets[0].Type != "crypt" {
+			return fmt.Errorf("mapping '%s' is not made of a single dm-crypt target", name)
+		}
+
+		keyStart, keyEnd := dmCryptKey(targets[0].rawParams)
+		if keyStart < 0 {
+			return fmt.Errorf("invalid dm-crypt parameters for mapping '%s'", name)
+		}
+
+		// The key points into the ioctl buffer, wiped by dmTableStatus.
+		key := targets[0].rawParams[keyStart:keyEnd]
+		if key[0] == ':' {
+			// ":<size>:<type>:<description>"
+			fields := strings.SplitN(string(key), ":", 4)
+			return &VolumeKeyInKeyringError{name: name, keyDescription: fields[len(fields)-1]}
+		}
+		if key[0] == '-' {
+			return fmt.Errorf("mapping '%s' has no volume key", name)
+		}
+
+		volumeKeySize = hex.DecodedLen(len(key))
+		memory := safeAlloc(volumeKeySize)
+		if memory == nil {
+			return &Error{functionName: "crypt_safe_alloc", code: int(ENOMEM)}
+		}
+		cVolumeKey = (*C.char)(memory)
+
+		buffer := (*[1 << 30]byte)(memory)[:volumeKeySize:volumeKeySize]
+		if _, err := hex.Decode(buffer, key); err != nil {
+			return fmt.Errorf("invalid dm-crypt volume key for mapping '%s'", name)
+		}
+		return nil
+	})
+	if cVolumeKey != nil {
+		defer safeFree(cVolumeKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	cNewName := C.CString(newName)
+	defer C.free(unsafe.Pointer(cNewName))
+
+	defer device.trackLoopDevices()()
+	result := C.crypt_activate_by_volume_key(device.cryptDevice, cNewName, cVolumeKey, C.size_t(volumeKeySize), C.uint32_t(flags|CRYPT_ACTIVATE_SHARED))
+	if result < 0 {
+		return device.newError("crypt_activate_by_volume_key", int(result), "clone "+name+" as "+newName)
+	}
+
+	device.emitActivated(newName, -1)
+	return nil
+}
@@ -0,0 +1,64 @@
+package cryptsetup
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_Device_Clone_Fails_If_Mapping_Is_Not_Active(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.Clone("nonExistingDeviceName", DeviceName, CRYPT_ACTIVATE_READONLY)
+	testWrapper.AssertError(err)
+}
+
+func Test_Device_SetVolumeKeyKeyring(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	testWrapper.AssertNoError(device.SetVolumeKeyKeyring(false))
+	testWrapper.AssertNoError(device.SetVolumeKeyKeyring(true))
+}
+
+func Test_Device_Clone_Fails_If_Volume_Key_Is_In_Keyring(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", PassKey)
+	testWrapper.AssertNoError(err)
+
+	err = device.ActivateByPassphrase(DeviceName, 0, PassKey, CRYPT_ACTIVATE_READONLY)
+	testWrapper.AssertNoError(err)
+	defer device.Deactivate(DeviceName)
+
+	targets, err := device.DMTable(DeviceName)
+	testWrapper.AssertNoError(err)
+	if targets[0].Crypt.KeyType == "hex" {
+		test.Skip("The kernel keyring is not used for volume keys in this environment")
+	}
+
+	err = device.Clone(DeviceName, DeviceName+"Clone", CRYPT_ACTIVATE_READONLY)
+	if !errors.Is(err, ErrVolumeKeyInKeyring) {
+		test.Fatalf("Cloning a mapping whose volume key is in the keyring should have failed with ErrVolumeKeyInKeyring, but got: %v", err)
+	}
+	if keyDescription := err.(*VolumeKeyInKeyringError).KeyDescription(); keyDescription != targets[0].Crypt.KeyDescription {
+		test.Errorf("The key description should have been '%s', but was '%s'", targets[0].Crypt.KeyDescription, keyDescription)
+	}
+}
@@ -0,0 +1,323 @@
+// Command gocryptsetup re-implements a subset of the cryptsetup command line tool purely through the cryptsetup package,
+// serving both as an integration test of the binding and as a reference for its users.
+//
+// Usage:
+//
+//	gocryptsetup format [-type luks2] [-cipher aes-xts-plain64] [-key-size 512] [-iter-time ms] [-key-file path] <device>
+//	gocryptsetup open [-readonly] [-key-file path] <device> <name>
+//	gocryptsetup close <name>
+//	gocryptsetup status [-json] <name>
+//	gocryptsetup dump [-json] <device>
+//
+// Passphrases are read from the key file if one is given, from the terminal if the standard input is one,
+// or from the first line of the standard input otherwise.
+// The -json flag prints the status of a mapping, or the metadata of a LUKS2 header, as JSON for inventory tooling.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"cryptsetup"
+)
+
+const usage = `usage: gocryptsetup <command> [options] <arguments>
+
+commands:
+  format <device>       formats a LUKS device and adds a passphrase to it
+  open <device> <name>  activates a LUKS device as /dev/mapper/<name>
+  close <name>          deactivates a mapping
+  status <name>         shows the status of a mapping
+  dump <device>         dumps the header of a LUKS device
+`
+
+func main() {
+	// Detaches the loop devices attached to image files that are no longer mapped, even if interrupted.
+	stopJanitor := cryptsetup.StartLoopDeviceJanitor()
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stopJanitor()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gocryptsetup: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command line 'args', without the program name.
+func run(args []string, stdin *os.File, stdout io.Writer, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errors.New("no command given")
+	}
+
+	cryptsetup.SetLogCallback(func(level int, message string) {
+		switch level {
+		case cryptsetup.CRYPT_LOG_ERROR:
+			fmt.Fprint(stderr, message)
+		case cryptsetup.CRYPT_LOG_NORMAL:
+			fmt.Fprint(stdout, message)
+		}
+	})
+	defer cryptsetup.SetLogCallback(nil)
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "format":
+		return runFormat(args, stdin, stderr)
+	case "open":
+		return runOpen(args, stdin, stderr)
+	case "close":
+		return runClose(args, stderr)
+	case "status":
+		return runStatus(args, stdout, stderr)
+	case "dump":
+		return runDump(args, stdout, stderr)
+	default:
+		fmt.Fprint(stderr, usage)
+		return fmt.Errorf("unknown command '%s'", command)
+	}
+}
+
+// newFlagSet returns the flag set of 'command', reporting errors to 'stderr'.
+func newFlagSet(command string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+// parseArguments parses 'args' with 'flags', and checks that exactly 'count' positional arguments are left.
+func parseArguments(flags *flag.FlagSet, args []string, count int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if flags.NArg() != count {
+		return nil, fmt.Errorf("%s takes %d arguments, but %d were given", flags.Name(), count, flags.NArg())
+	}
+
+	return flags.Args(), nil
+}
+
+func runFormat(args []string, stdin *os.File, stderr io.Writer) error {
+	flags := newFlagSet("format", stderr)
+	deviceType := flags.String("type", "luks2", "device type: luks1 or luks2")
+	cipher := flags.String("cipher", "aes-xts-plain64", "cipher specification")
+	keySize := flags.Int("key-size", 512, "volume key size, in bits")
+	iterationTime := flags.Uint64("iter-time", 0, "PBKDF iteration time, in milliseconds")
+	keyFile := flags.String("key-file", "", "read the passphrase from a file")
+
+	arguments, err := parseArguments(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	var luksType cryptsetup.DeviceType
+	switch *deviceType {
+	case "luks1":
+		luksType = cryptsetup.LUKS1{Hash: "sha256"}
+	case "luks2":
+		luksType = cryptsetup.LUKS2{SectorSize: 512}
+	default:
+		return fmt.Errorf("unsupported device type '%s'", *deviceType)
+	}
+
+	cipherParts := strings.SplitN(*cipher, "-", 2)
+	if len(cipherParts) != 2 {
+		return fmt.Errorf("invalid cipher specification '%s'", *cipher)
+	}
+
+	passphrase, err := readPassphrase(*keyFile, stdin, fmt.Sprintf("Enter passphrase for %s: ", arguments[0]), true)
+	if err != nil {
+		return err
+	}
+	defer cryptsetup.WipeBytes(passphrase)
+
+	device, err := cryptsetup.Init(arguments[0])
+	if err != nil {
+		return err
+	}
+	defer device.Free()
+
+	genericParams := cryptsetup.GenericParams{Cipher: cipherParts[0], CipherMode: cipherParts[1], VolumeKeySize: *keySize / 8}
+	if err = device.Format(luksType, genericParams); err != nil {
+		return err
+	}
+
+	if *iterationTime > 0 {
+		device.SetIterationTime(*iterationTime)
+	}
+
+	_, err = device.KeyslotAddByVolumeKeyBytes(cryptsetup.CRYPT_ANY_SLOT, nil, passphrase)
+	return err
+}
+
+func runOpen(args []string, stdin *os.File, stderr io.Writer) error {
+	flags := newFlagSet("open", stderr)
+	readOnly := flags.Bool("readonly", false, "activate the mapping read-only")
+	keyFile := flags.String("key-file", "", "read the passphrase from a file")
+
+	arguments, err := parseArguments(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	device, err := cryptsetup.Init(arguments[0])
+	if err != nil {
+		return err
+	}
+	defer device.Free()
+
+	if err = device.Load(); err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase(*keyFile, stdin, fmt.Sprintf("Enter passphrase for %s: ", arguments[0]), false)
+	if err != nil {
+		return err
+	}
+	defer cryptsetup.WipeBytes(passphrase)
+
+	activationFlags := 0
+	if *readOnly {
+		activationFlags |= cryptsetup.CRYPT_ACTIVATE_READONLY
+	}
+
+	return device.ActivateByPassphraseBytes(arguments[1], cryptsetup.CRYPT_ANY_SLOT, passphrase, activationFlags)
+}
+
+func runClose(args []string, stderr io.Writer) error {
+	arguments, err := parseArguments(newFlagSet("close", stderr), args, 1)
+	if err != nil {
+		return err
+	}
+
+	return cryptsetup.Deactivate(arguments[0])
+}
+
+func runStatus(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("status", stderr)
+	jsonOutput := flags.Bool("json", false, "print the status as JSON")
+
+	arguments, err := parseArguments(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	name := arguments[0]
+
+	device, err := cryptsetup.InitByName(name)
+	if err != nil {
+		if *jsonOutput {
+			return printJSON(stdout, cryptsetup.ActiveDevice{Name: name, Status: "inactive"})
+		}
+		fmt.Fprintf(stdout, "%s is inactive.\n", cryptsetup.MapperNodePath(name))
+		return err
+	}
+	defer device.Free()
+
+	if *jsonOutput {
+		active, err := device.Status(name)
+		if err != nil {
+			return err
+		}
+		return printJSON(stdout, active)
+	}
+
+	targets, err := device.DMTable(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s is active.\n", cryptsetup.MapperNodePath(name))
+	fmt.Fprintf(stdout, "  type:    %s\n", device.Type())
+	for _, target := range targets {
+		if target.Crypt == nil {
+			continue
+		}
+		fmt.Fprintf(stdout, "  cipher:  %s\n", target.Crypt.Cipher)
+		fmt.Fprintf(stdout, "  keysize: %d bits\n", target.Crypt.KeySize*8)
+		if target.Crypt.KeyType != "hex" {
+			fmt.Fprintf(stdout, "  key location: keyring\n")
+		}
+		fmt.Fprintf(stdout, "  device:  %s\n", device.DevicePath())
+		if detached, err := device.HeaderIsDetached(); err == nil && detached {
+			fmt.Fprintf(stdout, "  header:  %s\n", device.MetadataDevicePath())
+		}
+		fmt.Fprintf(stdout, "  offset:  %d sectors\n", target.Crypt.Offset)
+		fmt.Fprintf(stdout, "  size:    %d sectors\n", target.Length)
+		if len(target.Crypt.Options) > 0 {
+			fmt.Fprintf(stdout, "  flags:   %s\n", strings.Join(target.Crypt.Options, " "))
+		}
+	}
+
+	return nil
+}
+
+func runDump(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("dump", stderr)
+	jsonOutput := flags.Bool("json", false, "print the LUKS2 JSON metadata")
+
+	arguments, err := parseArguments(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	device, err := cryptsetup.Init(arguments[0])
+	if err != nil {
+		return err
+	}
+	defer device.Free()
+
+	if err = device.Load(); err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		if device.Type() != cryptsetup.TypeLUKS2 {
+			return fmt.Errorf("-json requires a LUKS2 device, but '%s' is %s", arguments[0], device.Type())
+		}
+		dump, err := device.DumpLUKS2()
+		if err != nil {
+			return err
+		}
+		return printJSON(stdout, dump)
+	}
+
+	if result := device.Dump(); result < 0 {
+		return fmt.Errorf("failed to dump the header of '%s' (code %d)", arguments[0], result)
+	}
+
+	return nil
+}
+
+// printJSON writes 'value' to 'stdout' as indented JSON.
+func printJSON(stdout io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// readPassphrase reads a passphrase from 'keyFile' if it is set, from the terminal if 'stdin' is one, or from 'stdin' otherwise.
+// 'confirm' asks for the passphrase twice when reading from the terminal.
+func readPassphrase(keyFile string, stdin *os.File, message string, confirm bool) ([]byte, error) {
+	if keyFile != "" {
+		return ioutil.ReadFile(keyFile)
+	}
+
+	if info, err := stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		prompter, err := cryptsetup.NewTerminalPrompter()
+		if err != nil {
+			return nil, err
+		}
+		defer prompter.Close()
+
+		return prompter.Prompt(message, confirm)
+	}
+
+	return cryptsetup.FilePrompter{File: stdin}.Prompt(message, confirm)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const devicePath string = "testDevice"
+
+func Test_Run_Format_Dump(test *testing.T) {
+	const keyFile = "testKeyFile"
+	defer os.Remove(keyFile)
+	if err := ioutil.WriteFile(keyFile, []byte("testPassphrase"), 0600); err != nil {
+		test.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"format", "-type", "luks1", "-key-file", keyFile, devicePath}, os.Stdin, &stdout, &stderr)
+	if err != nil {
+		test.Fatalf("format failed: %v: %s", err, stderr.String())
+	}
+
+	err = run([]string{"dump", devicePath}, os.Stdin, &stdout, &stderr)
+	if err != nil {
+		test.Fatalf("dump failed: %v: %s", err, stderr.String())
+	}
+
+	if !strings.Contains(stdout.String(), "LUKS header information") || !strings.Contains(stdout.String(), "Key Slot 0: ENABLED") {
+		test.Errorf("Unexpected dump: %s", stdout.String())
+	}
+}
+
+func Test_Run_Format_Dump_JSON(test *testing.T) {
+	const keyFile = "testKeyFile"
+	defer os.Remove(keyFile)
+	if err := ioutil.WriteFile(keyFile, []byte("testPassphrase"), 0600); err != nil {
+		test.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"format", "-type", "luks2", "-iter-time", "1", "-key-file", keyFile, devicePath}, os.Stdin, &stdout, &stderr)
+	if err != nil {
+		test.Fatalf("format failed: %v: %s", err, stderr.String())
+	}
+
+	stdout.Reset()
+	err = run([]string{"dump", "-json", devicePath}, os.Stdin, &stdout, &stderr)
+	if err != nil {
+		test.Fatalf("dump failed: %v: %s", err, stderr.String())
+	}
+
+	var metadata struct {
+		Keyslots map[string]struct {
+			Type string `json:"type"`
+		} `json:"keyslots"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &metadata); err != nil {
+		test.Fatalf("dump did not print JSON: %v: %s", err, stdout.String())
+	}
+	if metadata.Keyslots["0"].Type != "luks2" {
+		test.Errorf("Unexpected dump: %s", stdout.String())
+	}
+}
+
+func Test_Run_Format_Reads_Passphrase_From_Stdin(test *testing.T) {
+	stdin, err := ioutil.TempFile("", "stdin")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.Remove(stdin.Name())
+	defer stdin.Close()
+
+	if _, err = stdin.WriteString("testPassphrase\n"); err != nil {
+		test.Fatal(err)
+	}
+	if _, err = stdin.Seek(0, 0); err != nil {
+		test.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = run([]string{"format", "-type", "luks1", devicePath}, stdin, &stdout, &stderr)
+	if err != nil {
+		test.Fatalf("format failed: %v: %s", err, stderr.String())
+	}
+}
+
+func Test_Run_Fails_For_Invalid_Command_Lines(test *testing.T) {
+	commandLines := [][]string{
+		{},
+		{"unknownCommand"},
+		{"format"},
+		{"format", "-type", "unknownType", devicePath},
+		{"open", devicePath},
+		{"status", "nonExistingDeviceName"},
+	}
+
+	for _, commandLine := range commandLines {
+		var stdout, stderr bytes.Buffer
+		if err := run(commandLine, os.Stdin, &stdout, &stderr); err == nil {
+			test.Errorf("Command line %q should have failed.", commandLine)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	exec.Command("/bin/dd", "if=/dev/zero", fmt.Sprintf("of=%s", devicePath), "bs=64M", "count=1").Run()
+	result := m.Run()
+	exec.Command("/bin/rm", "-f", devicePath).Run()
+	os.Exit(result)
+}
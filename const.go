@@ -1,13 +1,50 @@
 package cryptsetup
 
-// #cgo pkg-config: libcryptsetup
-// #include <libcryptsetup.h>
+/*
+#cgo pkg-config: libcryptsetup
+#include <libcryptsetup.h>
+
+// Flags added after libcryptsetup 2.0 are 0 on older versions, and activation flags are reported unsupported by SupportsFlag.
+#ifndef CRYPT_ACTIVATE_IV_LARGE_SECTORS
+#define CRYPT_ACTIVATE_IV_LARGE_SECTORS 0
+#endif
+#ifndef CRYPT_ACTIVATE_NO_JOURNAL_BITMAP
+#define CRYPT_ACTIVATE_NO_JOURNAL_BITMAP 0
+#endif
+#ifndef CRYPT_ACTIVATE_PANIC_ON_CORRUPTION
+#define CRYPT_ACTIVATE_PANIC_ON_CORRUPTION 0
+#endif
+#ifndef CRYPT_ACTIVATE_RECALCULATE
+#define CRYPT_ACTIVATE_RECALCULATE 0
+#endif
+#ifndef CRYPT_ACTIVATE_RECALCULATE_RESET
+#define CRYPT_ACTIVATE_RECALCULATE_RESET 0
+#endif
+#ifndef CRYPT_ACTIVATE_NO_READ_WORKQUEUE
+#define CRYPT_ACTIVATE_NO_READ_WORKQUEUE 0
+#endif
+#ifndef CRYPT_ACTIVATE_NO_WRITE_WORKQUEUE
+#define CRYPT_ACTIVATE_NO_WRITE_WORKQUEUE 0
+#endif
+#ifndef CRYPT_VERITY_ROOT_HASH_SIGNATURE
+#define CRYPT_VERITY_ROOT_HASH_SIGNATURE 0
+#endif
+#ifndef CRYPT_REQUIREMENT_ONLINE_REENCRYPT
+#define CRYPT_REQUIREMENT_ONLINE_REENCRYPT 0
+#endif
+#ifndef CRYPT_DEACTIVATE_DEFERRED_CANCEL
+#define CRYPT_DEACTIVATE_DEFERRED_CANCEL 0
+#endif
+*/
 import "C"
 
 const (
 	/** enable discards aka trim */
 	CRYPT_ACTIVATE_ALLOW_DISCARDS = C.CRYPT_ACTIVATE_ALLOW_DISCARDS
 
+	/** dm-verity: check_at_most_once - check data blocks only the first time */
+	CRYPT_ACTIVATE_CHECK_AT_MOST_ONCE = C.CRYPT_ACTIVATE_CHECK_AT_MOST_ONCE
+
 	/** corruption detected (verity), output only */
 	CRYPT_ACTIVATE_CORRUPTED = C.CRYPT_ACTIVATE_CORRUPTED
 
@@ -20,21 +57,42 @@ const (
 	/** dm-verity: ignore_zero_blocks - do not verify zero blocks */
 	CRYPT_ACTIVATE_IGNORE_ZERO_BLOCKS = C.CRYPT_ACTIVATE_IGNORE_ZERO_BLOCKS
 
+	/** dm-crypt: calculate IV from the sector size of the device instead of 512 byte sectors */
+	CRYPT_ACTIVATE_IV_LARGE_SECTORS = C.CRYPT_ACTIVATE_IV_LARGE_SECTORS
+
 	/** key loaded in kernel keyring instead directly in dm-crypt */
 	CRYPT_ACTIVATE_KEYRING_KEY = C.CRYPT_ACTIVATE_KEYRING_KEY
 
 	/** dm-integrity: direct writes, do not use journal */
 	CRYPT_ACTIVATE_NO_JOURNAL = C.CRYPT_ACTIVATE_NO_JOURNAL
 
+	/** dm-integrity: use bitmap instead of journal */
+	CRYPT_ACTIVATE_NO_JOURNAL_BITMAP = C.CRYPT_ACTIVATE_NO_JOURNAL_BITMAP
+
+	/** dm-crypt: bypass internal workqueue and process read requests synchronously */
+	CRYPT_ACTIVATE_NO_READ_WORKQUEUE = C.CRYPT_ACTIVATE_NO_READ_WORKQUEUE
+
 	/** only reported for device without uuid */
 	CRYPT_ACTIVATE_NO_UUID = C.CRYPT_ACTIVATE_NO_UUID
 
+	/** dm-crypt: bypass internal workqueue and process write requests synchronously */
+	CRYPT_ACTIVATE_NO_WRITE_WORKQUEUE = C.CRYPT_ACTIVATE_NO_WRITE_WORKQUEUE
+
+	/** dm-verity: panic_on_corruption flag - panic kernel on corruption */
+	CRYPT_ACTIVATE_PANIC_ON_CORRUPTION = C.CRYPT_ACTIVATE_PANIC_ON_CORRUPTION
+
 	/** skip global udev rules in activation ("private device"), input only */
 	CRYPT_ACTIVATE_PRIVATE = C.CRYPT_ACTIVATE_PRIVATE
 
 	/** device is read only */
 	CRYPT_ACTIVATE_READONLY = C.CRYPT_ACTIVATE_READONLY
 
+	/** dm-integrity: recalculate tags on activation */
+	CRYPT_ACTIVATE_RECALCULATE = C.CRYPT_ACTIVATE_RECALCULATE
+
+	/** dm-integrity: reset automatic recalculation position */
+	CRYPT_ACTIVATE_RECALCULATE_RESET = C.CRYPT_ACTIVATE_RECALCULATE_RESET
+
 	/** dm-integrity: recovery mode - no journal, no integrity checks */
 	CRYPT_ACTIVATE_RECOVERY = C.CRYPT_ACTIVATE_RECOVERY
 
@@ -59,6 +117,9 @@ const (
 	/** lazy deactivation - remove once last user releases it */
 	CRYPT_DEACTIVATE_DEFERRED = C.CRYPT_DEACTIVATE_DEFERRED
 
+	/** cancel a deferred deactivation */
+	CRYPT_DEACTIVATE_DEFERRED_CANCEL = C.CRYPT_DEACTIVATE_DEFERRED_CANCEL
+
 	/** force deactivation - if the device is busy, it is replaced by error device */
 	CRYPT_DEACTIVATE_FORCE = C.CRYPT_DEACTIVATE_FORCE
 
@@ -68,6 +129,12 @@ const (
 	/** debug none */
 	CRYPT_DEBUG_NONE = C.CRYPT_DEBUG_NONE
 
+	/** activation flags, @see crypt_persistent_flags_get */
+	CRYPT_FLAGS_ACTIVATION = C.CRYPT_FLAGS_ACTIVATION
+
+	/** requirements flags, @see crypt_persistent_flags_get */
+	CRYPT_FLAGS_REQUIREMENTS = C.CRYPT_FLAGS_REQUIREMENTS
+
 	/** integrity dm-integrity device */
 	CRYPT_INTEGRITY = C.CRYPT_INTEGRITY
 
@@ -116,6 +183,9 @@ const (
 	/** unfinished offline reencryption */
 	CRYPT_REQUIREMENT_OFFLINE_REENCRYPT = C.CRYPT_REQUIREMENT_OFFLINE_REENCRYPT
 
+	/** unfinished online reencryption */
+	CRYPT_REQUIREMENT_ONLINE_REENCRYPT = C.CRYPT_REQUIREMENT_ONLINE_REENCRYPT
+
 	/** unknown requirement in header (output only) */
 	CRYPT_REQUIREMENT_UNKNOWN = C.CRYPT_REQUIREMENT_UNKNOWN
 
@@ -125,6 +195,21 @@ const (
 	/** crypt_rng_urandom - use /dev/urandom */
 	CRYPT_RNG_URANDOM = C.CRYPT_RNG_URANDOM
 
+	/** keyslot is in use */
+	CRYPT_SLOT_ACTIVE = C.CRYPT_SLOT_ACTIVE
+
+	/** keyslot is in use, and is the last one */
+	CRYPT_SLOT_ACTIVE_LAST = C.CRYPT_SLOT_ACTIVE_LAST
+
+	/** keyslot is free */
+	CRYPT_SLOT_INACTIVE = C.CRYPT_SLOT_INACTIVE
+
+	/** invalid keyslot */
+	CRYPT_SLOT_INVALID = C.CRYPT_SLOT_INVALID
+
+	/** keyslot is in use, but not bound to any crypt segment (LUKS2 only) */
+	CRYPT_SLOT_UNBOUND = C.CRYPT_SLOT_UNBOUND
+
 	/** tcrypt (truecrypt-compatible and veracrypt-compatible) mode */
 	CRYPT_TCRYPT = C.CRYPT_TCRYPT
 
@@ -146,6 +231,24 @@ const (
 	 */
 	CRYPT_TCRYPT_VERA_MODES = C.CRYPT_TCRYPT_VERA_MODES
 
+	/** token is external (plugin) type and loaded */
+	CRYPT_TOKEN_EXTERNAL = C.CRYPT_TOKEN_EXTERNAL
+
+	/** token is external (plugin) type, but not loaded */
+	CRYPT_TOKEN_EXTERNAL_UNKNOWN = C.CRYPT_TOKEN_EXTERNAL_UNKNOWN
+
+	/** token is free */
+	CRYPT_TOKEN_INACTIVE = C.CRYPT_TOKEN_INACTIVE
+
+	/** active internal token with driver */
+	CRYPT_TOKEN_INTERNAL = C.CRYPT_TOKEN_INTERNAL
+
+	/** active internal token (reserved name) with missing token driver */
+	CRYPT_TOKEN_INTERNAL_UNKNOWN = C.CRYPT_TOKEN_INTERNAL_UNKNOWN
+
+	/** token does not exist */
+	CRYPT_TOKEN_INVALID = C.CRYPT_TOKEN_INVALID
+
 	/** dm-verity mode */
 	CRYPT_VERITY = C.CRYPT_VERITY
 
@@ -158,9 +261,24 @@ const (
 	/** no on-disk header (only hashes) */
 	CRYPT_VERITY_NO_HEADER = C.CRYPT_VERITY_NO_HEADER
 
+	/** root hash signature required for activation */
+	CRYPT_VERITY_ROOT_HASH_SIGNATURE = C.CRYPT_VERITY_ROOT_HASH_SIGNATURE
+
 	/** create keyslot with volume key not associated with current dm-crypt segment */
 	CRYPT_VOLUME_KEY_NO_SEGMENT = C.CRYPT_VOLUME_KEY_NO_SEGMENT
 
+	/** wipe by encrypting zeroes with the volume key */
+	CRYPT_WIPE_ENCRYPTED_ZERO = C.CRYPT_WIPE_ENCRYPTED_ZERO
+
 	/** use direct-io */
 	CRYPT_WIPE_NO_DIRECT_IO = C.CRYPT_WIPE_NO_DIRECT_IO
+
+	/** wipe with random data */
+	CRYPT_WIPE_RANDOM = C.CRYPT_WIPE_RANDOM
+
+	/** wipe using a special pattern (Gutmann-like, for old magnetic drives) */
+	CRYPT_WIPE_SPECIAL = C.CRYPT_WIPE_SPECIAL
+
+	/** wipe with zeroes */
+	CRYPT_WIPE_ZERO = C.CRYPT_WIPE_ZERO
 )
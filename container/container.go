@@ -0,0 +1,95 @@
+// Package container manages encrypted container files: regular files holding a LUKS2 volume,
+// attached to a loop device when opened.
+package container
+
+import (
+	"fmt"
+	"os"
+
+	"cryptsetup"
+)
+
+// Params describes how a container file is formatted.
+// Unset parameters are filled with the application-wide defaults.
+type Params struct {
+	GenericParams cryptsetup.GenericParams
+	LUKS2         cryptsetup.LUKS2
+}
+
+// Create creates the container file at 'path', 'sizeBytes' long, and formats it as LUKS2 with a keyslot holding 'passphrase'.
+// The file is created sparse, so only the header takes disk space until data is written to the opened container.
+// The file must not exist yet, and is removed again if any step fails.
+// Returns nil on success, or an error otherwise.
+func Create(path string, sizeBytes int64, params Params, passphrase string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = file.Truncate(sizeBytes)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = format(path, params, passphrase)
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+// format formats the container file at 'path', and adds a keyslot holding 'passphrase'.
+func format(path string, params Params, passphrase string) error {
+	device, err := cryptsetup.Init(path)
+	if err != nil {
+		return err
+	}
+	defer device.Free()
+
+	genericParams, luks2 := params.GenericParams, params.LUKS2
+	genericParams.FillDefaultValues()
+	luks2.FillDefaultValues()
+	if err = device.Format(luks2, genericParams); err != nil {
+		return err
+	}
+
+	return device.KeyslotAddByVolumeKey(cryptsetup.CRYPT_ANY_SLOT, genericParams.VolumeKey, passphrase)
+}
+
+// Open activates the container file at 'path' using 'passphrase'. libcryptsetup attaches the file to a free loop device,
+// which is detached automatically once the container is closed.
+// The mapping is named "luks-<UUID>", like cryptsetup.Open names it.
+// Returns the path of the mapping's device node, usually in /dev/mapper, on success, or an error otherwise.
+func Open(path string, passphrase string) (string, error) {
+	volume, err := cryptsetup.Open(path, cryptsetup.Passphrase{Keyslot: cryptsetup.CRYPT_ANY_SLOT, Passphrase: passphrase})
+	if err != nil {
+		return "", err
+	}
+	defer volume.Device().Free()
+
+	return volume.MapperPath(), nil
+}
+
+// Close deactivates the container file at 'path', opened by Open, which also detaches its loop device.
+// Returns nil on success, or an error otherwise.
+func Close(path string) error {
+	device, err := cryptsetup.Init(path)
+	if err != nil {
+		return err
+	}
+	defer device.Free()
+
+	if err = device.Load(); err != nil {
+		return err
+	}
+
+	uuid := device.UUID()
+	if uuid == "" {
+		return fmt.Errorf("container '%s' has no UUID", path)
+	}
+
+	return device.Deactivate("luks-" + uuid)
+}
@@ -0,0 +1,156 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cryptsetup"
+)
+
+var testParams = Params{
+	GenericParams: cryptsetup.GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8},
+	LUKS2: cryptsetup.LUKS2{
+		SectorSize: 512,
+		PBKDFType:  &cryptsetup.PbkdfType{Type: cryptsetup.CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: cryptsetup.CRYPT_PBKDF_NO_BENCHMARK},
+	},
+}
+
+// requirePrivileges skips tests that need the kernel's device mapper when the suite runs without privileges.
+func requirePrivileges(test *testing.T) {
+	if !cryptsetup.HasDeviceMapperPrivileges() {
+		test.Skip("This test requires CAP_SYS_ADMIN, as libcryptsetup uses the kernel's device mapper.")
+	}
+}
+
+// tempContainerPath returns a container file path in a new temporary directory, and a function removing the directory.
+func tempContainerPath(test *testing.T) (string, func()) {
+	directory, err := ioutil.TempDir("", "container")
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	return filepath.Join(directory, "vault.img"), func() { os.RemoveAll(directory) }
+}
+
+func Test_Create(test *testing.T) {
+	path, remove := tempContainerPath(test)
+	defer remove()
+
+	if err := Create(path, 32*1024*1024, testParams, "passphrase"); err != nil {
+		test.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if info.Size() != 32*1024*1024 || info.Mode().Perm() != 0600 {
+		test.Errorf("Unexpected container file size %d or mode %v", info.Size(), info.Mode().Perm())
+	}
+
+	device, err := cryptsetup.Init(path)
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer device.Free()
+
+	if err = device.Load(); err != nil {
+		test.Fatal(err)
+	}
+	if device.Type() != cryptsetup.TypeLUKS2 {
+		test.Errorf("Expected a LUKS2 container, but got '%s'", device.Type())
+	}
+	if _, err = device.CheckPassphrase(cryptsetup.CRYPT_ANY_SLOT, "passphrase"); err != nil {
+		test.Error(err)
+	}
+}
+
+func Test_Create_Fails_If_File_Exists(test *testing.T) {
+	path, remove := tempContainerPath(test)
+	defer remove()
+	if err := ioutil.WriteFile(path, []byte("data"), 0600); err != nil {
+		test.Fatal(err)
+	}
+
+	if err := Create(path, 32*1024*1024, testParams, "passphrase"); err == nil {
+		test.Error("Creating a container over an existing file should have failed.")
+	}
+
+	if content, _ := ioutil.ReadFile(path); string(content) != "data" {
+		test.Error("The existing file should have been left untouched.")
+	}
+}
+
+func Test_Create_Removes_File_On_Failure(test *testing.T) {
+	path, remove := tempContainerPath(test)
+	defer remove()
+
+	params := testParams
+	params.GenericParams.Cipher = "nonexistent"
+	if err := Create(path, 32*1024*1024, params, "passphrase"); err == nil {
+		test.Fatal("Creating a container with an unknown cipher should have failed.")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		test.Errorf("The container file should have been removed: %v", err)
+	}
+}
+
+func Test_Create_Open_Close(test *testing.T) {
+	requirePrivileges(test)
+
+	path, remove := tempContainerPath(test)
+	defer remove()
+	if err := Create(path, 32*1024*1024, testParams, "passphrase"); err != nil {
+		test.Fatal(err)
+	}
+
+	mapperPath, err := Open(path, "passphrase")
+	if err != nil {
+		test.Fatal(err)
+	}
+	if _, err = os.Stat(mapperPath); err != nil {
+		test.Errorf("The mapping's device node should exist: %v", err)
+	}
+
+	if err = Close(path); err != nil {
+		test.Fatal(err)
+	}
+	if _, err = os.Stat(mapperPath); !os.IsNotExist(err) {
+		test.Errorf("The mapping's device node should have been removed: %v", err)
+	}
+
+	device, err := cryptsetup.Init(path)
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer device.Free()
+
+	info, err := device.DataDeviceInfo()
+	if err != nil {
+		test.Fatal(err)
+	}
+	if info.BlockDevice != "" {
+		test.Errorf("The container file should have been detached from its loop device, but is still attached to '%s'", info.BlockDevice)
+	}
+}
+
+func Test_Open_Fails_With_Wrong_Passphrase(test *testing.T) {
+	path, remove := tempContainerPath(test)
+	defer remove()
+	if err := Create(path, 32*1024*1024, testParams, "passphrase"); err != nil {
+		test.Fatal(err)
+	}
+
+	if _, err := Open(path, "wrongPassphrase"); err == nil {
+		test.Error("Opening a container with a wrong passphrase should have failed.")
+	}
+}
+
+func TestMain(m *testing.M) {
+	result := m.Run()
+	cryptsetup.CleanupLoopDevices()
+	os.Exit(result)
+}
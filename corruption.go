@@ -0,0 +1,54 @@
+package cryptsetup
+
+import "fmt"
+
+const (
+	// corruptionModeFlags choose how a dm-verity mapping handles corrupted blocks. At most one of them may be set.
+	corruptionModeFlags = CRYPT_ACTIVATE_IGNORE_CORRUPTION | CRYPT_ACTIVATE_RESTART_ON_CORRUPTION | CRYPT_ACTIVATE_PANIC_ON_CORRUPTION
+	// verityActivationFlags are only supported by VERITY devices.
+	verityActivationFlags = corruptionModeFlags | CRYPT_ACTIVATE_IGNORE_ZERO_BLOCKS | CRYPT_ACTIVATE_CHECK_AT_MOST_ONCE
+)
+
+// Corrupted reports whether dm-verity detected corrupted blocks on the mapping since it was activated.
+func (active ActiveDevice) Corrupted() bool {
+	return active.Flags&CRYPT_ACTIVATE_CORRUPTED != 0
+}
+
+// ValidateCorruptionFlags checks the corruption handling flags of 'flags' before a device of type 'deviceType' is activated:
+// CRYPT_ACTIVATE_IGNORE_CORRUPTION, CRYPT_ACTIVATE_RESTART_ON_CORRUPTION and CRYPT_ACTIVATE_PANIC_ON_CORRUPTION exclude each other,
+// and they, CRYPT_ACTIVATE_IGNORE_ZERO_BLOCKS and CRYPT_ACTIVATE_CHECK_AT_MOST_ONCE are only supported by VERITY devices.
+// Returns nil if the flags are valid, or an error describing the problem otherwise.
+func ValidateCorruptionFlags(deviceType Type, flags int) error {
+	if mode := flags & corruptionModeFlags; mode&(mode-1) != 0 {
+		return fmt.Errorf("corruption handling flags %#x exclude each other", mode)
+	}
+
+	if deviceType != TypeVerity && flags&verityActivationFlags != 0 {
+		return fmt.Errorf("flags %#x are only supported by VERITY devices, not by %s devices", flags&verityActivationFlags, deviceType)
+	}
+
+	return nil
+}
+
+// ActivateForRecovery activates the device as 'deviceName' using 'credential', so data can be imaged off failing media:
+// the mapping is always read-only, and VERITY devices ignore corrupted blocks, only logging them, unless 'flags'
+// choose another corruption handling mode, so damaged blocks are read instead of failing the imaging with I/O errors.
+// Use Status and ActiveDevice.Corrupted to find out whether corruption was detected.
+// The root hash of VERITY devices is their volume key credential.
+// Returns nil on success, or an error otherwise.
+func (device *Device) ActivateForRecovery(deviceName string, credential Credential, flags int) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	deviceType := device.Type()
+	if deviceType == TypeVerity && flags&corruptionModeFlags == 0 {
+		flags |= CRYPT_ACTIVATE_IGNORE_CORRUPTION
+	}
+
+	if err := ValidateCorruptionFlags(deviceType, flags); err != nil {
+		return err
+	}
+
+	return credential.Activate(device, deviceName, flags|CRYPT_ACTIVATE_READONLY)
+}
@@ -0,0 +1,43 @@
+package cryptsetup
+
+import "testing"
+
+func Test_Verity_ActivateForRecovery(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	dataPath, hashPath, _, remove := verityImages(64, test)
+	defer remove()
+
+	device, err := Init(hashPath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(VerityParams{HashName: "sha256", DataDevice: dataPath, HashType: 1, DataBlockSize: 4096, HashBlockSize: 4096}, GenericParams{})
+	testWrapper.AssertNoError(err)
+
+	rootHash, err := device.VerityRootHash()
+	testWrapper.AssertNoError(err)
+
+	err = device.ActivateForRecovery("", VolumeKey{VolumeKey: string(rootHash)}, CRYPT_ACTIVATE_IGNORE_ZERO_BLOCKS)
+	testWrapper.AssertNoError(err)
+
+	err = device.ActivateForRecovery("", VolumeKey{VolumeKey: string(rootHash)}, CRYPT_ACTIVATE_IGNORE_CORRUPTION|CRYPT_ACTIVATE_RESTART_ON_CORRUPTION)
+	testWrapper.AssertError(err)
+}
+
+func Test_ValidateCorruptionFlags(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	testWrapper.AssertNoError(ValidateCorruptionFlags(TypeVerity, CRYPT_ACTIVATE_RESTART_ON_CORRUPTION|CRYPT_ACTIVATE_IGNORE_ZERO_BLOCKS))
+	testWrapper.AssertNoError(ValidateCorruptionFlags(TypeLUKS2, CRYPT_ACTIVATE_READONLY|CRYPT_ACTIVATE_ALLOW_DISCARDS))
+	testWrapper.AssertError(ValidateCorruptionFlags(TypeVerity, CRYPT_ACTIVATE_IGNORE_CORRUPTION|CRYPT_ACTIVATE_RESTART_ON_CORRUPTION))
+	if CRYPT_ACTIVATE_PANIC_ON_CORRUPTION != 0 {
+		testWrapper.AssertError(ValidateCorruptionFlags(TypeVerity, CRYPT_ACTIVATE_IGNORE_CORRUPTION|CRYPT_ACTIVATE_PANIC_ON_CORRUPTION))
+	}
+	testWrapper.AssertError(ValidateCorruptionFlags(TypeLUKS2, CRYPT_ACTIVATE_IGNORE_CORRUPTION))
+	testWrapper.AssertError(ValidateCorruptionFlags(TypePlain, CRYPT_ACTIVATE_CHECK_AT_MOST_ONCE))
+
+	if !(ActiveDevice{Flags: CRYPT_ACTIVATE_READONLY | CRYPT_ACTIVATE_CORRUPTED}).Corrupted() || (ActiveDevice{Flags: CRYPT_ACTIVATE_READONLY}).Corrupted() {
+		test.Error("Only mappings flagged with CRYPT_ACTIVATE_CORRUPTED should have been reported as corrupted.")
+	}
+}
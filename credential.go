@@ -0,0 +1,111 @@
+package cryptsetup
+
+// Credential is the interface that all unlocking methods must implement.
+type Credential interface {
+	Activate(device *Device, deviceName string, flags int) error
+}
+
+// KeyslotAdder is implemented by credentials that can be used to perform the security check required to add a keyslot.
+// The new keyslot's passphrase is taken as a byte slice, which is not retained, so callers can wipe it once done.
+type KeyslotAdder interface {
+	KeyslotAdd(device *Device, keyslot int, passphrase []byte) (int, error)
+}
+
+// Passphrase is a Credential that activates a device using a passphrase from a specific keyslot.
+// Use CRYPT_ANY_SLOT as the Keyslot to try all keyslots.
+// Normalizers are applied to the passphrase before it is used, as NormalizePassphrase does.
+type Passphrase struct {
+	Keyslot     int
+	Passphrase  string
+	Normalizers []PassphraseNormalizer
+}
+
+// Activate activates a device using the passphrase.
+func (passphrase Passphrase) Activate(device *Device, deviceName string, flags int) error {
+	return device.ActivateByPassphrase(deviceName, passphrase.Keyslot, passphrase.normalized(), flags)
+}
+
+// KeyslotAdd adds a keyslot holding 'newPassphrase', using the passphrase to perform the required security check.
+// The normalizers are applied to 'newPassphrase' too, so it unlocks with the same Normalizers.
+// Normalizers work on strings: 'newPassphrase' is only copied to one if there are any.
+func (passphrase Passphrase) KeyslotAdd(device *Device, keyslot int, newPassphrase []byte) (int, error) {
+	if len(passphrase.Normalizers) > 0 {
+		newPassphrase = stringBytes(NormalizePassphrase(string(newPassphrase), passphrase.Normalizers...))
+	}
+	return device.KeyslotAddByPassphraseBytes(keyslot, stringBytes(passphrase.normalized()), newPassphrase)
+}
+
+// normalized returns the passphrase with the normalizers applied.
+func (passphrase Passphrase) normalized() string {
+	return NormalizePassphrase(passphrase.Passphrase, passphrase.Normalizers...)
+}
+
+// PassphraseBytes is like Passphrase, but holds the passphrase as a byte slice handed to libcryptsetup without copying it,
+// so callers can wipe it with WipeBytes once done. The passphrase is used as it is, without normalization.
+// Use CRYPT_ANY_SLOT as the Keyslot to try all keyslots.
+type PassphraseBytes struct {
+	Keyslot    int
+	Passphrase []byte
+}
+
+// Activate activates a device using the passphrase.
+func (passphrase PassphraseBytes) Activate(device *Device, deviceName string, flags int) error {
+	return device.ActivateByPassphraseBytes(deviceName, passphrase.Keyslot, passphrase.Passphrase, flags)
+}
+
+// KeyslotAdd adds a keyslot holding 'newPassphrase', using the passphrase to perform the required security check.
+func (passphrase PassphraseBytes) KeyslotAdd(device *Device, keyslot int, newPassphrase []byte) (int, error) {
+	return device.KeyslotAddByPassphraseBytes(keyslot, passphrase.Passphrase, newPassphrase)
+}
+
+// VolumeKey is a Credential that activates a device using its volume key.
+type VolumeKey struct {
+	VolumeKey string
+}
+
+// Activate activates a device using the volume key.
+func (volumeKey VolumeKey) Activate(device *Device, deviceName string, flags int) error {
+	return device.ActivateByVolumeKey(deviceName, volumeKey.VolumeKey, len(volumeKey.VolumeKey), flags)
+}
+
+// KeyslotAdd adds a keyslot holding 'passphrase', using the volume key to perform the required security check.
+func (volumeKey VolumeKey) KeyslotAdd(device *Device, keyslot int, passphrase []byte) (int, error) {
+	return device.KeyslotAddByVolumeKeyBytes(keyslot, stringBytes(volumeKey.VolumeKey), passphrase)
+}
+
+// KeyringKey is a Credential that activates a device using a passphrase stored in the kernel keyring.
+// Use CRYPT_ANY_SLOT as the Keyslot to try all keyslots.
+type KeyringKey struct {
+	Keyslot        int
+	KeyDescription string
+}
+
+// Activate activates a device using the passphrase stored in the kernel keyring.
+func (keyringKey KeyringKey) Activate(device *Device, deviceName string, flags int) error {
+	return device.ActivateByKeyring(deviceName, keyringKey.KeyDescription, keyringKey.Keyslot, flags)
+}
+
+// Keyfile is a Credential that activates a device using a passphrase read from a file.
+// Use CRYPT_ANY_SLOT as the Keyslot to try all keyslots. A Size of 0 reads the whole file, starting at Offset.
+type Keyfile struct {
+	Keyslot int
+	Path    string
+	Size    int
+	Offset  uint64
+}
+
+// Activate activates a device using the passphrase read from the key file.
+func (keyfile Keyfile) Activate(device *Device, deviceName string, flags int) error {
+	return device.ActivateByKeyfile(deviceName, keyfile.Keyslot, keyfile.Path, keyfile.Size, keyfile.Offset, flags)
+}
+
+// Token is a Credential that activates a device using a LUKS2 token.
+// Use CRYPT_ANY_TOKEN as the Token to try all tokens.
+type Token struct {
+	Token int
+}
+
+// Activate activates a device using the token.
+func (token Token) Activate(device *Device, deviceName string, flags int) error {
+	return device.ActivateByToken(deviceName, token.Token, flags)
+}
@@ -0,0 +1,161 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sysfsPath is the mount point of sysfs, used to inspect block devices.
+var sysfsPath = "/sys"
+
+// DataDeviceInfo describes the device holding a Device's encrypted data.
+type DataDeviceInfo struct {
+	// Path is the data device's path, as known by libcryptsetup.
+	Path string
+	// BlockDevice is the name of the underlying block device in sysfs, such as "sda1" or "loop0".
+	// It is empty for image files that are not attached to a loop device.
+	BlockDevice string
+	// Size is the size of the data device, in bytes.
+	Size uint64
+	// SectorSize is the logical sector size of the data device, in bytes.
+	SectorSize int
+	// Rotational reports whether the data device is backed by rotational media.
+	Rotational bool
+	// Holders are the names of the block devices, such as device-mapper mappings, currently stacked on the data device.
+	Holders []string
+}
+
+// InUse reports whether the data device is currently in use by another mapping.
+func (info DataDeviceInfo) InUse() bool {
+	return len(info.Holders) > 0
+}
+
+// DataDeviceInfo gathers information about the device holding the encrypted data from sysfs,
+// so that obviously wrong devices may be refused before calling Format.
+// Image files are reported through the loop device they are attached to, if any.
+// Returns the information on success, or an error otherwise.
+func (device *Device) DataDeviceInfo() (DataDeviceInfo, error) {
+	if err := device.checkUsable(); err != nil {
+		return DataDeviceInfo{}, err
+	}
+
+	info := DataDeviceInfo{Path: device.DevicePath(), SectorSize: 512}
+
+	var stat syscall.Stat_t
+	if err := syscall.Stat(info.Path, &stat); err != nil {
+		return info, &os.PathError{Op: "stat", Path: info.Path, Err: err}
+	}
+
+	switch stat.Mode & syscall.S_IFMT {
+	case syscall.S_IFBLK:
+		info.BlockDevice = blockDeviceName(stat.Rdev)
+	case syscall.S_IFREG:
+		info.Size = uint64(stat.Size)
+		info.BlockDevice = loopDeviceName(info.Path)
+	}
+
+	if info.BlockDevice == "" {
+		return info, nil
+	}
+
+	blockDevicePath := filepath.Join(sysfsPath, "class", "block", info.BlockDevice)
+
+	if sectors, err := readSysfsUint(filepath.Join(blockDevicePath, "size")); err == nil {
+		info.Size = sectors * 512
+	}
+
+	info.readQueue(blockDevicePath)
+
+	holders, err := ioutil.ReadDir(filepath.Join(blockDevicePath, "holders"))
+	if err != nil && !os.IsNotExist(err) {
+		return info, err
+	}
+	for _, holder := range holders {
+		info.Holders = append(info.Holders, holder.Name())
+	}
+
+	return info, nil
+}
+
+// readQueue reads the sector size and the rotational flag from the request queue of the block device at 'blockDevicePath' in sysfs,
+// keeping the current values if they are not available.
+func (info *DataDeviceInfo) readQueue(blockDevicePath string) {
+	queuePath := filepath.Join(blockDevicePath, "queue")
+	if _, err := os.Stat(queuePath); os.IsNotExist(err) {
+		// partitions share the queue of their parent disk, which holds their directory: the path in /sys/class/block is a symlink
+		// to it, and must be resolved first, as filepath.Join cleans ".." lexically
+		if resolvedPath, err := filepath.EvalSymlinks(blockDevicePath); err == nil {
+			queuePath = filepath.Join(resolvedPath, "..", "queue")
+		}
+	}
+
+	if sectorSize, err := readSysfsUint(filepath.Join(queuePath, "logical_block_size")); err == nil {
+		info.SectorSize = int(sectorSize)
+	}
+
+	if rotational, err := readSysfsUint(filepath.Join(queuePath, "rotational")); err == nil {
+		info.Rotational = rotational == 1
+	}
+}
+
+// splitDeviceNumber splits a Linux device number into its major and minor numbers.
+func splitDeviceNumber(rdev uint64) (uint32, uint32) {
+	major := (rdev>>8)&0xfff | (rdev>>32)&^0xfff
+	minor := rdev&0xff | (rdev>>12)&^0xff
+	return uint32(major), uint32(minor)
+}
+
+// blockDeviceName resolves a device number to its block device name in sysfs.
+func blockDeviceName(rdev uint64) string {
+	major, minor := splitDeviceNumber(rdev)
+
+	link, err := os.Readlink(filepath.Join(sysfsPath, "dev", "block", fmt.Sprintf("%d:%d", major, minor)))
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Base(link)
+}
+
+// loopDeviceName returns the name of the loop device backed by the image file at 'path', or an empty string if there is none.
+func loopDeviceName(path string) string {
+	if names := loopDevicesBackedBy(path); len(names) > 0 {
+		return names[0]
+	}
+
+	return ""
+}
+
+// loopDevicesBackedBy returns the names of the loop devices backed by the image file at 'path'.
+func loopDevicesBackedBy(path string) []string {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+
+	names := []string{}
+	backingFiles, _ := filepath.Glob(filepath.Join(sysfsPath, "block", "loop*", "loop", "backing_file"))
+	for _, backingFile := range backingFiles {
+		content, err := ioutil.ReadFile(backingFile)
+		if err == nil && strings.TrimSpace(string(content)) == absolutePath {
+			names = append(names, filepath.Base(filepath.Dir(filepath.Dir(backingFile))))
+		}
+	}
+
+	return names
+}
+
+// readSysfsUint reads a sysfs attribute holding a single unsigned integer.
+func readSysfsUint(path string) (uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
@@ -0,0 +1,74 @@
+package cryptsetup
+
+import "sync"
+
+var (
+	defaultsLock sync.RWMutex
+
+	defaultGenericParams = GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8}
+	defaultLUKS2Params   LUKS2
+)
+
+// SetDefaultGenericParams configures the application-wide defaults picked up by GenericParams.FillDefaultValues.
+// Only Cipher, CipherMode and VolumeKeySize are used, so that UUIDs and volume keys are never shared between devices.
+func SetDefaultGenericParams(genericParams GenericParams) {
+	defaultsLock.Lock()
+	defer defaultsLock.Unlock()
+
+	defaultGenericParams = GenericParams{
+		Cipher:        genericParams.Cipher,
+		CipherMode:    genericParams.CipherMode,
+		VolumeKeySize: genericParams.VolumeKeySize,
+	}
+}
+
+// DefaultGenericParams returns the application-wide defaults picked up by GenericParams.FillDefaultValues.
+// Unless configured by SetDefaultGenericParams, those are aes-xts-plain64 with a 512 bit volume key.
+func DefaultGenericParams() GenericParams {
+	defaultsLock.RLock()
+	defer defaultsLock.RUnlock()
+
+	return defaultGenericParams
+}
+
+// SetDefaultLUKS2Params configures the application-wide defaults picked up by LUKS2.FillDefaultValues,
+// such as the PBKDF and its argon2 cost.
+// DataDevice, DataOffset, Label and Subsystem are device specific, and are ignored.
+func SetDefaultLUKS2Params(luks2 LUKS2) {
+	defaultsLock.Lock()
+	defer defaultsLock.Unlock()
+
+	defaultLUKS2Params = copyLUKS2Defaults(luks2)
+}
+
+// DefaultLUKS2Params returns the application-wide defaults picked up by LUKS2.FillDefaultValues.
+// Unless configured by SetDefaultLUKS2Params, no defaults are set and libcryptsetup's own apply.
+func DefaultLUKS2Params() LUKS2 {
+	defaultsLock.RLock()
+	defer defaultsLock.RUnlock()
+
+	return copyLUKS2Defaults(defaultLUKS2Params)
+}
+
+// copyLUKS2Defaults copies the device independent LUKS2 parameters, so that callers cannot modify the defaults through shared pointers.
+func copyLUKS2Defaults(luks2 LUKS2) LUKS2 {
+	defaults := LUKS2{
+		Integrity:     luks2.Integrity,
+		DataAlignment: luks2.DataAlignment,
+		SectorSize:    luks2.SectorSize,
+		MetadataSize:  luks2.MetadataSize,
+		KeyslotsSize:  luks2.KeyslotsSize,
+	}
+
+	if luks2.PBKDFType != nil {
+		pbkdfType := *luks2.PBKDFType
+		defaults.PBKDFType = &pbkdfType
+	}
+
+	if luks2.IntegrityParams != nil {
+		integrityParams := *luks2.IntegrityParams
+		defaults.IntegrityParams = &integrityParams
+	}
+
+	return defaults
+}
@@ -0,0 +1,57 @@
+package cryptsetup
+
+import (
+	"testing"
+)
+
+func Test_GenericParams_FillDefaultValues(test *testing.T) {
+	genericParams := GenericParams{}
+	genericParams.FillDefaultValues()
+
+	if genericParams.Cipher != "aes" || genericParams.CipherMode != "xts-plain64" || genericParams.VolumeKeySize != 64 {
+		test.Errorf("Unexpected built-in defaults: %+v", genericParams)
+	}
+}
+
+func Test_GenericParams_FillDefaultValues_Uses_Configured_Defaults(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	previous := DefaultGenericParams()
+	defer SetDefaultGenericParams(previous)
+
+	SetDefaultGenericParams(GenericParams{Cipher: "aes", CipherMode: "cbc-essiv:sha256", VolumeKeySize: 256 / 8, UUID: "ignored"})
+
+	genericParams := GenericParams{VolumeKeySize: 128 / 8}
+	genericParams.FillDefaultValues()
+
+	if genericParams.CipherMode != "cbc-essiv:sha256" || genericParams.VolumeKeySize != 16 || genericParams.UUID != "" {
+		test.Errorf("Unexpected parameters: %+v", genericParams)
+	}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, genericParams)
+	testWrapper.AssertNoError(err)
+}
+
+func Test_LUKS2_FillDefaultValues(test *testing.T) {
+	previous := DefaultLUKS2Params()
+	defer SetDefaultLUKS2Params(previous)
+
+	pbkdfType := PbkdfType{Type: "argon2id", TimeMs: 100, MaxMemoryKb: 32768, ParallelThreads: 1}
+	SetDefaultLUKS2Params(LUKS2{PBKDFType: &pbkdfType, SectorSize: 4096, Label: "ignored"})
+	pbkdfType.MaxMemoryKb = 1
+
+	luks2 := LUKS2{SectorSize: 512}
+	luks2.FillDefaultValues()
+
+	if luks2.PBKDFType == nil || luks2.PBKDFType.MaxMemoryKb != 32768 {
+		test.Errorf("Default PBKDF should have been copied: %+v", luks2.PBKDFType)
+	}
+
+	if luks2.SectorSize != 512 || luks2.Label != "" {
+		test.Errorf("Unexpected parameters: %+v", luks2)
+	}
+}
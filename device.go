@@ -1,10 +1,43 @@
 package cryptsetup
 
-// #cgo pkg-config: libcryptsetup
-// #include <libcryptsetup.h>
-// #include <stdlib.h>
+/*
+#cgo pkg-config: libcryptsetup
+#include <errno.h>
+#include <libcryptsetup.h>
+#include <stdlib.h>
+#include <string.h>
+
+// crypt_header_is_detached was added in libcryptsetup 2.4, which is also when CRYPT_TOKEN_ABI_VERSION1 was defined.
+// Older versions only have a metadata device name if the header is detached.
+static int header_is_detached(struct crypt_device *cd)
+{
+#ifdef CRYPT_TOKEN_ABI_VERSION1
+	return crypt_header_is_detached(cd);
+#else
+	const char *type = crypt_get_type(cd);
+
+	if (!type || (strcmp(type, CRYPT_LUKS1) && strcmp(type, CRYPT_LUKS2)))
+		return -EINVAL;
+	return crypt_get_metadata_device_name(cd) ? 1 : 0;
+#endif
+}
+
+// crypt_get_metadata_size was added in libcryptsetup 2.1, which defines no feature macro:
+// CRYPT_REENCRYPT_INITIALIZE_ONLY, defined since 2.2, is checked instead.
+static int get_metadata_size(struct crypt_device *cd, uint64_t *metadata_size, uint64_t *keyslots_size)
+{
+#ifdef CRYPT_REENCRYPT_INITIALIZE_ONLY
+	return crypt_get_metadata_size(cd, metadata_size, keyslots_size);
+#else
+	return -ENOTSUP;
+#endif
+}
+*/
 import "C"
 import (
+	"fmt"
+	"os"
+	"sync"
 	"unsafe"
 )
 
@@ -13,6 +46,36 @@ import (
 type Device struct {
 	cryptDevice *C.struct_crypt_device
 	freed       bool
+	readOnly    bool
+	headerFile  *os.File
+	name        string
+	logID       *C.uintptr_t
+	journal     *Journal
+	pending     chan struct{}
+	pendingLock sync.Mutex
+	// keyslotGeneration is the generation of the keyslot lock the device's copy of the header was read or written at.
+	keyslotGeneration uint64
+	// imagePath is the absolute path of the image file the device was initialized with, which is attached to a loop device to activate it.
+	imagePath string
+	// loopDevices are the loop devices attached to the image file while activating the device, detached when it is freed if unused.
+	loopDevices []string
+	// headerReadOnly is set by SetHeaderReadOnly, and makes operations writing to the header fail like on read-only devices.
+	headerReadOnly bool
+	// deviceFile is the duplicated descriptor the device was initialized with by InitFd, closed when the device is freed.
+	deviceFile *os.File
+}
+
+// newDevice wraps a newly initialized crypt device.
+func newDevice(cryptDevice *C.struct_crypt_device) *Device {
+	device := &Device{cryptDevice: cryptDevice}
+	device.registerLogging()
+	return device
+}
+
+// newError returns an *Error for 'functionName' failing with 'code' while performing 'operation' on the device,
+// with 'details' such as the keyslot or the token involved.
+func (device *Device) newError(functionName string, code int, operation string, details ...string) *Error {
+	return &Error{functionName: functionName, code: code, operation: operation, devicePath: device.DevicePath(), details: details}
 }
 
 // Init initializes a crypt device backed by 'devicePath'.
@@ -24,41 +87,296 @@ func Init(devicePath string) (*Device, error) {
 
 	var cryptDevice *C.struct_crypt_device
 	if err := int(C.crypt_init(&cryptDevice, cryptDevicePath)); err < 0 {
-		return nil, &Error{functionName: "crypt_init", code: err}
+		return nil, &Error{functionName: "crypt_init", code: err, operation: "init", devicePath: devicePath}
+	}
+
+	device := newDevice(cryptDevice)
+	device.imagePath = loopImagePath(devicePath)
+	return device, nil
+}
+
+// InitDataDevice initializes a crypt device using a detached header.
+// The header is read from, or formatted to, 'headerDevicePath', while the encrypted data lives on 'dataDevicePath'.
+// Returns a pointer to the newly allocated Device or any error encountered.
+// C equivalent: crypt_init_data_device
+func InitDataDevice(headerDevicePath string, dataDevicePath string) (*Device, error) {
+	cHeaderDevicePath := C.CString(headerDevicePath)
+	defer C.free(unsafe.Pointer(cHeaderDevicePath))
+
+	cDataDevicePath := C.CString(dataDevicePath)
+	defer C.free(unsafe.Pointer(cDataDevicePath))
+
+	var cryptDevice *C.struct_crypt_device
+	if err := int(C.crypt_init_data_device(&cryptDevice, cHeaderDevicePath, cDataDevicePath)); err < 0 {
+		return nil, &Error{functionName: "crypt_init_data_device", code: err, operation: "init", devicePath: dataDevicePath, details: []string{"header " + headerDevicePath}}
+	}
+
+	return newDevice(cryptDevice), nil
+}
+
+// InitByName initializes a crypt device from the active mapping named 'name', such as "luks-<UUID>".
+// Returns a pointer to the newly allocated Device or any error encountered.
+// C equivalent: crypt_init_by_name
+func InitByName(name string) (*Device, error) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	var cryptDevice *C.struct_crypt_device
+	if err := int(C.crypt_init_by_name(&cryptDevice, cName)); err < 0 {
+		return nil, &Error{functionName: "crypt_init_by_name", code: err, operation: "init " + name}
+	}
+
+	device := newDevice(cryptDevice)
+	device.name = name
+	return device, nil
+}
+
+// InitByNameAndHeader initializes a crypt device from the active mapping named 'name', reading the header from the detached
+// header in 'headerDevicePath' instead of the data device.
+// libcryptsetup does not check that the header belongs to the mapping: use Reconcile to detect a wrong header.
+// Returns a pointer to the newly allocated Device or any error encountered.
+// C equivalent: crypt_init_by_name_and_header
+func InitByNameAndHeader(name string, headerDevicePath string) (*Device, error) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	cHeaderDevicePath := C.CString(headerDevicePath)
+	defer C.free(unsafe.Pointer(cHeaderDevicePath))
+
+	var cryptDevice *C.struct_crypt_device
+	if err := int(C.crypt_init_by_name_and_header(&cryptDevice, cName, cHeaderDevicePath)); err < 0 {
+		return nil, &Error{functionName: "crypt_init_by_name_and_header", code: err, operation: "init " + name, devicePath: headerDevicePath}
+	}
+
+	device := newDevice(cryptDevice)
+	device.name = name
+	return device, nil
+}
+
+// InitReadOnly initializes a crypt device backed by 'devicePath' for read-only inspection, such as loading and dumping
+// headers from disk images.
+// Operations that write to the header, like Format or adding and destroying keyslots, fail with ErrReadOnly.
+// Read-only means no header writes, not no locking: metadata is still locked while it is read, unless locking was
+// disabled for the whole process by DisableMetadataLocking.
+// Returns a pointer to the newly allocated Device or any error encountered.
+// C equivalent: crypt_init
+func InitReadOnly(devicePath string) (*Device, error) {
+	device, err := Init(devicePath)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Device{cryptDevice: cryptDevice}, nil
+	device.readOnly = true
+	return device, nil
+}
+
+// SetHeaderReadOnly write-protects the header in this handle, when 'readOnly' is true, so bugs in services
+// that only read headers and activate devices cannot modify them: Format, and adding, changing or destroying keyslots and tokens,
+// fail with ErrReadOnly until the protection is lifted again.
+// Other handles of the same device are not protected.
+// The protection of devices initialized with InitReadOnly cannot be lifted.
+func (device *Device) SetHeaderReadOnly(readOnly bool) {
+	device.headerReadOnly = readOnly
+}
+
+// HeaderReadOnly reports whether operations writing to the header fail with ErrReadOnly,
+// because the device was initialized with InitReadOnly, or write-protected by SetHeaderReadOnly.
+func (device *Device) HeaderReadOnly() bool {
+	return device.readOnly || device.headerReadOnly
+}
+
+// checkWritable returns ErrReadOnly if the device was initialized read-only, or its header was write-protected,
+// and ErrTimeout if it is unusable, see RunWithTimeout.
+func (device *Device) checkWritable() error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+	if device.HeaderReadOnly() {
+		return ErrReadOnly
+	}
+	return nil
 }
 
 // Free releases crypt device context and used memory.
+// If an operation run by RunWithTimeout timed out and is still blocked, the context is released once it returns.
 // C equivalent: crypt_free
 func (device *Device) Free() bool {
 	if !device.freed {
-		C.crypt_free(device.cryptDevice)
 		device.freed = true
+		if pending := device.pendingOperation(); pending != nil {
+			go func() {
+				<-pending
+				device.release()
+			}()
+			return true
+		}
+		device.release()
 		return true
 	}
 	return false
 }
 
+// release frees the crypt device context, and the resources attached to it.
+func (device *Device) release() {
+	C.crypt_free(device.cryptDevice)
+	device.unregisterLogging()
+	if device.headerFile != nil {
+		device.headerFile.Close()
+	}
+	if device.deviceFile != nil {
+		device.deviceFile.Close()
+	}
+	device.detachLoopDevices()
+}
+
 // C equivalent: crypt_dump
 func (device *Device) Dump() int {
+	if device.checkUsable() != nil {
+		return int(ETIMEDOUT)
+	}
 	return int(C.crypt_dump(device.cryptDevice))
 }
 
-// Type returns the device's type as a string.
+// Type returns the device's type.
+// Returns TypeNone if no header was loaded or formatted yet.
+// C equivalent: crypt_get_type
+func (device *Device) Type() Type {
+	if device.checkUsable() != nil {
+		return TypeNone
+	}
+	return Type(C.GoString(C.crypt_get_type(device.cryptDevice)))
+}
+
+// UUID returns the device's UUID as a string.
+// Returns an empty string if the device has no UUID, or if the information is not available.
+// C equivalent: crypt_get_uuid
+func (device *Device) UUID() string {
+	if device.checkUsable() != nil {
+		return ""
+	}
+	return C.GoString(C.crypt_get_uuid(device.cryptDevice))
+}
+
+// DevicePath returns the path of the device holding the encrypted data.
 // Returns an empty string if the information is not available.
-func (device *Device) Type() string {
-	return C.GoString(C.crypt_get_type(device.cryptDevice))
+// C equivalent: crypt_get_device_name
+func (device *Device) DevicePath() string {
+	if device.checkUsable() != nil {
+		return ""
+	}
+	return C.GoString(C.crypt_get_device_name(device.cryptDevice))
+}
+
+// MetadataDevicePath returns the path of the device holding the header: the header device given to InitDataDevice
+// or InitByNameAndHeader if the header is detached, or the same path as DevicePath otherwise.
+// C equivalent: crypt_get_metadata_device_name
+func (device *Device) MetadataDevicePath() string {
+	if device.checkUsable() != nil {
+		return ""
+	}
+	if path := C.crypt_get_metadata_device_name(device.cryptDevice); path != nil {
+		return C.GoString(path)
+	}
+	return device.DevicePath()
+}
+
+// HeaderIsDetached reports whether the LUKS header is stored on another device than the encrypted data,
+// so split header and data configurations can be told apart without comparing paths, which may differ for the same device.
+// Returns an error if the device holds a header of another type than LUKS.
+// C equivalent: crypt_header_is_detached
+func (device *Device) HeaderIsDetached() (bool, error) {
+	if err := device.checkUsable(); err != nil {
+		return false, err
+	}
+
+	detached := C.header_is_detached(device.cryptDevice)
+	if detached < 0 {
+		return false, device.newError("crypt_header_is_detached", int(detached), "check detached header")
+	}
+
+	return detached == 1, nil
+}
+
+// DataOffset returns the offset of the encrypted data on the data device, in 512 byte sectors.
+// Returns 0 if the information is not available.
+// C equivalent: crypt_get_data_offset
+func (device *Device) DataOffset() uint64 {
+	if device.checkUsable() != nil {
+		return 0
+	}
+	return uint64(C.crypt_get_data_offset(device.cryptDevice))
+}
+
+// IVOffset returns the IV offset, in 512 byte sectors, added to the sector number when computing IVs.
+// Returns 0 if the information is not available.
+// C equivalent: crypt_get_iv_offset
+func (device *Device) IVOffset() uint64 {
+	if device.checkUsable() != nil {
+		return 0
+	}
+	return uint64(C.crypt_get_iv_offset(device.cryptDevice))
+}
+
+// VolumeKeySize returns the size of the volume key, in bytes.
+// Returns 0 if the information is not available.
+// C equivalent: crypt_get_volume_key_size
+func (device *Device) VolumeKeySize() int {
+	if device.checkUsable() != nil {
+		return 0
+	}
+	return int(C.crypt_get_volume_key_size(device.cryptDevice))
+}
+
+// PayloadSize returns the space left for the decrypted data, in bytes: the data device size minus the data offset.
+// Returns the size on success, or an error if the data device size could not be determined, or is smaller than the data offset.
+func (device *Device) PayloadSize() (uint64, error) {
+	info, err := device.DataDeviceInfo()
+	if err != nil {
+		return 0, err
+	}
+
+	offset := device.DataOffset() * 512
+	if offset > info.Size {
+		return 0, fmt.Errorf("data offset %d exceeds the size %d of data device '%s'", offset, info.Size, info.Path)
+	}
+
+	return info.Size - offset, nil
 }
 
 // Format formats a Device, using a specific device type, and type-independent parameters.
+// Formatting a data device that is mounted, or is the backing device of an active mapping, fails with a *DeviceInUseError,
+// unless WithForce is given. PLAIN devices, which have no header, are not checked; instead, their cipher must be available
+// in the kernel, according to /proc/crypto, unless WithCipherSyntaxOnly is given.
 // Returns nil on success, or an error otherwise.
 // C equivalent: crypt_format
-func (device *Device) Format(deviceType DeviceType, genericParams GenericParams) error {
+func (device *Device) Format(deviceType DeviceType, genericParams GenericParams, optionFuncs ...Option) error {
+	if err := device.checkFormat(deviceType, genericParams, newOptions(optionFuncs)); err != nil {
+		return err
+	}
+
+	complete, err := device.beginJournaled(JournalOperationFormat, CRYPT_ANY_SLOT)
+	if err != nil {
+		return err
+	}
+	defer complete()
+
+	if preparer, ok := deviceType.(formatPreparer); ok {
+		if err := preparer.prepareFormat(device); err != nil {
+			return err
+		}
+	}
+
+	if genericParams.WipeSignatures && deviceType.Name() != CRYPT_PLAIN {
+		if _, err := device.WipeSignatures(); err != nil {
+			return err
+		}
+	}
+
 	cryptDeviceTypeName := C.CString(deviceType.Name())
 	defer C.free(unsafe.Pointer(cryptDeviceTypeName))
 
+	emitEvent(&FormatStarted{Device: device.DevicePath(), Type: Type(deviceType.Name())})
+
 	cCipher := C.CString(genericParams.Cipher)
 	defer C.free(unsafe.Pointer(cCipher))
 
@@ -73,8 +391,8 @@ func (device *Device) Format(deviceType DeviceType, genericParams GenericParams)
 
 	var cVolumeKey *C.char = nil
 	if len(genericParams.VolumeKey) > 0 {
-		cVolumeKey = C.CString(genericParams.VolumeKey)
-		defer C.free(unsafe.Pointer(cVolumeKey))
+		cVolumeKey = safeCString(genericParams.VolumeKey)
+		defer safeFree(cVolumeKey)
 	}
 
 	cVolumeKeySize := C.size_t(genericParams.VolumeKeySize)
@@ -82,9 +400,37 @@ func (device *Device) Format(deviceType DeviceType, genericParams GenericParams)
 	cTypeParams, freeCTypeParams := deviceType.Unmanaged()
 	defer freeCTypeParams()
 
-	err := C.crypt_format(device.cryptDevice, cryptDeviceTypeName, cCipher, cCipherMode, cUUID, cVolumeKey, cVolumeKeySize, cTypeParams)
-	if err < 0 {
-		return &Error{functionName: "crypt_format", code: int(err)}
+	if err := C.crypt_format(device.cryptDevice, cryptDeviceTypeName, cCipher, cCipherMode, cUUID, cVolumeKey, cVolumeKeySize, cTypeParams); err < 0 {
+		return device.newError("crypt_format", int(err), "format "+deviceType.Name())
+	}
+
+	device.observeKeyslots()
+	return nil
+}
+
+// checkFormat runs the checks made by Format before writing to the device, which DryRun.Format runs too.
+func (device *Device) checkFormat(deviceType DeviceType, genericParams GenericParams, options options) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	if validator, ok := deviceType.(Validator); ok {
+		if err := validator.Validate(genericParams); err != nil {
+			return err
+		}
+	}
+
+	_, plain := deviceType.(Plain)
+	if plain && !options.cipherSyntaxOnly {
+		if err := checkCipherAvailable(genericParams.Cipher, genericParams.CipherMode); err != nil {
+			return err
+		}
+	}
+
+	if !plain && !options.force {
+		if err := device.CheckNotInUse(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -94,67 +440,161 @@ func (device *Device) Format(deviceType DeviceType, genericParams GenericParams)
 // Returns nil on success, or an error otherwise.
 // C equivalent: crypt_load
 func (device *Device) Load() error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
 	err := C.crypt_load(device.cryptDevice, nil, nil)
 	if err < 0 {
-		return &Error{functionName: "crypt_load", code: int(err)}
+		return device.newError("crypt_load", int(err), "load")
 	}
 
+	device.observeKeyslots()
 	return nil
 }
 
-// KeyslotAddByVolumeKey adds a key slot using a volume key to perform the required security check.
-// Returns nil on success, or an error otherwise.
-// C equivalent: crypt_keyslot_add_by_volume_key
-func (device *Device) KeyslotAddByVolumeKey(keyslot int, volumeKey string, passphrase string) error {
-	var cVolumeKey *C.char = nil
-	if len(volumeKey) > 0 {
-		cVolumeKey = C.CString(volumeKey)
-		defer C.free(unsafe.Pointer(cVolumeKey))
+// Reload re-reads the on-disk header into the device, replacing the header loaded or formatted before,
+// so long-lived handles notice keyslots and tokens changed by other tools or Devices.
+// The header must keep the device's type.
+// Returns nil on success, a *TypeMismatchError if the header was reformatted with another LUKS version, or another error otherwise.
+// C equivalent: crypt_load, with the device's type
+func (device *Device) Reload() error {
+	if err := device.reload(); err != nil {
+		return err
+	}
+
+	device.observeKeyslots()
+	return nil
+}
+
+// reload is like Reload, without recording that the device holds the current header.
+func (device *Device) reload() error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	cType := C.crypt_get_type(device.cryptDevice)
+	if cType == nil {
+		return fmt.Errorf("device '%s' has no loaded header to reload", device.DevicePath())
+	}
+
+	return device.loadType(Type(C.GoString(cType)), "reload")
+}
+
+// PersistentFlags gets the persistent flags of type 'flagsType' stored in the header.
+// Use CRYPT_FLAGS_ACTIVATION or CRYPT_FLAGS_REQUIREMENTS as the flags type.
+// Returns the flags on success, or an error otherwise.
+// C equivalent: crypt_persistent_flags_get
+func (device *Device) PersistentFlags(flagsType int) (uint32, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
 	}
 
-	cPassphrase := C.CString(passphrase)
-	defer C.free(unsafe.Pointer(cPassphrase))
+	var cFlags C.uint32_t
 
-	err := C.crypt_keyslot_add_by_volume_key(device.cryptDevice, C.int(keyslot), cVolumeKey, C.size_t(len(volumeKey)), cPassphrase, C.size_t(len(passphrase)))
+	err := C.crypt_persistent_flags_get(device.cryptDevice, C.crypt_flags_type(flagsType), &cFlags)
 	if err < 0 {
-		return &Error{functionName: "crypt_keyslot_add_by_volume_key", code: int(err)}
+		return 0, device.newError("crypt_persistent_flags_get", int(err), "get persistent flags")
 	}
 
-	return nil
+	return uint32(cFlags), nil
 }
 
-// KeyslotAddByPassphrase adds a key slot using a previously added passphrase to perform the required security check.
-// Returns nil on success, or an error otherwise.
-// C equivalent: crypt_keyslot_add_by_passphrase
-func (device *Device) KeyslotAddByPassphrase(keyslot int, currentPassphrase string, newPassphrase string) error {
-	cCurrentPassphrase := C.CString(currentPassphrase)
-	defer C.free(unsafe.Pointer(cCurrentPassphrase))
+// MetadataSize returns the size of each LUKS2 JSON metadata area, and the size of the keyslots area, in bytes.
+// It fails with -ENOTSUP if libcryptsetup is older than 2.2.
+// Returns the sizes on success, or an error otherwise.
+// C equivalent: crypt_get_metadata_size
+func (device *Device) MetadataSize() (uint64, uint64, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, 0, err
+	}
 
-	cNewPassphrase := C.CString(newPassphrase)
-	defer C.free(unsafe.Pointer(cNewPassphrase))
+	var metadataSize, keyslotsSize C.uint64_t
 
-	err := C.crypt_keyslot_add_by_passphrase(
-		device.cryptDevice, C.int(keyslot),
-		cCurrentPassphrase, C.size_t(len(currentPassphrase)),
-		cNewPassphrase, C.size_t(len(newPassphrase)),
-	)
+	err := C.get_metadata_size(device.cryptDevice, &metadataSize, &keyslotsSize)
+	if err < 0 {
+		return 0, 0, device.newError("crypt_get_metadata_size", int(err), "get metadata size")
+	}
+
+	return uint64(metadataSize), uint64(keyslotsSize), nil
+}
+
+// SetPersistentFlags stores persistent flags of type 'flagsType' in the header, replacing the ones stored before.
+// Use CRYPT_FLAGS_ACTIVATION to store CRYPT_ACTIVATE_* flags applied on every activation.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_persistent_flags_set
+func (device *Device) SetPersistentFlags(flagsType int, flags uint32) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	err := C.crypt_persistent_flags_set(device.cryptDevice, C.crypt_flags_type(flagsType), C.uint32_t(flags))
 	if err < 0 {
-		return &Error{functionName: "crypt_keyslot_add_by_passphrase", code: int(err)}
+		return device.newError("crypt_persistent_flags_set", int(err), "set persistent flags")
 	}
 
 	return nil
 }
 
+// CheckRequirements checks whether the header carries any requirements that must be met before it may be modified.
+// Returns nil if there are none, a *RequirementsError if there are, or an error otherwise.
+func (device *Device) CheckRequirements() error {
+	requirements, err := device.PersistentFlags(CRYPT_FLAGS_REQUIREMENTS)
+	if err != nil {
+		return err
+	}
+
+	if requirements != 0 {
+		return &RequirementsError{requirements: requirements}
+	}
+
+	return nil
+}
+
+// KeyslotAddByVolumeKey adds a key slot using a volume key to perform the required security check.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_keyslot_add_by_volume_key
+func (device *Device) KeyslotAddByVolumeKey(keyslot int, volumeKey string, passphrase string) error {
+	_, err := device.keyslotAddByVolumeKey(keyslot, volumeKey, passphrase)
+	return err
+}
+
+// keyslotAddByVolumeKey is like KeyslotAddByVolumeKey, but also returns the number of the added keyslot.
+func (device *Device) keyslotAddByVolumeKey(keyslot int, volumeKey string, passphrase string) (int, error) {
+	return device.KeyslotAddByVolumeKeyBytes(keyslot, stringBytes(volumeKey), stringBytes(passphrase))
+}
+
+// KeyslotAddByPassphrase adds a key slot using a previously added passphrase to perform the required security check.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_keyslot_add_by_passphrase
+func (device *Device) KeyslotAddByPassphrase(keyslot int, currentPassphrase string, newPassphrase string) error {
+	_, err := device.keyslotAddByPassphrase(keyslot, currentPassphrase, newPassphrase)
+	return err
+}
+
+// keyslotAddByPassphrase is like KeyslotAddByPassphrase, but also returns the number of the added keyslot.
+func (device *Device) keyslotAddByPassphrase(keyslot int, currentPassphrase string, newPassphrase string) (int, error) {
+	return device.KeyslotAddByPassphraseBytes(keyslot, stringBytes(currentPassphrase), stringBytes(newPassphrase))
+}
+
 // KeyslotChangeByPassphrase changes a defined a key slot using a previously added passphrase to perform the required security check.
 // Returns nil on success, or an error otherwise.
 // C equivalent: crypt_keyslot_change_by_passphrase
 func (device *Device) KeyslotChangeByPassphrase(currentKeyslot int, newKeyslot int, currentPassphrase string, newPassphrase string) error {
-	cCurrentPassphrase := C.CString(currentPassphrase)
-	defer C.free(unsafe.Pointer(cCurrentPassphrase))
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
 
-	cNewPassphrase := C.CString(newPassphrase)
-	defer C.free(unsafe.Pointer(cNewPassphrase))
+	cCurrentPassphrase := bytesPointer(stringBytes(currentPassphrase))
+	cNewPassphrase := bytesPointer(stringBytes(newPassphrase))
 
+	unlock, lockErr := device.lockKeyslots()
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlock()
+
+	before := device.keyslotsInUse()
 	err := C.crypt_keyslot_change_by_passphrase(
 		device.cryptDevice,
 		C.int(currentKeyslot),
@@ -163,66 +603,289 @@ func (device *Device) KeyslotChangeByPassphrase(currentKeyslot int, newKeyslot i
 		cNewPassphrase, C.size_t(len(newPassphrase)),
 	)
 	if err < 0 {
-		return &Error{functionName: "crypt_keyslot_change_by_passphrase", code: int(err)}
+		return device.newError("crypt_keyslot_change_by_passphrase", int(err), "change keyslot", keyslotDetail(currentKeyslot), "new "+keyslotDetail(newKeyslot))
 	}
 
+	emitEvent(&KeyslotAdded{Device: device.DevicePath(), Keyslot: int(err)})
+	device.emitKeyslotsDestroyed(before)
 	return nil
 }
 
-// ActivateByPassphrase activates a device by using a passphrase from a specific keyslot.
+// KeyslotStatus returns the status of a key slot, as one of the CRYPT_SLOT_* constants.
+// C equivalent: crypt_keyslot_status
+func (device *Device) KeyslotStatus(keyslot int) int {
+	if device.checkUsable() != nil {
+		return CRYPT_SLOT_INVALID
+	}
+	return int(C.crypt_keyslot_status(device.cryptDevice, C.int(keyslot)))
+}
+
+// KeyslotMax returns the number of key slots supported by the device's type.
+// Returns a negative number if the device's type has no key slots.
+// C equivalent: crypt_keyslot_max
+func (device *Device) KeyslotMax() int {
+	if device.checkUsable() != nil {
+		return int(ETIMEDOUT)
+	}
+	return int(C.crypt_keyslot_max(C.crypt_get_type(device.cryptDevice)))
+}
+
+// KeyslotDestroy destroys a key slot, wiping its key material.
 // Returns nil on success, or an error otherwise.
-// C equivalent: crypt_activate_by_passphrase
-func (device *Device) ActivateByPassphrase(deviceName string, keyslot int, passphrase string, flags int) error {
-	cryptDeviceName := C.CString(deviceName)
-	defer C.free(unsafe.Pointer(cryptDeviceName))
+// C equivalent: crypt_keyslot_destroy
+func (device *Device) KeyslotDestroy(keyslot int) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
 
-	cPassphrase := C.CString(passphrase)
-	defer C.free(unsafe.Pointer(cPassphrase))
+	unlock, err := device.lockKeyslots()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	err := C.crypt_activate_by_passphrase(device.cryptDevice, cryptDeviceName, C.int(keyslot), cPassphrase, C.size_t(len(passphrase)), C.uint32_t(flags))
-	if err < 0 {
-		return &Error{functionName: "crypt_activate_by_passphrase", code: int(err)}
+	return device.keyslotDestroy(keyslot)
+}
+
+// keyslotDestroy destroys a key slot like KeyslotDestroy. The keyslot lock must be held.
+func (device *Device) keyslotDestroy(keyslot int) error {
+	complete, err := device.beginJournaled(JournalOperationKeyslotDestroy, keyslot)
+	if err != nil {
+		return err
+	}
+	defer complete()
+
+	if err := C.crypt_keyslot_destroy(device.cryptDevice, C.int(keyslot)); err < 0 {
+		return device.newError("crypt_keyslot_destroy", int(err), "destroy keyslot", keyslotDetail(keyslot))
 	}
 
+	emitEvent(&KeyslotDestroyed{Device: device.DevicePath(), Keyslot: keyslot})
 	return nil
 }
 
+// CheckPassphrase checks a passphrase against a specific keyslot, without activating the device.
+// Use CRYPT_ANY_SLOT to check the passphrase against all keyslots.
+// Returns the number of the keyslot that was unlocked on success, or an error otherwise.
+// C equivalent: crypt_activate_by_passphrase, with a NULL device name
+func (device *Device) CheckPassphrase(keyslot int, passphrase string) (int, error) {
+	return device.CheckPassphraseBytes(keyslot, stringBytes(passphrase))
+}
+
+// ActivateByPassphrase activates a device by using a passphrase from a specific keyslot.
+// If 'deviceName' is empty, the passphrase is only checked, and the device is not activated.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_activate_by_passphrase
+func (device *Device) ActivateByPassphrase(deviceName string, keyslot int, passphrase string, flags int) error {
+	_, err := device.ActivateByPassphraseKeyslot(deviceName, keyslot, passphrase, flags)
+	return err
+}
+
+// ActivateByPassphraseKeyslot is like ActivateByPassphrase, but also returns the number of the keyslot the passphrase unlocked,
+// so audit logs can record which credential unlocked the volume when activating with CRYPT_ANY_SLOT.
+// Returns the number of the unlocked keyslot on success, or an error otherwise.
+// C equivalent: crypt_activate_by_passphrase
+func (device *Device) ActivateByPassphraseKeyslot(deviceName string, keyslot int, passphrase string, flags int) (int, error) {
+	return device.activateByPassphraseBytes(deviceName, keyslot, stringBytes(passphrase), flags)
+}
+
 // ActivateByVolumeKey activates a device by using a volume key.
+// If 'deviceName' is empty, the volume key is only checked, and the device is not activated.
+// For PLAIN devices, an empty 'volumeKey' activates the device with an ephemeral random key of 'volumeKeySize' bytes,
+// or of the size given at Format if 'volumeKeySize' is 0, as used for encrypted swap.
+// The ephemeral key is generated in memory allocated by libcryptsetup and wiped right after activation, so Go never holds it.
 // Returns nil on success, or an error otherwise.
 // C equivalent: crypt_activate_by_volume_key
 func (device *Device) ActivateByVolumeKey(deviceName string, volumeKey string, volumeKeySize int, flags int) error {
-	cryptDeviceName := C.CString(deviceName)
-	defer C.free(unsafe.Pointer(cryptDeviceName))
+	if len(volumeKey) > 0 {
+		key := stringBytes(volumeKey)
+		if volumeKeySize > 0 && volumeKeySize < len(key) {
+			key = key[:volumeKeySize]
+		}
+		return device.ActivateByVolumeKeyBytes(deviceName, key, flags)
+	}
+
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	var cryptDeviceName *C.char = nil
+	if len(deviceName) > 0 {
+		cryptDeviceName = C.CString(deviceName)
+		defer C.free(unsafe.Pointer(cryptDeviceName))
+	}
 
 	var cVolumeKey *C.char = nil
-	if len(volumeKey) > 0 {
-		cVolumeKey = C.CString(volumeKey)
-		defer C.free(unsafe.Pointer(cVolumeKey))
+	if device.Type() == TypePlain {
+		if volumeKeySize == 0 {
+			volumeKeySize = int(C.crypt_get_volume_key_size(device.cryptDevice))
+		}
+
+		ephemeralKey, err := newEphemeralVolumeKey(volumeKeySize)
+		if err != nil {
+			return err
+		}
+		defer safeFree((*C.char)(ephemeralKey))
+
+		cVolumeKey = (*C.char)(ephemeralKey)
 	}
 
+	defer device.trackLoopDevices()()
 	err := C.crypt_activate_by_volume_key(device.cryptDevice, cryptDeviceName, cVolumeKey, C.size_t(volumeKeySize), C.uint32_t(flags))
 	if err < 0 {
-		return &Error{functionName: "crypt_activate_by_volume_key", code: int(err)}
+		return device.newError("crypt_activate_by_volume_key", int(err), activationOperation(deviceName))
 	}
 
+	device.emitActivated(deviceName, -1)
+	return nil
+}
+
+// SetVolumeKeyKeyring enables or disables passing volume keys to dm-crypt through the kernel keyring on activation.
+// When disabled, the key is loaded directly into the dm-crypt table, where it can be read back, as Clone requires.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_volume_key_keyring
+func (device *Device) SetVolumeKeyKeyring(enable bool) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	cEnable := C.int(0)
+	if enable {
+		cEnable = 1
+	}
+
+	if err := C.crypt_volume_key_keyring(device.cryptDevice, cEnable); err < 0 {
+		return device.newError("crypt_volume_key_keyring", int(err), "set volume key keyring")
+	}
+
+	return nil
+}
+
+// ActivateByKeyring activates a device by using a passphrase stored in the kernel keyring.
+// The passphrase is read from the user key identified by 'keyDescription', such as the ones cached by systemd-cryptsetup,
+// so that additional mappings may be activated without prompting for the passphrase again.
+// It unlocks a keyslot with the passphrase; to reuse a volume key already in the keyring, see ActivateByVolumeKeyInKeyring.
+// If 'deviceName' is empty, the passphrase is only checked, and the device is not activated.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_activate_by_keyring
+func (device *Device) ActivateByKeyring(deviceName string, keyDescription string, keyslot int, flags int) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	var cryptDeviceName *C.char = nil
+	if len(deviceName) > 0 {
+		cryptDeviceName = C.CString(deviceName)
+		defer C.free(unsafe.Pointer(cryptDeviceName))
+	}
+
+	cKeyDescription := C.CString(keyDescription)
+	defer C.free(unsafe.Pointer(cKeyDescription))
+
+	defer device.trackLoopDevices()()
+	err := C.crypt_activate_by_keyring(device.cryptDevice, cryptDeviceName, cKeyDescription, C.int(keyslot), C.uint32_t(flags))
+	if err < 0 {
+		return device.newError("crypt_activate_by_keyring", int(err), activationOperation(deviceName), keyslotDetail(keyslot))
+	}
+
+	device.emitActivated(deviceName, int(err))
+	return nil
+}
+
+// ActivateByKeyfile activates a device by using a passphrase read from the file in 'keyfilePath'.
+// At most 'keyfileSize' bytes are read, starting at 'keyfileOffset'. A 'keyfileSize' of 0 reads the whole file.
+// If 'deviceName' is empty, the passphrase is only checked, and the device is not activated.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_activate_by_keyfile_device_offset
+func (device *Device) ActivateByKeyfile(deviceName string, keyslot int, keyfilePath string, keyfileSize int, keyfileOffset uint64, flags int) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	var cryptDeviceName *C.char = nil
+	if len(deviceName) > 0 {
+		cryptDeviceName = C.CString(deviceName)
+		defer C.free(unsafe.Pointer(cryptDeviceName))
+	}
+
+	cKeyfilePath := C.CString(keyfilePath)
+	defer C.free(unsafe.Pointer(cKeyfilePath))
+
+	defer device.trackLoopDevices()()
+	err := C.crypt_activate_by_keyfile_device_offset(device.cryptDevice, cryptDeviceName, C.int(keyslot), cKeyfilePath, C.size_t(keyfileSize), C.uint64_t(keyfileOffset), C.uint32_t(flags))
+	if err < 0 {
+		return device.newError("crypt_activate_by_keyfile_device_offset", int(err), activationOperation(deviceName), keyslotDetail(keyslot))
+	}
+
+	device.emitActivated(deviceName, int(err))
+	return nil
+}
+
+// ActivateByToken activates a device by using a LUKS2 token, such as one handled by a token plugin.
+// Use CRYPT_ANY_TOKEN to try all tokens.
+// If 'deviceName' is empty, the token is only checked, and the device is not activated.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_activate_by_token
+func (device *Device) ActivateByToken(deviceName string, token int, flags int) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	var cryptDeviceName *C.char = nil
+	if len(deviceName) > 0 {
+		cryptDeviceName = C.CString(deviceName)
+		defer C.free(unsafe.Pointer(cryptDeviceName))
+	}
+
+	defer device.trackLoopDevices()()
+	err := C.crypt_activate_by_token(device.cryptDevice, cryptDeviceName, C.int(token), nil, C.uint32_t(flags))
+	if err < 0 {
+		return device.newError("crypt_activate_by_token", int(err), activationOperation(deviceName), tokenDetail(token))
+	}
+
+	device.emitActivated(deviceName, int(err))
 	return nil
 }
 
 // Deactivate deactivates a device.
+// With WithUdevWait, it waits for udev to remove the mapper node, and returns ErrTimeout if the node is still present.
 // Returns nil on success, or an error otherwise.
 // C equivalent: crypt_deactivate
-func (device *Device) Deactivate(deviceName string) error {
+func (device *Device) Deactivate(deviceName string, optionFuncs ...Option) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
 	cryptDeviceName := C.CString(deviceName)
 	defer C.free(unsafe.Pointer(cryptDeviceName))
 
 	err := C.crypt_deactivate(device.cryptDevice, cryptDeviceName)
 	if err < 0 {
-		return &Error{functionName: "crypt_deactivate", code: int(err)}
+		return device.newError("crypt_deactivate", int(err), "deactivate "+deviceName)
 	}
 
+	emitEvent(&Deactivated{Device: device.DevicePath(), Name: deviceName})
+
+	if timeout := newOptions(optionFuncs).udevWait; timeout > 0 {
+		return WaitForMapperNodeRemoval(deviceName, timeout)
+	}
 	return nil
 }
 
+// Deactivate deactivates the mapping named 'name' without requiring an initialized Device,
+// since teardown paths often only know the mapping name, not the backing device.
+// Takes the same options as Device.Deactivate.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_init_by_name, followed by crypt_deactivate
+func Deactivate(name string, optionFuncs ...Option) error {
+	device, err := InitByName(name)
+	if err != nil {
+		return err
+	}
+	defer device.Free()
+
+	return device.Deactivate(name, optionFuncs...)
+}
+
 // SetDebugLevel sets the debug level for the library.
 // C equivalent: crypt_set_debug_level
 func SetDebugLevel(debugLevel int) {
@@ -233,23 +896,29 @@ func SetDebugLevel(debugLevel int) {
 // Returns a slice of bytes having the volume key and the unlocked key slot number, or an error otherwise.
 // C equivalent: crypt_volume_key_get
 func (device *Device) VolumeKeyGet(keyslot int, passphrase string) ([]byte, int, error) {
-	cPassphrase := C.CString(passphrase)
-	defer C.free(unsafe.Pointer(cPassphrase))
+	return device.volumeKeyGet(keyslot, stringBytes(passphrase))
+}
+
+// volumeKeyGet is like VolumeKeyGet, but takes the passphrase as a byte slice handed to libcryptsetup without copying it.
+func (device *Device) volumeKeyGet(keyslot int, passphrase []byte) ([]byte, int, error) {
+	if err := device.checkUsable(); err != nil {
+		return nil, 0, err
+	}
 
 	cVKSize := C.crypt_get_volume_key_size(device.cryptDevice)
-	cVKSizePointer := C.malloc(C.size_t(cVKSize))
+	cVKSizePointer := safeAlloc(int(cVKSize))
 	if cVKSizePointer == nil {
-		return []byte{}, 0, &Error{functionName: "malloc"}
+		return []byte{}, 0, &Error{functionName: "crypt_safe_alloc", code: int(ENOMEM)}
 	}
-	defer C.free(cVKSizePointer)
+	defer safeFree((*C.char)(cVKSizePointer))
 
 	err := C.crypt_volume_key_get(
 		device.cryptDevice, C.int(keyslot),
 		(*C.char)(cVKSizePointer), (*C.size_t)(unsafe.Pointer(&cVKSize)),
-		cPassphrase, C.size_t(len(passphrase)),
+		bytesPointer(passphrase), C.size_t(len(passphrase)),
 	)
 	if err < 0 {
-		return []byte{}, 0, &Error{functionName: "crypt_volume_key_get", code: int(err)}
+		return []byte{}, 0, device.newError("crypt_volume_key_get", int(err), "get volume key", keyslotDetail(keyslot))
 	}
 	return C.GoBytes(unsafe.Pointer(cVKSizePointer), C.int(cVKSize)), int(err), nil
 }
@@ -1,6 +1,11 @@
 package cryptsetup
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 )
 
@@ -84,6 +89,8 @@ func Test_Device_Free_Doesnt_Fail_If_Called_Multiple_Times(test *testing.T) {
 }
 
 func Test_Device_Deactivate_Fails_If_Device_Is_Not_Active(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	device, err := Init(DevicePath)
@@ -95,6 +102,8 @@ func Test_Device_Deactivate_Fails_If_Device_Is_Not_Active(test *testing.T) {
 }
 
 func Test_Device_ActivateByPassphrase_Fails_If_Device_Has_No_Type(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	device, err := Init(DevicePath)
@@ -106,6 +115,8 @@ func Test_Device_ActivateByPassphrase_Fails_If_Device_Has_No_Type(test *testing.
 }
 
 func Test_Device_ActivateByVolumeKey_Fails_If_Device_Has_No_Type(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	genericParams := GenericParams{
@@ -205,3 +216,294 @@ func Test_Device_VolumeKeyGet_Fails_If_Wrong_Passphrase(test *testing.T) {
 		test.Errorf("Volume key slot should have been zero, but was: %d", volumeKeySlot)
 	}
 }
+
+func Test_Device_DataDeviceInfo(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	info, err := device.DataDeviceInfo()
+	testWrapper.AssertNoError(err)
+
+	if info.Size != 64*1024*1024 {
+		test.Errorf("Data device size should have been 64MiB, but was: %d", info.Size)
+	}
+
+	if info.SectorSize != 512 {
+		test.Errorf("Data device sector size should have been 512, but was: %d", info.SectorSize)
+	}
+
+	if info.InUse() {
+		test.Errorf("Data device should not be in use, but is held by: %v", info.Holders)
+	}
+}
+
+func Test_Device_DataDeviceInfo_Fails_If_Device_Is_Removed(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	exec.Command("/bin/dd", "if=/dev/zero", fmt.Sprintf("of=%s", "removedTestDevice"), "bs=1M", "count=1").Run()
+
+	device, err := Init("removedTestDevice")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	os.Remove("removedTestDevice")
+
+	_, err = device.DataDeviceInfo()
+	testWrapper.AssertError(err)
+}
+
+func Test_DataDeviceInfo_readQueue_Uses_Parent_Disk_Queue_For_Partitions(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	directory, err := ioutil.TempDir("", "datadevice")
+	testWrapper.AssertNoError(err)
+	defer os.RemoveAll(directory)
+
+	queue := filepath.Join(directory, "devices", "sda", "queue")
+	testWrapper.AssertNoError(os.MkdirAll(queue, 0755))
+	testWrapper.AssertNoError(ioutil.WriteFile(filepath.Join(queue, "logical_block_size"), []byte("4096\n"), 0644))
+	testWrapper.AssertNoError(ioutil.WriteFile(filepath.Join(queue, "rotational"), []byte("1\n"), 0644))
+	testWrapper.AssertNoError(os.MkdirAll(filepath.Join(directory, "devices", "sda", "sda1"), 0755))
+	testWrapper.AssertNoError(os.MkdirAll(filepath.Join(directory, "class", "block"), 0755))
+	testWrapper.AssertNoError(os.Symlink(filepath.Join(directory, "devices", "sda", "sda1"), filepath.Join(directory, "class", "block", "sda1")))
+
+	info := DataDeviceInfo{SectorSize: 512}
+	info.readQueue(filepath.Join(directory, "class", "block", "sda1"))
+
+	if info.SectorSize != 4096 || !info.Rotational {
+		test.Errorf("The partition should have reported the queue of its parent disk, but got: %+v", info)
+	}
+}
+
+func Test_Device_InitReadOnly(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	device.Free()
+
+	hashBeforeLoad := getFileMD5(DevicePath, test)
+
+	device, err = InitReadOnly(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.Load()
+	testWrapper.AssertNoError(err)
+
+	if device.Type() != "LUKS1" {
+		test.Error("Expected type: LUKS1.")
+	}
+
+	if code := device.Dump(); code != 0 {
+		test.Error("Dump() should have returned `0`.")
+	}
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	if err != ErrReadOnly {
+		test.Errorf("Format() should have returned ErrReadOnly, but returned: %v", err)
+	}
+
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	if err != ErrReadOnly {
+		test.Errorf("KeyslotAddByVolumeKey() should have returned ErrReadOnly, but returned: %v", err)
+	}
+
+	if hashBeforeLoad != getFileMD5(DevicePath, test) {
+		test.Error("Device should not have been written to.")
+	}
+}
+
+func Test_Device_SetHeaderReadOnly(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	pbkdfType := &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK}
+	err = device.Format(LUKS2{SectorSize: 512, PBKDFType: pbkdfType}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "testPassphrase"))
+
+	device.SetHeaderReadOnly(true)
+	if !device.HeaderReadOnly() {
+		test.Error("The header should have been reported as read-only.")
+	}
+
+	hashBeforeWrites := getFileMD5(DevicePath, test)
+
+	errs := map[string]error{
+		"Format":                    device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8}),
+		"KeyslotAddByPassphrase":    device.KeyslotAddByPassphrase(1, "testPassphrase", "otherPassphrase"),
+		"KeyslotChangeByPassphrase": device.KeyslotChangeByPassphrase(0, 0, "testPassphrase", "otherPassphrase"),
+		"KeyslotDestroy":            device.KeyslotDestroy(0),
+		"TokenRemove":               device.TokenRemove(0),
+	}
+	_, errs["TokenJSONSet"] = device.TokenJSONSet(CRYPT_ANY_TOKEN, `{"type":"test","keyslots":[]}`)
+	for operation, err := range errs {
+		if err != ErrReadOnly {
+			test.Errorf("%s should have returned ErrReadOnly, but returned: %v", operation, err)
+		}
+	}
+
+	if hashBeforeWrites != getFileMD5(DevicePath, test) {
+		test.Error("Device should not have been written to.")
+	}
+
+	if _, err = device.CheckPassphrase(0, "testPassphrase"); err != nil {
+		test.Errorf("Reading operations should still have succeeded, but returned: %v", err)
+	}
+
+	device.SetHeaderReadOnly(false)
+	testWrapper.AssertNoError(device.KeyslotAddByPassphrase(1, "testPassphrase", "otherPassphrase"))
+}
+
+func Test_Device_Reload(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512, PBKDFType: &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK}},
+		GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	other, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer other.Free()
+	testWrapper.AssertNoError(other.Load())
+	testWrapper.AssertNoError(other.KeyslotAddByPassphrase(1, "testPassphrase", "otherPassphrase"))
+
+	if status := device.KeyslotStatus(1); status != CRYPT_SLOT_INACTIVE {
+		test.Fatalf("Keyslot 1 should not be known before reloading, but has status: %d", status)
+	}
+
+	err = device.Reload()
+	testWrapper.AssertNoError(err)
+
+	if status := device.KeyslotStatus(1); status != CRYPT_SLOT_ACTIVE {
+		test.Errorf("Keyslot 1 should be active after reloading, but has status: %d", status)
+	}
+}
+
+func Test_Device_Reload_Fails_If_Device_Has_No_Type(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	testWrapper.AssertError(device.Reload())
+}
+
+func Test_Device_DataOffset_PayloadSize(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	if device.DataOffset() == 0 {
+		test.Error("A LUKS1 device should have a non-zero data offset.")
+	}
+
+	payloadSize, err := device.PayloadSize()
+	testWrapper.AssertNoError(err)
+
+	if payloadSize != 64*1024*1024-device.DataOffset()*512 {
+		test.Errorf("Unexpected payload size: %d", payloadSize)
+	}
+}
+
+func Test_Device_IVOffset(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(Plain{Hash: "sha256", Offset: 8, Skip: 16}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	if device.DataOffset() != 8 {
+		test.Errorf("Data offset should have been 8, but was: %d", device.DataOffset())
+	}
+
+	if device.IVOffset() != 16 {
+		test.Errorf("IV offset should have been 16, but was: %d", device.IVOffset())
+	}
+}
+
+func Test_InitByName_Fails_If_Device_Is_Not_Active(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := InitByName("nonExistingDeviceName")
+	testWrapper.AssertError(err)
+}
+
+func Test_Deactivate_Fails_If_Device_Is_Not_Active(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	err := Deactivate("nonExistingDeviceName")
+	testWrapper.AssertError(err)
+}
+
+func Test_Device_HeaderIsDetached_MetadataDevicePath(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const headerPath = "testHeader"
+	exec.Command("/bin/dd", "if=/dev/zero", fmt.Sprintf("of=%s", headerPath), "bs=1M", "count=16").Run()
+	defer os.Remove(headerPath)
+
+	device, err := InitDataDevice(headerPath, DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	detached, err := device.HeaderIsDetached()
+	testWrapper.AssertNoError(err)
+	if !detached || device.MetadataDevicePath() != headerPath || device.DevicePath() != DevicePath {
+		test.Errorf("Unexpected header location: detached %t, header %s, data %s", detached, device.MetadataDevicePath(), device.DevicePath())
+	}
+
+	attached, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer attached.Free()
+	err = attached.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	detached, err = attached.HeaderIsDetached()
+	testWrapper.AssertNoError(err)
+	if detached || attached.MetadataDevicePath() != DevicePath {
+		test.Errorf("Unexpected header location: detached %t, header %s", detached, attached.MetadataDevicePath())
+	}
+}
+
+func Test_Device_HeaderIsDetached_Fails_If_Device_Is_Not_LUKS(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	_, err = device.HeaderIsDetached()
+	testWrapper.AssertError(err)
+}
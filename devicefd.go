@@ -0,0 +1,58 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// InitFd initializes a crypt device backed by the already open file descriptor 'fd', such as one passed over a Unix socket
+// by a privileged broker, so the device never has to be opened by path. Pass file.Fd() to use an *os.File.
+// The descriptor is duplicated: the caller may close 'fd' once InitFd returns, while the duplicate is closed along with
+// the Device by Free.
+// libcryptsetup only opens devices by path, so it is given the /proc/self/fd path of the duplicate, which is what DevicePath returns.
+// Notice opening that path checks the permissions of the device node again, unlike using the descriptor directly,
+// and reopens it with the access modes libcryptsetup needs: a read-only descriptor does not prevent writes.
+// Returns a pointer to the newly allocated Device or any error encountered.
+// C equivalent: crypt_init
+func InitFd(fd uintptr) (*Device, error) {
+	deviceFile, err := duplicateFd(fd)
+	if err != nil {
+		return nil, err
+	}
+	devicePath := deviceFile.Name()
+
+	cDevicePath := C.CString(devicePath)
+	defer C.free(unsafe.Pointer(cDevicePath))
+
+	var cryptDevice *C.struct_crypt_device
+	if err := int(C.crypt_init(&cryptDevice, cDevicePath)); err < 0 {
+		deviceFile.Close()
+		return nil, &Error{functionName: "crypt_init", code: err, operation: "init", devicePath: devicePath}
+	}
+
+	device := newDevice(cryptDevice)
+	device.deviceFile = deviceFile
+	// Loop devices attached to image files record the path the file was opened at, not the /proc/self/fd one.
+	if target, err := os.Readlink(devicePath); err == nil {
+		device.imagePath = loopImagePath(target)
+	}
+	return device, nil
+}
+
+// duplicateFd duplicates the file descriptor 'fd' of the caller, so it can be wrapped in an *os.File closing it
+// without closing 'fd'. The name of the file is the /proc/self/fd path of the duplicate.
+// Returns the duplicate on success, or an error otherwise.
+func duplicateFd(fd uintptr) (*os.File, error) {
+	duplicate, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_DUPFD_CLOEXEC, 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("fcntl", errno)
+	}
+
+	return os.NewFile(duplicate, fmt.Sprintf("/proc/self/fd/%d", duplicate)), nil
+}
@@ -0,0 +1,44 @@
+package cryptsetup
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_InitFd_Format_Load(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	file, err := os.OpenFile(DevicePath, os.O_RDWR, 0)
+	testWrapper.AssertNoError(err)
+
+	device, err := InitFd(file.Fd())
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	// The device keeps working once the caller closed its descriptor.
+	testWrapper.AssertNoError(file.Close())
+
+	if !strings.HasPrefix(device.DevicePath(), "/proc/self/fd/") {
+		test.Errorf("Unexpected device path: %s", device.DevicePath())
+	}
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	loaded, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer loaded.Free()
+
+	testWrapper.AssertNoError(loaded.Load())
+	if loaded.Type() != TypeLUKS1 || loaded.UUID() != device.UUID() {
+		test.Errorf("Unexpected header: type %s, UUID %s", loaded.Type(), loaded.UUID())
+	}
+}
+
+func Test_InitFd_Fails_For_Invalid_Descriptor(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := InitFd(^uintptr(0))
+	testWrapper.AssertError(err)
+}
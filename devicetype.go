@@ -7,3 +7,13 @@ type DeviceType interface {
 	Name() string
 	Unmanaged() (unsafe.Pointer, func())
 }
+
+// Validator is implemented by device types that check their parameters before a device is formatted.
+type Validator interface {
+	Validate(genericParams GenericParams) error
+}
+
+// formatPreparer is implemented by device types that configure the device context before it is formatted.
+type formatPreparer interface {
+	prepareFormat(device *Device) error
+}
@@ -0,0 +1,80 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+import "C"
+import (
+	"os"
+	"sync"
+)
+
+var (
+	directoriesLock sync.RWMutex
+
+	// metadataLockDir is the directory in which libcryptsetup creates the metadata lock files of block devices.
+	metadataLockDir = "/run/cryptsetup"
+	// shmPath is a memory-backed file system, preferred for temporary data.
+	shmPath = "/dev/shm"
+	// tempDir is the directory configured by SetTempDir, or "" for the default one.
+	tempDir string
+)
+
+// SetLockDir configures where the package looks for the metadata lock files of libcryptsetup, as used by WaitForMetadataLock
+// and Preflight, for libcryptsetup builds configured with another --with-luks2-lock-path than /run/cryptsetup.
+// Notice the directory libcryptsetup itself locks in is fixed when it is built, and is not changed by SetLockDir:
+// sandboxed processes with a restricted /run, such as systemd services with DynamicUser, should make it available,
+// like RuntimeDirectory=cryptsetup does, or call DisableMetadataLocking.
+func SetLockDir(directory string) {
+	directoriesLock.Lock()
+	defer directoriesLock.Unlock()
+
+	metadataLockDir = directory
+}
+
+// LockDir returns the directory of the metadata lock files of libcryptsetup, /run/cryptsetup unless configured by SetLockDir.
+func LockDir() string {
+	directoriesLock.RLock()
+	defer directoriesLock.RUnlock()
+
+	return metadataLockDir
+}
+
+// SetTempDir configures the directory in which the package stages temporary data, such as the header backups of
+// HeaderBackupToWriter, for sandboxed processes in which /dev/shm and the default temporary directory are not writable.
+// Pass "" to restore the default.
+func SetTempDir(directory string) {
+	directoriesLock.Lock()
+	defer directoriesLock.Unlock()
+
+	tempDir = directory
+}
+
+// TempDir returns the directory in which the package stages temporary data: the one configured by SetTempDir,
+// otherwise /dev/shm if it exists, so secrets stay in memory, falling back to the default temporary directory.
+func TempDir() string {
+	directoriesLock.RLock()
+	directory := tempDir
+	directoriesLock.RUnlock()
+
+	if directory != "" {
+		return directory
+	}
+	if info, err := os.Stat(shmPath); err == nil && info.IsDir() {
+		return shmPath
+	}
+	return os.TempDir()
+}
+
+// DisableMetadataLocking disables the locking of the metadata of all devices of the process, like `cryptsetup --disable-locks` does,
+// so processes that cannot create libcryptsetup's lock directory, see LockDir, can still operate.
+// Call it before initializing devices: libcryptsetup refuses to enable locking again once disabled.
+// Notice concurrent accesses to the same header by other processes are not serialized anymore.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_metadata_locking
+func DisableMetadataLocking() error {
+	if err := C.crypt_metadata_locking(nil, 0); err < 0 {
+		return &Error{functionName: "crypt_metadata_locking", code: int(err), operation: "disable metadata locking"}
+	}
+
+	return nil
+}
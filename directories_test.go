@@ -0,0 +1,61 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_SetTempDir_Stages_Header_Backups(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	directory, err := ioutil.TempDir("", "tempdir")
+	testWrapper.AssertNoError(err)
+	defer os.RemoveAll(directory)
+
+	SetTempDir(directory)
+	defer SetTempDir("")
+	if TempDir() != directory {
+		test.Errorf("Unexpected temporary directory: %s", TempDir())
+	}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	var backup bytes.Buffer
+	testWrapper.AssertNoError(device.HeaderBackupToWriter(&backup))
+
+	SetTempDir(filepath.Join(directory, "missing"))
+	testWrapper.AssertError(device.HeaderBackupToWriter(&backup))
+
+	SetTempDir("")
+	if TempDir() != shmPath && TempDir() != os.TempDir() {
+		test.Errorf("Unexpected default temporary directory: %s", TempDir())
+	}
+}
+
+func Test_SetLockDir(test *testing.T) {
+	previousLockDir := LockDir()
+	defer SetLockDir(previousLockDir)
+
+	if previousLockDir != "/run/cryptsetup" {
+		test.Errorf("Unexpected default lock directory: %s", previousLockDir)
+	}
+
+	SetLockDir("/run/user/1000/cryptsetup")
+	if LockDir() != "/run/user/1000/cryptsetup" {
+		test.Errorf("Unexpected lock directory: %s", LockDir())
+	}
+
+	for _, check := range Preflight().Checks {
+		if check.Name == "lock directory /run/user/1000/cryptsetup" {
+			return
+		}
+	}
+	test.Error("Preflight should have checked the configured lock directory.")
+}
@@ -0,0 +1,260 @@
+package cryptsetup
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <sys/ioctl.h>
+#include <linux/dm-ioctl.h>
+
+static int dm_table_status(int fd, struct dm_ioctl *io) {
+	return ioctl(fd, DM_TABLE_STATUS, io);
+}
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// dmControlPath is the device-mapper control node used to issue DM ioctls.
+var dmControlPath = "/dev/mapper/control"
+
+// DMTarget is a target line of a device-mapper table, as shown by `dmsetup table`.
+type DMTarget struct {
+	// Start and Length are the range of the mapping covered by the target, in 512 byte sectors.
+	Start  uint64
+	Length uint64
+	// Type is the target type, such as "crypt".
+	Type string
+	// Params are the target's parameters. Volume keys of "crypt" targets are redacted.
+	Params string
+	// Crypt holds the parsed parameters of "crypt" targets, and is nil for other targets.
+	Crypt *DMCryptParams
+
+	// rawParams are the unredacted parameters, pointing into the ioctl buffer, which is wiped once dmTableStatus' visitor returns.
+	rawParams []byte
+}
+
+// DMCryptParams are the parsed parameters of a dm-crypt target.
+type DMCryptParams struct {
+	// Cipher is the cipher specification, such as "aes-xts-plain64".
+	Cipher string
+	// KeySize is the size of the volume key, in bytes.
+	KeySize int
+	// KeyType is "hex" for keys passed directly to the kernel, or the kernel keyring key type, such as "logon".
+	KeyType string
+	// KeyDescription is the kernel keyring key description, if the key is stored in the keyring.
+	KeyDescription string
+	// IVOffset is the IV offset, in 512 byte sectors.
+	IVOffset uint64
+	// Device is the backing device, as a "major:minor" pair or a path.
+	Device string
+	// Offset is the offset of the encrypted data on the backing device, in 512 byte sectors.
+	Offset uint64
+	// Options are the optional parameters, such as "allow_discards" or "sector_size:4096".
+	Options []string
+}
+
+// DMTable returns the kernel's device-mapper table for the active mapping named 'name', read through DM ioctls,
+// so the kernel's view can be verified against the header without running dmsetup.
+// Volume keys are never returned: they are redacted from the parameters, and only their size is reported.
+// Returns the table's targets on success, or an error otherwise.
+func (device *Device) DMTable(name string) ([]DMTarget, error) {
+	if err := device.checkUsable(); err != nil {
+		return nil, err
+	}
+
+	var targets []DMTarget
+	err := dmTableStatus(name, func(rawTargets []DMTarget) error {
+		targets = make([]DMTarget, 0, len(rawTargets))
+		for _, target := range rawTargets {
+			if target.Type == "crypt" {
+				crypt, redacted, err := parseDMCryptParams(target.Params)
+				if err != nil {
+					return err
+				}
+				target.Crypt, target.Params = crypt, redacted
+			}
+			targets = append(targets, target)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+// dmTableStatus reads the table of the active mapping named 'name' through the DM_TABLE_STATUS ioctl,
+// and passes its targets to 'visit'. Their unredacted parameters are only available as byte slices pointing into the ioctl buffer,
+// which may hold volume keys, and is wiped once 'visit' returns.
+func dmTableStatus(name string, visit func(targets []DMTarget) error) error {
+	control, err := os.OpenFile(dmControlPath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer control.Close()
+
+	if len(name) >= C.DM_NAME_LEN {
+		return fmt.Errorf("mapping name '%s' is too long", name)
+	}
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	for size := C.size_t(16 * 1024); ; size *= 2 {
+		buffer := C.calloc(1, size)
+		if buffer == nil {
+			return fmt.Errorf("failed to allocate a %d bytes device-mapper ioctl buffer", size)
+		}
+
+		io := (*C.struct_dm_ioctl)(buffer)
+		io.version[0] = C.DM_VERSION_MAJOR
+		io.data_size = C.__u32(size)
+		io.data_start = C.__u32(C.sizeof_struct_dm_ioctl)
+		io.flags = C.DM_STATUS_TABLE_FLAG
+		C.strncpy(&io.name[0], cName, C.DM_NAME_LEN-1)
+
+		if result, err := C.dm_table_status(C.int(control.Fd()), io); result < 0 {
+			C.free(buffer)
+			return os.NewSyscallError("ioctl", err)
+		}
+
+		if io.flags&C.DM_BUFFER_FULL_FLAG != 0 {
+			C.free(buffer)
+			continue
+		}
+
+		data := C.GoBytes(buffer, C.int(io.data_size))
+		targets, err := parseDMTargets(data, int(io.data_start), int(io.target_count))
+		if err == nil {
+			err = visit(targets)
+		}
+		WipeBytes(data)
+		C.memset(buffer, 0, size)
+		C.free(buffer)
+		return err
+	}
+}
+
+// parseDMTargets parses the 'count' dm_target_spec structures, and their parameters, starting at 'start' in 'data'.
+// Volume keys of "crypt" targets are redacted before the parameters are converted to strings.
+func parseDMTargets(data []byte, start int, count int) ([]DMTarget, error) {
+	targets := make([]DMTarget, 0, count)
+	offset := start
+
+	for index := 0; index < count; index++ {
+		if offset+C.sizeof_struct_dm_target_spec > len(data) {
+			return nil, fmt.Errorf("device-mapper target %d is out of bounds", index)
+		}
+
+		spec := (*C.struct_dm_target_spec)(unsafe.Pointer(&data[offset]))
+		paramsStart := offset + C.sizeof_struct_dm_target_spec
+		paramsEnd := paramsStart
+		for paramsEnd < len(data) && data[paramsEnd] != 0 {
+			paramsEnd++
+		}
+
+		target := DMTarget{
+			Start:     uint64(spec.sector_start),
+			Length:    uint64(spec.length),
+			Type:      C.GoString(&spec.target_type[0]),
+			rawParams: data[paramsStart:paramsEnd],
+		}
+		if target.Type == "crypt" {
+			target.Params = redactDMCryptKey(target.rawParams)
+		} else {
+			target.Params = string(target.rawParams)
+		}
+		targets = append(targets, target)
+
+		offset = start + int(spec.next)
+	}
+
+	return targets, nil
+}
+
+// dmCryptKey returns the bounds of the key, the second field of the dm-crypt parameters 'params'.
+// Returns -1 bounds if there is no key field.
+func dmCryptKey(params []byte) (int, int) {
+	isSpace := func(index int) bool {
+		return params[index] == ' ' || params[index] == '\t'
+	}
+
+	index := 0
+	for field := 0; field < 2; field++ {
+		for index < len(params) && isSpace(index) {
+			index++
+		}
+		start := index
+		for index < len(params) && !isSpace(index) {
+			index++
+		}
+		if field == 1 && start < index {
+			return start, index
+		}
+	}
+
+	return -1, -1
+}
+
+// redactDMCryptKey converts the dm-crypt parameters 'params' to a string, replacing their hex key by zeros,
+// as `dmsetup table` shows them, without copying the key. Kernel keyring key references are kept.
+func redactDMCryptKey(params []byte) string {
+	keyStart, keyEnd := dmCryptKey(params)
+	if keyStart < 0 || params[keyStart] == ':' {
+		return string(params)
+	}
+
+	return string(params[:keyStart]) + strings.Repeat("0", keyEnd-keyStart) + string(params[keyEnd:])
+}
+
+// parseDMCryptParams parses dm-crypt parameters:
+// <cipher> <key> <iv_offset> <device path> <offset> [<#opt_params> <opt_params>].
+// Returns the parsed parameters, along with the parameters with the key redacted, as `dmsetup table` shows them.
+func parseDMCryptParams(params string) (*DMCryptParams, string, error) {
+	fields := strings.Fields(params)
+	if len(fields) < 5 {
+		return nil, "", fmt.Errorf("invalid dm-crypt parameters: expected at least 5 fields, got %d", len(fields))
+	}
+
+	crypt := &DMCryptParams{Cipher: fields[0], Device: fields[3], Options: []string{}}
+
+	key := fields[1]
+	if strings.HasPrefix(key, ":") {
+		keyFields := strings.SplitN(key[1:], ":", 3)
+		if len(keyFields) != 3 {
+			return nil, "", fmt.Errorf("invalid dm-crypt keyring key '%s'", key)
+		}
+		keySize, err := strconv.Atoi(keyFields[0])
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid dm-crypt keyring key size '%s'", keyFields[0])
+		}
+		crypt.KeySize, crypt.KeyType, crypt.KeyDescription = keySize, keyFields[1], keyFields[2]
+	} else {
+		crypt.KeySize, crypt.KeyType = len(key)/2, "hex"
+		fields[1] = strings.Repeat("0", len(key))
+	}
+
+	var err error
+	if crypt.IVOffset, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+		return nil, "", fmt.Errorf("invalid dm-crypt IV offset '%s'", fields[2])
+	}
+	if crypt.Offset, err = strconv.ParseUint(fields[4], 10, 64); err != nil {
+		return nil, "", fmt.Errorf("invalid dm-crypt offset '%s'", fields[4])
+	}
+
+	if len(fields) > 5 {
+		optionCount, err := strconv.Atoi(fields[5])
+		if err != nil || optionCount != len(fields)-6 {
+			return nil, "", fmt.Errorf("invalid dm-crypt optional parameters '%s'", strings.Join(fields[5:], " "))
+		}
+		crypt.Options = append(crypt.Options, fields[6:]...)
+	}
+
+	return crypt, strings.Join(fields, " "), nil
+}
@@ -0,0 +1,92 @@
+package cryptsetup
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseDMCryptParams_Keyring_Key(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	params := "aes-xts-plain64 :64:logon:cryptsetup:d0a1b2c3-d0 16 7:0 32768 2 allow_discards sector_size:4096"
+	crypt, redacted, err := parseDMCryptParams(params)
+	testWrapper.AssertNoError(err)
+
+	expected := &DMCryptParams{
+		Cipher:         "aes-xts-plain64",
+		KeySize:        64,
+		KeyType:        "logon",
+		KeyDescription: "cryptsetup:d0a1b2c3-d0",
+		IVOffset:       16,
+		Device:         "7:0",
+		Offset:         32768,
+		Options:        []string{"allow_discards", "sector_size:4096"},
+	}
+	if !reflect.DeepEqual(crypt, expected) {
+		test.Errorf("Unexpected parameters: %+v", crypt)
+	}
+
+	if redacted != params {
+		test.Errorf("Keyring key parameters should not have been changed, but were: %s", redacted)
+	}
+}
+
+func Test_parseDMCryptParams_Redacts_Hex_Key(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	key := strings.Repeat("ab", 32)
+	crypt, redacted, err := parseDMCryptParams("aes-cbc-essiv:sha256 " + key + " 0 /dev/loop0 8")
+	testWrapper.AssertNoError(err)
+
+	if crypt.KeySize != 32 || crypt.KeyType != "hex" || crypt.Offset != 8 || len(crypt.Options) != 0 {
+		test.Errorf("Unexpected parameters: %+v", crypt)
+	}
+
+	if strings.Contains(redacted, key) || redacted != "aes-cbc-essiv:sha256 "+strings.Repeat("0", 64)+" 0 /dev/loop0 8" {
+		test.Errorf("Key should have been redacted: %s", redacted)
+	}
+}
+
+func Test_redactDMCryptKey(test *testing.T) {
+	key := strings.Repeat("cd", 32)
+	params := []byte("aes-xts-plain64 " + key + " 0 7:0 4096 1 allow_discards")
+	if redacted := redactDMCryptKey(params); redacted != "aes-xts-plain64 "+strings.Repeat("0", 64)+" 0 7:0 4096 1 allow_discards" {
+		test.Errorf("Key should have been redacted: %s", redacted)
+	}
+
+	keyStart, keyEnd := dmCryptKey(params)
+	if string(params[keyStart:keyEnd]) != key {
+		test.Errorf("Unexpected key bounds %d and %d", keyStart, keyEnd)
+	}
+
+	keyring := "aes-xts-plain64 :64:logon:cryptsetup:d0a1b2c3-d0 0 7:0 4096"
+	if redacted := redactDMCryptKey([]byte(keyring)); redacted != keyring {
+		test.Errorf("Keyring key parameters should not have been changed, but were: %s", redacted)
+	}
+
+	if keyStart, _ := dmCryptKey([]byte("aes-xts-plain64")); keyStart != -1 {
+		test.Errorf("Parameters without a key should have no key bounds, got: %d", keyStart)
+	}
+}
+
+func Test_parseDMCryptParams_Fails_For_Invalid_Parameters(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, _, err := parseDMCryptParams("aes-xts-plain64 abcd 0")
+	testWrapper.AssertError(err)
+
+	_, _, err = parseDMCryptParams("aes-xts-plain64 abcd 0 7:0 0 2 allow_discards")
+	testWrapper.AssertError(err)
+}
+
+func Test_Device_DMTable_Fails_If_Mapping_Is_Not_Active(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	_, err = device.DMTable("nonExistingDeviceName")
+	testWrapper.AssertError(err)
+}
@@ -0,0 +1,166 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+import "C"
+import (
+	"fmt"
+	"strings"
+)
+
+// Plan describes what an operation would do to a device, as returned by DryRun.
+type Plan struct {
+	// Operation is either "format" or "activate".
+	Operation string
+	// DevicePath is the path of the device the operation applies to.
+	DevicePath string
+	// DeviceType is the device's type, such as "LUKS2".
+	DeviceType string
+	// Cipher is the full cipher specification, such as "aes-xts-plain64".
+	Cipher string
+	// VolumeKeySize is the size of the volume key, in bytes.
+	VolumeKeySize int
+	// Name is the name the device would be activated with. Only set for activation.
+	Name string
+	// Keyslot is the keyslot that would be unlocked, or CRYPT_ANY_SLOT if no keyslot is involved. Only set for activation.
+	Keyslot int
+	// Flags are the activation flags. Only set for activation.
+	Flags int
+	// Table is the device-mapper table that would be loaded, with the key masked. Only set for activation.
+	Table string
+	// WipedSignatures are the signatures that GenericParams.WipeSignatures would wipe before formatting. Only set for format.
+	WipedSignatures []Signature
+}
+
+// DryRun wraps a Device so that Format and Activate calls describe what would be done instead of touching the device,
+// for preview and confirmation flows in installers.
+type DryRun struct {
+	device *Device
+}
+
+// DryRun returns a DryRun wrapping the device.
+func (device *Device) DryRun() DryRun {
+	return DryRun{device: device}
+}
+
+// Format describes how the device would be formatted, without writing to it.
+// The checks made by Format are run with the same 'optionFuncs', so a format refused by Format is refused here too.
+// Returns the plan on success, or an error if the parameters are obviously invalid.
+func (dryRun DryRun) Format(deviceType DeviceType, genericParams GenericParams, optionFuncs ...Option) (Plan, error) {
+	if err := dryRun.device.checkFormat(deviceType, genericParams, newOptions(optionFuncs)); err != nil {
+		return Plan{}, err
+	}
+
+	if dryRun.device.IsFormatted() {
+		return Plan{}, fmt.Errorf("device already has type '%s'", dryRun.device.Type())
+	}
+
+	if genericParams.VolumeKey != "" && len(genericParams.VolumeKey) != genericParams.VolumeKeySize {
+		return Plan{}, fmt.Errorf("volume key is %d bytes long, but VolumeKeySize is %d", len(genericParams.VolumeKey), genericParams.VolumeKeySize)
+	}
+
+	plan := Plan{
+		Operation:     "format",
+		DevicePath:    dryRun.device.DevicePath(),
+		DeviceType:    deviceType.Name(),
+		Cipher:        genericParams.Cipher + "-" + genericParams.CipherMode,
+		VolumeKeySize: genericParams.VolumeKeySize,
+		Keyslot:       CRYPT_ANY_SLOT,
+	}
+
+	if genericParams.WipeSignatures && deviceType.Name() != CRYPT_PLAIN {
+		signatures, err := dryRun.device.ProbeSignatures()
+		if err != nil {
+			return Plan{}, err
+		}
+		plan.WipedSignatures = signatures
+	}
+
+	return plan, nil
+}
+
+// ActivateByPassphrase describes how the device would be activated using a passphrase, without activating it.
+// The passphrase is checked, so the plan reports the keyslot that would be unlocked.
+// Returns the plan on success, or an error otherwise.
+func (dryRun DryRun) ActivateByPassphrase(deviceName string, keyslot int, passphrase string, flags int) (Plan, error) {
+	if err := dryRun.device.checkUsable(); err != nil {
+		return Plan{}, err
+	}
+
+	if dryRun.device.Type() == TypePlain {
+		return dryRun.activationPlan(deviceName, CRYPT_ANY_SLOT, flags), nil
+	}
+
+	unlockedKeyslot, err := dryRun.device.CheckPassphrase(keyslot, passphrase)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	return dryRun.activationPlan(deviceName, unlockedKeyslot, flags), nil
+}
+
+// ActivateByVolumeKey describes how the device would be activated using a volume key, without activating it.
+// Returns the plan on success, or an error otherwise.
+func (dryRun DryRun) ActivateByVolumeKey(deviceName string, volumeKey string, volumeKeySize int, flags int) (Plan, error) {
+	if err := dryRun.device.checkUsable(); err != nil {
+		return Plan{}, err
+	}
+
+	if dryRun.device.Type() != TypePlain {
+		if err := dryRun.device.ActivateByVolumeKey("", volumeKey, volumeKeySize, flags); err != nil {
+			return Plan{}, err
+		}
+	}
+
+	return dryRun.activationPlan(deviceName, CRYPT_ANY_SLOT, flags), nil
+}
+
+// activationPlan builds the plan for activating the device as 'deviceName'.
+func (dryRun DryRun) activationPlan(deviceName string, keyslot int, flags int) Plan {
+	device := dryRun.device
+	cipher := C.GoString(C.crypt_get_cipher(device.cryptDevice))
+	cipherMode := C.GoString(C.crypt_get_cipher_mode(device.cryptDevice))
+	volumeKeySize := int(C.crypt_get_volume_key_size(device.cryptDevice))
+	dataOffset := uint64(C.crypt_get_data_offset(device.cryptDevice))
+	ivOffset := uint64(C.crypt_get_iv_offset(device.cryptDevice))
+	sectorSize := int(C.crypt_get_sector_size(device.cryptDevice))
+
+	var sectors uint64
+	if info, err := device.DataDeviceInfo(); err == nil && info.Size/512 > dataOffset {
+		sectors = info.Size/512 - dataOffset
+	}
+
+	options := make([]string, 0)
+	for _, option := range []struct {
+		flag int
+		name string
+	}{
+		{CRYPT_ACTIVATE_ALLOW_DISCARDS, "allow_discards"},
+		{CRYPT_ACTIVATE_SAME_CPU_CRYPT, "same_cpu_crypt"},
+		{CRYPT_ACTIVATE_SUBMIT_FROM_CRYPT_CPUS, "submit_from_crypt_cpus"},
+	} {
+		if flags&option.flag != 0 {
+			options = append(options, option.name)
+		}
+	}
+	if sectorSize > 512 {
+		options = append(options, fmt.Sprintf("sector_size:%d", sectorSize))
+	}
+
+	table := fmt.Sprintf("0 %d crypt %s-%s %s %d %s %d", sectors, cipher, cipherMode, strings.Repeat("0", volumeKeySize*2), ivOffset, device.DevicePath(), dataOffset)
+	if len(options) > 0 {
+		table += fmt.Sprintf(" %d %s", len(options), strings.Join(options, " "))
+	}
+
+	return Plan{
+		Operation:     "activate",
+		DevicePath:    device.DevicePath(),
+		DeviceType:    string(device.Type()),
+		Cipher:        cipher + "-" + cipherMode,
+		VolumeKeySize: volumeKeySize,
+		Name:          deviceName,
+		Keyslot:       keyslot,
+		Flags:         flags,
+		Table:         table,
+	}
+}
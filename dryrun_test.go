@@ -0,0 +1,90 @@
+package cryptsetup
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_DryRun_Format(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	hashBeforeFormat := getFileMD5(DevicePath, test)
+
+	plan, err := device.DryRun().Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	if plan.Operation != "format" || plan.DeviceType != "LUKS2" || plan.Cipher != "aes-xts-plain64" || plan.VolumeKeySize != 64 {
+		test.Errorf("Unexpected format plan: %+v", plan)
+	}
+
+	if hashBeforeFormat != getFileMD5(DevicePath, test) {
+		test.Error("Device should not have been written to.")
+	}
+}
+
+func Test_DryRun_Format_Runs_Format_Checks(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	_, err = device.DryRun().Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "no-such-mode!", VolumeKeySize: 512 / 8})
+	testWrapper.AssertError(err)
+
+	file, err := os.OpenFile(DevicePath, os.O_WRONLY, 0)
+	testWrapper.AssertNoError(err)
+	size, err := file.Seek(0, io.SeekEnd)
+	testWrapper.AssertNoError(err)
+	_, err = file.WriteAt([]byte("EFI PART"), size-512)
+	testWrapper.AssertNoError(err)
+	file.Close()
+
+	plan, err := device.DryRun().Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8, WipeSignatures: true})
+	testWrapper.AssertNoError(err)
+
+	found := false
+	for _, signature := range plan.WipedSignatures {
+		found = found || signature.Type == "gpt"
+	}
+	if !found {
+		test.Errorf("The plan should have reported the GPT signature as wiped: %+v", plan.WipedSignatures)
+	}
+}
+
+func Test_DryRun_ActivateByPassphrase(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.KeyslotAddByVolumeKey(3, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	plan, err := device.DryRun().ActivateByPassphrase(DeviceName, CRYPT_ANY_SLOT, "testPassphrase", CRYPT_ACTIVATE_ALLOW_DISCARDS)
+	testWrapper.AssertNoError(err)
+
+	if plan.Operation != "activate" || plan.Name != DeviceName || plan.Keyslot != 3 {
+		test.Errorf("Unexpected activation plan: %+v", plan)
+	}
+
+	if !strings.HasPrefix(plan.Table, "0 ") || !strings.Contains(plan.Table, " crypt aes-xts-plain64 ") || !strings.HasSuffix(plan.Table, " 1 allow_discards") {
+		test.Errorf("Unexpected activation table: %s", plan.Table)
+	}
+
+	_, err = device.DryRun().ActivateByPassphrase(DeviceName, CRYPT_ANY_SLOT, "wrongPassphrase", 0)
+	testWrapper.AssertError(err)
+	testWrapper.AssertErrorCodeEquals(err, -1)
+}
@@ -0,0 +1,41 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <sys/random.h>
+import "C"
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// newEphemeralVolumeKey fills 'size' bytes of memory allocated by safeAlloc with random data from the kernel.
+// The memory must be released with safeFree, which wipes it.
+// Returns a pointer to the key on success, or an error otherwise.
+func newEphemeralVolumeKey(size int) (unsafe.Pointer, error) {
+	if size <= 0 {
+		return nil, errors.New("ephemeral volume key size must be positive")
+	}
+
+	key := safeAlloc(size)
+	if key == nil {
+		return nil, &Error{functionName: "crypt_safe_alloc", code: int(ENOMEM)}
+	}
+
+	buffer := (*[1 << 30]byte)(key)[:size:size]
+	for filled := 0; filled < size; {
+		count, err := C.getrandom(unsafe.Pointer(&buffer[filled]), C.size_t(size-filled), 0)
+		if count < 0 {
+			if err == syscall.EINTR {
+				continue
+			}
+			safeFree((*C.char)(key))
+			return nil, os.NewSyscallError("getrandom", err)
+		}
+		filled += int(count)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,31 @@
+package cryptsetup
+
+import (
+	"testing"
+)
+
+func Test_newEphemeralVolumeKey_Fails_For_Invalid_Size(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := newEphemeralVolumeKey(0)
+	testWrapper.AssertError(err)
+}
+
+func Test_Plain_ActivateByVolumeKey_With_Ephemeral_Key(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.ActivateByVolumeKey(DeviceName, "", 0, 0)
+	testWrapper.AssertNoError(err)
+
+	err = device.Deactivate(DeviceName)
+	testWrapper.AssertNoError(err)
+}
@@ -0,0 +1,111 @@
+package cryptsetup
+
+// #include <errno.h>
+import "C"
+import (
+	"fmt"
+	"syscall"
+)
+
+// Errno is an error code returned by libcryptsetup functions: a negated errno value.
+type Errno int
+
+// Error codes returned by libcryptsetup functions.
+const (
+	/** Argument list too long */
+	E2BIG Errno = -C.E2BIG
+	/** Permission denied */
+	EACCES Errno = -C.EACCES
+	/** Resource temporarily unavailable */
+	EAGAIN Errno = -C.EAGAIN
+	/** Device or resource busy */
+	EBUSY Errno = -C.EBUSY
+	/** File exists */
+	EEXIST Errno = -C.EEXIST
+	/** Bad address */
+	EFAULT Errno = -C.EFAULT
+	/** Interrupted system call */
+	EINTR Errno = -C.EINTR
+	/** Invalid argument */
+	EINVAL Errno = -C.EINVAL
+	/** Input/output error */
+	EIO Errno = -C.EIO
+	/** Key was rejected by service */
+	EKEYREJECTED Errno = -C.EKEYREJECTED
+	/** No such device */
+	ENODEV Errno = -C.ENODEV
+	/** No such file or directory */
+	ENOENT Errno = -C.ENOENT
+	/** Required key not available */
+	ENOKEY Errno = -C.ENOKEY
+	/** Cannot allocate memory */
+	ENOMEM Errno = -C.ENOMEM
+	/** No space left on device */
+	ENOSPC Errno = -C.ENOSPC
+	/** Function not implemented */
+	ENOSYS Errno = -C.ENOSYS
+	/** Block device required */
+	ENOTBLK Errno = -C.ENOTBLK
+	/** Not a directory */
+	ENOTDIR Errno = -C.ENOTDIR
+	/** Operation not supported */
+	ENOTSUP Errno = -C.ENOTSUP
+	/** Inappropriate ioctl for device */
+	ENOTTY Errno = -C.ENOTTY
+	/** No such device or address */
+	ENXIO Errno = -C.ENXIO
+	/** Operation not permitted */
+	EPERM Errno = -C.EPERM
+	/** Numerical result out of range */
+	ERANGE Errno = -C.ERANGE
+	/** Read-only file system */
+	EROFS Errno = -C.EROFS
+	/** Connection timed out */
+	ETIMEDOUT Errno = -C.ETIMEDOUT
+)
+
+var errnoNames = map[Errno]string{
+	E2BIG:        "E2BIG",
+	EACCES:       "EACCES",
+	EAGAIN:       "EAGAIN",
+	EBUSY:        "EBUSY",
+	EEXIST:       "EEXIST",
+	EFAULT:       "EFAULT",
+	EINTR:        "EINTR",
+	EINVAL:       "EINVAL",
+	EIO:          "EIO",
+	EKEYREJECTED: "EKEYREJECTED",
+	ENODEV:       "ENODEV",
+	ENOENT:       "ENOENT",
+	ENOKEY:       "ENOKEY",
+	ENOMEM:       "ENOMEM",
+	ENOSPC:       "ENOSPC",
+	ENOSYS:       "ENOSYS",
+	ENOTBLK:      "ENOTBLK",
+	ENOTDIR:      "ENOTDIR",
+	ENOTSUP:      "ENOTSUP",
+	ENOTTY:       "ENOTTY",
+	ENXIO:        "ENXIO",
+	EPERM:        "EPERM",
+	ERANGE:       "ERANGE",
+	EROFS:        "EROFS",
+	ETIMEDOUT:    "ETIMEDOUT",
+}
+
+// String returns the system's description of the error code, such as "device or resource busy".
+func (errno Errno) String() string {
+	if errno >= 0 {
+		return fmt.Sprintf("unknown error code %d", int(errno))
+	}
+	return syscall.Errno(-errno).Error()
+}
+
+// Name returns the symbolic name of the error code, such as "EBUSY", or an empty string if it is unknown.
+func (errno Errno) Name() string {
+	return errnoNames[errno]
+}
+
+// Errno returns the error code returned by a libcryptsetup function as an Errno.
+func (e *Error) Errno() Errno {
+	return Errno(e.code)
+}
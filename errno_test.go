@@ -0,0 +1,40 @@
+package cryptsetup
+
+import (
+	"testing"
+)
+
+func Test_Errno_String(test *testing.T) {
+	if EBUSY.String() != "device or resource busy" {
+		test.Errorf("Unexpected description for EBUSY: %s", EBUSY.String())
+	}
+
+	if int(EBUSY) != -16 || EBUSY.Name() != "EBUSY" {
+		test.Errorf("Unexpected value or name for EBUSY: %d %s", int(EBUSY), EBUSY.Name())
+	}
+
+	if Errno(0).String() != "unknown error code 0" || Errno(0).Name() != "" {
+		test.Errorf("Unexpected description for a non-negative code: %s", Errno(0).String())
+	}
+}
+
+func Test_Error_Errno(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := Init("nonExistingDevicePath")
+	testWrapper.AssertError(err)
+
+	cryptsetupError, ok := err.(*Error)
+	if !ok {
+		test.Fatalf("Expected *Error, but got: %T", err)
+	}
+
+	if cryptsetupError.Errno() != ENOTBLK {
+		test.Errorf("Expected ENOTBLK, but got: %s", cryptsetupError.Errno().Name())
+	}
+
+	expected := "cryptsetup: init on nonExistingDevicePath: libcryptsetup function 'crypt_init' returned error with code '-15' (block device required)."
+	if err.Error() != expected {
+		test.Errorf("Unexpected error message: %s", err.Error())
+	}
+}
@@ -1,18 +1,114 @@
 package cryptsetup
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
-// Error holds the name and the return value of a libcryptsetup function that was executed with an error.
+// ErrReadOnly is returned by operations that would write to the header of a device initialized with InitReadOnly,
+// or write-protected by SetHeaderReadOnly.
+var ErrReadOnly = errors.New("device was initialized read-only")
+
+// ErrTimeout is returned by operations that didn't complete within the allotted time.
+var ErrTimeout = errors.New("operation timed out")
+
+// ErrMetadataLocked matches, through errors.Is, the errors of operations that failed because another process
+// held the device's metadata lock. See Device.WaitForMetadataLock.
+var ErrMetadataLocked = errors.New("device metadata is locked by another process")
+
+// Error holds the name and the return value of a libcryptsetup function that was executed with an error,
+// along with the operation that was being performed, the device it was performed on, and the keyslot or token involved,
+// so errors of different devices can be told apart in logs.
 type Error struct {
 	code         int
 	functionName string
+	operation    string
+	devicePath   string
+	details      []string
 }
 
 func (e *Error) Error() string {
-	return fmt.Sprintf("libcryptsetup function '%s' returned error with code '%d'.", e.functionName, e.code)
+	message := fmt.Sprintf("libcryptsetup function '%s' returned error with code '%d' (%s).", e.functionName, e.code, e.Errno())
+	if e.operation == "" {
+		return message
+	}
+
+	context := e.operation
+	if e.devicePath != "" {
+		context += " on " + e.devicePath
+	}
+	for _, detail := range e.details {
+		context += ": " + detail
+	}
+	return fmt.Sprintf("cryptsetup: %s: %s", context, message)
 }
 
 // Code returns the error code returned by a libcryptsetup function.
 func (e *Error) Code() int {
 	return e.code
 }
+
+// Is reports whether the error matches 'target', so errors.Is(err, ErrMetadataLocked) detects metadata lock contention,
+// which libcryptsetup reports as EAGAIN.
+func (e *Error) Is(target error) bool {
+	return target == ErrMetadataLocked && e.Errno() == EAGAIN
+}
+
+// Operation returns the operation during which the error occurred, such as "activate luks-data" or "add keyslot".
+// Returns an empty string if the information is not available.
+func (e *Error) Operation() string {
+	return e.operation
+}
+
+// DevicePath returns the path of the device the failed operation was performed on.
+// Returns an empty string if the information is not available.
+func (e *Error) DevicePath() string {
+	return e.devicePath
+}
+
+// keyslotDetail describes the keyslot involved in an operation, for Error messages.
+func keyslotDetail(keyslot int) string {
+	if keyslot == CRYPT_ANY_SLOT {
+		return "any keyslot"
+	}
+	return fmt.Sprintf("keyslot %d", keyslot)
+}
+
+// tokenDetail describes the token involved in an operation, for Error messages.
+func tokenDetail(token int) string {
+	if token == CRYPT_ANY_TOKEN {
+		return "any token"
+	}
+	return fmt.Sprintf("token %d", token)
+}
+
+// activationOperation names the activation of a mapping named 'deviceName', which is only a check if the name is empty.
+func activationOperation(deviceName string) string {
+	if deviceName == "" {
+		return "check"
+	}
+	return "activate " + deviceName
+}
+
+// RequirementsError is returned when a LUKS2 header carries requirements that must be met before it may be modified,
+// such as an unfinished reencryption, or a requirement unknown to this version of libcryptsetup.
+type RequirementsError struct {
+	requirements uint32
+}
+
+func (e *RequirementsError) Error() string {
+	if e.Unknown() {
+		return fmt.Sprintf("device header has requirements unknown to libcryptsetup (flags '%#x').", e.requirements)
+	}
+	return fmt.Sprintf("device header has unmet requirements (flags '%#x').", e.requirements)
+}
+
+// Requirements returns the requirements flags stored in the header.
+func (e *RequirementsError) Requirements() uint32 {
+	return e.requirements
+}
+
+// Unknown reports whether the header carries requirements unknown to this version of libcryptsetup.
+func (e *RequirementsError) Unknown() bool {
+	return e.requirements&CRYPT_REQUIREMENT_UNKNOWN != 0
+}
@@ -0,0 +1,44 @@
+package cryptsetup
+
+import (
+	"strconv"
+	"testing"
+)
+
+func Test_Error_Context(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.ActivateByPassphrase(DeviceName, 2, "wrongPassphrase", 0)
+	testWrapper.AssertError(err)
+
+	cryptErr, ok := err.(*Error)
+	if !ok {
+		test.Fatalf("Expected *Error, but got: %T", err)
+	}
+
+	if cryptErr.Operation() != "activate "+DeviceName || cryptErr.DevicePath() != device.DevicePath() {
+		test.Errorf("Unexpected operation '%s' and device path '%s'.", cryptErr.Operation(), cryptErr.DevicePath())
+	}
+
+	expected := "cryptsetup: activate " + DeviceName + " on " + device.DevicePath() + ": keyslot 2: " +
+		"libcryptsetup function 'crypt_activate_by_passphrase' returned error with code '" + strconv.Itoa(cryptErr.Code()) + "' (" + cryptErr.Errno().String() + ")."
+	if err.Error() != expected {
+		test.Errorf("Unexpected error message: %s", err.Error())
+	}
+}
+
+func Test_Error_Without_Context(test *testing.T) {
+	err := &Error{functionName: "crypt_safe_alloc", code: int(ENOMEM)}
+
+	expected := "libcryptsetup function 'crypt_safe_alloc' returned error with code '-12' (cannot allocate memory)."
+	if err.Error() != expected {
+		test.Errorf("Unexpected error message: %s", err.Error())
+	}
+}
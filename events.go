@@ -0,0 +1,319 @@
+package cryptsetup
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Event is an operation reported to the EventSink set by SetEventSink, such as *KeyslotAdded.
+// Events are encoded to JSON with stable field names, and their type in the "event" field,
+// so they can be forwarded to an audit trail as they are.
+type Event interface {
+	// EventType names the kind of event, such as "keyslot_added".
+	EventType() string
+}
+
+// EventSink receives the events of all devices, so security teams can record tamper-relevant operations
+// without wrapping every call site.
+type EventSink interface {
+	HandleEvent(event Event)
+}
+
+// EventSinkFunc adapts a function to an EventSink.
+type EventSinkFunc func(event Event)
+
+// HandleEvent calls the function.
+func (sink EventSinkFunc) HandleEvent(event Event) {
+	sink(event)
+}
+
+// FormatStarted is reported when a device is about to be formatted, before its previous header is overwritten.
+type FormatStarted struct {
+	// Device is the path of the data device.
+	Device string `json:"device"`
+	// Type is the type the device is formatted as, such as "LUKS2".
+	Type Type `json:"type"`
+}
+
+// EventType returns "format_started".
+func (*FormatStarted) EventType() string {
+	return "format_started"
+}
+
+// MarshalJSON encodes the event with "format_started" in its "event" field.
+func (event FormatStarted) MarshalJSON() ([]byte, error) {
+	type fields FormatStarted
+	return marshalEvent(&event, fields(event))
+}
+
+// KeyslotAdded is reported once a keyslot was added, or changed to hold a new passphrase.
+type KeyslotAdded struct {
+	Device  string `json:"device"`
+	Keyslot int    `json:"keyslot"`
+}
+
+// EventType returns "keyslot_added".
+func (*KeyslotAdded) EventType() string {
+	return "keyslot_added"
+}
+
+// MarshalJSON encodes the event with "keyslot_added" in its "event" field.
+func (event KeyslotAdded) MarshalJSON() ([]byte, error) {
+	type fields KeyslotAdded
+	return marshalEvent(&event, fields(event))
+}
+
+// KeyslotDestroyed is reported once a keyslot was destroyed.
+type KeyslotDestroyed struct {
+	Device  string `json:"device"`
+	Keyslot int    `json:"keyslot"`
+}
+
+// EventType returns "keyslot_destroyed".
+func (*KeyslotDestroyed) EventType() string {
+	return "keyslot_destroyed"
+}
+
+// MarshalJSON encodes the event with "keyslot_destroyed" in its "event" field.
+func (event KeyslotDestroyed) MarshalJSON() ([]byte, error) {
+	type fields KeyslotDestroyed
+	return marshalEvent(&event, fields(event))
+}
+
+// Activated is reported once a mapping was activated. Checking a credential without activating a mapping is not reported.
+type Activated struct {
+	Device string `json:"device"`
+	// Name is the name of the mapping.
+	Name string `json:"name"`
+	// Keyslot is the keyslot that was unlocked, or -1 if the mapping was activated with its volume key.
+	Keyslot int `json:"keyslot"`
+}
+
+// EventType returns "activated".
+func (*Activated) EventType() string {
+	return "activated"
+}
+
+// MarshalJSON encodes the event with "activated" in its "event" field.
+func (event Activated) MarshalJSON() ([]byte, error) {
+	type fields Activated
+	return marshalEvent(&event, fields(event))
+}
+
+// Deactivated is reported once a mapping was deactivated.
+type Deactivated struct {
+	Device string `json:"device"`
+	// Name is the name of the mapping.
+	Name string `json:"name"`
+}
+
+// EventType returns "deactivated".
+func (*Deactivated) EventType() string {
+	return "deactivated"
+}
+
+// MarshalJSON encodes the event with "deactivated" in its "event" field.
+func (event Deactivated) MarshalJSON() ([]byte, error) {
+	type fields Deactivated
+	return marshalEvent(&event, fields(event))
+}
+
+// HeaderRestored is reported once the header and keyslot areas were restored from a backup, replacing all keyslots and tokens.
+type HeaderRestored struct {
+	Device string `json:"device"`
+}
+
+// EventType returns "header_restored".
+func (*HeaderRestored) EventType() string {
+	return "header_restored"
+}
+
+// MarshalJSON encodes the event with "header_restored" in its "event" field.
+func (event HeaderRestored) MarshalJSON() ([]byte, error) {
+	type fields HeaderRestored
+	return marshalEvent(&event, fields(event))
+}
+
+// HeaderWiped is reported once the header area, including all keyslot areas, was overwritten by WipeHeader.
+type HeaderWiped struct {
+	Device string `json:"device"`
+}
+
+// EventType returns "header_wiped".
+func (*HeaderWiped) EventType() string {
+	return "header_wiped"
+}
+
+// MarshalJSON encodes the event with "header_wiped" in its "event" field.
+func (event HeaderWiped) MarshalJSON() ([]byte, error) {
+	type fields HeaderWiped
+	return marshalEvent(&event, fields(event))
+}
+
+// TokenSet is reported once a token was stored in a token slot, or replaced.
+type TokenSet struct {
+	Device string `json:"device"`
+	Token  int    `json:"token"`
+}
+
+// EventType returns "token_set".
+func (*TokenSet) EventType() string {
+	return "token_set"
+}
+
+// MarshalJSON encodes the event with "token_set" in its "event" field.
+func (event TokenSet) MarshalJSON() ([]byte, error) {
+	type fields TokenSet
+	return marshalEvent(&event, fields(event))
+}
+
+// TokenRemoved is reported once a token was removed from its token slot.
+type TokenRemoved struct {
+	Device string `json:"device"`
+	Token  int    `json:"token"`
+}
+
+// EventType returns "token_removed".
+func (*TokenRemoved) EventType() string {
+	return "token_removed"
+}
+
+// MarshalJSON encodes the event with "token_removed" in its "event" field.
+func (event TokenRemoved) MarshalJSON() ([]byte, error) {
+	type fields TokenRemoved
+	return marshalEvent(&event, fields(event))
+}
+
+// TokenKeyslotAssigned is reported once a keyslot was assigned to a token.
+type TokenKeyslotAssigned struct {
+	Device string `json:"device"`
+	Token  int    `json:"token"`
+	// Keyslot is the keyslot that was assigned, or CRYPT_ANY_SLOT if all active keyslots were.
+	Keyslot int `json:"keyslot"`
+}
+
+// EventType returns "token_keyslot_assigned".
+func (*TokenKeyslotAssigned) EventType() string {
+	return "token_keyslot_assigned"
+}
+
+// MarshalJSON encodes the event with "token_keyslot_assigned" in its "event" field.
+func (event TokenKeyslotAssigned) MarshalJSON() ([]byte, error) {
+	type fields TokenKeyslotAssigned
+	return marshalEvent(&event, fields(event))
+}
+
+// marshalEvent encodes 'fields', the fields of 'event', preceded by an "event" field holding its type.
+func marshalEvent(event Event, fields interface{}) ([]byte, error) {
+	encodedType, err := json.Marshal(event.EventType())
+	if err != nil {
+		return nil, err
+	}
+	encodedFields, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := append([]byte(`{"event":`), encodedType...)
+	if len(encodedFields) > 2 {
+		encoded = append(encoded, ',')
+	}
+	return append(encoded, encodedFields[1:]...), nil
+}
+
+var (
+	eventLock sync.RWMutex
+	eventSink EventSink
+
+	// eventQueueLock guards eventQueue and eventDelivering. Events emitted while another one is delivered are queued,
+	// and delivered in order by the goroutine delivering it, which doesn't hold the lock while calling the sink.
+	eventQueueLock  sync.Mutex
+	eventQueue      []Event
+	eventDelivering bool
+)
+
+// SetEventSink sets the sink receiving the events of all devices, or removes it if 'sink' is nil.
+// Events are delivered once the operation they report completed, so sinks may timestamp them on receipt,
+// but should not block. Calls to the sink are serialized, so it need not be goroutine-safe: events emitted meanwhile,
+// by other goroutines or by the sink itself calling back into the package, such as Deactivate, are queued
+// and delivered in order once it returns, by the goroutine delivering the current event.
+// Keyslot and token changes are reported while the header is locked: a sink must not modify the keyslots or tokens
+// of the header an event reports about.
+func SetEventSink(sink EventSink) {
+	eventLock.Lock()
+	defer eventLock.Unlock()
+
+	eventSink = sink
+}
+
+// emitEvent delivers 'event' to the event sink, if one is set.
+func emitEvent(event Event) {
+	eventLock.RLock()
+	sink := eventSink
+	eventLock.RUnlock()
+
+	if sink == nil {
+		return
+	}
+
+	eventQueueLock.Lock()
+	eventQueue = append(eventQueue, event)
+	if eventDelivering {
+		eventQueueLock.Unlock()
+		return
+	}
+	eventDelivering = true
+	eventQueueLock.Unlock()
+
+	deliverEvents()
+}
+
+// deliverEvents delivers the queued events to the event sink, until the queue is empty.
+// If the sink panics, the remaining events are dropped, so later events are delivered again.
+func deliverEvents() {
+	delivered := false
+	defer func() {
+		if !delivered {
+			eventQueueLock.Lock()
+			eventQueue, eventDelivering = nil, false
+			eventQueueLock.Unlock()
+		}
+	}()
+
+	eventQueueLock.Lock()
+	for len(eventQueue) > 0 {
+		event := eventQueue[0]
+		eventQueue = eventQueue[1:]
+		eventQueueLock.Unlock()
+
+		eventLock.RLock()
+		sink := eventSink
+		eventLock.RUnlock()
+		if sink != nil {
+			sink.HandleEvent(event)
+		}
+
+		eventQueueLock.Lock()
+	}
+	eventDelivering = false
+	eventQueueLock.Unlock()
+	delivered = true
+}
+
+// emitActivated reports the activation of the mapping named 'deviceName' by unlocking 'keyslot',
+// unless 'deviceName' is empty, when the credential was only checked.
+func (device *Device) emitActivated(deviceName string, keyslot int) {
+	if deviceName != "" {
+		emitEvent(&Activated{Device: device.DevicePath(), Name: deviceName, Keyslot: keyslot})
+	}
+}
+
+// emitKeyslotsDestroyed reports the keyslots of 'before', as returned by keyslotsInUse, that are not in use anymore,
+// for operations that free keyslots as a side effect.
+func (device *Device) emitKeyslotsDestroyed(before []int) {
+	for _, keyslot := range before {
+		if device.KeyslotStatus(keyslot) == CRYPT_SLOT_INACTIVE {
+			emitEvent(&KeyslotDestroyed{Device: device.DevicePath(), Keyslot: keyslot})
+		}
+	}
+}
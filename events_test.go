@@ -0,0 +1,155 @@
+package cryptsetup
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_SetEventSink_Reports_Header_Operations(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	var events []Event
+	SetEventSink(EventSinkFunc(func(event Event) {
+		events = append(events, event)
+	}))
+	defer SetEventSink(nil)
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	device.restorePBKDFType(&PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK})
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "testPassphrase"))
+	testWrapper.AssertNoError(device.KeyslotAddByPassphrase(CRYPT_ANY_SLOT, "testPassphrase", "otherPassphrase"))
+	testWrapper.AssertNoError(device.KeyslotDestroy(1))
+
+	// Checking a passphrase activates nothing, and is not reported.
+	_, err = device.CheckPassphrase(0, "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	expected := []Event{
+		&FormatStarted{Device: DevicePath, Type: TypeLUKS1},
+		&KeyslotAdded{Device: DevicePath, Keyslot: 0},
+		&KeyslotAdded{Device: DevicePath, Keyslot: 1},
+		&KeyslotDestroyed{Device: DevicePath, Keyslot: 1},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		test.Errorf("Unexpected events: %+v", events)
+	}
+
+	encoded, err := json.Marshal(events[1])
+	testWrapper.AssertNoError(err)
+	if string(encoded) != `{"event":"keyslot_added","device":"testDevice","keyslot":0}` || events[1].EventType() != "keyslot_added" {
+		test.Errorf("Unexpected encoding of %s: %s", events[1].EventType(), encoded)
+	}
+}
+
+func Test_SetEventSink_Reports_Keyslot_Token_And_Header_Changes(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const backupPath = "testEventsHeaderBackup"
+	defer os.Remove(backupPath)
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	pbkdfType := &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK}
+	err = device.Format(LUKS2{SectorSize: 512, PBKDFType: pbkdfType}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "testPassphrase"))
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(1, "", "otherPassphrase"))
+
+	var events []Event
+	SetEventSink(EventSinkFunc(func(event Event) {
+		events = append(events, event)
+	}))
+	defer SetEventSink(nil)
+
+	testWrapper.AssertNoError(device.KeyslotChangeByPassphrase(0, 2, "testPassphrase", "newPassphrase"))
+	token, err := device.TokenJSONSet(CRYPT_ANY_TOKEN, `{"type":"test","keyslots":[]}`)
+	testWrapper.AssertNoError(err)
+	testWrapper.AssertNoError(device.TokenAssignKeyslot(token, 2))
+	testWrapper.AssertNoError(device.TokenRemove(token))
+	testWrapper.AssertNoError(device.Rekey(Passphrase{Keyslot: 2, Passphrase: "newPassphrase"}, pbkdfType, true))
+	testWrapper.AssertNoError(device.HeaderBackup(backupPath))
+	testWrapper.AssertNoError(device.WipeHeader())
+	testWrapper.AssertNoError(device.HeaderRestore(backupPath))
+
+	expected := []Event{
+		&KeyslotAdded{Device: DevicePath, Keyslot: 2},
+		&KeyslotDestroyed{Device: DevicePath, Keyslot: 0},
+		&TokenSet{Device: DevicePath, Token: token},
+		&TokenKeyslotAssigned{Device: DevicePath, Token: token, Keyslot: 2},
+		&TokenRemoved{Device: DevicePath, Token: token},
+		&KeyslotAdded{Device: DevicePath, Keyslot: 0},
+		&KeyslotDestroyed{Device: DevicePath, Keyslot: 1},
+		&KeyslotDestroyed{Device: DevicePath, Keyslot: 2},
+		&HeaderWiped{Device: DevicePath},
+		&HeaderRestored{Device: DevicePath},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		test.Errorf("Unexpected events: %+v", events)
+	}
+}
+
+func Test_SetEventSink_Sink_May_Call_Back_Into_The_Package(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const backupPath = "testEventsReentrantBackup"
+	defer os.Remove(backupPath)
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	testWrapper.AssertNoError(device.HeaderBackup(backupPath))
+
+	var events []Event
+	SetEventSink(EventSinkFunc(func(event Event) {
+		events = append(events, event)
+		if _, wiped := event.(*HeaderWiped); wiped {
+			testWrapper.AssertNoError(device.HeaderRestore(backupPath))
+			if len(events) != 1 {
+				test.Error("The events emitted by the sink should be delivered once it returns.")
+			}
+		}
+	}))
+	defer SetEventSink(nil)
+
+	testWrapper.AssertNoError(device.WipeHeader())
+
+	expected := []Event{
+		&HeaderWiped{Device: DevicePath},
+		&HeaderRestored{Device: DevicePath},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		test.Errorf("Unexpected events: %+v", events)
+	}
+}
+
+func Test_SetEventSink_Nil_Stops_Reporting(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	count := 0
+	SetEventSink(EventSinkFunc(func(event Event) {
+		count++
+	}))
+	SetEventSink(nil)
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	if count != 0 {
+		test.Errorf("No event should have been reported, got %d.", count)
+	}
+}
@@ -0,0 +1,153 @@
+package cryptsetup_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"cryptsetup"
+)
+
+// fastPBKDF keeps the examples quick. Real devices should keep libcryptsetup's benchmarked defaults.
+var fastPBKDF = &cryptsetup.PbkdfType{
+	Type:       cryptsetup.CRYPT_KDF_PBKDF2,
+	Hash:       "sha256",
+	Iterations: 1000,
+	Flags:      cryptsetup.CRYPT_PBKDF_NO_BENCHMARK,
+}
+
+// createImage creates a sparse image file of 'size' bytes, standing in for a block device.
+func createImage(size int64) string {
+	file, err := ioutil.TempFile("", "example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := file.Truncate(size); err != nil {
+		log.Fatal(err)
+	}
+	return file.Name()
+}
+
+// This example detaches the loop devices libcryptsetup attached to image files once the program is done with them,
+// including when it is interrupted: nothing detaches them at process exit otherwise.
+func ExampleStartLoopDeviceJanitor() {
+	stopJanitor := cryptsetup.StartLoopDeviceJanitor()
+	defer stopJanitor()
+
+	// Image files activated from here on get their loop devices detached once no longer mapped.
+}
+
+// This example formats a device with LUKS2, adds a passphrase, and maps the decrypted data to /dev/mapper/example.
+// Activating devices requires CAP_SYS_ADMIN, so it is not run.
+func Example_formatActivate() {
+	device, err := cryptsetup.Init("/dev/sdb")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer device.Free()
+
+	// The volume key is generated at random.
+	genericParams := cryptsetup.GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8}
+	if err := device.Format(cryptsetup.LUKS2{SectorSize: 4096}, genericParams); err != nil {
+		log.Fatal(err)
+	}
+
+	// An empty volume key uses the one generated by Format.
+	if err := device.KeyslotAddByVolumeKey(0, "", "passphrase"); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := device.ActivateByPassphrase("example", cryptsetup.CRYPT_ANY_SLOT, "passphrase", 0); err != nil {
+		log.Fatal(err)
+	}
+	defer device.Deactivate("example")
+
+	// /dev/mapper/example can now be formatted with a file system and mounted.
+}
+
+// This example replaces the passphrase of a device: the new passphrase is added to a free keyslot, and the keyslot
+// holding the old one is destroyed once the new passphrase was verified.
+func Example_keyslotRotation() {
+	image := createImage(32 << 20)
+	defer os.Remove(image)
+
+	device, err := cryptsetup.Init(image)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer device.Free()
+
+	genericParams := cryptsetup.GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8}
+	if err := device.Format(cryptsetup.LUKS2{PBKDFType: fastPBKDF}, genericParams); err != nil {
+		log.Fatal(err)
+	}
+	if err := device.KeyslotAddByVolumeKey(0, "", "old passphrase"); err != nil {
+		log.Fatal(err)
+	}
+
+	keyslot, err := device.RotatePassphrase("old passphrase", "new passphrase")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("new passphrase in keyslot", keyslot)
+
+	if _, err := device.CheckPassphrase(cryptsetup.CRYPT_ANY_SLOT, "old passphrase"); err != nil {
+		fmt.Println("old passphrase rejected")
+	}
+
+	// Output:
+	// new passphrase in keyslot 1
+	// old passphrase rejected
+}
+
+// This example keeps the LUKS2 header in a separate file, so the data device holds nothing but encrypted data,
+// and cannot be told apart from random data without the header.
+func Example_detachedHeader() {
+	header := createImage(16 << 20)
+	defer os.Remove(header)
+	data := createImage(16 << 20)
+	defer os.Remove(data)
+
+	device, err := cryptsetup.InitDataDevice(header, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer device.Free()
+
+	genericParams := cryptsetup.GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8}
+	if err := device.Format(cryptsetup.LUKS2{PBKDFType: fastPBKDF}, genericParams); err != nil {
+		log.Fatal(err)
+	}
+	if err := device.KeyslotAddByVolumeKey(0, "", "passphrase"); err != nil {
+		log.Fatal(err)
+	}
+
+	// Later, the header is loaded again from its file, along with the data device.
+	loaded, err := cryptsetup.InitDataDevice(header, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer loaded.Free()
+
+	if err := loaded.Load(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("type:", loaded.Type())
+	fmt.Println("data offset:", loaded.DataOffset())
+
+	// The data device itself has no header.
+	unformatted, err := cryptsetup.Init(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer unformatted.Free()
+	fmt.Println("data device has a header:", unformatted.Load() == nil)
+
+	// Output:
+	// type: LUKS2
+	// data offset: 0
+	// data device has a header: false
+}
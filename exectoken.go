@@ -0,0 +1,105 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ExecTokenHandler unlocks devices through LUKS2 tokens handled by an external helper, such as a clevis or
+// systemd-cryptsetup token plugin wrapper, bridging until libcryptsetup's native token plugins can be used.
+// The helper receives the token's JSON on its standard input, and the device's path, UUID and token number in the
+// CRYPTSETUP_DEVICE, CRYPTSETUP_UUID and CRYPTSETUP_TOKEN environment variables.
+// It must print the key on its standard output, and exit with a non-zero status if it cannot unlock the token.
+type ExecTokenHandler struct {
+	// TokenType is the type of the tokens handled by the helper, such as "clevis".
+	TokenType string
+	// Command is the path of the helper, and Args its arguments.
+	Command string
+	Args    []string
+	// Passphrase reports whether the helper prints a keyslot passphrase, with an optional trailing newline,
+	// instead of the raw volume key.
+	Passphrase bool
+}
+
+// ActivateByExecToken activates the device as 'deviceName' using the key returned by 'handler' for the device's tokens
+// of type handler.TokenType, trying them in order until one unlocks the device.
+// The volume key is passed to ActivateByVolumeKey, or the passphrase to ActivateByPassphrase, and wiped afterwards.
+// If 'deviceName' is empty, the key is only checked, and the device is not activated.
+// Returns nil on success, or the error of the last token tried otherwise.
+func (device *Device) ActivateByExecToken(ctx context.Context, deviceName string, handler ExecTokenHandler, flags int) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	tokens, err := device.Tokens()
+	if err != nil {
+		return err
+	}
+
+	err = fmt.Errorf("no token of type '%s' found on '%s'", handler.TokenType, device.DevicePath())
+	for _, token := range tokens {
+		if token.Type != handler.TokenType {
+			continue
+		}
+
+		if err = device.activateByExecToken(ctx, deviceName, handler, token.ID, flags); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// activateByExecToken activates the device using the key returned by 'handler' for 'token'.
+func (device *Device) activateByExecToken(ctx context.Context, deviceName string, handler ExecTokenHandler, token int, flags int) error {
+	tokenJSON, err := device.TokenJSONGet(token)
+	if err != nil {
+		return err
+	}
+
+	key, err := device.runExecTokenHandler(ctx, handler, token, tokenJSON)
+	if err != nil {
+		return err
+	}
+	defer WipeBytes(key)
+
+	if handler.Passphrase {
+		passphrase := bytes.TrimSuffix(bytes.TrimSuffix(key, []byte("\n")), []byte("\r"))
+		return device.ActivateByPassphraseBytes(deviceName, CRYPT_ANY_SLOT, passphrase, flags)
+	}
+
+	if len(key) == 0 {
+		return fmt.Errorf("token helper '%s' returned an empty volume key for token %d", handler.Command, token)
+	}
+	return device.ActivateByVolumeKeyBytes(deviceName, key, flags)
+}
+
+// runExecTokenHandler runs the helper of 'handler' for 'token', and returns what it printed on its standard output.
+func (device *Device) runExecTokenHandler(ctx context.Context, handler ExecTokenHandler, token int, tokenJSON string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	command := exec.CommandContext(ctx, handler.Command, handler.Args...)
+	command.Stdin = strings.NewReader(tokenJSON)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	command.Env = append(os.Environ(),
+		"CRYPTSETUP_DEVICE="+device.DevicePath(),
+		"CRYPTSETUP_UUID="+device.UUID(),
+		"CRYPTSETUP_TOKEN="+strconv.Itoa(token),
+	)
+
+	if err := command.Run(); err != nil {
+		WipeBytes(stdout.Bytes())
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("token helper '%s' failed for token %d: %v: %s", handler.Command, token, err, message)
+		}
+		return nil, fmt.Errorf("token helper '%s' failed for token %d: %v", handler.Command, token, err)
+	}
+
+	return stdout.Bytes(), nil
+}
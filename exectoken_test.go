@@ -0,0 +1,72 @@
+package cryptsetup
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_Device_ActivateByExecToken(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	pbkdfType := &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK}
+	err = device.Format(LUKS2{SectorSize: 512, PBKDFType: pbkdfType}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+	_, err = device.TokenJSONSet(CRYPT_ANY_TOKEN, `{"type":"go-cryptsetup-exec","keyslots":["0"],"secret":"testPassphrase"}`)
+	testWrapper.AssertNoError(err)
+
+	handler := ExecTokenHandler{
+		TokenType:  "go-cryptsetup-exec",
+		Command:    "/bin/sh",
+		Args:       []string{"-c", `sed 's/.*"secret":"\([^"]*\)".*/\1/'`},
+		Passphrase: true,
+	}
+	err = device.ActivateByExecToken(context.Background(), "", handler, 0)
+	testWrapper.AssertNoError(err)
+
+	volumeKey, _, err := device.VolumeKeyGet(0, "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	keyFile, err := ioutil.TempFile("", "volumeKey")
+	testWrapper.AssertNoError(err)
+	defer os.Remove(keyFile.Name())
+	_, err = keyFile.Write(volumeKey)
+	testWrapper.AssertNoError(err)
+	keyFile.Close()
+
+	handler = ExecTokenHandler{TokenType: "go-cryptsetup-exec", Command: "/bin/cat", Args: []string{keyFile.Name()}}
+	err = device.ActivateByExecToken(context.Background(), "", handler, 0)
+	testWrapper.AssertNoError(err)
+}
+
+func Test_Device_ActivateByExecToken_Fails(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	pbkdfType := &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK}
+	err = device.Format(LUKS2{SectorSize: 512, PBKDFType: pbkdfType}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	handler := ExecTokenHandler{TokenType: "go-cryptsetup-exec", Command: "/bin/sh", Args: []string{"-c", "echo 'no TPM found' >&2; exit 1"}}
+	err = device.ActivateByExecToken(context.Background(), "", handler, 0)
+	testWrapper.AssertError(err)
+
+	_, err = device.TokenJSONSet(CRYPT_ANY_TOKEN, `{"type":"go-cryptsetup-exec","keyslots":[]}`)
+	testWrapper.AssertNoError(err)
+
+	err = device.ActivateByExecToken(context.Background(), "", handler, 0)
+	if err == nil || !strings.Contains(err.Error(), "no TPM found") {
+		test.Errorf("The helper's error output should have been reported, but got: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import "fmt"
+
+// FormatLike formats the device with the same type, cipher, volume key size and key derivation parameters as the LUKS
+// device 'template', and copies its persistent activation flags, so that a fleet of devices can be provisioned consistently
+// from a reference header.
+// Cipher, CipherMode and VolumeKeySize are taken from 'genericParams' when set, and from 'template' otherwise;
+// UUID, VolumeKey and WipeSignatures are always taken from 'genericParams', so the volume key of another device may be reused.
+// The PBKDF, hash and cost of the template's first active keyslot are applied without benchmarking to the keyslots added afterwards.
+// Labels and the data offset are not copied, since they are specific to each device.
+// Returns nil on success, or an error otherwise.
+func (device *Device) FormatLike(template *Device, genericParams GenericParams) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	if genericParams.Cipher == "" && genericParams.CipherMode == "" {
+		genericParams.Cipher = C.GoString(C.crypt_get_cipher(template.cryptDevice))
+		genericParams.CipherMode = C.GoString(C.crypt_get_cipher_mode(template.cryptDevice))
+	}
+	if genericParams.VolumeKeySize == 0 {
+		genericParams.VolumeKeySize = template.VolumeKeySize()
+	}
+
+	pbkdfType, err := template.templatePBKDFType()
+	if err != nil {
+		return err
+	}
+
+	var deviceType DeviceType
+	switch template.Type() {
+	case TypeLUKS1:
+		luks1 := LUKS1{Hash: "sha256"}
+		if pbkdfType != nil {
+			luks1.Hash = pbkdfType.Hash
+		}
+		deviceType = luks1
+	case TypeLUKS2:
+		metadataSize, keyslotsSize, err := template.MetadataSize()
+		if err != nil {
+			return err
+		}
+		deviceType = LUKS2{
+			PBKDFType:    pbkdfType,
+			SectorSize:   uint32(C.crypt_get_sector_size(template.cryptDevice)),
+			MetadataSize: metadataSize,
+			KeyslotsSize: keyslotsSize,
+		}
+	default:
+		return fmt.Errorf("cannot format like a device of type '%s'", template.Type())
+	}
+
+	if err := device.Format(deviceType, genericParams); err != nil {
+		return err
+	}
+
+	if pbkdfType != nil {
+		device.restorePBKDFType(pbkdfType)
+	}
+
+	if deviceType.Name() == CRYPT_LUKS2 {
+		flags, err := template.PersistentFlags(CRYPT_FLAGS_ACTIVATION)
+		if err != nil {
+			return err
+		}
+		if flags != 0 {
+			return device.SetPersistentFlags(CRYPT_FLAGS_ACTIVATION, flags)
+		}
+	}
+
+	return nil
+}
+
+// templatePBKDFType returns the key derivation parameters of the first active keyslot, with benchmarking disabled so
+// that they are applied as is, or nil if no keyslot is active.
+func (device *Device) templatePBKDFType() (*PbkdfType, error) {
+	for keyslot := 0; keyslot < device.KeyslotMax(); keyslot++ {
+		if status := device.KeyslotStatus(keyslot); status != CRYPT_SLOT_ACTIVE && status != CRYPT_SLOT_ACTIVE_LAST {
+			continue
+		}
+
+		info, err := device.KeyslotPBKDFInfo(keyslot)
+		if err != nil {
+			return nil, err
+		}
+
+		return &PbkdfType{
+			Type:            info.Type,
+			Hash:            info.Hash,
+			Iterations:      info.Iterations,
+			MaxMemoryKb:     info.MaxMemoryKb,
+			ParallelThreads: info.ParallelThreads,
+			Flags:           CRYPT_PBKDF_NO_BENCHMARK,
+		}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,74 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_Device_FormatLike(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	template, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer template.Free()
+
+	err = template.Format(LUKS2{
+		SectorSize: 4096,
+		PBKDFType:  &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha512", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK},
+	}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 256 / 8})
+	testWrapper.AssertNoError(err)
+	err = template.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+	err = template.SetPersistentFlags(CRYPT_FLAGS_ACTIVATION, CRYPT_ACTIVATE_ALLOW_DISCARDS)
+	testWrapper.AssertNoError(err)
+
+	file, err := ioutil.TempFile("", "formatlike")
+	testWrapper.AssertNoError(err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+	err = file.Truncate(32 * 1024 * 1024)
+	testWrapper.AssertNoError(err)
+
+	device, err := Init(file.Name())
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.FormatLike(template, GenericParams{})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "otherPassphrase")
+	testWrapper.AssertNoError(err)
+
+	if device.Type() != CRYPT_LUKS2 || device.VolumeKeySize() != 256/8 {
+		test.Errorf("Unexpected type or volume key size: %s, %d", device.Type(), device.VolumeKeySize())
+	}
+
+	info, err := device.KeyslotPBKDFInfo(0)
+	testWrapper.AssertNoError(err)
+	if info.Type != CRYPT_KDF_PBKDF2 || info.Hash != "sha512" || info.Iterations != 1000 {
+		test.Errorf("The keyslot PBKDF was not copied: %+v", info)
+	}
+
+	flags, err := device.PersistentFlags(CRYPT_FLAGS_ACTIVATION)
+	testWrapper.AssertNoError(err)
+	if flags != CRYPT_ACTIVATE_ALLOW_DISCARDS {
+		test.Errorf("The persistent flags were not copied: %d", flags)
+	}
+}
+
+func Test_Device_FormatLike_Fails_If_Template_Is_Not_LUKS(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	template, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer template.Free()
+
+	err = template.Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "cbc-essiv:sha256", VolumeKeySize: 256 / 8})
+	testWrapper.AssertNoError(err)
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	testWrapper.AssertError(device.FormatLike(template, GenericParams{}))
+}
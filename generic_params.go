@@ -7,4 +7,23 @@ type GenericParams struct {
 	UUID          string
 	VolumeKey     string
 	VolumeKeySize int
+	// WipeSignatures makes Format zero the magic strings of the signatures reported by ProbeSignatures before writing the header,
+	// like `cryptsetup luksFormat` does, so stale filesystem superblocks don't confuse mount tooling later.
+	// It is ignored for PLAIN devices, whose Format writes nothing to the device.
+	WipeSignatures bool
+}
+
+// FillDefaultValues sets Cipher, CipherMode and VolumeKeySize to the application-wide defaults, if they are unset.
+// The defaults are configured by SetDefaultGenericParams.
+func (genericParams *GenericParams) FillDefaultValues() {
+	defaults := DefaultGenericParams()
+
+	if genericParams.Cipher == "" && genericParams.CipherMode == "" {
+		genericParams.Cipher = defaults.Cipher
+		genericParams.CipherMode = defaults.CipherMode
+	}
+
+	if genericParams.VolumeKeySize == 0 {
+		genericParams.VolumeKeySize = defaults.VolumeKeySize
+	}
 }
@@ -0,0 +1,96 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unsafe"
+)
+
+// HeaderBackup stores a binary backup of the device's LUKS header and keyslot areas in 'backupPath'.
+// The backup file must not exist yet.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_header_backup
+func (device *Device) HeaderBackup(backupPath string) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	cBackupPath := C.CString(backupPath)
+	defer C.free(unsafe.Pointer(cBackupPath))
+
+	err := C.crypt_header_backup(device.cryptDevice, nil, cBackupPath)
+	if err < 0 {
+		return device.newError("crypt_header_backup", int(err), "back up header to "+backupPath)
+	}
+
+	return nil
+}
+
+// HeaderRestore restores the device's LUKS header and keyslot areas from the backup in 'backupPath'.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_header_restore
+func (device *Device) HeaderRestore(backupPath string) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	cBackupPath := C.CString(backupPath)
+	defer C.free(unsafe.Pointer(cBackupPath))
+
+	err := C.crypt_header_restore(device.cryptDevice, nil, cBackupPath)
+	if err < 0 {
+		return device.newError("crypt_header_restore", int(err), "restore header from "+backupPath)
+	}
+
+	emitEvent(&HeaderRestored{Device: device.DevicePath()})
+	return nil
+}
+
+// HeaderBackupToWriter streams a binary backup of the device's LUKS header and keyslot areas to 'writer',
+// so backups can go straight to remote storage.
+// The backup is staged in a temporary file in TempDir, and removed before returning.
+// Returns nil on success, or an error otherwise.
+func (device *Device) HeaderBackupToWriter(writer io.Writer) error {
+	temporaryDirectory, err := ioutil.TempDir(TempDir(), "cryptsetup-backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(temporaryDirectory)
+
+	backupPath := filepath.Join(temporaryDirectory, "header")
+	if err := device.HeaderBackup(backupPath); err != nil {
+		return err
+	}
+
+	backupFile, err := os.Open(backupPath)
+	if err != nil {
+		return err
+	}
+	defer backupFile.Close()
+
+	_, err = io.Copy(writer, backupFile)
+	return err
+}
+
+// HeaderRestoreFromReader restores the device's LUKS header and keyslot areas from a backup read from 'reader'.
+// The backup is staged in an anonymous memory-backed file, and never touches persistent storage.
+// Returns nil on success, or an error otherwise.
+func (device *Device) HeaderRestoreFromReader(reader io.Reader) error {
+	backupFile, backupPath, err := newMemFile("cryptsetup-backup")
+	if err != nil {
+		return err
+	}
+	defer backupFile.Close()
+
+	if _, err := io.Copy(backupFile, reader); err != nil {
+		return err
+	}
+
+	return device.HeaderRestore(backupPath)
+}
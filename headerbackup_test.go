@@ -0,0 +1,79 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func Test_HeaderBackup_HeaderRestore(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const backupPath = "testHeaderBackup"
+	defer os.Remove(backupPath)
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.HeaderBackup(backupPath)
+	testWrapper.AssertNoError(err)
+
+	err = device.KeyslotDestroy(0)
+	testWrapper.AssertNoError(err)
+
+	err = device.HeaderRestore(backupPath)
+	testWrapper.AssertNoError(err)
+
+	_, err = device.CheckPassphrase(0, "testPassphrase")
+	testWrapper.AssertNoError(err)
+}
+
+func Test_HeaderBackupToWriter_HeaderRestoreFromReader(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	var backup bytes.Buffer
+	err = device.HeaderBackupToWriter(&backup)
+	testWrapper.AssertNoError(err)
+
+	if backup.Len() == 0 {
+		test.Error("Header backup should not be empty.")
+	}
+
+	err = device.KeyslotDestroy(0)
+	testWrapper.AssertNoError(err)
+
+	err = device.HeaderRestoreFromReader(&backup)
+	testWrapper.AssertNoError(err)
+
+	_, err = device.CheckPassphrase(0, "testPassphrase")
+	testWrapper.AssertNoError(err)
+}
+
+func Test_HeaderRestoreFromReader_Fails_For_Invalid_Backup(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.HeaderRestoreFromReader(bytes.NewReader(make([]byte, 4096)))
+	testWrapper.AssertError(err)
+}
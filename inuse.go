@@ -0,0 +1,158 @@
+package cryptsetup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// procMountInfoPath lists the mounts of the process' mount namespace.
+var procMountInfoPath = "/proc/self/mountinfo"
+
+// ErrDeviceInUse is matched, through errors.Is, by the *DeviceInUseError returned when formatting or wiping a device in use.
+var ErrDeviceInUse = errors.New("device is in use")
+
+// DeviceInUseError is returned by Format when the data device, or one of its partitions, is mounted
+// or is the backing device of an active mapping, and by WipeMappedDevice when the mapping is open.
+type DeviceInUseError struct {
+	path   string
+	reason string
+}
+
+func (e *DeviceInUseError) Error() string {
+	return fmt.Sprintf("cryptsetup: device '%s' is in use: %s", e.path, e.reason)
+}
+
+// Is reports whether 'target' is ErrDeviceInUse.
+func (e *DeviceInUseError) Is(target error) bool {
+	return target == ErrDeviceInUse
+}
+
+// Reason describes how the device is used, such as "sda1 is mounted".
+func (e *DeviceInUseError) Reason() string {
+	return e.reason
+}
+
+// WithForce makes Format, DryRun.Format, FormatAndWipe and WipeMappedDevice proceed even if the device is in use.
+func WithForce() Option {
+	return func(options *options) {
+		options.force = true
+	}
+}
+
+// CheckNotInUse returns a *DeviceInUseError if the data device, or one of its partitions, is mounted,
+// or has holders such as device-mapper mappings. Image files are checked through the loop device they are attached to.
+// Format runs this check itself: call it before destructive steps preceding Format, such as wiping the device.
+// Returns nil if the device is not in use, or an error otherwise.
+func (device *Device) CheckNotInUse() error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	info, err := device.DataDeviceInfo()
+	if err != nil {
+		return err
+	}
+	if info.BlockDevice == "" {
+		return nil
+	}
+
+	reason, err := blockDeviceUsage(info.BlockDevice)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return &DeviceInUseError{path: info.Path, reason: reason}
+	}
+
+	return nil
+}
+
+// blockDeviceUsage describes how the block device named 'name' in sysfs, or one of its partitions, is used.
+// Returns an empty string if it is not used.
+func blockDeviceUsage(name string) (string, error) {
+	devicePaths, err := blockDeviceAndPartitions(filepath.Join(sysfsPath, "class", "block", name))
+	if err != nil {
+		return "", err
+	}
+
+	names := make(map[string]string, len(devicePaths))
+	for number, devicePath := range devicePaths {
+		holders, err := ioutil.ReadDir(filepath.Join(devicePath, "holders"))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if len(holders) > 0 {
+			return fmt.Sprintf("%s is held by %s", filepath.Base(devicePath), holders[0].Name()), nil
+		}
+		names[number] = filepath.Base(devicePath)
+	}
+
+	mountPoint, device, err := findMount(names)
+	if err != nil {
+		return "", err
+	}
+	if mountPoint != "" {
+		return fmt.Sprintf("%s is mounted on %s", device, mountPoint), nil
+	}
+
+	return "", nil
+}
+
+// blockDeviceAndPartitions returns the sysfs directories of the block device in 'path' and of its partitions,
+// indexed by their "major:minor" device numbers.
+func blockDeviceAndPartitions(path string) (map[string]string, error) {
+	devicePaths := make(map[string]string)
+
+	number, err := ioutil.ReadFile(filepath.Join(path, "dev"))
+	if err != nil {
+		return nil, err
+	}
+	devicePaths[strings.TrimSpace(string(number))] = path
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		partitionPath := filepath.Join(path, entry.Name())
+		if _, err := os.Stat(filepath.Join(partitionPath, "partition")); err != nil {
+			continue
+		}
+		number, err := ioutil.ReadFile(filepath.Join(partitionPath, "dev"))
+		if err != nil {
+			return nil, err
+		}
+		devicePaths[strings.TrimSpace(string(number))] = partitionPath
+	}
+
+	return devicePaths, nil
+}
+
+// findMount looks for a mount of one of the devices in 'names', indexed by their "major:minor" device numbers, in procMountInfoPath.
+// Returns the mount point and the name of the mounted device, or empty strings if none is mounted.
+func findMount(names map[string]string) (string, string, error) {
+	file, err := os.Open(procMountInfoPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// <mount ID> <parent ID> <major:minor> <root> <mount point> ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		if name, found := names[fields[2]]; found {
+			return fields[4], name, nil
+		}
+	}
+
+	return "", "", scanner.Err()
+}
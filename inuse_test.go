@@ -0,0 +1,79 @@
+package cryptsetup
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupInUseSysfs(test *testing.T, mountInfo string) func() {
+	testWrapper := TestWrapper{test}
+
+	directory, err := ioutil.TempDir("", "inuse")
+	testWrapper.AssertNoError(err)
+
+	previousSysfsPath, previousProcMountInfoPath := sysfsPath, procMountInfoPath
+	sysfsPath, procMountInfoPath = directory, filepath.Join(directory, "mountinfo")
+
+	sda := filepath.Join(directory, "class", "block", "sda")
+	testWrapper.AssertNoError(os.MkdirAll(filepath.Join(sda, "holders"), 0755))
+	testWrapper.AssertNoError(os.MkdirAll(filepath.Join(sda, "sda1", "holders"), 0755))
+	testWrapper.AssertNoError(ioutil.WriteFile(filepath.Join(sda, "dev"), []byte("8:0\n"), 0644))
+	testWrapper.AssertNoError(ioutil.WriteFile(filepath.Join(sda, "sda1", "dev"), []byte("8:1\n"), 0644))
+	testWrapper.AssertNoError(ioutil.WriteFile(filepath.Join(sda, "sda1", "partition"), []byte("1\n"), 0644))
+	testWrapper.AssertNoError(ioutil.WriteFile(procMountInfoPath, []byte(mountInfo), 0644))
+
+	return func() {
+		sysfsPath, procMountInfoPath = previousSysfsPath, previousProcMountInfoPath
+		os.RemoveAll(directory)
+	}
+}
+
+func Test_blockDeviceUsage(test *testing.T) {
+	testWrapper := TestWrapper{test}
+	defer setupInUseSysfs(test, "22 1 0:21 / /proc rw,nosuid - proc proc rw\n")()
+
+	reason, err := blockDeviceUsage("sda")
+	testWrapper.AssertNoError(err)
+	if reason != "" {
+		test.Errorf("Device should not have been in use, but: %s", reason)
+	}
+
+	testWrapper.AssertNoError(os.MkdirAll(filepath.Join(sysfsPath, "class", "block", "sda", "sda1", "holders", "dm-0"), 0755))
+	reason, err = blockDeviceUsage("sda")
+	testWrapper.AssertNoError(err)
+	if reason != "sda1 is held by dm-0" {
+		test.Errorf("Unexpected reason: %s", reason)
+	}
+}
+
+func Test_blockDeviceUsage_Mounted_Partition(test *testing.T) {
+	testWrapper := TestWrapper{test}
+	defer setupInUseSysfs(test, "36 1 8:1 / /home rw,relatime shared:1 - ext4 /dev/sda1 rw\n")()
+
+	reason, err := blockDeviceUsage("sda")
+	testWrapper.AssertNoError(err)
+	if reason != "sda1 is mounted on /home" {
+		test.Errorf("Unexpected reason: %s", reason)
+	}
+}
+
+func Test_DeviceInUseError_Is_ErrDeviceInUse(test *testing.T) {
+	var err error = &DeviceInUseError{path: "/dev/sda", reason: "sda1 is mounted on /home"}
+	if !errors.Is(err, ErrDeviceInUse) {
+		test.Error("DeviceInUseError should match ErrDeviceInUse.")
+	}
+}
+
+func Test_Device_Format_WithForce(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8}, WithForce())
+	testWrapper.AssertNoError(err)
+}
@@ -0,0 +1,180 @@
+package cryptsetup
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Operations recorded in a Journal.
+const (
+	JournalOperationFormat         = "format"
+	JournalOperationKeyslotDestroy = "keyslot-destroy"
+	JournalOperationReencrypt      = "reencrypt"
+)
+
+// JournalEntry records a destructive operation that was started.
+type JournalEntry struct {
+	ID         uint64    `json:"id"`
+	Operation  string    `json:"operation"`
+	DevicePath string    `json:"device_path"`
+	UUID       string    `json:"uuid,omitempty"`
+	Keyslot    int       `json:"keyslot"`
+	PID        int       `json:"pid"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// Journal is a small state file recording destructive operations while they are in progress,
+// so that a supervisor restarted after the process was killed can detect and report half-completed operations.
+// Entries are removed once the operation returns, whether it succeeded or not, since its outcome was then observed.
+// The file is replaced atomically on every update.
+type Journal struct {
+	path string
+	lock sync.Mutex
+}
+
+// NewJournal returns a Journal stored in the file in 'path'. The file is created when the first operation begins.
+func NewJournal(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// Pending returns the entries of the operations that were started, but did not complete.
+// Returns the entries on success, or an error otherwise.
+func (journal *Journal) Pending() ([]JournalEntry, error) {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+
+	return journal.read()
+}
+
+// Begin records the start of an operation, assigning the entry a new ID and filling in its PID and start time.
+// Returns the entry's ID on success, or an error otherwise.
+func (journal *Journal) Begin(entry JournalEntry) (uint64, error) {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+
+	entries, err := journal.read()
+	if err != nil {
+		return 0, err
+	}
+
+	entry.ID = 1
+	for _, pending := range entries {
+		if pending.ID >= entry.ID {
+			entry.ID = pending.ID + 1
+		}
+	}
+	entry.PID = os.Getpid()
+	entry.StartedAt = time.Now()
+
+	return entry.ID, journal.write(append(entries, entry))
+}
+
+// Complete removes the entry with the given ID, once its operation returned.
+// Returns nil on success, or an error otherwise.
+func (journal *Journal) Complete(id uint64) error {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+
+	entries, err := journal.read()
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]JournalEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.ID != id {
+			remaining = append(remaining, entry)
+		}
+	}
+
+	return journal.write(remaining)
+}
+
+// Clear removes all entries, such as after half-completed operations were reported.
+// Returns nil on success, or an error otherwise.
+func (journal *Journal) Clear() error {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+
+	if err := os.Remove(journal.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// read reads the journal's entries. A missing file holds no entries. The lock must be held.
+func (journal *Journal) read() ([]JournalEntry, error) {
+	data, err := ioutil.ReadFile(journal.path)
+	if os.IsNotExist(err) {
+		return []JournalEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []JournalEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// write atomically replaces the journal's entries, syncing them to disk before renaming the file into place.
+// The file is removed when no entries remain. The lock must be held.
+func (journal *Journal) write(entries []JournalEntry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(journal.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(journal.path), filepath.Base(journal.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), journal.path)
+}
+
+// SetJournal sets the Journal recording the device's destructive operations, Format and KeyslotDestroy, while they are in progress.
+// A nil journal disables journaling.
+func (device *Device) SetJournal(journal *Journal) {
+	device.journal = journal
+}
+
+// beginJournaled records the start of a destructive operation in the device's journal, if any.
+// Returns the function to call once the operation returned, or an error if the operation could not be recorded.
+func (device *Device) beginJournaled(operation string, keyslot int) (func(), error) {
+	if device.journal == nil {
+		return func() {}, nil
+	}
+
+	journal := device.journal
+	id, err := journal.Begin(JournalEntry{Operation: operation, DevicePath: device.DevicePath(), UUID: device.UUID(), Keyslot: keyslot})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() { journal.Complete(id) }, nil
+}
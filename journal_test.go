@@ -0,0 +1,72 @@
+package cryptsetup
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_Journal_Begin_Complete(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const journalPath = "testJournal"
+	defer os.Remove(journalPath)
+
+	journal := NewJournal(journalPath)
+
+	first, err := journal.Begin(JournalEntry{Operation: JournalOperationKeyslotDestroy, DevicePath: DevicePath, Keyslot: 1})
+	testWrapper.AssertNoError(err)
+	second, err := journal.Begin(JournalEntry{Operation: JournalOperationReencrypt, DevicePath: DevicePath})
+	testWrapper.AssertNoError(err)
+
+	err = journal.Complete(first)
+	testWrapper.AssertNoError(err)
+
+	// A supervisor restarted after the process was killed reads the same file.
+	pending, err := NewJournal(journalPath).Pending()
+	testWrapper.AssertNoError(err)
+
+	if len(pending) != 1 || pending[0].ID != second || pending[0].Operation != JournalOperationReencrypt || pending[0].PID != os.Getpid() {
+		test.Errorf("Unexpected pending entries: %+v", pending)
+	}
+
+	err = journal.Clear()
+	testWrapper.AssertNoError(err)
+
+	pending, err = journal.Pending()
+	testWrapper.AssertNoError(err)
+	if len(pending) != 0 {
+		test.Errorf("A cleared journal should have no pending entries: %+v", pending)
+	}
+}
+
+func Test_Device_SetJournal(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const journalPath = "testJournal"
+	defer os.Remove(journalPath)
+
+	journal := NewJournal(journalPath)
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	device.SetJournal(journal)
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotDestroy(0)
+	testWrapper.AssertNoError(err)
+
+	pending, err := journal.Pending()
+	testWrapper.AssertNoError(err)
+	if len(pending) != 0 {
+		test.Errorf("Completed operations should not be pending: %+v", pending)
+	}
+
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		test.Error("An empty journal should have been removed.")
+	}
+}
@@ -0,0 +1,93 @@
+package cryptsetup
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+)
+
+// MinKeyfileSize is the smallest size accepted by GenerateKeyfile, in bytes, so generated key files hold at least 256 bits of entropy.
+const MinKeyfileSize = 32
+
+// GenerateKeyfile writes 'size' random bytes read from crypto/rand to a new file in 'path', syncs it to disk,
+// and then restricts its permissions to 'perm', or to 0400 if 'perm' is 0, so it can be used as a key file.
+// An existing file is never overwritten. The file is removed if any step fails.
+// Returns nil on success, or an error otherwise.
+func GenerateKeyfile(path string, size int, perm os.FileMode) error {
+	key, err := generateKeyfile(path, size, perm)
+	WipeBytes(key)
+	return err
+}
+
+// AddKeyfile generates a key file like GenerateKeyfile does, and stores its contents in 'keyslot', in one call.
+// Use CRYPT_ANY_SLOT to store it in the first free keyslot.
+// 'credential' must already unlock the device and implement KeyslotAdder, such as Passphrase or VolumeKey.
+// The key file is removed if the keyslot cannot be added.
+// Returns the number of the keyslot the key file was stored in on success, or an error otherwise.
+func (device *Device) AddKeyfile(credential Credential, keyslot int, path string, size int, perm os.FileMode) (int, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
+	}
+
+	adder, ok := credential.(KeyslotAdder)
+	if !ok {
+		return 0, fmt.Errorf("credential of type '%T' cannot be used to add keyslots", credential)
+	}
+
+	key, err := generateKeyfile(path, size, perm)
+	defer WipeBytes(key)
+	if err != nil {
+		return 0, err
+	}
+
+	keyslot, err = adder.KeyslotAdd(device, keyslot, key)
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+
+	return keyslot, nil
+}
+
+// generateKeyfile is like GenerateKeyfile, but also returns the generated key, which the caller must wipe.
+func generateKeyfile(path string, size int, perm os.FileMode) ([]byte, error) {
+	if size < MinKeyfileSize {
+		return nil, fmt.Errorf("key file size must be at least %d bytes, got %d", MinKeyfileSize, size)
+	}
+	if perm == 0 {
+		perm = 0400
+	}
+
+	key := make([]byte, size)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return key, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return key, err
+	}
+
+	err = writeKeyfile(file, key, perm)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return key, err
+	}
+
+	return key, nil
+}
+
+// writeKeyfile writes 'key' to 'file', syncs it, and then sets its permissions to 'perm'.
+func writeKeyfile(file *os.File, key []byte, perm os.FileMode) error {
+	if _, err := file.Write(key); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	return file.Chmod(perm)
+}
@@ -0,0 +1,65 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_GenerateKeyfile(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	directory, err := ioutil.TempDir("", "keyfile")
+	testWrapper.AssertNoError(err)
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "key")
+	err = GenerateKeyfile(path, 4096, 0)
+	testWrapper.AssertNoError(err)
+
+	info, err := os.Stat(path)
+	testWrapper.AssertNoError(err)
+	if info.Size() != 4096 || info.Mode().Perm() != 0400 {
+		test.Errorf("Unexpected key file size or permissions: %d, %v", info.Size(), info.Mode().Perm())
+	}
+
+	testWrapper.AssertError(GenerateKeyfile(path, 4096, 0))
+	testWrapper.AssertError(GenerateKeyfile(filepath.Join(directory, "short"), MinKeyfileSize-1, 0))
+	if _, err := os.Stat(filepath.Join(directory, "short")); !os.IsNotExist(err) {
+		test.Error("No key file should have been created for a short key.")
+	}
+}
+
+func Test_Device_AddKeyfile(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	directory, err := ioutil.TempDir("", "keyfile")
+	testWrapper.AssertNoError(err)
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "key")
+	keyslot, err := device.AddKeyfile(Passphrase{Keyslot: 0, Passphrase: "testPassphrase"}, CRYPT_ANY_SLOT, path, 512, 0)
+	testWrapper.AssertNoError(err)
+	if keyslot != 1 {
+		test.Errorf("Expected the key file to be stored in keyslot 1, but got: %d", keyslot)
+	}
+
+	err = device.ActivateByKeyfile("", keyslot, path, 0, 0, 0)
+	testWrapper.AssertNoError(err)
+
+	_, err = device.AddKeyfile(Passphrase{Keyslot: 0, Passphrase: "wrongPassphrase"}, CRYPT_ANY_SLOT, filepath.Join(directory, "other"), 512, 0)
+	testWrapper.AssertError(err)
+	if _, err := os.Stat(filepath.Join(directory, "other")); !os.IsNotExist(err) {
+		test.Error("The key file should have been removed after failing to add the keyslot.")
+	}
+}
@@ -0,0 +1,60 @@
+package cryptsetup
+
+import (
+	"context"
+	"errors"
+)
+
+// KeyProvider is implemented by external key management services, such as cloud KMS or Vault, holding volume keys,
+// so integrations can live outside of this package.
+type KeyProvider interface {
+	// GetVolumeKey returns the volume key of the device with the given UUID.
+	// The returned slice is wiped once it has been used.
+	GetVolumeKey(ctx context.Context, deviceUUID string) ([]byte, error)
+}
+
+// errEmptyProvidedVolumeKey is returned when a KeyProvider returns an empty volume key.
+var errEmptyProvidedVolumeKey = errors.New("key provider returned an empty volume key")
+
+// AddKeyslotFromProvider adds a keyslot holding 'passphrase', using the volume key returned by 'provider' for the device's UUID.
+// Returns the number of the added keyslot on success, or an error otherwise.
+func (device *Device) AddKeyslotFromProvider(ctx context.Context, provider KeyProvider, keyslot int, passphrase string) (int, error) {
+	volumeKey, err := device.providedVolumeKey(ctx, provider)
+	if err != nil {
+		return 0, err
+	}
+	defer WipeBytes(volumeKey)
+
+	passphraseBytes := []byte(passphrase)
+	defer WipeBytes(passphraseBytes)
+
+	return device.KeyslotAddByVolumeKeyBytes(keyslot, volumeKey, passphraseBytes)
+}
+
+// ActivateFromProvider activates the device as 'deviceName', using the volume key returned by 'provider' for the device's UUID.
+// If 'deviceName' is empty, the volume key is only checked, and the device is not activated.
+// Returns nil on success, or an error otherwise.
+func (device *Device) ActivateFromProvider(ctx context.Context, deviceName string, provider KeyProvider, flags int) error {
+	volumeKey, err := device.providedVolumeKey(ctx, provider)
+	if err != nil {
+		return err
+	}
+	defer WipeBytes(volumeKey)
+
+	return device.ActivateByVolumeKeyBytes(deviceName, volumeKey, flags)
+}
+
+// providedVolumeKey returns the volume key returned by 'provider' for the device's UUID.
+// An empty key is rejected, as it would make ActivateByVolumeKey use an ephemeral key for PLAIN devices.
+func (device *Device) providedVolumeKey(ctx context.Context, provider KeyProvider) ([]byte, error) {
+	volumeKey, err := provider.GetVolumeKey(ctx, device.UUID())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(volumeKey) == 0 {
+		return nil, errEmptyProvidedVolumeKey
+	}
+
+	return volumeKey, nil
+}
@@ -0,0 +1,68 @@
+package cryptsetup
+
+import (
+	"context"
+	"testing"
+)
+
+type staticKeyProvider struct {
+	volumeKey string
+	uuid      *string
+}
+
+func (provider staticKeyProvider) GetVolumeKey(ctx context.Context, deviceUUID string) ([]byte, error) {
+	*provider.uuid = deviceUUID
+	return []byte(provider.volumeKey), nil
+}
+
+func Test_Device_AddKeyslotFromProvider_ActivateFromProvider(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const volumeKey = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKey: volumeKey, VolumeKeySize: len(volumeKey)})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	var uuid string
+	provider := staticKeyProvider{volumeKey: volumeKey, uuid: &uuid}
+
+	keyslot, err := device.AddKeyslotFromProvider(context.Background(), provider, CRYPT_ANY_SLOT, "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	if uuid != device.UUID() {
+		test.Errorf("The provider should have been asked for the key of '%s', but was asked for '%s'.", device.UUID(), uuid)
+	}
+
+	unlocked, err := device.CheckPassphrase(CRYPT_ANY_SLOT, "testPassphrase")
+	testWrapper.AssertNoError(err)
+	if unlocked != keyslot {
+		test.Errorf("Passphrase should have unlocked keyslot %d, but unlocked %d.", keyslot, unlocked)
+	}
+
+	err = device.ActivateFromProvider(context.Background(), "", provider, 0)
+	testWrapper.AssertNoError(err)
+
+	err = device.ActivateFromProvider(context.Background(), "", staticKeyProvider{volumeKey: "wrongVolumeKey", uuid: &uuid}, 0)
+	testWrapper.AssertError(err)
+}
+
+func Test_Device_ActivateFromProvider_Fails_For_Empty_Key(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	var uuid string
+	err = device.ActivateFromProvider(context.Background(), "", staticKeyProvider{uuid: &uuid}, 0)
+	if err != errEmptyProvidedVolumeKey {
+		test.Errorf("ActivateFromProvider should have failed with errEmptyProvidedVolumeKey, but got: %v", err)
+	}
+}
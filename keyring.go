@@ -0,0 +1,88 @@
+package cryptsetup
+
+/*
+#cgo pkg-config: libcryptsetup
+#include <errno.h>
+#include <libcryptsetup.h>
+#include <linux/keyctl.h>
+#include <stdlib.h>
+#include <sys/mman.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+// VOLUME_KEY_MAX_SIZE bounds the volume keys read from user keys, which libcryptsetup never generates larger than 512 bytes.
+#define VOLUME_KEY_MAX_SIZE 512
+
+// crypt_keyslot_context_init_by_vk_in_keyring was added in libcryptsetup 2.7, which is also when CRYPT_KC_TYPE_VK_KEYRING was defined.
+// It uses the key in the kernel, so it also works with logon keys, whose payload cannot be read back.
+// Older versions can only reuse user keys, whose payload is read into locked memory, and wiped once activated.
+static int activate_by_vk_in_keyring(struct crypt_device *cd, const char *name, const char *key_description, uint32_t flags)
+{
+#ifdef CRYPT_KC_TYPE_VK_KEYRING
+	struct crypt_keyslot_context *kc = NULL;
+	int r;
+
+	r = crypt_keyslot_context_init_by_vk_in_keyring(cd, key_description, &kc);
+	if (r < 0)
+		return r;
+	r = crypt_activate_by_keyslot_context(cd, name, CRYPT_ANY_SLOT, kc, CRYPT_ANY_SLOT, NULL, flags);
+	crypt_keyslot_context_free(kc);
+	return r;
+#else
+	char volume_key[VOLUME_KEY_MAX_SIZE];
+	volatile char *wipe;
+	long key_id, size;
+	int r;
+
+	key_id = syscall(SYS_request_key, "user", key_description, NULL, 0);
+	if (key_id < 0)
+		return -errno;
+
+	mlock(volume_key, sizeof(volume_key));
+	size = syscall(SYS_keyctl, KEYCTL_READ, key_id, volume_key, sizeof(volume_key));
+	if (size < 0)
+		r = -errno;
+	else if (size > VOLUME_KEY_MAX_SIZE)
+		r = -EINVAL;
+	else
+		r = crypt_activate_by_volume_key(cd, name, volume_key, size, flags);
+
+	for (wipe = volume_key; wipe < volume_key + sizeof(volume_key); wipe++)
+		*wipe = 0;
+	munlock(volume_key, sizeof(volume_key));
+	return r;
+#endif
+}
+*/
+import "C"
+import "unsafe"
+
+// ActivateByVolumeKeyInKeyring activates a device using its volume key, already uploaded to the kernel keyring
+// under 'keyDescription', such as by systemd-cryptsetup, so additional mappings may be activated without entering the passphrase again.
+// With libcryptsetup 2.7 or newer the key stays in the kernel, and may be a logon key; older versions can only read the payload of user keys.
+// If 'deviceName' is empty, the volume key is only checked, and the device is not activated.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_keyslot_context_init_by_vk_in_keyring, followed by crypt_activate_by_keyslot_context
+func (device *Device) ActivateByVolumeKeyInKeyring(deviceName string, keyDescription string, flags int) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	var cryptDeviceName *C.char = nil
+	if len(deviceName) > 0 {
+		cryptDeviceName = C.CString(deviceName)
+		defer C.free(unsafe.Pointer(cryptDeviceName))
+	}
+
+	cKeyDescription := C.CString(keyDescription)
+	defer C.free(unsafe.Pointer(cKeyDescription))
+
+	defer device.trackLoopDevices()()
+	err := C.activate_by_vk_in_keyring(device.cryptDevice, cryptDeviceName, cKeyDescription, C.uint32_t(flags))
+	if err < 0 {
+		return device.newError("crypt_activate_by_keyslot_context", int(err), activationOperation(deviceName), "key "+keyDescription)
+	}
+
+	device.emitActivated(deviceName, -1)
+	return nil
+}
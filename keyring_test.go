@@ -0,0 +1,69 @@
+package cryptsetup
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// addUserKey adds a user key to the process keyring, as systemd-cryptsetup does with the keys it caches.
+// Returns a function revoking the key.
+func addUserKey(test *testing.T, description string, payload []byte) func() {
+	keyType, err := syscall.BytePtrFromString("user")
+	if err != nil {
+		test.Fatal(err)
+	}
+	keyDescription, err := syscall.BytePtrFromString(description)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	keySpecProcessKeyring := -2
+	keyID, _, errno := syscall.Syscall6(syscall.SYS_ADD_KEY, uintptr(unsafe.Pointer(keyType)), uintptr(unsafe.Pointer(keyDescription)),
+		uintptr(unsafe.Pointer(&payload[0])), uintptr(len(payload)), uintptr(keySpecProcessKeyring), 0)
+	if errno != 0 {
+		test.Fatal(errno)
+	}
+
+	keyctlRevoke := 3
+	return func() { syscall.Syscall(syscall.SYS_KEYCTL, uintptr(keyctlRevoke), keyID, 0) }
+}
+
+func Test_Device_ActivateByVolumeKeyInKeyring(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", PassKey)
+	testWrapper.AssertNoError(err)
+
+	volumeKey, _, err := device.VolumeKeyGet(0, PassKey)
+	testWrapper.AssertNoError(err)
+	defer addUserKey(test, "cryptsetup-test:volume-key", volumeKey)()
+
+	err = device.ActivateByVolumeKeyInKeyring(DeviceName, "cryptsetup-test:volume-key", CRYPT_ACTIVATE_READONLY)
+	testWrapper.AssertNoError(err)
+
+	err = device.Deactivate(DeviceName)
+	testWrapper.AssertNoError(err)
+}
+
+func Test_Device_ActivateByVolumeKeyInKeyring_Fails_If_Key_Is_Not_Found(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	err = device.ActivateByVolumeKeyInKeyring("", "cryptsetup-test:nonExistingKeyDescription", 0)
+	testWrapper.AssertError(err)
+}
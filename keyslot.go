@@ -0,0 +1,401 @@
+package cryptsetup
+
+/*
+#cgo pkg-config: libcryptsetup
+#include <errno.h>
+#include <libcryptsetup.h>
+#include <stdlib.h>
+
+// crypt_keyslot_get_pbkdf was added in libcryptsetup 2.1, which defines no feature macro:
+// CRYPT_REENCRYPT_INITIALIZE_ONLY, defined since 2.2, is checked instead.
+static int keyslot_get_pbkdf(struct crypt_device *cd, int keyslot, struct crypt_pbkdf_type *pbkdf)
+{
+#ifdef CRYPT_REENCRYPT_INITIALIZE_ONLY
+	return crypt_keyslot_get_pbkdf(cd, keyslot, pbkdf);
+#else
+	return -ENOTSUP;
+#endif
+}
+*/
+import "C"
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// luks1SaltLength is the length of the PBKDF salt of LUKS1 keyslots, fixed by the on-disk format.
+const luks1SaltLength = 32
+
+// KeyslotPBKDFInfo describes the key derivation protecting a keyslot.
+type KeyslotPBKDFInfo struct {
+	// Type is the PBKDF type, such as "pbkdf2", "argon2i" or "argon2id".
+	Type string
+	// Hash is the hash function used by PBKDF2.
+	Hash string
+	// Iterations is the iteration count for PBKDF2, or the time cost for Argon2.
+	Iterations uint32
+	// MaxMemoryKb is the memory cost for Argon2, in KiB.
+	MaxMemoryKb uint32
+	// ParallelThreads is the parallel cost for Argon2.
+	ParallelThreads uint32
+	// SaltLength is the length of the PBKDF salt, in bytes.
+	SaltLength int
+}
+
+// ErrNoCredential is reported by VerifyAllKeyslots for keyslots no credential was supplied for.
+var ErrNoCredential = errors.New("no credential supplied for keyslot")
+
+// KeyslotVerification is the result of verifying a keyslot with VerifyAllKeyslots.
+type KeyslotVerification struct {
+	Keyslot int
+	// Status is the keyslot's status, as one of the CRYPT_SLOT_* constants.
+	Status int
+	// Err is nil if the supplied credential opened the keyslot, ErrNoCredential if none was supplied,
+	// or the error returned when trying it otherwise.
+	Err error
+}
+
+// Verified reports whether the supplied credential opened the keyslot.
+func (verification KeyslotVerification) Verified() bool {
+	return verification.Err == nil
+}
+
+// VerifyAllKeyslots tries to open every keyslot in use with its passphrase in 'credentials', indexed by keyslot,
+// as a scheduled health check that every passphrase on record still opens its keyslot.
+// Keyslots in use without a passphrase in 'credentials' are reported with ErrNoCredential, and passphrases supplied for keyslots
+// that are not in use are reported too, with the error returned when trying them.
+// Keyslots are only opened by their own passphrase: a passphrase opening another keyslot does not verify its own.
+// Returns the verifications, ordered by keyslot, on success, or an error if the device has no keyslots.
+func (device *Device) VerifyAllKeyslots(credentials map[int][]byte) ([]KeyslotVerification, error) {
+	if err := device.checkUsable(); err != nil {
+		return nil, err
+	}
+
+	keyslotMax := device.KeyslotMax()
+	if keyslotMax < 0 {
+		return nil, device.newError("crypt_keyslot_max", keyslotMax, "verify keyslots")
+	}
+
+	verifications := make([]KeyslotVerification, 0)
+	for keyslot := 0; keyslot < keyslotMax; keyslot++ {
+		status := device.KeyslotStatus(keyslot)
+		passphrase, supplied := credentials[keyslot]
+		if status == CRYPT_SLOT_INACTIVE && !supplied {
+			continue
+		}
+
+		verification := KeyslotVerification{Keyslot: keyslot, Status: status, Err: ErrNoCredential}
+		if supplied {
+			verification.Err = device.verifyKeyslot(keyslot, status, passphrase)
+		}
+		verifications = append(verifications, verification)
+	}
+
+	return verifications, nil
+}
+
+// verifyKeyslot opens 'keyslot' with 'passphrase'. Unbound keyslots, which cannot activate the device,
+// are opened by retrieving the key they hold.
+func (device *Device) verifyKeyslot(keyslot int, status int, passphrase []byte) error {
+	if status != CRYPT_SLOT_UNBOUND {
+		_, err := device.CheckPassphraseBytes(keyslot, passphrase)
+		return err
+	}
+
+	key, _, err := device.volumeKeyGet(keyslot, passphrase)
+	WipeBytes(key)
+	return err
+}
+
+// RotatePassphrase replaces 'currentPassphrase' with 'newPassphrase' as an all-or-nothing operation.
+// The new passphrase is added to a free keyslot and verified before the keyslot holding the current passphrase is destroyed.
+// If any step fails, the new keyslot is destroyed again, leaving the device as it was.
+// Returns the number of the keyslot holding the new passphrase on success, or an error otherwise.
+func (device *Device) RotatePassphrase(currentPassphrase string, newPassphrase string) (int, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
+	}
+
+	currentKeyslot, err := device.CheckPassphrase(CRYPT_ANY_SLOT, currentPassphrase)
+	if err != nil {
+		return 0, err
+	}
+
+	newKeyslot, err := device.keyslotAddByPassphrase(CRYPT_ANY_SLOT, currentPassphrase, newPassphrase)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = device.CheckPassphrase(newKeyslot, newPassphrase); err != nil {
+		device.KeyslotDestroy(newKeyslot)
+		return 0, err
+	}
+
+	if err = device.KeyslotDestroy(currentKeyslot); err != nil {
+		device.KeyslotDestroy(newKeyslot)
+		return 0, err
+	}
+
+	return newKeyslot, nil
+}
+
+// ResetPassphraseWithVolumeKey recovers a device whose passphrases were lost, adding 'newPassphrase' to a free keyslot
+// from 'volumeKey', such as a volume key held in escrow, which libcryptsetup verifies against the header first.
+// If 'destroyOthers' is true, every other keyslot is destroyed afterwards, so the lost passphrases cannot unlock the device anymore.
+// Returns the number of the keyslot holding the new passphrase on success, or an error otherwise.
+// C equivalent: crypt_keyslot_add_by_volume_key, followed by crypt_keyslot_destroy
+func (device *Device) ResetPassphraseWithVolumeKey(volumeKey []byte, newPassphrase string, destroyOthers bool) (int, error) {
+	if err := device.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	if len(volumeKey) == 0 {
+		return 0, fmt.Errorf("no volume key supplied to reset the passphrase of '%s'", device.DevicePath())
+	}
+
+	unlock, err := device.lockKeyslots()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	newKeyslot, err := device.keyslotAddByVolumeKeyBytes(CRYPT_ANY_SLOT, volumeKey, stringBytes(newPassphrase))
+	if err != nil {
+		return 0, err
+	}
+
+	if !destroyOthers {
+		return newKeyslot, nil
+	}
+
+	for keyslot := 0; keyslot < device.KeyslotMax(); keyslot++ {
+		if keyslot == newKeyslot {
+			continue
+		}
+
+		switch device.KeyslotStatus(keyslot) {
+		case CRYPT_SLOT_ACTIVE, CRYPT_SLOT_ACTIVE_LAST, CRYPT_SLOT_UNBOUND:
+			if err := device.keyslotDestroy(keyslot); err != nil {
+				return newKeyslot, err
+			}
+		}
+	}
+
+	return newKeyslot, nil
+}
+
+// PBKDFType returns the PBKDF parameters used for new keyslots, or nil if none were set yet.
+// C equivalent: crypt_get_pbkdf_type
+func (device *Device) PBKDFType() *PbkdfType {
+	if device.checkUsable() != nil {
+		return nil
+	}
+	cPBKDFType := C.crypt_get_pbkdf_type(device.cryptDevice)
+	if cPBKDFType == nil || cPBKDFType._type == nil {
+		return nil
+	}
+
+	return &PbkdfType{
+		Type:            C.GoString(cPBKDFType._type),
+		Hash:            C.GoString(cPBKDFType.hash),
+		TimeMs:          uint32(cPBKDFType.time_ms),
+		Iterations:      uint32(cPBKDFType.iterations),
+		MaxMemoryKb:     uint32(cPBKDFType.max_memory_kb),
+		ParallelThreads: uint32(cPBKDFType.parallel_threads),
+		Flags:           uint32(cPBKDFType.flags),
+	}
+}
+
+// SetIterationTime sets the target time the PBKDF of new keyslots should take to unlock them, benchmarking the iterations needed.
+// C equivalent: crypt_set_iteration_time
+func (device *Device) SetIterationTime(iterationTimeMs uint64) {
+	if device.checkUsable() != nil {
+		return
+	}
+	C.crypt_set_iteration_time(device.cryptDevice, C.uint64_t(iterationTimeMs))
+}
+
+// WithIterationTime pins the target unlock time of keyslots added by 'operation' to 'iterationTimeMs',
+// restoring the previous PBKDF parameters afterwards, so that
+// keyslots such as user and recovery ones can have different derivation costs.
+// Returns the error returned by 'operation'.
+func (device *Device) WithIterationTime(iterationTimeMs uint64, operation func() error) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	previous := device.PBKDFType()
+	device.SetIterationTime(iterationTimeMs)
+	defer device.restorePBKDFType(previous)
+
+	return operation()
+}
+
+// restorePBKDFType sets the PBKDF parameters back to 'pbkdfType', or to libcryptsetup's defaults if it is nil.
+func (device *Device) restorePBKDFType(pbkdfType *PbkdfType) {
+	if pbkdfType == nil {
+		C.crypt_set_pbkdf_type(device.cryptDevice, nil)
+		return
+	}
+
+	var cPBKDFType C.struct_crypt_pbkdf_type
+
+	cPBKDFType._type = C.CString(pbkdfType.Type)
+	defer C.free(unsafe.Pointer(cPBKDFType._type))
+
+	cPBKDFType.hash = nil
+	if pbkdfType.Hash != "" {
+		cPBKDFType.hash = C.CString(pbkdfType.Hash)
+		defer C.free(unsafe.Pointer(cPBKDFType.hash))
+	}
+
+	cPBKDFType.time_ms = C.uint32_t(pbkdfType.TimeMs)
+	cPBKDFType.iterations = C.uint32_t(pbkdfType.Iterations)
+	cPBKDFType.max_memory_kb = C.uint32_t(pbkdfType.MaxMemoryKb)
+	cPBKDFType.parallel_threads = C.uint32_t(pbkdfType.ParallelThreads)
+	cPBKDFType.flags = C.uint32_t(pbkdfType.Flags)
+
+	C.crypt_set_pbkdf_type(device.cryptDevice, &cPBKDFType)
+}
+
+// KeyslotKeySize returns the size, in bytes, of the key stored in 'keyslot'.
+// A size different from VolumeKeySize reveals a keyslot bound to another key,
+// such as the previous volume key after a reencryption changing the key size.
+// Returns the key size on success, or an error otherwise.
+// C equivalent: crypt_keyslot_get_key_size
+func (device *Device) KeyslotKeySize(keyslot int) (int, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
+	}
+
+	size := C.crypt_keyslot_get_key_size(device.cryptDevice, C.int(keyslot))
+	if size < 0 {
+		return 0, device.newError("crypt_keyslot_get_key_size", int(size), "get keyslot key size", keyslotDetail(keyslot))
+	}
+
+	return int(size), nil
+}
+
+// KeyslotPBKDFInfo returns the key derivation parameters protecting 'keyslot', so security scanners can flag weak keyslots,
+// such as PBKDF2 ones with low iteration counts.
+// It fails with -ENOTSUP if libcryptsetup is older than 2.2.
+// Returns the parameters on success, or an error otherwise.
+// C equivalent: crypt_keyslot_get_pbkdf
+func (device *Device) KeyslotPBKDFInfo(keyslot int) (KeyslotPBKDFInfo, error) {
+	if err := device.checkUsable(); err != nil {
+		return KeyslotPBKDFInfo{}, err
+	}
+
+	var info KeyslotPBKDFInfo
+	var cPBKDFType C.struct_crypt_pbkdf_type
+
+	err := C.keyslot_get_pbkdf(device.cryptDevice, C.int(keyslot), &cPBKDFType)
+	if err < 0 {
+		return info, device.newError("crypt_keyslot_get_pbkdf", int(err), "get keyslot PBKDF", keyslotDetail(keyslot))
+	}
+
+	info.Type = C.GoString(cPBKDFType._type)
+	info.Hash = C.GoString(cPBKDFType.hash)
+	info.Iterations = uint32(cPBKDFType.iterations)
+	info.MaxMemoryKb = uint32(cPBKDFType.max_memory_kb)
+	info.ParallelThreads = uint32(cPBKDFType.parallel_threads)
+
+	switch device.Type() {
+	case TypeLUKS1:
+		info.SaltLength = luks1SaltLength
+	case TypeLUKS2:
+		dump, err := device.DumpLUKS2()
+		if err != nil {
+			return info, err
+		}
+		for _, keyslotInfo := range dump.Keyslots {
+			if keyslotInfo.ID == keyslot {
+				salt, err := base64.StdEncoding.DecodeString(keyslotInfo.Salt)
+				if err != nil {
+					return info, fmt.Errorf("invalid salt for keyslot %d: %v", keyslot, err)
+				}
+				info.SaltLength = len(salt)
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// DestroyOtherKeyslots destroys every keyslot except 'keepKeyslot', revoking all other credentials.
+// 'credential' is first verified to unlock 'keepKeyslot', so the device cannot be left without a working keyslot:
+// it must be a credential unlocking keyslots, such as Passphrase, PassphraseBytes, Keyfile, KeyringKey or PassphraseFd.
+// Returns the destroyed keyslots on success, or the keyslots destroyed so far along with an error otherwise.
+func (device *Device) DestroyOtherKeyslots(keepKeyslot int, credential Credential) ([]int, error) {
+	if err := device.checkUsable(); err != nil {
+		return nil, err
+	}
+
+	destroyed := []int{}
+
+	if status := device.KeyslotStatus(keepKeyslot); status != CRYPT_SLOT_ACTIVE && status != CRYPT_SLOT_ACTIVE_LAST {
+		return destroyed, fmt.Errorf("keyslot %d is not active", keepKeyslot)
+	}
+
+	keepCredential, restricted := keyslotCredential(credential, keepKeyslot)
+	if !restricted {
+		return destroyed, fmt.Errorf("%T cannot be verified to unlock keyslot %d", credential, keepKeyslot)
+	}
+	if err := keepCredential.Activate(device, "", 0); err != nil {
+		return destroyed, err
+	}
+
+	for keyslot := 0; keyslot < device.KeyslotMax(); keyslot++ {
+		if keyslot == keepKeyslot {
+			continue
+		}
+
+		switch device.KeyslotStatus(keyslot) {
+		case CRYPT_SLOT_ACTIVE, CRYPT_SLOT_ACTIVE_LAST, CRYPT_SLOT_UNBOUND:
+			if err := device.KeyslotDestroy(keyslot); err != nil {
+				return destroyed, err
+			}
+			destroyed = append(destroyed, keyslot)
+		}
+	}
+
+	return destroyed, nil
+}
+
+// keyslotCredential restricts credentials unlocking keyslots to 'keyslot', so that they are verified against it only.
+// Reports whether 'credential' could be restricted: other credentials, such as VolumeKey and Token, are returned as they are.
+func keyslotCredential(credential Credential, keyslot int) (Credential, bool) {
+	switch typed := credential.(type) {
+	case Passphrase:
+		typed.Keyslot = keyslot
+		return typed, true
+	case Keyfile:
+		typed.Keyslot = keyslot
+		return typed, true
+	case KeyringKey:
+		typed.Keyslot = keyslot
+		return typed, true
+	case PassphraseFd:
+		typed.Keyslot = keyslot
+		return typed, true
+	case PassphraseBytes:
+		typed.Keyslot = keyslot
+		return typed, true
+	default:
+		return credential, false
+	}
+}
+
+// keyslotsInUse returns the keyslots that are active or unbound.
+func (device *Device) keyslotsInUse() []int {
+	keyslots := make([]int, 0)
+	for keyslot := 0; keyslot < device.KeyslotMax(); keyslot++ {
+		switch device.KeyslotStatus(keyslot) {
+		case CRYPT_SLOT_ACTIVE, CRYPT_SLOT_ACTIVE_LAST, CRYPT_SLOT_UNBOUND:
+			keyslots = append(keyslots, keyslot)
+		}
+	}
+	return keyslots
+}
@@ -0,0 +1,353 @@
+package cryptsetup
+
+import (
+	"testing"
+)
+
+func Test_Keyslot_RotatePassphrase(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	keyslot, err := device.RotatePassphrase("testPassphrase", "newTestPassphrase")
+	testWrapper.AssertNoError(err)
+	if keyslot != 1 {
+		test.Errorf("New passphrase should have been added to keyslot 1, but was added to: %d", keyslot)
+	}
+
+	_, err = device.CheckPassphrase(CRYPT_ANY_SLOT, "testPassphrase")
+	testWrapper.AssertError(err)
+
+	unlockedKeyslot, err := device.CheckPassphrase(CRYPT_ANY_SLOT, "newTestPassphrase")
+	testWrapper.AssertNoError(err)
+	if unlockedKeyslot != keyslot {
+		test.Errorf("New passphrase should have unlocked keyslot %d, but unlocked: %d", keyslot, unlockedKeyslot)
+	}
+}
+
+func Test_Keyslot_RotatePassphrase_Fails_If_Passphrase_Is_Wrong(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	_, err = device.RotatePassphrase("wrongPassphrase", "newTestPassphrase")
+	testWrapper.AssertError(err)
+	testWrapper.AssertErrorCodeEquals(err, -1)
+
+	_, err = device.CheckPassphrase(0, "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	_, err = device.CheckPassphrase(CRYPT_ANY_SLOT, "newTestPassphrase")
+	testWrapper.AssertError(err)
+}
+
+func Test_Keyslot_ResetPassphraseWithVolumeKey(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+	device.restorePBKDFType(&PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK})
+
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "lostPassphrase"))
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(1, "", "otherPassphrase"))
+
+	volumeKey, _, err := device.VolumeKeyGet(0, "lostPassphrase")
+	testWrapper.AssertNoError(err)
+
+	keyslot, err := device.ResetPassphraseWithVolumeKey(volumeKey, "newPassphrase", false)
+	testWrapper.AssertNoError(err)
+	if _, err = device.CheckPassphrase(keyslot, "newPassphrase"); err != nil {
+		test.Errorf("The new passphrase should have unlocked keyslot %d, but returned: %v", keyslot, err)
+	}
+	_, err = device.CheckPassphrase(1, "otherPassphrase")
+	testWrapper.AssertNoError(err)
+
+	keyslot, err = device.ResetPassphraseWithVolumeKey(volumeKey, "resetPassphrase", true)
+	testWrapper.AssertNoError(err)
+	for otherKeyslot := 0; otherKeyslot < device.KeyslotMax(); otherKeyslot++ {
+		if status := device.KeyslotStatus(otherKeyslot); otherKeyslot != keyslot && status != CRYPT_SLOT_INACTIVE {
+			test.Errorf("Keyslot %d should have been destroyed, but has status %d.", otherKeyslot, status)
+		}
+	}
+	_, err = device.CheckPassphrase(CRYPT_ANY_SLOT, "resetPassphrase")
+	testWrapper.AssertNoError(err)
+}
+
+func Test_Keyslot_ResetPassphraseWithVolumeKey_Fails_If_Volume_Key_Is_Wrong(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+	device.restorePBKDFType(&PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK})
+
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "testPassphrase"))
+
+	_, err = device.ResetPassphraseWithVolumeKey(make([]byte, 512/8), "newPassphrase", true)
+	testWrapper.AssertError(err)
+
+	_, err = device.ResetPassphraseWithVolumeKey(nil, "newPassphrase", true)
+	testWrapper.AssertError(err)
+
+	_, err = device.CheckPassphrase(0, "testPassphrase")
+	testWrapper.AssertNoError(err)
+}
+
+func Test_Keyslot_WithIterationTime(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	previous := device.PBKDFType()
+	if previous == nil {
+		test.Fatal("A formatted device should have PBKDF parameters.")
+	}
+
+	err = device.WithIterationTime(10, func() error {
+		if pbkdfType := device.PBKDFType(); pbkdfType == nil || pbkdfType.TimeMs != 10 {
+			test.Errorf("Iteration time should have been pinned to 10ms: %+v", pbkdfType)
+		}
+		return device.KeyslotAddByVolumeKey(0, "", "recoveryPassphrase")
+	})
+	testWrapper.AssertNoError(err)
+
+	if pbkdfType := device.PBKDFType(); pbkdfType == nil || pbkdfType.TimeMs != previous.TimeMs {
+		test.Errorf("Iteration time should have been restored to %dms: %+v", previous.TimeMs, pbkdfType)
+	}
+
+	_, err = device.CheckPassphrase(0, "recoveryPassphrase")
+	testWrapper.AssertNoError(err)
+
+	err = device.WithIterationTime(10, func() error { return ErrTimeout })
+	if err != ErrTimeout {
+		test.Errorf("The operation's error should have been returned, but got: %v", err)
+	}
+}
+
+func Test_Keyslot_KeyslotPBKDFInfo_LUKS1(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	info, err := device.KeyslotPBKDFInfo(0)
+	testWrapper.AssertNoError(err)
+
+	if info.Type != "pbkdf2" || info.Hash != "sha256" || info.Iterations == 0 || info.SaltLength != 32 {
+		test.Errorf("Unexpected PBKDF information: %+v", info)
+	}
+
+	_, err = device.KeyslotPBKDFInfo(1)
+	testWrapper.AssertError(err)
+}
+
+func Test_Keyslot_KeyslotPBKDFInfo_LUKS2(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	info, err := device.KeyslotPBKDFInfo(0)
+	testWrapper.AssertNoError(err)
+
+	if info.Type == "" || info.Iterations == 0 || info.SaltLength != 32 {
+		test.Errorf("Unexpected PBKDF information: %+v", info)
+	}
+}
+
+func Test_Keyslot_DestroyOtherKeyslots(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	for keyslot, passphrase := range []string{"firstPassphrase", "secondPassphrase", "thirdPassphrase"} {
+		err = device.KeyslotAddByVolumeKey(keyslot, "", passphrase)
+		testWrapper.AssertNoError(err)
+	}
+
+	_, err = device.DestroyOtherKeyslots(1, Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "firstPassphrase"})
+	testWrapper.AssertError(err)
+
+	destroyed, err := device.DestroyOtherKeyslots(1, Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "secondPassphrase"})
+	testWrapper.AssertNoError(err)
+
+	if len(destroyed) != 2 || destroyed[0] != 0 || destroyed[1] != 2 {
+		test.Errorf("Keyslots 0 and 2 should have been destroyed, but were: %v", destroyed)
+	}
+
+	if status := device.KeyslotStatus(1); status != CRYPT_SLOT_ACTIVE_LAST {
+		test.Errorf("Keyslot 1 should have been the last active keyslot, but had status: %d", status)
+	}
+
+	_, err = device.DestroyOtherKeyslots(0, Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "secondPassphrase"})
+	testWrapper.AssertError(err)
+}
+
+func Test_Keyslot_DestroyOtherKeyslots_With_PassphraseBytes(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	for keyslot, passphrase := range []string{"firstPassphrase", "secondPassphrase"} {
+		err = device.KeyslotAddByVolumeKey(keyslot, "", passphrase)
+		testWrapper.AssertNoError(err)
+	}
+
+	_, err = device.DestroyOtherKeyslots(1, PassphraseBytes{Keyslot: CRYPT_ANY_SLOT, Passphrase: []byte("firstPassphrase")})
+	testWrapper.AssertError(err)
+
+	destroyed, err := device.DestroyOtherKeyslots(1, PassphraseBytes{Keyslot: CRYPT_ANY_SLOT, Passphrase: []byte("secondPassphrase")})
+	testWrapper.AssertNoError(err)
+
+	if len(destroyed) != 1 || destroyed[0] != 0 {
+		test.Errorf("Keyslot 0 should have been destroyed, but were: %v", destroyed)
+	}
+}
+
+func Test_Keyslot_DestroyOtherKeyslots_Fails_If_Credential_Cannot_Be_Restricted_To_Keyslot(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	device.restorePBKDFType(&PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK})
+	for keyslot, passphrase := range []string{"firstPassphrase", "secondPassphrase"} {
+		err = device.KeyslotAddByVolumeKey(keyslot, "", passphrase)
+		testWrapper.AssertNoError(err)
+	}
+
+	volumeKey, _, err := device.VolumeKeyGet(0, "firstPassphrase")
+	testWrapper.AssertNoError(err)
+
+	for _, credential := range []Credential{VolumeKey{VolumeKey: string(volumeKey)}, Token{Token: CRYPT_ANY_TOKEN}} {
+		destroyed, err := device.DestroyOtherKeyslots(1, credential)
+		testWrapper.AssertError(err)
+		if len(destroyed) != 0 {
+			test.Errorf("No keyslot should have been destroyed using %T, but were: %v", credential, destroyed)
+		}
+	}
+
+	if status := device.KeyslotStatus(0); status != CRYPT_SLOT_ACTIVE {
+		test.Errorf("Keyslot 0 should have been kept, but had status: %d", status)
+	}
+}
+
+func Test_Keyslot_KeyslotKeySize(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	if device.VolumeKeySize() != 512/8 {
+		test.Errorf("Unexpected volume key size: %d", device.VolumeKeySize())
+	}
+
+	keySize, err := device.KeyslotKeySize(0)
+	testWrapper.AssertNoError(err)
+	if keySize != device.VolumeKeySize() {
+		test.Errorf("Keyslot key size should have been %d, but was %d.", device.VolumeKeySize(), keySize)
+	}
+
+	_, err = device.KeyslotKeySize(device.KeyslotMax())
+	testWrapper.AssertError(err)
+}
+
+func Test_Keyslot_VerifyAllKeyslots(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "firstPassphrase")
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(1, "", "secondPassphrase")
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(2, "", "thirdPassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	verifications, err := device.VerifyAllKeyslots(map[int][]byte{
+		0: []byte("firstPassphrase"),
+		1: []byte("firstPassphrase"),
+		3: []byte("firstPassphrase"),
+	})
+	testWrapper.AssertNoError(err)
+
+	if len(verifications) != 4 {
+		test.Fatalf("Expected 4 verifications, but got: %+v", verifications)
+	}
+	if !verifications[0].Verified() || verifications[0].Keyslot != 0 {
+		test.Errorf("Keyslot 0 should have been verified: %+v", verifications[0])
+	}
+	if verifications[1].Verified() || verifications[1].Keyslot != 1 {
+		test.Errorf("Keyslot 1 should not have been opened by the passphrase of keyslot 0: %+v", verifications[1])
+	}
+	if verifications[2].Err != ErrNoCredential || verifications[2].Keyslot != 2 {
+		test.Errorf("Keyslot 2 should have been reported without credential: %+v", verifications[2])
+	}
+	if verifications[3].Verified() || verifications[3].Status != CRYPT_SLOT_INACTIVE {
+		test.Errorf("Keyslot 3 should have been reported as inactive: %+v", verifications[3])
+	}
+}
@@ -0,0 +1,166 @@
+package cryptsetup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// KeyslotLabelTokenType is the LUKS2 token type used to store keyslot labels.
+const KeyslotLabelTokenType = "go-cryptsetup-label"
+
+// KeyslotLabelMaxLength is the maximum length of a keyslot label, in bytes.
+const KeyslotLabelMaxLength = 256
+
+// ErrKeyslotLabelNotFound is returned when a keyslot has no label, or no keyslot has the requested label.
+var ErrKeyslotLabelNotFound = errors.New("keyslot label not found")
+
+// keyslotLabelTokenJSON is the JSON representation of a keyslot label token.
+type keyslotLabelTokenJSON struct {
+	Type     string   `json:"type"`
+	Keyslots []string `json:"keyslots"`
+	Label    string   `json:"label"`
+}
+
+// SetKeyslotLabel attaches a human-readable label, such as "alice-laptop" or "recovery-2024", to the keyslot in use 'keyslot',
+// replacing its previous label, if any. The label is stored in a LUKS2 token assigned to the keyslot,
+// which libcryptsetup detaches when the keyslot is destroyed.
+// Returns the number of the token slot that was used on success, or an error otherwise.
+func (device *Device) SetKeyslotLabel(keyslot int, label string) (int, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
+	}
+
+	if label == "" || len(label) > KeyslotLabelMaxLength {
+		return 0, fmt.Errorf("keyslot label must be between 1 and %d bytes long", KeyslotLabelMaxLength)
+	}
+
+	switch device.KeyslotStatus(keyslot) {
+	case CRYPT_SLOT_ACTIVE, CRYPT_SLOT_ACTIVE_LAST, CRYPT_SLOT_UNBOUND:
+	default:
+		return 0, fmt.Errorf("keyslot %d is not in use", keyslot)
+	}
+
+	tokenJSON, err := json.Marshal(keyslotLabelTokenJSON{
+		Type:     KeyslotLabelTokenType,
+		Keyslots: []string{strconv.Itoa(keyslot)},
+		Label:    label,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	token := CRYPT_ANY_TOKEN
+	labels, err := device.keyslotLabelTokens()
+	if err != nil {
+		return 0, err
+	}
+	if existing, found := labels[keyslot]; found {
+		if err := device.TokenRemove(existing.token); err != nil {
+			return 0, err
+		}
+		token = existing.token
+	}
+
+	return device.TokenJSONSet(token, string(tokenJSON))
+}
+
+// KeyslotLabel returns the label attached to 'keyslot' by SetKeyslotLabel.
+// Returns the label on success, ErrKeyslotLabelNotFound if the keyslot has no label, or an error otherwise.
+func (device *Device) KeyslotLabel(keyslot int) (string, error) {
+	labels, err := device.keyslotLabelTokens()
+	if err != nil {
+		return "", err
+	}
+
+	label, found := labels[keyslot]
+	if !found {
+		return "", ErrKeyslotLabelNotFound
+	}
+	return label.label, nil
+}
+
+// KeyslotLabels lists the labels attached to keyslots by SetKeyslotLabel, indexed by keyslot.
+// Returns the labels on success, or an error otherwise.
+func (device *Device) KeyslotLabels() (map[int]string, error) {
+	labels, err := device.keyslotLabelTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	keyslotLabels := make(map[int]string, len(labels))
+	for keyslot, label := range labels {
+		keyslotLabels[keyslot] = label.label
+	}
+	return keyslotLabels, nil
+}
+
+// KeyslotByLabel returns the keyslot 'label' is attached to.
+// Returns the keyslot on success, ErrKeyslotLabelNotFound if no keyslot has that label, or an error otherwise.
+func (device *Device) KeyslotByLabel(label string) (int, error) {
+	labels, err := device.keyslotLabelTokens()
+	if err != nil {
+		return 0, err
+	}
+
+	for keyslot, keyslotLabel := range labels {
+		if keyslotLabel.label == label {
+			return keyslot, nil
+		}
+	}
+	return 0, ErrKeyslotLabelNotFound
+}
+
+// RemoveKeyslotLabel removes the label attached to 'keyslot'.
+// Returns nil on success, ErrKeyslotLabelNotFound if the keyslot has no label, or an error otherwise.
+func (device *Device) RemoveKeyslotLabel(keyslot int) error {
+	labels, err := device.keyslotLabelTokens()
+	if err != nil {
+		return err
+	}
+
+	label, found := labels[keyslot]
+	if !found {
+		return ErrKeyslotLabelNotFound
+	}
+	return device.TokenRemove(label.token)
+}
+
+// keyslotLabelToken is a keyslot label, along with the token slot holding it.
+type keyslotLabelToken struct {
+	label string
+	token int
+}
+
+// keyslotLabelTokens reads the keyslot label tokens, indexed by keyslot.
+// Tokens left without a keyslot, once theirs was destroyed, are ignored.
+func (device *Device) keyslotLabelTokens() (map[int]keyslotLabelToken, error) {
+	tokens, err := device.Tokens()
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[int]keyslotLabelToken)
+	for _, token := range tokens {
+		if token.Type != KeyslotLabelTokenType || len(token.Keyslots) == 0 {
+			continue
+		}
+
+		tokenJSON, err := device.TokenJSONGet(token.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		var stored keyslotLabelTokenJSON
+		if err := json.Unmarshal([]byte(tokenJSON), &stored); err != nil {
+			return nil, fmt.Errorf("invalid keyslot label token %d: %v", token.ID, err)
+		}
+
+		for _, keyslot := range token.Keyslots {
+			labels[keyslot] = keyslotLabelToken{label: stored.Label, token: token.ID}
+		}
+	}
+
+	return labels, nil
+}
@@ -0,0 +1,67 @@
+package cryptsetup
+
+import "testing"
+
+func Test_Device_KeyslotLabels(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	pbkdfType := &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK}
+	err = device.Format(LUKS2{SectorSize: 512, PBKDFType: pbkdfType}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "firstPassphrase"))
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(2, "", "secondPassphrase"))
+
+	first, err := device.SetKeyslotLabel(0, "alice-old-laptop")
+	testWrapper.AssertNoError(err)
+	_, err = device.SetKeyslotLabel(2, "recovery-2024")
+	testWrapper.AssertNoError(err)
+
+	replaced, err := device.SetKeyslotLabel(0, "alice-laptop")
+	testWrapper.AssertNoError(err)
+	if replaced != first {
+		test.Errorf("Replaced label should have kept token slot %d, but used: %d", first, replaced)
+	}
+
+	labels, err := device.KeyslotLabels()
+	testWrapper.AssertNoError(err)
+	if len(labels) != 2 || labels[0] != "alice-laptop" || labels[2] != "recovery-2024" {
+		test.Errorf("Unexpected labels: %v", labels)
+	}
+
+	keyslot, err := device.KeyslotByLabel("recovery-2024")
+	testWrapper.AssertNoError(err)
+	if keyslot != 2 {
+		test.Errorf("Label should have been attached to keyslot 2, but was attached to %d.", keyslot)
+	}
+
+	testWrapper.AssertNoError(device.KeyslotDestroy(2))
+	if _, err = device.KeyslotLabel(2); err != ErrKeyslotLabelNotFound {
+		test.Errorf("The label of a destroyed keyslot should not be found, but got: %v", err)
+	}
+
+	testWrapper.AssertNoError(device.RemoveKeyslotLabel(0))
+	if err = device.RemoveKeyslotLabel(0); err != ErrKeyslotLabelNotFound {
+		test.Errorf("Removing a missing label should have failed with ErrKeyslotLabelNotFound, but got: %v", err)
+	}
+}
+
+func Test_Device_SetKeyslotLabel_Fails(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	_, err = device.SetKeyslotLabel(1, "unused")
+	testWrapper.AssertError(err)
+
+	_, err = device.SetKeyslotLabel(1, "")
+	testWrapper.AssertError(err)
+}
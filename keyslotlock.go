@@ -0,0 +1,83 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// keyslotLock serializes the keyslot and token modifications of a header, across all the Devices using it in the process.
+type keyslotLock struct {
+	mutex sync.Mutex
+	// generation is incremented by every modification, so Devices can tell whether their copy of the header is stale.
+	generation uint64
+}
+
+var (
+	keyslotLocksMutex sync.Mutex
+	keyslotLocks      = make(map[string]*keyslotLock)
+)
+
+// keyslotLockFor returns the keyslot lock of the header identified by 'key', creating it if needed.
+func keyslotLockFor(key string) *keyslotLock {
+	keyslotLocksMutex.Lock()
+	defer keyslotLocksMutex.Unlock()
+
+	lock, found := keyslotLocks[key]
+	if !found {
+		lock = &keyslotLock{}
+		keyslotLocks[key] = lock
+	}
+	return lock
+}
+
+// keyslotLockKey identifies the device holding the header by its device number, or by its inode for image files,
+// so Devices opened through different paths share the same lock.
+func (device *Device) keyslotLockKey() string {
+	path := device.MetadataDevicePath()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return path
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return path
+	}
+	if info.Mode()&os.ModeDevice != 0 {
+		return fmt.Sprintf("block %d", stat.Rdev)
+	}
+	return fmt.Sprintf("file %d:%d", stat.Dev, stat.Ino)
+}
+
+// lockKeyslots waits for the keyslot and token modifications made to the header through other Devices to complete,
+// queueing concurrent callers, and reloads the header if it was modified since the device last read or wrote it,
+// so free keyslots and token slots are picked from the current header.
+// Returns the function releasing the lock on success, or an error if the header could not be reloaded.
+func (device *Device) lockKeyslots() (func(), error) {
+	lock := keyslotLockFor(device.keyslotLockKey())
+	lock.mutex.Lock()
+
+	if lock.generation != device.keyslotGeneration && device.Type() != TypeNone {
+		if err := device.reload(); err != nil {
+			lock.mutex.Unlock()
+			return nil, err
+		}
+	}
+
+	return func() {
+		lock.generation++
+		device.keyslotGeneration = lock.generation
+		lock.mutex.Unlock()
+	}, nil
+}
+
+// observeKeyslots records that the device holds the current header, after it was loaded or formatted.
+func (device *Device) observeKeyslots() {
+	lock := keyslotLockFor(device.keyslotLockKey())
+	lock.mutex.Lock()
+	device.keyslotGeneration = lock.generation
+	lock.mutex.Unlock()
+}
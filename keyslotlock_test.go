@@ -0,0 +1,62 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func Test_Device_Concurrent_Keyslot_Additions(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	pbkdfType := &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(LUKS2{SectorSize: 512, PBKDFType: pbkdfType}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	device.restorePBKDFType(pbkdfType)
+	devices := []*Device{device}
+	for index := 1; index < 4; index++ {
+		other, err := Init(DevicePath)
+		testWrapper.AssertNoError(err)
+		defer other.Free()
+		testWrapper.AssertNoError(other.Load())
+		other.restorePBKDFType(pbkdfType)
+		devices = append(devices, other)
+	}
+
+	var group sync.WaitGroup
+	keyslots := make([]int, len(devices))
+	errs := make([]error, len(devices))
+	for index, handle := range devices {
+		group.Add(1)
+		go func(index int, handle *Device) {
+			defer group.Done()
+			keyslots[index], errs[index] = handle.keyslotAddByPassphrase(CRYPT_ANY_SLOT, "testPassphrase", fmt.Sprintf("passphrase%d", index))
+		}(index, handle)
+	}
+	group.Wait()
+
+	used := make(map[int]bool)
+	for index := range devices {
+		testWrapper.AssertNoError(errs[index])
+		if used[keyslots[index]] {
+			test.Errorf("Keyslot %d was picked twice.", keyslots[index])
+		}
+		used[keyslots[index]] = true
+	}
+
+	testWrapper.AssertNoError(device.Reload())
+	for index := range devices {
+		unlocked, err := device.CheckPassphrase(CRYPT_ANY_SLOT, fmt.Sprintf("passphrase%d", index))
+		testWrapper.AssertNoError(err)
+		if unlocked != keyslots[index] {
+			test.Errorf("Passphrase %d should have unlocked keyslot %d, but unlocked %d.", index, keyslots[index], unlocked)
+		}
+	}
+}
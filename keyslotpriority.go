@@ -0,0 +1,57 @@
+package cryptsetup
+
+import "errors"
+
+// ErrNoFreeKeyslot is returned by NextFreeKeyslot when every keyslot is in use.
+var ErrNoFreeKeyslot = errors.New("device has no free keyslot")
+
+// KeyslotPriority tells NextFreeKeyslot which role a new keyslot plays in the conventional keyslot layout,
+// where keyslot 0 holds the primary passphrase and the last keyslot holds the recovery key.
+type KeyslotPriority int
+
+const (
+	// KeyslotPriorityPrimary selects keyslot 0 if it is free, or the lowest free keyslot otherwise.
+	KeyslotPriorityPrimary KeyslotPriority = iota
+	// KeyslotPriorityNormal selects the lowest free keyslot, other than keyslot 0 and the last keyslot,
+	// unless they are the only free ones.
+	KeyslotPriorityNormal
+	// KeyslotPriorityRecovery selects the highest free keyslot, keeping the lower keyslots available for regular passphrases.
+	KeyslotPriorityRecovery
+)
+
+// NextFreeKeyslot returns the free keyslot a new keyslot of the role 'priority' should use,
+// so callers don't need to assume keyslot numbers.
+// Returns the keyslot number on success, ErrNoFreeKeyslot if every keyslot is in use, or an error otherwise.
+func (device *Device) NextFreeKeyslot(priority KeyslotPriority) (int, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
+	}
+
+	keyslotMax := device.KeyslotMax()
+	if keyslotMax < 0 {
+		return 0, device.newError("crypt_keyslot_max", keyslotMax, "find free keyslot")
+	}
+
+	free := make([]int, 0, keyslotMax)
+	for keyslot := 0; keyslot < keyslotMax; keyslot++ {
+		if device.KeyslotStatus(keyslot) == CRYPT_SLOT_INACTIVE {
+			free = append(free, keyslot)
+		}
+	}
+	if len(free) == 0 {
+		return 0, ErrNoFreeKeyslot
+	}
+
+	switch priority {
+	case KeyslotPriorityRecovery:
+		return free[len(free)-1], nil
+	case KeyslotPriorityNormal:
+		for _, keyslot := range free {
+			if keyslot != 0 && keyslot != keyslotMax-1 {
+				return keyslot, nil
+			}
+		}
+	}
+
+	return free[0], nil
+}
@@ -0,0 +1,54 @@
+package cryptsetup
+
+import "testing"
+
+func Test_Device_NextFreeKeyslot(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	for priority, expected := range map[KeyslotPriority]int{
+		KeyslotPriorityPrimary:  0,
+		KeyslotPriorityNormal:   1,
+		KeyslotPriorityRecovery: 7,
+	} {
+		keyslot, err := device.NextFreeKeyslot(priority)
+		testWrapper.AssertNoError(err)
+		if keyslot != expected {
+			test.Errorf("Expected keyslot %d for priority %d, but got: %d", expected, priority, keyslot)
+		}
+	}
+
+	err = device.KeyslotAddByVolumeKey(0, "", "primaryPassphrase")
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(1, "", "otherPassphrase")
+	testWrapper.AssertNoError(err)
+
+	keyslot, err := device.NextFreeKeyslot(KeyslotPriorityPrimary)
+	testWrapper.AssertNoError(err)
+	if keyslot != 2 {
+		test.Errorf("Expected the lowest free keyslot once keyslot 0 is used, but got: %d", keyslot)
+	}
+
+	keyslot, err = device.NextFreeKeyslot(KeyslotPriorityNormal)
+	testWrapper.AssertNoError(err)
+	if keyslot != 2 {
+		test.Errorf("Expected keyslot 2, but got: %d", keyslot)
+	}
+}
+
+func Test_Device_NextFreeKeyslot_Fails_If_Device_Has_No_Type(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	_, err = device.NextFreeKeyslot(KeyslotPriorityNormal)
+	testWrapper.AssertError(err)
+}
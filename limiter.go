@@ -0,0 +1,130 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// wrongPassphraseCode is the error code libcryptsetup returns when no keyslot could be unlocked with a passphrase (-EPERM).
+const wrongPassphraseCode = -1
+
+// BackoffError is returned by UnlockLimiter when an unlock attempt is made before the backoff delay has elapsed.
+type BackoffError struct {
+	retryAfter time.Duration
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("too many failed unlock attempts, retry after %s.", e.retryAfter)
+}
+
+// RetryAfter returns how long to wait before the next unlock attempt is allowed.
+func (e *BackoffError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// UnlockAttempts holds the failed unlock attempts recorded for a device.
+type UnlockAttempts struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// UnlockLimiter tracks failed passphrase attempts per device and enforces an exponential backoff between them,
+// so interactive unlock UIs can implement lockout policies consistently.
+// Devices are identified by their UUID, or by their path if they have none.
+// An UnlockLimiter is safe for concurrent use.
+type UnlockLimiter struct {
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	clock     Clock
+
+	mutex    sync.Mutex
+	attempts map[string]UnlockAttempts
+}
+
+// NewUnlockLimiter returns an UnlockLimiter that waits 'baseDelay' after the first failed attempt,
+// doubling the delay after every subsequent failure, up to 'maxDelay'.
+// Delays are measured with the system clock, unless WithClock is given.
+func NewUnlockLimiter(baseDelay time.Duration, maxDelay time.Duration, optionFuncs ...Option) *UnlockLimiter {
+	return &UnlockLimiter{
+		baseDelay: baseDelay,
+		maxDelay:  maxDelay,
+		clock:     newOptions(optionFuncs).clock,
+		attempts:  make(map[string]UnlockAttempts),
+	}
+}
+
+// ActivateByPassphrase calls device.ActivateByPassphrase, unless the backoff delay for the device hasn't elapsed yet.
+// Wrong passphrases are recorded as failures, and a successful activation clears them.
+// The failure is recorded before the passphrase is tried, so concurrent attempts on the same device are refused
+// until it completes, rather than all being tried in parallel.
+// Returns nil on success, a *BackoffError if the attempt was refused, or the activation error otherwise.
+func (limiter *UnlockLimiter) ActivateByPassphrase(device *Device, deviceName string, keyslot int, passphrase string, flags int) error {
+	key := unlockLimiterKey(device)
+
+	limiter.mutex.Lock()
+	previous := limiter.attempts[key]
+	if previous.Failures > 0 {
+		if wait := previous.LastFailure.Add(limiter.delay(previous.Failures)).Sub(limiter.clock.Now()); wait > 0 {
+			limiter.mutex.Unlock()
+			return &BackoffError{retryAfter: wait}
+		}
+	}
+	limiter.attempts[key] = UnlockAttempts{Failures: previous.Failures + 1, LastFailure: limiter.clock.Now()}
+	limiter.mutex.Unlock()
+
+	err := device.ActivateByPassphrase(deviceName, keyslot, passphrase, flags)
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if err == nil {
+		delete(limiter.attempts, key)
+	} else if cryptErr, ok := err.(*Error); ok && cryptErr.Code() == wrongPassphraseCode {
+		attempts := limiter.attempts[key]
+		attempts.LastFailure = limiter.clock.Now()
+		limiter.attempts[key] = attempts
+	} else if previous.Failures > 0 {
+		limiter.attempts[key] = previous
+	} else {
+		delete(limiter.attempts, key)
+	}
+
+	return err
+}
+
+// Attempts returns the failed unlock attempts recorded for a device.
+func (limiter *UnlockLimiter) Attempts(device *Device) UnlockAttempts {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	return limiter.attempts[unlockLimiterKey(device)]
+}
+
+// Reset clears the failed unlock attempts recorded for a device.
+func (limiter *UnlockLimiter) Reset(device *Device) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	delete(limiter.attempts, unlockLimiterKey(device))
+}
+
+// delay returns the backoff delay after 'failures' consecutive failed attempts.
+func (limiter *UnlockLimiter) delay(failures int) time.Duration {
+	delay := limiter.baseDelay
+	for index := 1; index < failures && delay < limiter.maxDelay; index++ {
+		delay *= 2
+	}
+
+	if delay > limiter.maxDelay {
+		return limiter.maxDelay
+	}
+	return delay
+}
+
+func unlockLimiterKey(device *Device) string {
+	if uuid := device.UUID(); uuid != "" {
+		return uuid
+	}
+	return device.DevicePath()
+}
@@ -0,0 +1,134 @@
+package cryptsetup
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_UnlockLimiter_ActivateByPassphrase(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	limiter := NewUnlockLimiter(time.Hour, 4*time.Hour)
+
+	err = limiter.ActivateByPassphrase(device, "", 0, "wrongPassphrase", 0)
+	testWrapper.AssertError(err)
+	testWrapper.AssertErrorCodeEquals(err, -1)
+
+	if attempts := limiter.Attempts(device); attempts.Failures != 1 {
+		test.Errorf("One failure should have been recorded, but %d were.", attempts.Failures)
+	}
+
+	err = limiter.ActivateByPassphrase(device, "", 0, "testPassphrase", 0)
+	if backoffErr, ok := err.(*BackoffError); !ok {
+		test.Errorf("Attempt should have been refused with a BackoffError, but returned: %v", err)
+	} else if backoffErr.RetryAfter() <= 0 || backoffErr.RetryAfter() > time.Hour {
+		test.Errorf("Unexpected retry delay: %s", backoffErr.RetryAfter())
+	}
+
+	limiter.Reset(device)
+
+	err = limiter.ActivateByPassphrase(device, "", 0, "testPassphrase", 0)
+	testWrapper.AssertNoError(err)
+
+	if attempts := limiter.Attempts(device); attempts.Failures != 0 {
+		test.Errorf("No failures should have been recorded, but %d were.", attempts.Failures)
+	}
+}
+
+func Test_UnlockLimiter_ActivateByPassphrase_Refuses_Concurrent_Attempts(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	limiter := NewUnlockLimiter(time.Hour, 4*time.Hour)
+
+	const attemptCount = 4
+	errs := make(chan error, attemptCount)
+	var start sync.WaitGroup
+	start.Add(1)
+	for index := 0; index < attemptCount; index++ {
+		go func() {
+			start.Wait()
+			errs <- limiter.ActivateByPassphrase(device, "", 0, "wrongPassphrase", 0)
+		}()
+	}
+	start.Done()
+
+	refused := 0
+	for index := 0; index < attemptCount; index++ {
+		if _, ok := (<-errs).(*BackoffError); ok {
+			refused++
+		}
+	}
+
+	if refused != attemptCount-1 {
+		test.Errorf("All but one attempt should have been refused, but %d were.", refused)
+	}
+	if attempts := limiter.Attempts(device); attempts.Failures != 1 {
+		test.Errorf("One failure should have been recorded, but %d were.", attempts.Failures)
+	}
+}
+
+func Test_UnlockLimiter_Delay(test *testing.T) {
+	limiter := NewUnlockLimiter(time.Second, 10*time.Second)
+
+	expectedDelays := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for index, expectedDelay := range expectedDelays {
+		if delay := limiter.delay(index + 1); delay != expectedDelay {
+			test.Errorf("Delay after %d failures should have been %s, but was: %s", index+1, expectedDelay, delay)
+		}
+	}
+}
+
+func Test_UnlockLimiter_ActivateByPassphrase_WithClock(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := NewUnlockLimiter(time.Hour, 4*time.Hour, WithClock(clock))
+
+	err = limiter.ActivateByPassphrase(device, "", 0, "wrongPassphrase", 0)
+	testWrapper.AssertErrorCodeEquals(err, -1)
+
+	if attempts := limiter.Attempts(device); !attempts.LastFailure.Equal(clock.now) {
+		test.Errorf("Failure should have been recorded at %s, but was recorded at %s.", clock.now, attempts.LastFailure)
+	}
+
+	clock.Sleep(time.Hour - time.Minute)
+	err = limiter.ActivateByPassphrase(device, "", 0, "testPassphrase", 0)
+	if backoffErr, ok := err.(*BackoffError); !ok || backoffErr.RetryAfter() != time.Minute {
+		test.Errorf("Attempt should have been refused for a minute, but returned: %v", err)
+	}
+
+	clock.Sleep(time.Minute)
+	err = limiter.ActivateByPassphrase(device, "", 0, "testPassphrase", 0)
+	testWrapper.AssertNoError(err)
+}
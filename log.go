@@ -3,24 +3,130 @@ package cryptsetup
 /*
 #cgo pkg-config: libcryptsetup
 #include <libcryptsetup.h>
+#include <stdint.h>
 #include <stdlib.h>
 
 extern void log_callback(int level, char * message, void * usrptr);
 */
 import "C"
-import "unsafe"
+import (
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
 
-var logCallback func(level int, message string)
+var (
+	logLock sync.RWMutex
+	// deliveryLock serializes calls to the log callbacks, which may be triggered concurrently by devices used from different goroutines.
+	deliveryLock sync.Mutex
+	// deliveryThread is the ID of the OS thread holding deliveryLock, or 0. A callback calling back into the package may trigger
+	// more messages, delivered on the same thread, as cgo callbacks stay locked to it: they are delivered without locking again.
+	deliveryThread int64
+
+	logCallback       func(level int, message string)
+	deviceLogCallback func(device *Device, level int, message string)
+
+	// logDevices maps the IDs passed to libcryptsetup as log callback user data to their live Device.
+	logDevices      = make(map[uintptr]*Device)
+	nextLogDeviceID uintptr
+)
 
 //export log_callback
 func log_callback(level C.int, message *C.char, usrptr unsafe.Pointer) {
-	if logCallback != nil {
-		logCallback(int(level), C.GoString(message))
+	logLock.RLock()
+	callback, deviceCallback := logCallback, deviceLogCallback
+	var device *Device
+	if usrptr != nil {
+		device = logDevices[uintptr(*(*C.uintptr_t)(usrptr))]
+	}
+	logLock.RUnlock()
+
+	if callback == nil && deviceCallback == nil {
+		return
+	}
+
+	goMessage := C.GoString(message)
+
+	if thread := int64(syscall.Gettid()); atomic.LoadInt64(&deliveryThread) != thread {
+		deliveryLock.Lock()
+		atomic.StoreInt64(&deliveryThread, thread)
+		defer func() {
+			atomic.StoreInt64(&deliveryThread, 0)
+			deliveryLock.Unlock()
+		}()
+	}
+
+	if callback != nil {
+		callback(int(level), goMessage)
+	}
+	if deviceCallback != nil {
+		deviceCallback(device, int(level), goMessage)
 	}
 }
 
+// SetLogCallback sets the function receiving libcryptsetup's log messages.
+// Calls to the callback are serialized, so it need not be goroutine-safe. It may call back into the package,
+// which delivers the messages this logs to it re-entrantly.
+// C equivalent: crypt_set_log_callback
 func SetLogCallback(newLogCallback func(level int, message string)) {
+	logLock.Lock()
 	logCallback = newLogCallback
+	logLock.Unlock()
 
 	C.crypt_set_log_callback(nil, (*[0]byte)(C.log_callback), nil)
 }
+
+// SetDeviceLogCallback sets the function receiving libcryptsetup's log messages tagged with the Device they originate from,
+// so daemons managing several devices can attribute messages correctly.
+// 'device' is nil for messages not related to any Device.
+// Calls to the callback are serialized like those of SetLogCallback's.
+// It should be set before devices are used concurrently, since it is installed on every live Device.
+// C equivalent: crypt_set_log_callback
+func SetDeviceLogCallback(newDeviceLogCallback func(device *Device, level int, message string)) {
+	logLock.Lock()
+	defer logLock.Unlock()
+
+	deviceLogCallback = newDeviceLogCallback
+	for _, device := range logDevices {
+		device.installLogCallback()
+	}
+
+	C.crypt_set_log_callback(nil, (*[0]byte)(C.log_callback), nil)
+}
+
+// registerLogging records a newly initialized device, so that its log messages can be attributed to it.
+func (device *Device) registerLogging() {
+	logLock.Lock()
+	defer logLock.Unlock()
+
+	nextLogDeviceID++
+	device.logID = (*C.uintptr_t)(C.malloc(C.sizeof_uintptr_t))
+	*device.logID = C.uintptr_t(nextLogDeviceID)
+	logDevices[nextLogDeviceID] = device
+
+	device.installLogCallback()
+}
+
+// unregisterLogging forgets a freed device. It must be called after crypt_free, since freeing may log messages.
+func (device *Device) unregisterLogging() {
+	logLock.Lock()
+	defer logLock.Unlock()
+
+	if device.logID != nil {
+		delete(logDevices, uintptr(*device.logID))
+		C.free(unsafe.Pointer(device.logID))
+		device.logID = nil
+	}
+}
+
+// installLogCallback sets or clears the device specific log callback, depending on whether a device log callback is set.
+// Without it, libcryptsetup falls back to the callback set by SetLogCallback, or to its own default.
+// The caller must hold logLock.
+func (device *Device) installLogCallback() {
+	if deviceLogCallback != nil {
+		C.crypt_set_log_callback(device.cryptDevice, (*[0]byte)(C.log_callback), unsafe.Pointer(device.logID))
+	} else {
+		C.crypt_set_log_callback(device.cryptDevice, nil, nil)
+	}
+}
@@ -34,3 +34,63 @@ func Test_Log(test *testing.T) {
 		}
 	}
 }
+
+func Test_SetDeviceLogCallback(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	first, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer first.Free()
+
+	messages := make(map[*Device]int)
+	SetDeviceLogCallback(func(device *Device, level int, message string) {
+		messages[device]++
+	})
+	defer SetDeviceLogCallback(nil)
+
+	second, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer second.Free()
+
+	err = first.Deactivate(DevicePath)
+	testWrapper.AssertError(err)
+
+	if messages[first] == 0 || messages[second] != 0 {
+		test.Errorf("Messages should have been attributed to the first device only: %v", messages)
+	}
+
+	err = second.Deactivate(DevicePath)
+	testWrapper.AssertError(err)
+
+	if messages[second] == 0 {
+		test.Errorf("Messages should have been attributed to the second device: %v", messages)
+	}
+}
+
+func Test_SetLogCallback_May_Call_Back_Into_The_Package(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	SetDebugLevel(CRYPT_DEBUG_ALL)
+	defer SetDebugLevel(CRYPT_DEBUG_NONE)
+
+	nested, messages := false, 0
+	SetLogCallback(func(level int, message string) {
+		messages++
+		if !nested {
+			nested = true
+			device.Deactivate(DevicePath)
+		}
+	})
+	defer SetLogCallback(nil)
+
+	err = device.Deactivate(DevicePath)
+	testWrapper.AssertError(err)
+
+	if messages < 2 {
+		test.Errorf("The messages logged from the callback should have been delivered too, but got %d messages", messages)
+	}
+}
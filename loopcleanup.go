@@ -0,0 +1,207 @@
+package cryptsetup
+
+/*
+#include <sys/ioctl.h>
+#include <linux/loop.h>
+
+static int loop_clear_fd(int fd) {
+	return ioctl(fd, LOOP_CLR_FD, 0);
+}
+*/
+import "C"
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+)
+
+// devPath is the directory holding the device nodes of block devices.
+var devPath = "/dev"
+
+// attachedLoopDevices records the loop devices the package attached, by the path of their backing image file,
+// so CleanupLoopDevices never detaches loop devices attached by others.
+var attachedLoopDevices = struct {
+	sync.Mutex
+	backingFiles map[string]string
+}{backingFiles: make(map[string]string)}
+
+// loopImagePath returns the absolute path of 'path' if it is an image file, which libcryptsetup attaches to a loop device
+// to activate it, or an empty string if it is not one.
+func loopImagePath(path string) string {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+
+	return absolutePath
+}
+
+// trackLoopDevices lists the loop devices backed by the device's image file, and returns a function recording the ones
+// attached since, such as by libcryptsetup to activate it. Defer the returned function around the call that may attach one.
+func (device *Device) trackLoopDevices() func() {
+	if device.imagePath == "" {
+		return func() {}
+	}
+
+	existing := make(map[string]bool)
+	for _, name := range loopDevicesBackedBy(device.imagePath) {
+		existing[name] = true
+	}
+
+	return func() {
+		for _, name := range loopDevicesBackedBy(device.imagePath) {
+			if existing[name] {
+				continue
+			}
+
+			attachedLoopDevices.Lock()
+			attachedLoopDevices.backingFiles[name] = device.imagePath
+			attachedLoopDevices.Unlock()
+			device.loopDevices = append(device.loopDevices, name)
+		}
+	}
+}
+
+// CleanupLoopDevices detaches the loop devices the package attached to image files, which are neither held by a mapping
+// nor mounted, such as those left behind by mappings that failed or were removed without their loop device.
+// Devices already detach the unused loop devices they attached when freed, but nothing runs at process exit on its own:
+// callers must call it, or the function returned by StartLoopDeviceJanitor, before exiting, such as deferred in main
+// or at the end of TestMain, to catch the loop devices of mappings deactivated later, so repeated runs don't exhaust them.
+// Loop devices attached by other processes, such as with losetup, are left alone.
+// Returns the names of the detached loop devices on success, or the ones detached so far along with an error otherwise.
+func CleanupLoopDevices() ([]string, error) {
+	attachedLoopDevices.Lock()
+	names := make([]string, 0, len(attachedLoopDevices.backingFiles))
+	for name := range attachedLoopDevices.backingFiles {
+		names = append(names, name)
+	}
+	attachedLoopDevices.Unlock()
+	sort.Strings(names)
+
+	return detachUnusedLoopDevices(names)
+}
+
+// StartLoopDeviceJanitor runs CleanupLoopDevices when the process receives one of 'signals', which default to
+// SIGINT, SIGTERM and SIGHUP, so loop devices are detached even if the process is interrupted.
+// The signal is then sent again to the process, so it terminates as it would have without the janitor,
+// or reaches the handlers registered elsewhere with signal.Notify.
+// Returns the function to call, such as deferred in main, on normal exit: it stops watching the signals,
+// and runs CleanupLoopDevices.
+func StartLoopDeviceJanitor(signals ...os.Signal) func() {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+	}
+
+	received := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(received, signals...)
+
+	go func() {
+		select {
+		case sig := <-received:
+			signal.Stop(received)
+			CleanupLoopDevices()
+			if sysSignal, ok := sig.(syscall.Signal); ok {
+				syscall.Kill(os.Getpid(), sysSignal)
+			}
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(received)
+			close(done)
+			CleanupLoopDevices()
+		})
+	}
+}
+
+// detachLoopDevices detaches the unused loop devices the device attached.
+func (device *Device) detachLoopDevices() {
+	detachUnusedLoopDevices(device.loopDevices)
+	device.loopDevices = nil
+}
+
+// detachUnusedLoopDevices detaches the loop devices named 'names' the package attached, which are neither held nor mounted.
+// Loop devices no longer backed by the image file they were attached to, which may have been reused by others, are forgotten.
+// Returns the names of the detached loop devices, along with an error if one of them could not be inspected or detached.
+func detachUnusedLoopDevices(names []string) ([]string, error) {
+	detached := []string{}
+	for _, name := range names {
+		attachedLoopDevices.Lock()
+		path, found := attachedLoopDevices.backingFiles[name]
+		attachedLoopDevices.Unlock()
+		if !found {
+			continue
+		}
+
+		if !loopDeviceBackedBy(name, path) {
+			forgetLoopDevice(name)
+			continue
+		}
+
+		reason, err := blockDeviceUsage(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				forgetLoopDevice(name)
+				continue
+			}
+			return detached, err
+		}
+		if reason != "" {
+			continue
+		}
+
+		if err := detachLoopDevice(name); err != nil {
+			return detached, err
+		}
+		forgetLoopDevice(name)
+		detached = append(detached, name)
+	}
+
+	return detached, nil
+}
+
+// loopDeviceBackedBy reports whether the loop device named 'name' is backed by the image file at 'path'.
+func loopDeviceBackedBy(name string, path string) bool {
+	for _, backedName := range loopDevicesBackedBy(path) {
+		if backedName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// forgetLoopDevice removes the loop device named 'name' from the loop devices the package attached.
+func forgetLoopDevice(name string) {
+	attachedLoopDevices.Lock()
+	delete(attachedLoopDevices.backingFiles, name)
+	attachedLoopDevices.Unlock()
+}
+
+// detachLoopDevice detaches the loop device named 'name' from its backing file through the LOOP_CLR_FD ioctl.
+// Loop devices that were already detached are ignored.
+func detachLoopDevice(name string) error {
+	path := filepath.Join(devPath, name)
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if result, err := C.loop_clear_fd(C.int(file.Fd())); result < 0 && err != syscall.ENXIO {
+		return &os.PathError{Op: "ioctl", Path: path, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// attachLoopDevice attaches the image file at 'path' to a free loop device, without autoclear, like a leaked loop device.
+func attachLoopDevice(path string, test *testing.T) string {
+	output, err := exec.Command("losetup", "--find", "--show", path).Output()
+	if err != nil {
+		test.Fatalf("Could not attach a loop device: %v", err)
+	}
+
+	return filepath.Base(strings.TrimSpace(string(output)))
+}
+
+func Test_Device_Free_Detaches_Unused_Loop_Devices_It_Attached(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	// Loop devices attached by others, such as with losetup, are left alone.
+	otherName := attachLoopDevice(DevicePath, test)
+	defer exec.Command("losetup", "--detach", filepath.Join("/dev", otherName)).Run()
+
+	// Loop devices attached while the device was being activated are detached.
+	recordAttached := device.trackLoopDevices()
+	name := attachLoopDevice(DevicePath, test)
+	recordAttached()
+
+	device.Free()
+
+	names := loopDevicesBackedBy(DevicePath)
+	if len(names) != 1 || names[0] != otherName {
+		exec.Command("losetup", "--detach", filepath.Join("/dev", name)).Run()
+		test.Errorf("Only loop device '%s' should have been left, but found: %v", otherName, names)
+	}
+}
+
+func Test_CleanupLoopDevices(test *testing.T) {
+	requirePrivileges(test)
+
+	image, err := ioutil.TempFile("", "loopcleanup")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.Remove(image.Name())
+	testWrapper := TestWrapper{test}
+	testWrapper.AssertNoError(image.Truncate(8 * 1024 * 1024))
+	image.Close()
+
+	device := &Device{imagePath: loopImagePath(image.Name())}
+	recordAttached := device.trackLoopDevices()
+	name := attachLoopDevice(image.Name(), test)
+	recordAttached()
+
+	detached, err := CleanupLoopDevices()
+	testWrapper.AssertNoError(err)
+
+	found := false
+	for _, detachedName := range detached {
+		found = found || detachedName == name
+	}
+	if !found {
+		exec.Command("losetup", "--detach", filepath.Join("/dev", name)).Run()
+		test.Errorf("Loop device '%s' should have been detached, but detached: %v", name, detached)
+	}
+
+	if names := loopDevicesBackedBy(image.Name()); len(names) != 0 {
+		test.Errorf("Loop devices should have been detached, but found: %v", names)
+	}
+}
+
+func Test_StartLoopDeviceJanitor_Cleans_Up_On_Signal(test *testing.T) {
+	requirePrivileges(test)
+
+	image, err := ioutil.TempFile("", "loopcleanup")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.Remove(image.Name())
+	testWrapper := TestWrapper{test}
+	testWrapper.AssertNoError(image.Truncate(8 * 1024 * 1024))
+	image.Close()
+
+	device := &Device{imagePath: loopImagePath(image.Name())}
+	recordAttached := device.trackLoopDevices()
+	name := attachLoopDevice(image.Name(), test)
+	recordAttached()
+	defer exec.Command("losetup", "--detach", filepath.Join("/dev", name)).Run()
+
+	// The janitor sends the signal again once done, which this handler receives instead of the test process being killed.
+	received := make(chan os.Signal, 2)
+	signal.Notify(received, syscall.SIGUSR1)
+	defer signal.Stop(received)
+
+	stop := StartLoopDeviceJanitor(syscall.SIGUSR1)
+	defer stop()
+
+	testWrapper.AssertNoError(syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	for count := 0; count < 2; count++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			test.Fatal("The janitor should have sent the signal again once done.")
+		}
+	}
+
+	if names := loopDevicesBackedBy(image.Name()); len(names) != 0 {
+		test.Errorf("Loop devices should have been detached, but found: %v", names)
+	}
+}
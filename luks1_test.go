@@ -29,6 +29,8 @@ func Test_LUKS1_Format(test *testing.T) {
 }
 
 func Test_LUKS1_Load_ActivateByPassphrase_Deactivate(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 	luks1 := LUKS1{Hash: "sha256"}
 
@@ -61,6 +63,8 @@ func Test_LUKS1_Load_ActivateByPassphrase_Deactivate(test *testing.T) {
 }
 
 func Test_LUKS1_ActivateByVolumeKey_Deactivate(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	genericParams := GenericParams{
@@ -90,6 +94,8 @@ func Test_LUKS1_ActivateByVolumeKey_Deactivate(test *testing.T) {
 }
 
 func Test_LUKS1_ActivateByAutoGeneratedVolumeKey_Deactivate(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	genericParams := GenericParams{
@@ -159,6 +165,8 @@ func Test_LUKS1_KeyslotAddByPassphrase(test *testing.T) {
 }
 
 func Test_LUKS1_KeyslotChangeByPassphrase(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	device, err := Init(DevicePath)
@@ -185,3 +193,30 @@ func Test_LUKS1_KeyslotChangeByPassphrase(test *testing.T) {
 
 	device.Free()
 }
+
+func Test_LUKS1_ActivateByVolumeKey_Deactivate_By_Name(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	genericParams := GenericParams{
+		Cipher:        "aes",
+		CipherMode:    "xts-plain64",
+		VolumeKey:     generateKey(512/8, test),
+		VolumeKeySize: 512 / 8,
+	}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	err = device.Format(LUKS1{Hash: "sha256"}, genericParams)
+	testWrapper.AssertNoError(err)
+
+	err = device.ActivateByVolumeKey(DeviceName, genericParams.VolumeKey, genericParams.VolumeKeySize, CRYPT_ACTIVATE_READONLY)
+	testWrapper.AssertNoError(err)
+
+	device.Free()
+
+	err = Deactivate(DeviceName)
+	testWrapper.AssertNoError(err)
+}
@@ -1,9 +1,32 @@
 package cryptsetup
 
-// #cgo pkg-config: libcryptsetup
-// #include <libcryptsetup.h>
-// #include <stdlib.h>
-// #include <string.h>
+/*
+#cgo pkg-config: libcryptsetup
+#include <errno.h>
+#include <libcryptsetup.h>
+#include <stdlib.h>
+#include <string.h>
+
+// crypt_set_metadata_size and crypt_set_data_offset were added in libcryptsetup 2.1, which defines no feature macro:
+// CRYPT_REENCRYPT_INITIALIZE_ONLY, defined since 2.2, is checked instead. Older versions cannot customize the layout.
+static int set_metadata_size(struct crypt_device *cd, uint64_t metadata_size, uint64_t keyslots_size)
+{
+#ifdef CRYPT_REENCRYPT_INITIALIZE_ONLY
+	return crypt_set_metadata_size(cd, metadata_size, keyslots_size);
+#else
+	return -ENOTSUP;
+#endif
+}
+
+static int set_data_offset(struct crypt_device *cd, uint64_t data_offset)
+{
+#ifdef CRYPT_REENCRYPT_INITIALIZE_ONLY
+	return crypt_set_data_offset(cd, data_offset);
+#else
+	return -ENOTSUP;
+#endif
+}
+*/
 import "C"
 import "unsafe"
 
@@ -17,6 +40,14 @@ type LUKS2 struct {
 	SectorSize      uint32
 	Label           string
 	Subsystem       string
+	// MetadataSize is the size of each of the two JSON metadata areas, including the binary header, in bytes.
+	// It must be a power of two between 16KiB and 4MiB. If 0, libcryptsetup's default is used.
+	MetadataSize uint64
+	// KeyslotsSize is the size of the keyslots area, in bytes. If 0, it is derived from the data offset,
+	// or libcryptsetup's default is used, so the whole metadata area takes 16MiB.
+	KeyslotsSize uint64
+	// DataOffset is the offset of the encrypted data, in 512 byte sectors. If 0, it is aligned after the keyslots area.
+	DataOffset uint64
 }
 
 type PbkdfType struct {
@@ -30,14 +61,27 @@ type PbkdfType struct {
 }
 
 type IntegrityParams struct {
-	JournalSize       uint64
-	JournalWatermark  uint
+	JournalSize uint64
+	// JournalWatermark is the journal fill percentage at which dm-integrity starts flushing it. If 0, the kernel's default is used.
+	JournalWatermark uint
+	// JournalCommitTime is the time, in milliseconds, after which the journal is committed. If 0, the kernel's default is used.
 	JournalCommitTime uint
 
+	// BitmapMode replaces the journal by a bitmap of the areas being written, like `integritysetup --integrity-bitmap-mode`,
+	// which is faster, but cannot detect data and tags torn by a crash. See ActivationFlags.
+	BitmapMode bool
+	// BitmapSectorsPerBit is the number of 512 byte sectors tracked by each bit of the bitmap, in bitmap mode.
+	// If 0, the kernel's default is used.
+	BitmapSectorsPerBit uint
+	// BitmapFlushTime is the time, in milliseconds, after which the bitmap is flushed, in bitmap mode.
+	// If 0, the kernel's default is used.
+	BitmapFlushTime uint
+
 	InterleaveSectors uint32
 	TagSize           uint32
-	SectorSize        uint32
-	BufferSectors     uint32
+	// SectorSize is the size of the sectors protected by a tag, in bytes: a power of two between 512 and 4096.
+	SectorSize    uint32
+	BufferSectors uint32
 
 	Integrity        string
 	IntegrityKeySize uint32
@@ -51,11 +95,53 @@ type IntegrityParams struct {
 	JournalCryptKeySize uint32
 }
 
+// ActivationFlags returns the CRYPT_ACTIVATE_* flags selecting the parameters' journaling mode,
+// to be added to the flags the device is activated with, since the mode is not stored in the header.
+// Returns the flags on success, or an ENOTSUP error in bitmap mode if libcryptsetup is too old to select it.
+func (integrityParams IntegrityParams) ActivationFlags() (int, error) {
+	if integrityParams.BitmapMode {
+		if CRYPT_ACTIVATE_NO_JOURNAL_BITMAP == 0 {
+			return 0, &Error{functionName: "crypt_activate_by_volume_key", code: int(ENOTSUP), operation: "select integrity bitmap mode"}
+		}
+		return CRYPT_ACTIVATE_NO_JOURNAL_BITMAP, nil
+	}
+	return 0, nil
+}
+
+// journalTuning returns the watermark and the commit time handed to dm-integrity,
+// which holds the sectors per bit and the flush time of the bitmap instead, in bitmap mode.
+func (integrityParams IntegrityParams) journalTuning() (uint, uint) {
+	if integrityParams.BitmapMode {
+		return integrityParams.BitmapSectorsPerBit, integrityParams.BitmapFlushTime
+	}
+	return integrityParams.JournalWatermark, integrityParams.JournalCommitTime
+}
+
 // Name returns the LUKS2 device type name as a string.
 func (luks2 LUKS2) Name() string {
 	return C.CRYPT_LUKS2
 }
 
+// prepareFormat sets the metadata area sizes and the data offset, which crypt_format reads from the device context.
+// Setting them fails with -ENOTSUP if libcryptsetup is older than 2.2.
+func (luks2 LUKS2) prepareFormat(device *Device) error {
+	if luks2.MetadataSize != 0 || luks2.KeyslotsSize != 0 {
+		err := C.set_metadata_size(device.cryptDevice, C.uint64_t(luks2.MetadataSize), C.uint64_t(luks2.KeyslotsSize))
+		if err < 0 {
+			return device.newError("crypt_set_metadata_size", int(err), "format "+luks2.Name())
+		}
+	}
+
+	if luks2.DataOffset != 0 {
+		err := C.set_data_offset(device.cryptDevice, C.uint64_t(luks2.DataOffset))
+		if err < 0 {
+			return device.newError("crypt_set_data_offset", int(err), "format "+luks2.Name())
+		}
+	}
+
+	return nil
+}
+
 // Unmanaged is used to specialize LUKS2.
 func (luks2 LUKS2) Unmanaged() (unsafe.Pointer, func()) {
 	deallocations := make([]func(), 0)
@@ -141,8 +227,9 @@ func (luks2 LUKS2) Unmanaged() (unsafe.Pointer, func()) {
 		cIntegrityParams := (*C.struct_crypt_params_integrity)(C.malloc(C.sizeof_struct_crypt_params_integrity))
 
 		cIntegrityParams.journal_size = C.uint64_t(luks2.IntegrityParams.JournalSize)
-		cIntegrityParams.journal_watermark = C.uint(luks2.IntegrityParams.JournalWatermark)
-		cIntegrityParams.journal_commit_time = C.uint(luks2.IntegrityParams.JournalCommitTime)
+		watermark, commitTime := luks2.IntegrityParams.journalTuning()
+		cIntegrityParams.journal_watermark = C.uint(watermark)
+		cIntegrityParams.journal_commit_time = C.uint(commitTime)
 
 		cIntegrityParams.interleave_sectors = C.uint32_t(luks2.IntegrityParams.InterleaveSectors)
 		cIntegrityParams.tag_size = C.uint32_t(luks2.IntegrityParams.TagSize)
@@ -167,9 +254,9 @@ func (luks2 LUKS2) Unmanaged() (unsafe.Pointer, func()) {
 		}
 		cIntegrityParams.journal_integrity_key = nil
 		if luks2.IntegrityParams.JournalIntegrityKey != "" {
-			cIntegrityParams.journal_integrity_key = C.CString(luks2.IntegrityParams.JournalIntegrityKey)
+			cIntegrityParams.journal_integrity_key = safeCString(luks2.IntegrityParams.JournalIntegrityKey)
 			deallocations = append(deallocations, func() {
-				C.free(unsafe.Pointer(cIntegrityParams.journal_integrity_key))
+				safeFree(cIntegrityParams.journal_integrity_key)
 			})
 		}
 		cIntegrityParams.journal_integrity_key_size = C.uint32_t(luks2.IntegrityParams.JournalIntegrityKeySize)
@@ -183,9 +270,9 @@ func (luks2 LUKS2) Unmanaged() (unsafe.Pointer, func()) {
 		}
 		cIntegrityParams.journal_crypt_key = nil
 		if luks2.IntegrityParams.JournalCryptKey != "" {
-			cIntegrityParams.journal_crypt_key = C.CString(luks2.IntegrityParams.JournalCryptKey)
+			cIntegrityParams.journal_crypt_key = safeCString(luks2.IntegrityParams.JournalCryptKey)
 			deallocations = append(deallocations, func() {
-				C.free(unsafe.Pointer(cIntegrityParams.journal_crypt_key))
+				safeFree(cIntegrityParams.journal_crypt_key)
 			})
 		}
 		cIntegrityParams.journal_crypt_key_size = C.uint32_t(luks2.IntegrityParams.JournalCryptKeySize)
@@ -199,3 +286,33 @@ func (luks2 LUKS2) Unmanaged() (unsafe.Pointer, func()) {
 
 	return unsafe.Pointer(&cParams), deallocate
 }
+
+// FillDefaultValues sets the unset device independent parameters to the application-wide defaults.
+// The defaults are configured by SetDefaultLUKS2Params.
+func (luks2 *LUKS2) FillDefaultValues() {
+	defaults := DefaultLUKS2Params()
+
+	if luks2.PBKDFType == nil {
+		luks2.PBKDFType = defaults.PBKDFType
+	}
+
+	if luks2.Integrity == "" {
+		luks2.Integrity = defaults.Integrity
+		if luks2.IntegrityParams == nil {
+			luks2.IntegrityParams = defaults.IntegrityParams
+		}
+	}
+
+	if luks2.DataAlignment == 0 {
+		luks2.DataAlignment = defaults.DataAlignment
+	}
+
+	if luks2.SectorSize == 0 {
+		luks2.SectorSize = defaults.SectorSize
+	}
+
+	if luks2.MetadataSize == 0 && luks2.KeyslotsSize == 0 {
+		luks2.MetadataSize = defaults.MetadataSize
+		luks2.KeyslotsSize = defaults.KeyslotsSize
+	}
+}
@@ -66,6 +66,43 @@ func Test_LUKS2_Format_Using_PbkdfType(test *testing.T) {
 	device.Free()
 }
 
+func Test_LUKS2_Format_Using_MetadataSize(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	luks2 := LUKS2{SectorSize: 512, MetadataSize: 16 * 1024, KeyslotsSize: 1024 * 1024, DataOffset: 4096}
+	err = device.Format(luks2, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	metadataSize, keyslotsSize, err := device.MetadataSize()
+	testWrapper.AssertNoError(err)
+
+	if metadataSize != 16*1024 || keyslotsSize != 1024*1024 {
+		test.Errorf("Unexpected metadata size '%d' and keyslots size '%d'.", metadataSize, keyslotsSize)
+	}
+
+	if device.DataOffset() != 4096 {
+		test.Errorf("Data offset should have been 4096 sectors, but was: %d", device.DataOffset())
+	}
+}
+
+func Test_LUKS2_Format_Using_MetadataSize_Should_Fail_For_Invalid_Size(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.Format(LUKS2{SectorSize: 512, MetadataSize: 1000}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertError(err)
+	testWrapper.AssertErrorCodeEquals(err, -22)
+}
+
 func Test_LUKS2_Format_Using_IntegrityParams_Should_Fail_For_Invalid_Parameters(test *testing.T) {
 	testWrapper := TestWrapper{test}
 
@@ -89,6 +126,8 @@ func Test_LUKS2_Format_Using_IntegrityParams_Should_Fail_For_Invalid_Parameters(
 }
 
 func Test_LUKS2_Format_Using_IntegrityParams(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	integrityParams := IntegrityParams{
@@ -125,7 +164,36 @@ func Test_LUKS2_Format_Using_IntegrityParams(test *testing.T) {
 	device.Free()
 }
 
+func Test_IntegrityParams_Journal_Tuning(test *testing.T) {
+	journal := IntegrityParams{JournalWatermark: 50, JournalCommitTime: 1000, BitmapSectorsPerBit: 2048, BitmapFlushTime: 5000}
+	if watermark, commitTime := journal.journalTuning(); watermark != 50 || commitTime != 1000 {
+		test.Errorf("The journal parameters should have been used, but got %d and %d.", watermark, commitTime)
+	}
+	if flags, err := journal.ActivationFlags(); err != nil || flags != 0 {
+		test.Errorf("The journal mode should not need activation flags, but needs: %#x (%v)", flags, err)
+	}
+
+	bitmap := journal
+	bitmap.BitmapMode = true
+	if sectorsPerBit, flushTime := bitmap.journalTuning(); sectorsPerBit != 2048 || flushTime != 5000 {
+		test.Errorf("The bitmap parameters should have been used, but got %d and %d.", sectorsPerBit, flushTime)
+	}
+	if CRYPT_ACTIVATE_NO_JOURNAL_BITMAP == 0 {
+		if _, err := bitmap.ActivationFlags(); err == nil || err.(*Error).Code() != int(ENOTSUP) {
+			test.Errorf("The bitmap mode should fail with ENOTSUP when libcryptsetup cannot select it, but got: %v", err)
+		}
+	}
+	if !SupportsFlag(CRYPT_ACTIVATE_NO_JOURNAL_BITMAP) {
+		test.Skip("The integrity bitmap mode is not supported in this environment")
+	}
+	if flags, err := bitmap.ActivationFlags(); err != nil || flags != CRYPT_ACTIVATE_NO_JOURNAL_BITMAP {
+		test.Errorf("The bitmap mode should be selected by CRYPT_ACTIVATE_NO_JOURNAL_BITMAP, but got: %#x (%v)", flags, err)
+	}
+}
+
 func Test_LUKS2_Load_ActivateByPassphrase_Deactivate(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 	luks2 := LUKS2{SectorSize: 512}
 
@@ -157,6 +225,8 @@ func Test_LUKS2_Load_ActivateByPassphrase_Deactivate(test *testing.T) {
 }
 
 func Test_LUKS2_ActivateByVolumeKey_Deactivate(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	genericParams := GenericParams{
@@ -186,6 +256,8 @@ func Test_LUKS2_ActivateByVolumeKey_Deactivate(test *testing.T) {
 }
 
 func Test_LUKS2_ActivateByAutoGeneratedVolumeKey_Deactivate(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	genericParams := GenericParams{
@@ -255,6 +327,8 @@ func Test_LUKS2_KeyslotAddByPassphrase(test *testing.T) {
 }
 
 func Test_LUKS2_KeyslotChangeByPassphrase(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	device, err := Init(DevicePath)
@@ -281,3 +355,52 @@ func Test_LUKS2_KeyslotChangeByPassphrase(test *testing.T) {
 
 	device.Free()
 }
+
+func Test_LUKS2_CheckRequirements(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	requirements, err := device.PersistentFlags(CRYPT_FLAGS_REQUIREMENTS)
+	testWrapper.AssertNoError(err)
+	if requirements != 0 {
+		test.Errorf("A freshly formatted device should have no requirements, but had: %#x", requirements)
+	}
+
+	err = device.CheckRequirements()
+	testWrapper.AssertNoError(err)
+}
+
+func Test_RequirementsError_Unknown(test *testing.T) {
+	err := &RequirementsError{requirements: CRYPT_REQUIREMENT_UNKNOWN | CRYPT_REQUIREMENT_ONLINE_REENCRYPT}
+	if !err.Unknown() {
+		test.Error("Unknown() should have returned `true`.")
+	}
+
+	err = &RequirementsError{requirements: CRYPT_REQUIREMENT_ONLINE_REENCRYPT}
+	if err.Unknown() {
+		test.Error("Unknown() should have returned `false`.")
+	}
+}
+
+func Test_LUKS2_ActivateByKeyring_Fails_If_Key_Is_Not_Found(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	err = device.ActivateByKeyring(DeviceName, "cryptsetup:nonExistingKeyDescription", CRYPT_ANY_SLOT, CRYPT_ACTIVATE_READONLY)
+	testWrapper.AssertError(err)
+}
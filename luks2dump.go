@@ -0,0 +1,364 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LUKS2Dump is the structured content of a LUKS2 header's JSON metadata area,
+// along with the identifiers stored in its binary header.
+type LUKS2Dump struct {
+	UUID      string
+	Label     string
+	Subsystem string
+	Keyslots  []LUKS2KeyslotInfo
+	Digests   []LUKS2DigestInfo
+	Segments  []LUKS2SegmentInfo
+
+	// metadata is the JSON metadata area the dump was parsed from, as stored in the header.
+	metadata json.RawMessage
+}
+
+// LUKS2KeyslotInfo describes a keyslot, and the digests and segments it is associated with.
+type LUKS2KeyslotInfo struct {
+	ID      int
+	Type    string
+	KeySize int
+	// Digests are the IDs of the digests verifying the key stored in this keyslot.
+	Digests []int
+	// Segments are the IDs of the segments that can be unlocked using the key stored in this keyslot.
+	Segments []int
+	// Salt is the base64 encoded PBKDF salt.
+	Salt string
+}
+
+// LUKS2DigestInfo describes a digest, associating keyslots with the segments their key unlocks.
+type LUKS2DigestInfo struct {
+	ID       int
+	Type     string
+	Keyslots []int
+	Segments []int
+	// Salt is the base64 encoded salt of the digest.
+	Salt string
+	// Digest is the base64 encoded digest of the volume key.
+	Digest string
+}
+
+// LUKS2SegmentInfo describes a data segment.
+// Devices in the middle of a reencryption have several segments, such as "crypt" segments using the old and the new
+// volume keys, and "linear" segments for areas that are not encrypted.
+type LUKS2SegmentInfo struct {
+	ID   int
+	Type string
+	// Offset is the offset of the segment on the data device, in bytes, as a decimal string.
+	Offset string
+	// Size is the size of the segment, in bytes, as a decimal string, or "dynamic" if it extends to the end of the device.
+	Size  string
+	Flags []string
+	// Encryption is the cipher specification of a "crypt" segment, such as "aes-xts-plain64".
+	Encryption string
+	// SectorSize is the encryption sector size of a "crypt" segment, in bytes.
+	SectorSize int
+	// IVTweak is the offset added to sector numbers when computing the IVs of a "crypt" segment, as a decimal string.
+	IVTweak string
+}
+
+// Active reports whether the segment holds live data, as opposed to a backup segment used during reencryption.
+func (segment LUKS2SegmentInfo) Active() bool {
+	for _, flag := range segment.Flags {
+		if strings.HasPrefix(flag, "backup-") {
+			return false
+		}
+	}
+	return true
+}
+
+// Reencrypting reports whether the header describes a device in the middle of a reencryption,
+// in which case the data spans several segments.
+func (dump LUKS2Dump) Reencrypting() bool {
+	for _, segment := range dump.Segments {
+		for _, flag := range segment.Flags {
+			if flag == "in-reencryption" {
+				return true
+			}
+		}
+	}
+
+	for _, keyslot := range dump.Keyslots {
+		if keyslot.Type == "reencrypt" {
+			return true
+		}
+	}
+	return false
+}
+
+// OrphanedKeyslots returns the IDs of the keyslots whose key does not unlock any active segment,
+// as may be left behind by a failed reencryption. Reencryption keyslots hold no key, and are never reported.
+func (dump LUKS2Dump) OrphanedKeyslots() []int {
+	active := make(map[int]bool)
+	for _, segment := range dump.Segments {
+		if segment.Active() {
+			active[segment.ID] = true
+		}
+	}
+
+	orphaned := []int{}
+	for _, keyslot := range dump.Keyslots {
+		if keyslot.Type == "reencrypt" {
+			continue
+		}
+
+		associated := false
+		for _, segment := range keyslot.Segments {
+			if active[segment] {
+				associated = true
+				break
+			}
+		}
+		if !associated {
+			orphaned = append(orphaned, keyslot.ID)
+		}
+	}
+	return orphaned
+}
+
+// DumpLUKS2 reads the JSON metadata area of the device's LUKS2 header,
+// using the most recent header copy with a valid checksum.
+// Returns the parsed metadata on success, or an error otherwise.
+func (device *Device) DumpLUKS2() (LUKS2Dump, error) {
+	if err := device.checkUsable(); err != nil {
+		return LUKS2Dump{}, err
+	}
+
+	var dump LUKS2Dump
+
+	report, err := device.CheckHeader()
+	if err != nil {
+		return dump, err
+	}
+
+	header := report.Primary
+	if !header.ChecksumValid || (report.Secondary.ChecksumValid && report.Secondary.SequenceID > header.SequenceID) {
+		header = report.Secondary
+	}
+	if !header.ChecksumValid {
+		return dump, fmt.Errorf("no valid LUKS2 header copy found on '%s'", device.MetadataDevicePath())
+	}
+
+	file, err := os.Open(device.MetadataDevicePath())
+	if err != nil {
+		return dump, err
+	}
+	defer file.Close()
+
+	headerArea := make([]byte, header.Size)
+	if _, err := file.ReadAt(headerArea, int64(header.Offset)); err != nil {
+		return dump, err
+	}
+
+	if dump, err = parseLUKS2Dump(bytes.TrimRight(headerArea[luks2BinaryHeaderSize:], "\x00")); err != nil {
+		return dump, err
+	}
+	dump.Label = luks2HeaderString(headerArea[24:72])
+	dump.UUID = luks2HeaderString(headerArea[168:208])
+	dump.Subsystem = luks2HeaderString(headerArea[208:256])
+
+	return dump, nil
+}
+
+// luks2HeaderString returns the NUL terminated string in 'field' of a LUKS2 binary header.
+func luks2HeaderString(field []byte) string {
+	if end := bytes.IndexByte(field, 0); end >= 0 {
+		field = field[:end]
+	}
+	return string(field)
+}
+
+// luks2Metadata mirrors the parts of the LUKS2 JSON metadata used by LUKS2Dump.
+type luks2Metadata struct {
+	Keyslots map[string]luks2KeyslotMetadata `json:"keyslots"`
+	Digests  map[string]luks2DigestMetadata  `json:"digests"`
+	Segments map[string]luks2SegmentMetadata `json:"segments"`
+}
+
+type luks2KeyslotMetadata struct {
+	Type    string `json:"type"`
+	KeySize int    `json:"key_size"`
+	KDF     struct {
+		Salt string `json:"salt,omitempty"`
+	} `json:"kdf"`
+}
+
+type luks2DigestMetadata struct {
+	Type     string   `json:"type"`
+	Keyslots []string `json:"keyslots"`
+	Segments []string `json:"segments"`
+	Salt     string   `json:"salt,omitempty"`
+	Digest   string   `json:"digest,omitempty"`
+}
+
+type luks2SegmentMetadata struct {
+	Type       string   `json:"type"`
+	Offset     string   `json:"offset"`
+	Size       string   `json:"size"`
+	Flags      []string `json:"flags,omitempty"`
+	Encryption string   `json:"encryption,omitempty"`
+	SectorSize int      `json:"sector_size,omitempty"`
+	IVTweak    string   `json:"iv_tweak,omitempty"`
+}
+
+// MarshalJSON encodes the dump as the JSON metadata area it was read from, unchanged but for whitespace,
+// as printed by `cryptsetup luksDump --dump-json-metadata`, so inventory tooling can consume either.
+// Dumps holding no metadata read from a header, such as those returned by DumpRedacted, are encoded
+// in the same layout from the fields known to LUKS2Dump only.
+// The identifiers from the binary header, such as the UUID, are not part of the JSON metadata, and are left out.
+func (dump LUKS2Dump) MarshalJSON() ([]byte, error) {
+	if dump.metadata != nil {
+		return dump.metadata, nil
+	}
+
+	metadata := luks2Metadata{
+		Keyslots: make(map[string]luks2KeyslotMetadata),
+		Digests:  make(map[string]luks2DigestMetadata),
+		Segments: make(map[string]luks2SegmentMetadata),
+	}
+
+	for _, keyslot := range dump.Keyslots {
+		keyslotMetadata := luks2KeyslotMetadata{Type: keyslot.Type, KeySize: keyslot.KeySize}
+		keyslotMetadata.KDF.Salt = keyslot.Salt
+		metadata.Keyslots[strconv.Itoa(keyslot.ID)] = keyslotMetadata
+	}
+
+	for _, digest := range dump.Digests {
+		metadata.Digests[strconv.Itoa(digest.ID)] = luks2DigestMetadata{
+			Type:     digest.Type,
+			Keyslots: formatLUKS2IDs(digest.Keyslots),
+			Segments: formatLUKS2IDs(digest.Segments),
+			Salt:     digest.Salt,
+			Digest:   digest.Digest,
+		}
+	}
+
+	for _, segment := range dump.Segments {
+		metadata.Segments[strconv.Itoa(segment.ID)] = luks2SegmentMetadata{
+			Type:       segment.Type,
+			Offset:     segment.Offset,
+			Size:       segment.Size,
+			Flags:      segment.Flags,
+			Encryption: segment.Encryption,
+			SectorSize: segment.SectorSize,
+			IVTweak:    segment.IVTweak,
+		}
+	}
+
+	return json.Marshal(metadata)
+}
+
+// parseLUKS2Dump parses LUKS2 JSON metadata, resolving the keyslot to segment associations through digests.
+func parseLUKS2Dump(data []byte) (LUKS2Dump, error) {
+	var dump LUKS2Dump
+	var metadata luks2Metadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return dump, err
+	}
+	dump.metadata = append(json.RawMessage(nil), data...)
+
+	keyslotDigests := make(map[int][]int)
+	keyslotSegments := make(map[int][]int)
+
+	for id, digest := range metadata.Digests {
+		digestID, err := strconv.Atoi(id)
+		if err != nil {
+			return dump, fmt.Errorf("invalid digest ID '%s'", id)
+		}
+		keyslots, err := parseLUKS2IDs(digest.Keyslots)
+		if err != nil {
+			return dump, err
+		}
+		segments, err := parseLUKS2IDs(digest.Segments)
+		if err != nil {
+			return dump, err
+		}
+
+		for _, keyslot := range keyslots {
+			keyslotDigests[keyslot] = append(keyslotDigests[keyslot], digestID)
+			keyslotSegments[keyslot] = append(keyslotSegments[keyslot], segments...)
+		}
+		dump.Digests = append(dump.Digests, LUKS2DigestInfo{
+			ID:       digestID,
+			Type:     digest.Type,
+			Keyslots: keyslots,
+			Segments: segments,
+			Salt:     digest.Salt,
+			Digest:   digest.Digest,
+		})
+	}
+
+	for id, keyslot := range metadata.Keyslots {
+		keyslotID, err := strconv.Atoi(id)
+		if err != nil {
+			return dump, fmt.Errorf("invalid keyslot ID '%s'", id)
+		}
+		sort.Ints(keyslotDigests[keyslotID])
+		sort.Ints(keyslotSegments[keyslotID])
+		dump.Keyslots = append(dump.Keyslots, LUKS2KeyslotInfo{
+			ID:       keyslotID,
+			Type:     keyslot.Type,
+			KeySize:  keyslot.KeySize,
+			Digests:  keyslotDigests[keyslotID],
+			Segments: keyslotSegments[keyslotID],
+			Salt:     keyslot.KDF.Salt,
+		})
+	}
+
+	for id, segment := range metadata.Segments {
+		segmentID, err := strconv.Atoi(id)
+		if err != nil {
+			return dump, fmt.Errorf("invalid segment ID '%s'", id)
+		}
+		dump.Segments = append(dump.Segments, LUKS2SegmentInfo{
+			ID:         segmentID,
+			Type:       segment.Type,
+			Offset:     segment.Offset,
+			Size:       segment.Size,
+			Flags:      segment.Flags,
+			Encryption: segment.Encryption,
+			SectorSize: segment.SectorSize,
+			IVTweak:    segment.IVTweak,
+		})
+	}
+
+	sort.Slice(dump.Keyslots, func(i, j int) bool { return dump.Keyslots[i].ID < dump.Keyslots[j].ID })
+	sort.Slice(dump.Digests, func(i, j int) bool { return dump.Digests[i].ID < dump.Digests[j].ID })
+	sort.Slice(dump.Segments, func(i, j int) bool { return dump.Segments[i].ID < dump.Segments[j].ID })
+
+	return dump, nil
+}
+
+// parseLUKS2IDs converts the string IDs used in LUKS2 JSON metadata to integers, in ascending order.
+func parseLUKS2IDs(ids []string) ([]int, error) {
+	parsed := make([]int, 0, len(ids))
+	for _, id := range ids {
+		value, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID '%s'", id)
+		}
+		parsed = append(parsed, value)
+	}
+	sort.Ints(parsed)
+	return parsed, nil
+}
+
+// formatLUKS2IDs converts integer IDs to the strings used in LUKS2 JSON metadata.
+func formatLUKS2IDs(ids []int) []string {
+	formatted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		formatted = append(formatted, strconv.Itoa(id))
+	}
+	return formatted
+}
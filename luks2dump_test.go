@@ -0,0 +1,141 @@
+package cryptsetup
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_LUKS2_DumpLUKS2(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(1, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	dump, err := device.DumpLUKS2()
+	testWrapper.AssertNoError(err)
+
+	if len(dump.Keyslots) != 1 || dump.Keyslots[0].ID != 1 || dump.Keyslots[0].KeySize != 64 {
+		test.Fatalf("Unexpected keyslots: %+v", dump.Keyslots)
+	}
+
+	if len(dump.Keyslots[0].Segments) != 1 || dump.Keyslots[0].Segments[0] != 0 {
+		test.Errorf("Keyslot should have been associated with segment 0: %+v", dump.Keyslots[0])
+	}
+
+	if len(dump.Segments) != 1 || dump.Segments[0].Type != "crypt" {
+		test.Errorf("Unexpected segments: %+v", dump.Segments)
+	}
+
+	if orphaned := dump.OrphanedKeyslots(); len(orphaned) != 0 {
+		test.Errorf("No keyslot should have been orphaned, but found: %v", orphaned)
+	}
+}
+
+func Test_LUKS2Dump_OrphanedKeyslots(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	dump, err := parseLUKS2Dump([]byte(`{
+		"keyslots": {"0": {"type": "luks2", "key_size": 64}, "1": {"type": "luks2", "key_size": 64}, "2": {"type": "luks2", "key_size": 64}},
+		"digests": {
+			"0": {"type": "pbkdf2", "keyslots": ["0"], "segments": ["0"]},
+			"1": {"type": "pbkdf2", "keyslots": ["1"], "segments": ["1"]}
+		},
+		"segments": {
+			"0": {"type": "crypt", "offset": "16777216", "size": "dynamic"},
+			"1": {"type": "crypt", "offset": "16777216", "size": "dynamic", "flags": ["backup-previous"]}
+		}
+	}`))
+	testWrapper.AssertNoError(err)
+
+	orphaned := dump.OrphanedKeyslots()
+	if len(orphaned) != 2 || orphaned[0] != 1 || orphaned[1] != 2 {
+		test.Errorf("Keyslots 1 and 2 should have been orphaned, but found: %v", orphaned)
+	}
+}
+
+func Test_LUKS2_DumpLUKS2_Fails_If_Device_Has_No_Header(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	_, err = device.DumpLUKS2()
+	testWrapper.AssertError(err)
+}
+
+func Test_LUKS2Dump_Reencrypting(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	dump, err := parseLUKS2Dump([]byte(`{
+		"keyslots": {"0": {"type": "luks2", "key_size": 64}, "1": {"type": "luks2", "key_size": 64}, "2": {"type": "reencrypt", "key_size": 1}},
+		"digests": {
+			"0": {"type": "pbkdf2", "keyslots": ["0"], "segments": ["0", "2"]},
+			"1": {"type": "pbkdf2", "keyslots": ["1"], "segments": ["1", "3"]}
+		},
+		"segments": {
+			"0": {"type": "crypt", "offset": "16777216", "size": "8388608", "iv_tweak": "0", "encryption": "aes-xts-plain64", "sector_size": 4096, "flags": ["in-reencryption"]},
+			"1": {"type": "crypt", "offset": "25165824", "size": "dynamic", "iv_tweak": "16384", "encryption": "aes-cbc-essiv:sha256", "sector_size": 512},
+			"2": {"type": "crypt", "offset": "16777216", "size": "dynamic", "iv_tweak": "0", "encryption": "aes-xts-plain64", "sector_size": 4096, "flags": ["backup-final"]},
+			"3": {"type": "crypt", "offset": "16777216", "size": "dynamic", "iv_tweak": "0", "encryption": "aes-cbc-essiv:sha256", "sector_size": 512, "flags": ["backup-previous"]}
+		}
+	}`))
+	testWrapper.AssertNoError(err)
+
+	if !dump.Reencrypting() {
+		test.Error("Dump should have been reported as reencrypting.")
+	}
+
+	if len(dump.Segments) != 4 || dump.Segments[1].Encryption != "aes-cbc-essiv:sha256" || dump.Segments[1].IVTweak != "16384" || dump.Segments[0].SectorSize != 4096 {
+		test.Errorf("Unexpected segments: %+v", dump.Segments)
+	}
+
+	if orphaned := dump.OrphanedKeyslots(); len(orphaned) != 0 {
+		test.Errorf("No keyslot should have been orphaned, but found: %v", orphaned)
+	}
+}
+
+func Test_LUKS2Dump_MarshalJSON(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	metadata := `{"keyslots":{"1":{"type":"luks2","key_size":64,"af":{"type":"luks1","stripes":4000,"hash":"sha256"},"kdf":{"type":"argon2id","salt":"c2FsdA=="}}},` +
+		`"tokens":{},` +
+		`"digests":{"0":{"type":"pbkdf2","keyslots":["1"],"segments":["0"],"digest":"ZGlnZXN0"}},` +
+		`"segments":{"0":{"type":"crypt","offset":"16777216","size":"dynamic","iv_tweak":"0","encryption":"aes-xts-plain64","sector_size":512}}}`
+	dump, err := parseLUKS2Dump([]byte(metadata))
+	testWrapper.AssertNoError(err)
+
+	encoded, err := json.Marshal(dump)
+	testWrapper.AssertNoError(err)
+	if string(encoded) != metadata {
+		test.Errorf("The JSON should be the metadata the dump was read from: %s", encoded)
+	}
+
+	dump.metadata = nil
+	encoded, err = json.Marshal(dump)
+	testWrapper.AssertNoError(err)
+
+	expected := `{"keyslots":{"1":{"type":"luks2","key_size":64,"kdf":{"salt":"c2FsdA=="}}},` +
+		`"digests":{"0":{"type":"pbkdf2","keyslots":["1"],"segments":["0"],"digest":"ZGlnZXN0"}},` +
+		`"segments":{"0":{"type":"crypt","offset":"16777216","size":"dynamic","encryption":"aes-xts-plain64","sector_size":512,"iv_tweak":"0"}}}`
+	if string(encoded) != expected {
+		test.Errorf("Unexpected JSON: %s", encoded)
+	}
+
+	decoded, err := parseLUKS2Dump(encoded)
+	testWrapper.AssertNoError(err)
+	decoded.metadata = nil
+	if !reflect.DeepEqual(decoded, dump) {
+		test.Errorf("The JSON should decode to the same dump: %+v", decoded)
+	}
+}
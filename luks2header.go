@@ -0,0 +1,163 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+import "C"
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+const (
+	luks2BinaryHeaderSize = 4096
+	luks2ChecksumOffset   = 448
+	luks2ChecksumLength   = 64
+
+	// luks2MinHeaderSize and luks2MaxHeaderSize bound the size of a header copy, which must be a power of two.
+	luks2MinHeaderSize = 0x4000
+	luks2MaxHeaderSize = 0x400000
+)
+
+var (
+	luks2PrimaryMagic   = []byte{'L', 'U', 'K', 'S', 0xba, 0xbe}
+	luks2SecondaryMagic = []byte{'S', 'K', 'U', 'L', 0xba, 0xbe}
+
+	// luks2SecondaryOffsets are the offsets at which the secondary header may be found, as defined by the LUKS2 on-disk format.
+	luks2SecondaryOffsets = []int64{0x4000, 0x8000, 0x10000, 0x20000, 0x40000, 0x80000, 0x100000, 0x200000, 0x400000}
+
+	luks2ChecksumAlgorithms = map[string]func() hash.Hash{
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+)
+
+// LUKS2HeaderInfo describes one of the two copies of a LUKS2 header.
+type LUKS2HeaderInfo struct {
+	// Found reports whether a header copy with a valid magic was found.
+	Found bool
+	// Offset is the offset of the header copy on the device, in bytes.
+	Offset uint64
+	// Size is the size of the header copy, including its JSON area, in bytes.
+	Size uint64
+	// SequenceID is incremented on every header update.
+	SequenceID uint64
+	// ChecksumValid reports whether the header copy's checksum matches its contents.
+	ChecksumValid bool
+}
+
+// LUKS2HeaderReport is the result of comparing both copies of a LUKS2 header.
+type LUKS2HeaderReport struct {
+	Primary   LUKS2HeaderInfo
+	Secondary LUKS2HeaderInfo
+}
+
+// Consistent reports whether both header copies were found, have valid checksums, and share the same sequence ID.
+func (report LUKS2HeaderReport) Consistent() bool {
+	return report.Primary.Found && report.Primary.ChecksumValid &&
+		report.Secondary.Found && report.Secondary.ChecksumValid &&
+		report.Primary.SequenceID == report.Secondary.SequenceID
+}
+
+// CheckHeader reads both the primary and the secondary copies of the device's LUKS2 header,
+// verifying their checksums and comparing their sequence IDs, so header corruption can be detected before it becomes unreadable.
+// Returns the report on success, or an error if the header could not be read, or if neither copy could be found.
+func (device *Device) CheckHeader() (LUKS2HeaderReport, error) {
+	if err := device.checkUsable(); err != nil {
+		return LUKS2HeaderReport{}, err
+	}
+
+	var report LUKS2HeaderReport
+
+	headerPath := device.MetadataDevicePath()
+	file, err := os.Open(headerPath)
+	if err != nil {
+		return report, err
+	}
+	defer file.Close()
+
+	if report.Primary, err = readLUKS2HeaderInfo(file, 0, luks2PrimaryMagic); err != nil {
+		return report, err
+	}
+
+	secondaryOffsets := luks2SecondaryOffsets
+	if report.Primary.Found && validLUKS2HeaderSize(report.Primary.Size) {
+		secondaryOffsets = []int64{int64(report.Primary.Size)}
+	}
+	for _, offset := range secondaryOffsets {
+		if report.Secondary, err = readLUKS2HeaderInfo(file, offset, luks2SecondaryMagic); err != nil {
+			return report, err
+		}
+		if report.Secondary.Found {
+			break
+		}
+	}
+
+	if !report.Primary.Found && !report.Secondary.Found {
+		return report, fmt.Errorf("no LUKS2 header found on '%s'", headerPath)
+	}
+
+	return report, nil
+}
+
+// validLUKS2HeaderSize reports whether 'size' is a header size allowed by the LUKS2 on-disk format,
+// so a corrupt size is never used to allocate the buffer the header copy is read into.
+func validLUKS2HeaderSize(size uint64) bool {
+	return size >= luks2MinHeaderSize && size <= luks2MaxHeaderSize && size&(size-1) == 0
+}
+
+// readLUKS2HeaderInfo reads the header copy with the expected magic at 'offset'.
+// A header copy that cannot be found is reported as such, and is not considered an error.
+func readLUKS2HeaderInfo(reader io.ReaderAt, offset int64, magic []byte) (LUKS2HeaderInfo, error) {
+	info := LUKS2HeaderInfo{Offset: uint64(offset)}
+
+	binaryHeader := make([]byte, luks2BinaryHeaderSize)
+	if _, err := reader.ReadAt(binaryHeader, offset); err != nil {
+		if err == io.EOF {
+			return info, nil
+		}
+		return info, err
+	}
+
+	if !bytes.Equal(binaryHeader[0:6], magic) || binary.BigEndian.Uint16(binaryHeader[6:8]) != 2 {
+		return info, nil
+	}
+
+	info.Found = true
+	info.Size = binary.BigEndian.Uint64(binaryHeader[8:16])
+	info.SequenceID = binary.BigEndian.Uint64(binaryHeader[16:24])
+
+	checksumAlgorithm := string(bytes.TrimRight(binaryHeader[72:104], "\x00"))
+	newHash, found := luks2ChecksumAlgorithms[checksumAlgorithm]
+	if !found || !validLUKS2HeaderSize(info.Size) {
+		return info, nil
+	}
+
+	header := make([]byte, info.Size)
+	if _, err := reader.ReadAt(header, offset); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return info, nil
+		}
+		return info, err
+	}
+
+	checksum := make([]byte, luks2ChecksumLength)
+	copy(checksum, header[luks2ChecksumOffset:luks2ChecksumOffset+luks2ChecksumLength])
+	for index := luks2ChecksumOffset; index < luks2ChecksumOffset+luks2ChecksumLength; index++ {
+		header[index] = 0
+	}
+
+	checksumHash := newHash()
+	checksumHash.Write(header)
+	computed := checksumHash.Sum(nil)
+	info.ChecksumValid = bytes.Equal(checksum[:len(computed)], computed)
+
+	return info, nil
+}
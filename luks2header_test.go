@@ -0,0 +1,100 @@
+package cryptsetup
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_LUKS2_CheckHeader(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	report, err := device.CheckHeader()
+	testWrapper.AssertNoError(err)
+
+	if !report.Consistent() {
+		test.Errorf("A freshly formatted header should be consistent: %+v", report)
+	}
+
+	if report.Secondary.Offset != report.Primary.Size {
+		test.Errorf("Secondary header should have been found at offset %d, but was found at: %d", report.Primary.Size, report.Secondary.Offset)
+	}
+}
+
+func Test_LUKS2_CheckHeader_Detects_Corrupted_Secondary_Header(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	report, err := device.CheckHeader()
+	testWrapper.AssertNoError(err)
+
+	file, err := os.OpenFile(DevicePath, os.O_WRONLY, 0)
+	testWrapper.AssertNoError(err)
+	_, err = file.WriteAt([]byte("corrupted"), int64(report.Secondary.Offset)+luks2BinaryHeaderSize)
+	testWrapper.AssertNoError(err)
+	file.Close()
+
+	report, err = device.CheckHeader()
+	testWrapper.AssertNoError(err)
+
+	if report.Consistent() {
+		test.Error("A header with a corrupted secondary copy should not be consistent.")
+	}
+
+	if !report.Primary.ChecksumValid || report.Secondary.ChecksumValid {
+		test.Errorf("Only the secondary header checksum should have been invalid: %+v", report)
+	}
+}
+
+func Test_LUKS2_CheckHeader_Rejects_Out_Of_Range_Header_Size(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	file, err := os.OpenFile(DevicePath, os.O_WRONLY, 0)
+	testWrapper.AssertNoError(err)
+	_, err = file.WriteAt([]byte{0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}, 8)
+	testWrapper.AssertNoError(err)
+	file.Close()
+
+	report, err := device.CheckHeader()
+	testWrapper.AssertNoError(err)
+
+	if !report.Primary.Found || report.Primary.ChecksumValid {
+		test.Errorf("The primary header copy should have been reported as invalid: %+v", report)
+	}
+	if !report.Secondary.Found || !report.Secondary.ChecksumValid {
+		test.Errorf("The secondary header copy should have been found by its offset: %+v", report)
+	}
+}
+
+func Test_LUKS2_CheckHeader_Fails_If_Device_Has_No_Header(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	_, err = device.CheckHeader()
+	testWrapper.AssertError(err)
+}
@@ -39,6 +39,17 @@ func (testWrapper TestWrapper) AssertErrorCodeEquals(err error, expectedErrorCod
 	}
 }
 
+// privileged reports whether the suite may use the kernel's device mapper, see HasDeviceMapperPrivileges.
+var privileged = HasDeviceMapperPrivileges()
+
+// requirePrivileges skips tests that need the kernel's device mapper, or libcryptsetup's locking directory,
+// when the suite runs without privileges, so the other tests can still run.
+func requirePrivileges(test *testing.T) {
+	if !privileged {
+		test.Skip("This test requires CAP_SYS_ADMIN, as libcryptsetup uses the kernel's device mapper.")
+	}
+}
+
 func getFileMD5(filePath string, test *testing.T) string {
 	fileHandle, error := os.Open(filePath)
 	if error != nil {
@@ -75,13 +86,13 @@ func teardown() {
 }
 
 func TestMain(m *testing.M) {
-	if os.Getuid() != 0 {
-		fmt.Printf("This test suite requires root privileges, as libcrypsetup uses the kernel's device mapper.\n")
-		os.Exit(1)
+	if !privileged {
+		fmt.Printf("Running without CAP_SYS_ADMIN: tests using the kernel's device mapper will be skipped.\n")
 	}
 
 	setup()
 	result := m.Run()
+	CleanupLoopDevices()
 	teardown()
 	os.Exit(result)
 }
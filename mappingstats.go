@@ -0,0 +1,95 @@
+package cryptsetup
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <sys/ioctl.h>
+#include <linux/dm-ioctl.h>
+
+static int dm_dev_status(int fd, struct dm_ioctl *io) {
+	return ioctl(fd, DM_DEV_STATUS, io);
+}
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// MappingInfo is the device-mapper state of an active mapping, as shown by `dmsetup info`.
+type MappingInfo struct {
+	Name string
+	// UUID is the device-mapper UUID of the mapping, such as "CRYPT-LUKS2-<UUID>-<name>".
+	UUID string
+	// Major and Minor are the device number of the mapping.
+	Major uint32
+	Minor uint32
+	// OpenCount is the number of open references to the mapping, such as mounts, holders stacked on top of it,
+	// or processes having its device node open.
+	OpenCount int32
+	// TargetCount is the number of targets in the live table.
+	TargetCount uint32
+	// EventNumber is incremented on every device-mapper event of the mapping.
+	EventNumber uint32
+	// Suspended reports whether I/O to the mapping is suspended, such as by Suspend.
+	Suspended bool
+	ReadOnly  bool
+	// InactiveTable reports whether a table was loaded, but not activated yet.
+	InactiveTable bool
+	// DeferredRemove reports whether a deferred deactivation is scheduled for when the mapping is closed.
+	DeferredRemove bool
+}
+
+// Busy reports whether the mapping is open, in which case deactivating it fails with EBUSY.
+func (stats MappingInfo) Busy() bool {
+	return stats.OpenCount > 0
+}
+
+// MappingStats returns the device-mapper state of the active mapping named 'name', read through the DM_DEV_STATUS ioctl,
+// so callers whose Deactivate failed can tell a mapping that is still in use from a stale one.
+// Like Deactivate, it doesn't require an initialized Device, since only the mapping name is needed.
+// Returns the state on success, or an error otherwise.
+func MappingStats(name string) (MappingInfo, error) {
+	stats := MappingInfo{Name: name}
+
+	if len(name) >= C.DM_NAME_LEN {
+		return stats, fmt.Errorf("mapping name '%s' is too long", name)
+	}
+
+	control, err := os.OpenFile(dmControlPath, os.O_RDWR, 0)
+	if err != nil {
+		return stats, err
+	}
+	defer control.Close()
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	var io C.struct_dm_ioctl
+	io.version[0] = C.DM_VERSION_MAJOR
+	io.data_size = C.__u32(C.sizeof_struct_dm_ioctl)
+	io.data_start = C.__u32(C.sizeof_struct_dm_ioctl)
+	C.strncpy(&io.name[0], cName, C.DM_NAME_LEN-1)
+
+	if result, err := C.dm_dev_status(C.int(control.Fd()), &io); result < 0 {
+		return stats, os.NewSyscallError("ioctl", err)
+	}
+
+	stats.UUID = C.GoString(&io.uuid[0])
+	stats.Major, stats.Minor = splitDeviceNumber(uint64(io.dev))
+	stats.OpenCount = int32(io.open_count)
+	stats.TargetCount = uint32(io.target_count)
+	stats.EventNumber = uint32(io.event_nr)
+	stats.setFlags(uint32(io.flags))
+
+	return stats, nil
+}
+
+// setFlags decodes the DM_*_FLAG flags returned by the DM_DEV_STATUS ioctl.
+func (stats *MappingInfo) setFlags(flags uint32) {
+	stats.Suspended = flags&C.DM_SUSPEND_FLAG != 0
+	stats.ReadOnly = flags&C.DM_READONLY_FLAG != 0
+	stats.InactiveTable = flags&C.DM_INACTIVE_PRESENT_FLAG != 0
+	stats.DeferredRemove = flags&C.DM_DEFERRED_REMOVE != 0
+}
@@ -0,0 +1,32 @@
+package cryptsetup
+
+import "testing"
+
+func Test_MappingInfo_setFlags(test *testing.T) {
+	var stats MappingInfo
+
+	// DM_READONLY_FLAG | DM_SUSPEND_FLAG | DM_DEFERRED_REMOVE
+	stats.setFlags(1 | 2 | 1<<17)
+	if !stats.ReadOnly || !stats.Suspended || !stats.DeferredRemove || stats.InactiveTable {
+		test.Errorf("Unexpected flags: %+v", stats)
+	}
+
+	// DM_INACTIVE_PRESENT_FLAG
+	stats.setFlags(1 << 6)
+	if stats.ReadOnly || stats.Suspended || stats.DeferredRemove || !stats.InactiveTable {
+		test.Errorf("Unexpected flags: %+v", stats)
+	}
+}
+
+func Test_MappingInfo_Busy(test *testing.T) {
+	if (MappingInfo{OpenCount: 0}).Busy() || !(MappingInfo{OpenCount: 1}).Busy() {
+		test.Error("Only mappings with open references should be busy.")
+	}
+}
+
+func Test_MappingStats_Fails_If_Mapping_Is_Not_Active(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := MappingStats(DeviceName)
+	testWrapper.AssertError(err)
+}
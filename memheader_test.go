@@ -0,0 +1,47 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func Test_InitWithHeader(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const headerPath = "testHeader"
+	exec.Command("/bin/dd", "if=/dev/zero", fmt.Sprintf("of=%s", headerPath), "bs=1M", "count=16").Run()
+	defer os.Remove(headerPath)
+
+	device, err := InitDataDevice(headerPath, DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+	device.Free()
+
+	header, err := ioutil.ReadFile(headerPath)
+	testWrapper.AssertNoError(err)
+
+	device, err = InitWithHeader(header, DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.Load()
+	testWrapper.AssertNoError(err)
+
+	if device.Type() != "LUKS2" {
+		test.Error("Expected type: LUKS2.")
+	}
+
+	if device.DevicePath() != DevicePath {
+		test.Errorf("Data device should have been '%s', but was: %s", DevicePath, device.DevicePath())
+	}
+
+	_, err = device.CheckPassphrase(CRYPT_ANY_SLOT, "testPassphrase")
+	testWrapper.AssertNoError(err)
+}
@@ -0,0 +1,81 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// metadataLockPollInterval is how often WaitForMetadataLock checks whether the metadata lock was released.
+const metadataLockPollInterval = 50 * time.Millisecond
+
+// WaitForMetadataLock waits until no other process holds the metadata lock of the device holding the header,
+// so concurrent provisioning jobs on the same device can take turns instead of failing with ErrMetadataLocked.
+// The lock is not kept: another process may still take it before the next operation, which should be retried then.
+// Delays are measured with the system clock, unless WithClock is given.
+// Returns nil once the lock is free, or ErrTimeout if it was still held after 'timeout'.
+func (device *Device) WaitForMetadataLock(timeout time.Duration, optionFuncs ...Option) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	clock := newOptions(optionFuncs).clock
+
+	lockPath, err := metadataLockPath(device.MetadataDevicePath())
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := os.Open(lockPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	deadline := clock.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+		}
+		if err != syscall.EWOULDBLOCK {
+			return os.NewSyscallError("flock", err)
+		}
+
+		if clock.Now().After(deadline) {
+			return ErrTimeout
+		}
+		clock.Sleep(metadataLockPollInterval)
+	}
+}
+
+// metadataLockPath returns the path of the file libcryptsetup locks to protect the metadata of the device at 'devicePath':
+// a file named after the device's numbers in LockDir for block devices, or the image file itself otherwise.
+func metadataLockPath(devicePath string) (string, error) {
+	info, err := os.Stat(devicePath)
+	if err != nil {
+		return "", err
+	}
+
+	if info.Mode()&os.ModeDevice == 0 {
+		return devicePath, nil
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", fmt.Errorf("cannot determine the device numbers of '%s'", devicePath)
+	}
+
+	return filepath.Join(LockDir(), "L_"+deviceNumber(uint64(stat.Rdev))), nil
+}
+
+// deviceNumber formats the device number 'rdev' of a device node as "major:minor".
+func deviceNumber(rdev uint64) string {
+	major, minor := (rdev>>8)&0xfff|(rdev>>32)&^0xfff, rdev&0xff|(rdev>>12)&^0xff
+	return fmt.Sprintf("%d:%d", major, minor)
+}
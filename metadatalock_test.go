@@ -0,0 +1,51 @@
+package cryptsetup
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_Error_Is_ErrMetadataLocked(test *testing.T) {
+	var err error = &Error{functionName: "crypt_load", code: int(EAGAIN)}
+	if !errors.Is(err, ErrMetadataLocked) {
+		test.Error("EAGAIN errors should match ErrMetadataLocked.")
+	}
+
+	err = &Error{functionName: "crypt_load", code: int(EBUSY)}
+	if errors.Is(err, ErrMetadataLocked) {
+		test.Error("EBUSY errors should not match ErrMetadataLocked.")
+	}
+}
+
+func Test_Device_WaitForMetadataLock(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.WaitForMetadataLock(time.Second)
+	testWrapper.AssertNoError(err)
+
+	holder, err := os.Open(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer holder.Close()
+	testWrapper.AssertNoError(syscall.Flock(int(holder.Fd()), syscall.LOCK_SH))
+
+	clock := &fakeClock{}
+	err = device.WaitForMetadataLock(time.Second, WithClock(clock))
+	if err != ErrTimeout {
+		test.Errorf("WaitForMetadataLock should have timed out while the lock was held, but returned: %v", err)
+	}
+	if len(clock.sleeps) == 0 {
+		test.Error("WaitForMetadataLock should have polled the lock.")
+	}
+
+	testWrapper.AssertNoError(syscall.Flock(int(holder.Fd()), syscall.LOCK_UN))
+
+	err = device.WaitForMetadataLock(time.Second)
+	testWrapper.AssertNoError(err)
+}
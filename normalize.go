@@ -0,0 +1,28 @@
+package cryptsetup
+
+import "strings"
+
+// PassphraseNormalizer rewrites a passphrase before it is passed to libcryptsetup, so that the same passphrase typed on
+// different keyboards, terminals or GUIs yields the same bytes.
+// Unicode normalization forms are provided by golang.org/x/text/unicode/norm, which this package doesn't depend on:
+// use PassphraseNormalizer(norm.NFC.String) or PassphraseNormalizer(norm.NFKD.String).
+type PassphraseNormalizer func(passphrase string) string
+
+// TrimTrailingNewline is a PassphraseNormalizer removing a single trailing "\n" or "\r\n",
+// as left by `echo` or by files edited with a text editor.
+func TrimTrailingNewline(passphrase string) string {
+	if strings.HasSuffix(passphrase, "\r\n") {
+		return passphrase[:len(passphrase)-2]
+	}
+	return strings.TrimSuffix(passphrase, "\n")
+}
+
+// NormalizePassphrase applies 'normalizers' to 'passphrase', in order.
+// Passphrases must be normalized the same way when adding keyslots and when unlocking them,
+// since keyslots only match the exact bytes they were created with.
+func NormalizePassphrase(passphrase string, normalizers ...PassphraseNormalizer) string {
+	for _, normalizer := range normalizers {
+		passphrase = normalizer(passphrase)
+	}
+	return passphrase
+}
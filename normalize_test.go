@@ -0,0 +1,48 @@
+package cryptsetup
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_NormalizePassphrase(test *testing.T) {
+	for input, expected := range map[string]string{
+		"passphrase":       "passphrase",
+		"passphrase\n":     "passphrase",
+		"passphrase\r\n":   "passphrase",
+		"passphrase\n\n":   "passphrase\n",
+		"pass\nphrase\n\n": "pass\nphrase\n",
+	} {
+		if normalized := NormalizePassphrase(input, TrimTrailingNewline); normalized != expected {
+			test.Errorf("Expected %q to be normalized to %q, but got %q", input, expected, normalized)
+		}
+	}
+
+	if normalized := NormalizePassphrase("Passphrase\n", TrimTrailingNewline, strings.ToLower); normalized != "passphrase" {
+		test.Errorf("Normalizers should have been applied in order, but got %q", normalized)
+	}
+}
+
+func Test_Passphrase_Normalizers(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	credential := Passphrase{Keyslot: 0, Passphrase: "testPassphrase\n", Normalizers: []PassphraseNormalizer{TrimTrailingNewline}}
+	testWrapper.AssertNoError(credential.Activate(device, "", 0))
+
+	keyslot, err := credential.KeyslotAdd(device, 1, []byte("otherPassphrase\r\n"))
+	testWrapper.AssertNoError(err)
+
+	_, err = device.CheckPassphrase(keyslot, "otherPassphrase")
+	testWrapper.AssertNoError(err)
+
+	testWrapper.AssertError(Passphrase{Keyslot: 0, Passphrase: "testPassphrase\n"}.Activate(device, "", 0))
+}
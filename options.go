@@ -0,0 +1,73 @@
+package cryptsetup
+
+import (
+	"crypto/rand"
+	"io"
+	"time"
+)
+
+// Clock is the source of time of helpers that wait, retry or record timestamps.
+type Clock interface {
+	Now() time.Time
+	Sleep(duration time.Duration)
+}
+
+// systemClock is the Clock used by default, backed by package time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(duration time.Duration) {
+	time.Sleep(duration)
+}
+
+// Option customizes the behavior of the functions accepting it. Each With* function documents what it applies to.
+type Option func(*options)
+
+type options struct {
+	clock   Clock
+	random  io.Reader
+	timeout time.Duration
+	force   bool
+	// rateLimit is the number of bytes per second WipeMappedDevice writes at most, or 0 for no limit.
+	rateLimit uint64
+	// udevWait is how long activation and deactivation wait for udev to create or remove the mapper node, or 0 not to wait.
+	udevWait time.Duration
+	// cipherSyntaxOnly skips checking that the cipher of PLAIN devices is listed in /proc/crypto when formatting.
+	cipherSyntaxOnly bool
+}
+
+// WithClock makes helpers that wait or record timestamps, such as ActivateWithRetry and NewUnlockLimiter,
+// use 'clock' instead of the system clock, so tests relying on them can be deterministic.
+func WithClock(clock Clock) Option {
+	return func(options *options) {
+		options.clock = clock
+	}
+}
+
+// WithRandom makes helpers generating secrets, such as GenerateRecoveryKey, read random bytes from 'random' instead of crypto/rand.
+// It must only be used by tests: keys generated from a predictable source are not secret.
+func WithRandom(random io.Reader) Option {
+	return func(options *options) {
+		options.random = random
+	}
+}
+
+// WithTimeout makes RunWithTimeout give up on an operation after 'timeout', instead of the timeout set by SetOperationTimeout.
+// A 'timeout' of 0 lets the operation run for as long as it takes.
+func WithTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.timeout = timeout
+	}
+}
+
+// newOptions applies 'optionFuncs' over the defaults.
+func newOptions(optionFuncs []Option) options {
+	options := options{clock: systemClock{}, random: rand.Reader, timeout: OperationTimeout()}
+	for _, option := range optionFuncs {
+		option(&options)
+	}
+	return options
+}
@@ -0,0 +1,18 @@
+package cryptsetup
+
+import "time"
+
+// fakeClock is a Clock whose time only moves forward when Sleep is called.
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func (clock *fakeClock) Sleep(duration time.Duration) {
+	clock.sleeps = append(clock.sleeps, duration)
+	clock.now = clock.now.Add(duration)
+}
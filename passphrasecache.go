@@ -0,0 +1,142 @@
+package cryptsetup
+
+import (
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PassphraseCache keeps recently entered passphrases in memory for a limited time, keyed by device UUID,
+// so unlocking several volumes that share a passphrase only prompts the user once, see ActivateByPassphrase.
+// Cached passphrases are locked in memory when possible, so they are not written to swap,
+// and are wiped when they expire or are forgotten.
+// A PassphraseCache is safe for concurrent use.
+type PassphraseCache struct {
+	ttl time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*passphraseCacheEntry
+}
+
+type passphraseCacheEntry struct {
+	passphrase []byte
+	locked     bool
+	timer      *time.Timer
+}
+
+// NewPassphraseCache returns a PassphraseCache keeping passphrases for 'ttl'.
+func NewPassphraseCache(ttl time.Duration) *PassphraseCache {
+	return &PassphraseCache{ttl: ttl, entries: make(map[string]*passphraseCacheEntry)}
+}
+
+// Put caches a copy of the passphrase for the device with the given UUID, replacing any previously cached one.
+// The caller keeps ownership of 'passphrase', and may wipe it right away.
+func (cache *PassphraseCache) Put(uuid string, passphrase []byte) {
+	entry := &passphraseCacheEntry{passphrase: append([]byte(nil), passphrase...)}
+	entry.locked = len(entry.passphrase) > 0 && syscall.Mlock(entry.passphrase) == nil
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.forget(uuid)
+	cache.entries[uuid] = entry
+	entry.timer = time.AfterFunc(cache.ttl, func() {
+		cache.mutex.Lock()
+		defer cache.mutex.Unlock()
+
+		if cache.entries[uuid] == entry {
+			cache.forget(uuid)
+		}
+	})
+}
+
+// PutAll caches a copy of the passphrase for each of the devices with the given UUIDs, like Put.
+func (cache *PassphraseCache) PutAll(uuids []string, passphrase []byte) {
+	for _, uuid := range uuids {
+		cache.Put(uuid, passphrase)
+	}
+}
+
+// Get returns a copy of the passphrase cached for the device with the given UUID, and whether one was found.
+// The copy is to be wiped with WipeBytes once done.
+func (cache *PassphraseCache) Get(uuid string) ([]byte, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry, found := cache.entries[uuid]
+	if !found {
+		return nil, false
+	}
+	return append([]byte(nil), entry.passphrase...), true
+}
+
+// Forget wipes the passphrase cached for the device with the given UUID.
+func (cache *PassphraseCache) Forget(uuid string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.forget(uuid)
+}
+
+// Clear wipes all cached passphrases.
+func (cache *PassphraseCache) Clear() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for uuid := range cache.entries {
+		cache.forget(uuid)
+	}
+}
+
+// ActivateByPassphrase activates a device, trying the passphrase cached for its UUID first.
+// If there is none, or it doesn't unlock the device, 'prompt' is called to ask for the passphrase,
+// which is wiped once used. Passphrases cached for other devices are not tried.
+// The passphrase that unlocked the device is cached for its UUID, and for the UUIDs of the 'siblings'
+// expected to share it, so unlocking them next does not prompt again. UUIDs can be read with Device.UUID
+// once a device is loaded, before it is unlocked.
+// Returns nil on success, or an error otherwise.
+func (cache *PassphraseCache) ActivateByPassphrase(device *Device, deviceName string, keyslot int, prompt func() ([]byte, error), flags int, siblings ...string) error {
+	uuid := device.UUID()
+
+	if passphrase, found := cache.Get(uuid); found {
+		err := device.ActivateByPassphraseBytes(deviceName, keyslot, passphrase, flags)
+		WipeBytes(passphrase)
+		if err == nil {
+			return nil
+		}
+
+		if cryptErr, ok := err.(*Error); !ok || cryptErr.Code() != wrongPassphraseCode {
+			return err
+		}
+		cache.Forget(uuid)
+	}
+
+	passphrase, err := prompt()
+	if err != nil {
+		return err
+	}
+	defer WipeBytes(passphrase)
+
+	if err := device.ActivateByPassphraseBytes(deviceName, keyslot, passphrase, flags); err != nil {
+		return err
+	}
+
+	cache.PutAll(append([]string{uuid}, siblings...), passphrase)
+	return nil
+}
+
+// forget wipes the passphrase cached for the device with the given UUID. The mutex must be held.
+func (cache *PassphraseCache) forget(uuid string) {
+	entry, found := cache.entries[uuid]
+	if !found {
+		return
+	}
+
+	entry.timer.Stop()
+	WipeBytes(entry.passphrase)
+	if entry.locked {
+		syscall.Munlock(entry.passphrase)
+	}
+
+	delete(cache.entries, uuid)
+}
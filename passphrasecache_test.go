@@ -0,0 +1,148 @@
+package cryptsetup
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_PassphraseCache_Put_Get_Forget(test *testing.T) {
+	cache := NewPassphraseCache(time.Hour)
+
+	cache.Put("testUUID", []byte("testPassphrase"))
+	if passphrase, found := cache.Get("testUUID"); !found || string(passphrase) != "testPassphrase" {
+		test.Errorf("Cached passphrase should have been found, but got: %q, %t", passphrase, found)
+	}
+
+	cache.Forget("testUUID")
+	if _, found := cache.Get("testUUID"); found {
+		test.Error("Forgotten passphrase should not have been found.")
+	}
+}
+
+func Test_PassphraseCache_Expires(test *testing.T) {
+	cache := NewPassphraseCache(10 * time.Millisecond)
+
+	cache.Put("testUUID", []byte("testPassphrase"))
+	time.Sleep(100 * time.Millisecond)
+
+	if _, found := cache.Get("testUUID"); found {
+		test.Error("Expired passphrase should not have been found.")
+	}
+}
+
+func Test_PassphraseCache_ActivateByPassphrase_Prompts_Once(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	cache := NewPassphraseCache(time.Hour)
+	cache.Put(device.UUID(), []byte("testPassphrase"))
+
+	prompts := 0
+	prompt := func() ([]byte, error) {
+		prompts++
+		return nil, errors.New("prompt should not have been called")
+	}
+
+	err = cache.ActivateByPassphrase(device, "", CRYPT_ANY_SLOT, prompt, 0)
+	testWrapper.AssertNoError(err)
+
+	if prompts != 0 {
+		test.Errorf("Prompt should not have been called, but was called %d times.", prompts)
+	}
+
+	if passphrase, found := cache.Get(device.UUID()); !found || string(passphrase) != "testPassphrase" {
+		test.Error("Passphrase should have been cached for the device's UUID.")
+	}
+}
+
+func Test_PassphraseCache_ActivateByPassphrase_Ignores_Other_Devices(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	cache := NewPassphraseCache(time.Hour)
+	cache.Put("otherUUID", []byte("testPassphrase"))
+	cache.Put(device.UUID(), []byte("wrongPassphrase"))
+
+	prompts := 0
+	prompt := func() ([]byte, error) {
+		prompts++
+		return []byte("testPassphrase"), nil
+	}
+
+	err = cache.ActivateByPassphrase(device, "", CRYPT_ANY_SLOT, prompt, 0)
+	testWrapper.AssertNoError(err)
+
+	if prompts != 1 {
+		test.Errorf("Prompt should have been called once, but was called %d times.", prompts)
+	}
+
+	if passphrase, found := cache.Get(device.UUID()); !found || string(passphrase) != "testPassphrase" {
+		test.Error("The passphrase that unlocked the device should have replaced the wrong one.")
+	}
+}
+
+func Test_PassphraseCache_ActivateByPassphrase_Shares_With_Siblings(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	image, err := ioutil.TempFile("", "passphrasecache")
+	testWrapper.AssertNoError(err)
+	defer os.Remove(image.Name())
+	testWrapper.AssertNoError(image.Truncate(64 * 1024 * 1024))
+	testWrapper.AssertNoError(image.Close())
+
+	var devices []*Device
+	for _, path := range []string{DevicePath, image.Name()} {
+		device, err := Init(path)
+		testWrapper.AssertNoError(err)
+		defer device.Free()
+
+		err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+		testWrapper.AssertNoError(err)
+		err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+		testWrapper.AssertNoError(err)
+
+		devices = append(devices, device)
+	}
+
+	cache := NewPassphraseCache(time.Hour)
+
+	prompts := 0
+	prompt := func() ([]byte, error) {
+		prompts++
+		return []byte("testPassphrase"), nil
+	}
+
+	for _, device := range devices {
+		err = cache.ActivateByPassphrase(device, "", CRYPT_ANY_SLOT, prompt, 0, devices[1].UUID())
+		testWrapper.AssertNoError(err)
+	}
+
+	if prompts != 1 {
+		test.Errorf("Prompt should have been called once for both devices, but was called %d times.", prompts)
+	}
+
+	if passphrase, found := cache.Get(devices[1].UUID()); !found || string(passphrase) != "testPassphrase" {
+		test.Error("Passphrase should have been cached for the sibling's UUID.")
+	}
+}
@@ -6,10 +6,16 @@ package cryptsetup
 import "C"
 import "unsafe"
 
+// Plain is the struct used to manipulate PLAIN devices.
+// The IV generator is specified as part of GenericParams.CipherMode, such as "cbc-essiv:sha256" or "xts-plain64".
 type Plain struct {
-	Hash       string
-	Offset     uint64
-	Skip       uint64
+	// Hash is the hash used to derive the volume key from a passphrase.
+	Hash string
+	// Offset is the offset of the encrypted data on the device, in 512 byte sectors, like `cryptsetup create --offset`.
+	Offset uint64
+	// Skip is the IV offset, in 512 byte sectors, added to sector numbers when computing IVs, like `cryptsetup create --skip`.
+	Skip uint64
+	// Size is the size of the mapping, in 512 byte sectors. If 0, the mapping extends to the end of the device.
 	Size       uint64
 	SectorSize uint32
 }
@@ -19,6 +25,14 @@ func (plain Plain) Name() string {
 	return "PLAIN"
 }
 
+// Validate checks the syntax of the cipher specification before formatting, since PLAIN devices have no header to catch
+// misconfigurations, and activating them with the wrong parameters silently yields garbage data.
+// Format also checks that the cipher is available in the kernel, see WithCipherSyntaxOnly.
+func (plain Plain) Validate(genericParams GenericParams) error {
+	return ValidateCipherSpec(genericParams.Cipher, genericParams.CipherMode)
+}
+
+// Unmanaged is used to specialize Plain.
 func (plain Plain) Unmanaged() (unsafe.Pointer, func()) {
 	deallocations := make([]func(), 0, 1)
 	deallocate := func() {
@@ -1,10 +1,15 @@
 package cryptsetup
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 func Test_Plain_ActivateByPassphrase_Deactivate(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	device, err := Init(DevicePath)
@@ -23,6 +28,8 @@ func Test_Plain_ActivateByPassphrase_Deactivate(test *testing.T) {
 }
 
 func Test_Plain_ActivateByVolumeKey_Deactivate(test *testing.T) {
+	requirePrivileges(test)
+
 	testWrapper := TestWrapper{test}
 
 	genericParams := GenericParams{
@@ -114,3 +121,48 @@ func Test_Plain_KeyslotChangeByPassphrase_Should_Not_Be_Supported(test *testing.
 
 	device.Free()
 }
+
+func Test_Plain_Format_Fails_For_Invalid_IV(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain46", VolumeKeySize: 512 / 8})
+	testWrapper.AssertError(err)
+
+	if device.Type() != "" {
+		test.Error("Device should have no type.")
+	}
+}
+
+func Test_Plain_Format_Checks_Cipher_Availability(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	directory, err := ioutil.TempDir("", "cipher")
+	testWrapper.AssertNoError(err)
+	defer os.RemoveAll(directory)
+
+	previousProcCryptoPath := procCryptoPath
+	procCryptoPath = filepath.Join(directory, "crypto")
+	defer func() { procCryptoPath = previousProcCryptoPath }()
+	testWrapper.AssertNoError(ioutil.WriteFile(procCryptoPath, []byte("name         : aes\n"), 0644))
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	genericParams := GenericParams{Cipher: "serpent", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8}
+	err = device.Format(Plain{Hash: "sha256"}, genericParams)
+	testWrapper.AssertError(err)
+
+	if device.Type() != "" {
+		test.Error("Device should have no type.")
+	}
+
+	_, err = device.DryRun().Format(Plain{Hash: "sha256"}, genericParams, WithCipherSyntaxOnly())
+	testWrapper.AssertNoError(err)
+}
@@ -0,0 +1,217 @@
+package cryptsetup
+
+/*
+#cgo pkg-config: libcryptsetup
+#include <libcryptsetup.h>
+
+// token_external_path returns NULL on libcryptsetup older than 2.4, which lacks crypt_token_external_path,
+// and is told apart by not defining CRYPT_TOKEN_ABI_VERSION1.
+static const char *token_external_path(void) {
+#ifdef CRYPT_TOKEN_ABI_VERSION1
+	return crypt_token_external_path();
+#else
+	return NULL;
+#endif
+}
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// preflightTargets are the device-mapper targets checked by Preflight, with their kernel modules,
+// and whether activating LUKS devices requires them.
+var preflightTargets = []struct {
+	target   string
+	module   string
+	required bool
+}{
+	{target: "crypt", module: "dm_crypt", required: true},
+	{target: "integrity", module: "dm_integrity"},
+	{target: "verity", module: "dm_verity"},
+}
+
+// PreflightCheck is the outcome of one of the checks performed by Preflight.
+type PreflightCheck struct {
+	// Name describes what was checked, such as "dm_crypt module" or "cipher aes-xts-plain64".
+	Name string
+	OK   bool
+	// Optional reports whether a failure of the check only prevents optional features, such as authenticated encryption.
+	Optional bool
+	// Detail explains the outcome, such as the version of a device-mapper target, or what is missing.
+	Detail string
+}
+
+// PreflightReport is the outcome of Preflight.
+type PreflightReport struct {
+	Checks []PreflightCheck
+}
+
+// OK reports whether every check that is not optional succeeded.
+func (report PreflightReport) OK() bool {
+	return len(report.Failures()) == 0
+}
+
+// Failures returns the checks that are not optional and failed.
+func (report PreflightReport) Failures() []PreflightCheck {
+	failures := []PreflightCheck{}
+	for _, check := range report.Checks {
+		if !check.OK && !check.Optional {
+			failures = append(failures, check)
+		}
+	}
+	return failures
+}
+
+// String lists the checks, one per line, like "FAIL cipher serpent-xts-plain64: cipher 'serpent' is not available in the kernel".
+func (report PreflightReport) String() string {
+	lines := make([]string, 0, len(report.Checks))
+	for _, check := range report.Checks {
+		status := "ok"
+		if !check.OK {
+			status = "FAIL"
+			if check.Optional {
+				status = "warn"
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", status, check.Name, check.Detail))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Preflight checks that the environment can activate encrypted devices, listing what's missing in a structured report,
+// instead of leaving it to be found out from a failing activation: the device-mapper control node and the privileges to use it,
+// the dm_crypt, dm_integrity and dm_verity kernel modules, the kernel algorithms of 'ciphers',
+// dm-crypt cipher specifications such as "aes-xts-plain64", and the features libcryptsetup was built with.
+// If no cipher is given, the cipher of DefaultGenericParams is checked.
+// Notice the kernel loads modules on first use, so modules and algorithms that were never used may be reported as missing,
+// while they would be loaded by an activation.
+func Preflight(ciphers ...string) PreflightReport {
+	var report PreflightReport
+
+	report.Checks = append(report.Checks, checkDeviceMapperControl(), PreflightCheck{
+		Name:   "privileges",
+		OK:     HasDeviceMapperPrivileges(),
+		Detail: "CAP_SYS_ADMIN is required to use the device mapper",
+	})
+
+	_, targetVersions, dmErr := dmTargetVersions()
+	for _, preflightTarget := range preflightTargets {
+		report.Checks = append(report.Checks, checkDeviceMapperTarget(preflightTarget.target, preflightTarget.module, !preflightTarget.required, targetVersions, dmErr))
+	}
+
+	if len(ciphers) == 0 {
+		defaults := DefaultGenericParams()
+		ciphers = []string{defaults.Cipher + "-" + defaults.CipherMode}
+	}
+	for _, cipher := range ciphers {
+		report.Checks = append(report.Checks, checkCipher(cipher))
+	}
+
+	report.Checks = append(report.Checks, checkLockDirectory(), checkExternalTokens())
+
+	return report
+}
+
+// checkDeviceMapperControl checks that the device-mapper control node exists.
+func checkDeviceMapperControl() PreflightCheck {
+	check := PreflightCheck{Name: dmControlPath}
+
+	info, err := os.Stat(dmControlPath)
+	switch {
+	case err != nil:
+		check.Detail = err.Error()
+	case info.Mode()&os.ModeCharDevice == 0:
+		check.Detail = "not a character device"
+	default:
+		check.OK = true
+		check.Detail = "device-mapper control node found"
+	}
+
+	return check
+}
+
+// checkDeviceMapperTarget checks that the device-mapper target 'target' is registered, or that its kernel module is loaded.
+func checkDeviceMapperTarget(target string, module string, optional bool, targetVersions map[string]dmVersion, dmErr error) PreflightCheck {
+	check := PreflightCheck{Name: module + " module", Optional: optional}
+
+	if version, found := targetVersions[target]; found {
+		check.OK = true
+		check.Detail = fmt.Sprintf("target '%s' version %d.%d.%d", target, version[0], version[1], version[2])
+		return check
+	}
+
+	if _, err := os.Stat(filepath.Join(sysfsPath, "module", module)); err == nil {
+		check.OK = true
+		check.Detail = "module loaded"
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("target '%s' is not registered, and module %s is not loaded", target, module)
+	if dmErr != nil {
+		check.Detail = fmt.Sprintf("module %s is not loaded, and the device-mapper targets could not be listed: %v", module, dmErr)
+	}
+	return check
+}
+
+// checkCipher checks that the kernel provides the algorithms of the dm-crypt cipher specification 'cipher'.
+func checkCipher(cipher string) PreflightCheck {
+	check := PreflightCheck{Name: "cipher " + cipher}
+
+	parts := strings.SplitN(cipher, "-", 2)
+	if len(parts) != 2 {
+		check.Detail = "not a cipher specification, such as aes-xts-plain64"
+		return check
+	}
+
+	if err := ValidateCipherSpec(parts[0], parts[1]); err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	if err := checkCipherAvailable(parts[0], parts[1]); err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	check.OK = true
+	check.Detail = "available in the kernel"
+	return check
+}
+
+// checkLockDirectory checks that the directory of libcryptsetup's metadata locks exists,
+// which, if it is missing, makes operations on block devices fail until locking is disabled.
+func checkLockDirectory() PreflightCheck {
+	lockDir := LockDir()
+	check := PreflightCheck{Name: "lock directory " + lockDir}
+
+	info, err := os.Stat(lockDir)
+	switch {
+	case err != nil:
+		check.Detail = err.Error() + ", see DisableMetadataLocking"
+	case !info.IsDir():
+		check.Detail = "not a directory"
+	default:
+		check.OK = true
+		check.Detail = "found"
+	}
+
+	return check
+}
+
+// checkExternalTokens checks that libcryptsetup was built with support for external token handlers, such as systemd's.
+func checkExternalTokens() PreflightCheck {
+	check := PreflightCheck{Name: "external tokens", Optional: true}
+
+	path := C.token_external_path()
+	if path == nil {
+		check.Detail = "libcryptsetup is older than 2.4, was built without external token support, or it was disabled"
+		return check
+	}
+
+	check.OK = true
+	check.Detail = "token plugins are loaded from " + C.GoString(path)
+	return check
+}
@@ -0,0 +1,86 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupPreflightEnvironment(test *testing.T) func() {
+	testWrapper := TestWrapper{test}
+
+	directory, err := ioutil.TempDir("", "preflight")
+	testWrapper.AssertNoError(err)
+
+	previousSysfsPath, previousProcCryptoPath := sysfsPath, procCryptoPath
+	previousDMControlPath, previousMetadataLockDir := dmControlPath, metadataLockDir
+	sysfsPath, procCryptoPath = directory, filepath.Join(directory, "crypto")
+	dmControlPath, metadataLockDir = filepath.Join(directory, "control"), filepath.Join(directory, "cryptsetup")
+
+	testWrapper.AssertNoError(ioutil.WriteFile(procCryptoPath, []byte("name         : aes\ndriver       : aes-generic\n\nname         : sha256\n"), 0644))
+	testWrapper.AssertNoError(os.MkdirAll(filepath.Join(directory, "module", "dm_crypt"), 0755))
+	testWrapper.AssertNoError(os.MkdirAll(metadataLockDir, 0755))
+
+	return func() {
+		sysfsPath, procCryptoPath = previousSysfsPath, previousProcCryptoPath
+		dmControlPath, metadataLockDir = previousDMControlPath, previousMetadataLockDir
+		os.RemoveAll(directory)
+	}
+}
+
+func Test_Preflight(test *testing.T) {
+	defer setupPreflightEnvironment(test)()
+
+	report := Preflight("aes-xts-plain64", "serpent-xts-plain64")
+
+	checks := make(map[string]PreflightCheck)
+	for _, check := range report.Checks {
+		checks[check.Name] = check
+	}
+
+	if check := checks[dmControlPath]; check.OK {
+		test.Errorf("The missing control node should have been reported: %+v", check)
+	}
+	if check := checks["dm_crypt module"]; !check.OK || check.Optional {
+		test.Errorf("The loaded dm_crypt module should have been found: %+v", check)
+	}
+	if check := checks["dm_integrity module"]; check.OK || !check.Optional {
+		test.Errorf("The missing dm_integrity module should have been reported as optional: %+v", check)
+	}
+	if check := checks["cipher aes-xts-plain64"]; !check.OK {
+		test.Errorf("The aes cipher should have been found: %+v", check)
+	}
+	if check := checks["cipher serpent-xts-plain64"]; check.OK || !strings.Contains(check.Detail, "serpent") {
+		test.Errorf("The missing serpent cipher should have been reported: %+v", check)
+	}
+	if check := checks["lock directory "+metadataLockDir]; !check.OK {
+		test.Errorf("The lock directory should have been found: %+v", check)
+	}
+
+	if report.OK() {
+		test.Error("The report should have failed.")
+	}
+	for _, failure := range report.Failures() {
+		if failure.Optional {
+			test.Errorf("Optional checks should not be failures: %+v", failure)
+		}
+	}
+	if !strings.Contains(report.String(), "FAIL cipher serpent-xts-plain64: ") || !strings.Contains(report.String(), "warn dm_verity module: ") {
+		test.Errorf("Unexpected report:\n%s", report)
+	}
+}
+
+func Test_Preflight_Checks_Default_Cipher(test *testing.T) {
+	defer setupPreflightEnvironment(test)()
+
+	report := Preflight()
+	found := false
+	for _, check := range report.Checks {
+		found = found || (check.Name == "cipher aes-xts-plain64" && check.OK)
+	}
+	if !found {
+		test.Errorf("The default cipher should have been checked:\n%s", report)
+	}
+}
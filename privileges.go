@@ -0,0 +1,35 @@
+package cryptsetup
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// procSelfStatusPath describes the process, including its capabilities.
+var procSelfStatusPath = "/proc/self/status"
+
+// capSysAdmin is the number of the CAP_SYS_ADMIN capability, which device-mapper ioctls require.
+const capSysAdmin = 21
+
+// HasDeviceMapperPrivileges reports whether the process holds CAP_SYS_ADMIN in its effective capabilities,
+// which activating, deactivating and querying mappings through the kernel's device mapper require.
+// Test suites can use it to skip privileged tests, and still run parameter and validation tests without root.
+func HasDeviceMapperPrivileges() bool {
+	file, err := os.Open(procSelfStatusPath)
+	if err != nil {
+		return os.Geteuid() == 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if capabilities := strings.TrimPrefix(scanner.Text(), "CapEff:"); capabilities != scanner.Text() {
+			mask, err := strconv.ParseUint(strings.TrimSpace(capabilities), 16, 64)
+			return err == nil && mask&(1<<capSysAdmin) != 0
+		}
+	}
+
+	return os.Geteuid() == 0
+}
@@ -0,0 +1,36 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_HasDeviceMapperPrivileges(test *testing.T) {
+	directory, err := ioutil.TempDir("", "privileges")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	previous := procSelfStatusPath
+	procSelfStatusPath = filepath.Join(directory, "status")
+	defer func() { procSelfStatusPath = previous }()
+
+	for capabilities, expected := range map[string]bool{
+		"000001ffffffffff": true,
+		"0000000000200000": true,
+		"00000000001fffff": false,
+		"0000000000000000": false,
+	} {
+		status := "Name:\tgo\nCapInh:\t0000000000000000\nCapPrm:\t000001ffffffffff\nCapEff:\t" + capabilities + "\n"
+		if err := ioutil.WriteFile(procSelfStatusPath, []byte(status), 0644); err != nil {
+			test.Fatal(err)
+		}
+
+		if actual := HasDeviceMapperPrivileges(); actual != expected {
+			test.Errorf("Effective capabilities '%s' should have been reported as %v, but were reported as %v.", capabilities, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package cryptsetup
+
+/*
+#cgo pkg-config: libcryptsetup
+#include <libcryptsetup.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+extern int wipe_progress(uint64_t size, uint64_t offset, void * usrptr);
+*/
+import "C"
+import (
+	"context"
+	"sync"
+	"unsafe"
+)
+
+// ProgressFunc receives the progress of long running operations on a device: 'offset' is the position reached, in bytes,
+// and 'size' the position at which the operation ends. Operations that don't start at the beginning of the device,
+// such as wiping a data area, report positions relative to the beginning of the device.
+type ProgressFunc func(size uint64, offset uint64)
+
+// wipeOperation is the state of a crypt_wipe call, shared with its progress callback.
+type wipeOperation struct {
+	ctx      context.Context
+	progress ProgressFunc
+}
+
+var (
+	wipeLock sync.Mutex
+	// wipeOperations maps the IDs passed to libcryptsetup as progress callback user data to their operation.
+	wipeOperations = make(map[uintptr]*wipeOperation)
+	nextWipeID     uintptr
+)
+
+//export wipe_progress
+func wipe_progress(size C.uint64_t, offset C.uint64_t, usrptr unsafe.Pointer) C.int {
+	wipeLock.Lock()
+	operation := wipeOperations[uintptr(*(*C.uintptr_t)(usrptr))]
+	wipeLock.Unlock()
+
+	if operation == nil {
+		return 0
+	}
+	if operation.progress != nil {
+		operation.progress(uint64(size), uint64(offset))
+	}
+	if operation.ctx.Err() != nil {
+		return 1
+	}
+	return 0
+}
+
+// WipeContext is like Wipe, but reports its progress to 'progress', if it is not nil, and stops once 'ctx' is done.
+// The area is left partially wiped when the wipe is canceled, and may be wiped again from the start.
+// Like Wipe, it fails with ErrReadOnly if the device was initialized read-only, or its header was write-protected.
+// Returns nil on success, the context's error if it was canceled, or an error otherwise.
+// C equivalent: crypt_wipe
+func (device *Device) WipeContext(ctx context.Context, devicePath string, pattern int, offset uint64, length uint64, flags uint32, progress ProgressFunc) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var cDevicePath *C.char = nil
+	if len(devicePath) > 0 {
+		cDevicePath = C.CString(devicePath)
+		defer C.free(unsafe.Pointer(cDevicePath))
+	}
+
+	wipeLock.Lock()
+	nextWipeID++
+	id := (*C.uintptr_t)(C.malloc(C.sizeof_uintptr_t))
+	*id = C.uintptr_t(nextWipeID)
+	wipeOperations[nextWipeID] = &wipeOperation{ctx: ctx, progress: progress}
+	wipeLock.Unlock()
+
+	defer func() {
+		wipeLock.Lock()
+		delete(wipeOperations, uintptr(*id))
+		wipeLock.Unlock()
+		C.free(unsafe.Pointer(id))
+	}()
+
+	err := C.crypt_wipe(device.cryptDevice, cDevicePath, C.crypt_wipe_pattern(pattern), C.uint64_t(offset), C.uint64_t(length), 0, C.uint32_t(flags),
+		(*[0]byte)(C.wipe_progress), unsafe.Pointer(id))
+	if err < 0 {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return device.newError("crypt_wipe", int(err), "wipe")
+	}
+
+	return nil
+}
+
+// FormatAndWipe formats the device like Format does, with the same options, and then overwrites its whole data area with random data,
+// reporting the progress of the wipe to 'progress', if it is not nil, so that no previous content can be told apart from encrypted data.
+// The wipe stops once 'ctx' is done. The header is always fully written before the wipe starts:
+// a canceled FormatAndWipe leaves a valid, usable header, with a partially wiped data area, which WipeContext may complete later.
+// Returns nil on success, the context's error if it was canceled, or an error otherwise.
+func (device *Device) FormatAndWipe(ctx context.Context, deviceType DeviceType, genericParams GenericParams, progress ProgressFunc, optionFuncs ...Option) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := device.Format(deviceType, genericParams, optionFuncs...); err != nil {
+		return err
+	}
+
+	length, err := device.PayloadSize()
+	if err != nil {
+		return err
+	}
+
+	return device.WipeContext(ctx, "", CRYPT_WIPE_RANDOM, device.DataOffset()*512, length, 0, progress)
+}
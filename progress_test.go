@@ -0,0 +1,59 @@
+package cryptsetup
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_Device_FormatAndWipe(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	var lastSize, lastOffset uint64
+	err = device.FormatAndWipe(context.Background(), LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8},
+		func(size uint64, offset uint64) {
+			lastSize, lastOffset = size, offset
+		})
+	testWrapper.AssertNoError(err)
+
+	payloadSize, err := device.PayloadSize()
+	testWrapper.AssertNoError(err)
+	end := device.DataOffset()*512 + payloadSize
+	if lastSize != end || lastOffset != end {
+		test.Errorf("The progress should have reached %d bytes, but was %d out of %d", end, lastOffset, lastSize)
+	}
+}
+
+func Test_Device_FormatAndWipe_Canceled(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	err = device.FormatAndWipe(ctx, LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8},
+		func(size uint64, offset uint64) {
+			calls++
+			cancel()
+		})
+	if err != context.Canceled {
+		test.Errorf("FormatAndWipe should have been canceled, but returned: %v", err)
+	}
+	if calls != 1 {
+		test.Errorf("The wipe should have stopped after the first progress report, but made %d", calls)
+	}
+	device.Free()
+
+	device, err = Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Load()
+	testWrapper.AssertNoError(err)
+}
@@ -0,0 +1,139 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ErrPassphraseMismatch is returned by prompters when a passphrase and its confirmation differ.
+var ErrPassphraseMismatch = errors.New("passphrases do not match")
+
+// Prompter is the interface implemented by sources of passphrases, so CLIs built on this package get consistent input behavior.
+// If 'confirm' is set, sources able to do so ask for the passphrase twice, and fail with ErrPassphraseMismatch if both differ.
+type Prompter interface {
+	Prompt(message string, confirm bool) ([]byte, error)
+}
+
+// TerminalPrompter is a Prompter reading passphrases from a terminal with echo disabled.
+type TerminalPrompter struct {
+	Terminal *os.File
+}
+
+// NewTerminalPrompter returns a TerminalPrompter using the process' controlling terminal, /dev/tty.
+// Returns the prompter on success, or an error otherwise. Close releases the terminal.
+func NewTerminalPrompter() (*TerminalPrompter, error) {
+	terminal, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &TerminalPrompter{Terminal: terminal}, nil
+}
+
+// Close closes the terminal.
+func (prompter *TerminalPrompter) Close() error {
+	return prompter.Terminal.Close()
+}
+
+// Prompt writes 'message' to the terminal and reads a line with echo disabled.
+func (prompter *TerminalPrompter) Prompt(message string, confirm bool) ([]byte, error) {
+	passphrase, err := prompter.readHidden(message)
+	if err != nil || !confirm {
+		return passphrase, err
+	}
+
+	confirmation, err := prompter.readHidden("Verify passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	defer WipeBytes(confirmation)
+
+	if !bytes.Equal(passphrase, confirmation) {
+		WipeBytes(passphrase)
+		return nil, ErrPassphraseMismatch
+	}
+	return passphrase, nil
+}
+
+// readHidden writes 'message' and reads a line from the terminal with echo disabled, restoring the terminal state afterwards.
+func (prompter *TerminalPrompter) readHidden(message string) ([]byte, error) {
+	fd := prompter.Terminal.Fd()
+
+	var state syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS, uintptr(unsafe.Pointer(&state))); errno != 0 {
+		return nil, os.NewSyscallError("ioctl", errno)
+	}
+
+	hidden := state
+	hidden.Lflag &^= syscall.ECHO
+	hidden.Lflag |= syscall.ICANON | syscall.ECHONL
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS, uintptr(unsafe.Pointer(&hidden))); errno != 0 {
+		return nil, os.NewSyscallError("ioctl", errno)
+	}
+	defer syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS, uintptr(unsafe.Pointer(&state)))
+
+	if _, err := fmt.Fprint(prompter.Terminal, message); err != nil {
+		return nil, err
+	}
+	return readLine(prompter.Terminal)
+}
+
+// FilePrompter is a Prompter reading passphrases from a file descriptor, such as a pipe set up by a parent process.
+// Confirmation is not requested, as the input is not interactive.
+type FilePrompter struct {
+	File *os.File
+}
+
+// Prompt reads a line from the file. The message is ignored.
+func (prompter FilePrompter) Prompt(message string, confirm bool) ([]byte, error) {
+	return readLine(prompter.File)
+}
+
+// readLine reads a line one byte at a time, so that no input following the line is consumed.
+// The trailing newline is not returned. Reaching the end of the input ends the line, unless nothing was read.
+func readLine(reader io.Reader) ([]byte, error) {
+	line := make([]byte, 0, 64)
+	character := make([]byte, 1)
+
+	for {
+		count, err := reader.Read(character)
+		if count == 1 {
+			if character[0] == '\n' {
+				return line, nil
+			}
+			line = appendWiping(line, character[0])
+			continue
+		}
+		if err == io.EOF && len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			WipeBytes(line)
+			return nil, err
+		}
+	}
+}
+
+// appendWiping appends a byte to 'buffer', wiping the previous backing array if it had to be grown.
+func appendWiping(buffer []byte, value byte) []byte {
+	if len(buffer) < cap(buffer) {
+		return append(buffer, value)
+	}
+
+	grown := make([]byte, len(buffer), 2*cap(buffer)+1)
+	copy(grown, buffer)
+	WipeBytes(buffer)
+	return append(grown, value)
+}
+
+// PromptFunc adapts 'prompter' to the prompt functions taken by helpers such as PassphraseCache.ActivateByPassphrase.
+// The passphrases it returns are to be wiped with WipeBytes once done, like those returned by Prompt.
+func PromptFunc(prompter Prompter, message string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return prompter.Prompt(message, false)
+	}
+}
@@ -0,0 +1,68 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_FilePrompter_Prompt(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	reader, writer, err := os.Pipe()
+	testWrapper.AssertNoError(err)
+	defer reader.Close()
+
+	_, err = writer.Write([]byte("firstPassphrase\nsecondPassphrase"))
+	testWrapper.AssertNoError(err)
+	writer.Close()
+
+	prompter := FilePrompter{File: reader}
+
+	passphrase, err := prompter.Prompt("Enter passphrase: ", true)
+	testWrapper.AssertNoError(err)
+	if string(passphrase) != "firstPassphrase" {
+		test.Errorf("Unexpected first passphrase: %q", passphrase)
+	}
+
+	passphrase, err = prompter.Prompt("Enter passphrase: ", false)
+	testWrapper.AssertNoError(err)
+	if string(passphrase) != "secondPassphrase" {
+		test.Errorf("Unexpected second passphrase: %q", passphrase)
+	}
+
+	_, err = prompter.Prompt("Enter passphrase: ", false)
+	testWrapper.AssertError(err)
+}
+
+func Test_TerminalPrompter_Prompt_Fails_If_File_Is_Not_A_Terminal(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	file, err := ioutil.TempFile("", "prompter")
+	testWrapper.AssertNoError(err)
+	defer os.Remove(file.Name())
+
+	prompter := TerminalPrompter{Terminal: file}
+	defer prompter.Close()
+
+	_, err = prompter.Prompt("Enter passphrase: ", false)
+	testWrapper.AssertError(err)
+}
+
+func Test_PromptFunc(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	reader, writer, err := os.Pipe()
+	testWrapper.AssertNoError(err)
+	defer reader.Close()
+
+	_, err = writer.Write([]byte("testPassphrase\n"))
+	testWrapper.AssertNoError(err)
+	writer.Close()
+
+	passphrase, err := PromptFunc(FilePrompter{File: reader}, "Enter passphrase: ")()
+	testWrapper.AssertNoError(err)
+	if string(passphrase) != "testPassphrase" {
+		test.Errorf("Unexpected passphrase: %q", passphrase)
+	}
+}
@@ -0,0 +1,169 @@
+// Package provision encapsulates the steps needed to turn a blank disk into a ready to use LUKS2 volume.
+package provision
+
+import (
+	"fmt"
+
+	"cryptsetup"
+)
+
+// DefaultWipeSize is the size of the area wiped before formatting, covering the default LUKS2 header and keyslot areas.
+const DefaultWipeSize uint64 = 16 * 1024 * 1024
+
+// Keyslot describes a passphrase to add to the provisioned device.
+type Keyslot struct {
+	// Keyslot is the keyslot to use, or CRYPT_ANY_SLOT to use the first free one.
+	Keyslot    int
+	Passphrase string
+	// IterationTimeMs pins the target unlock time of the keyslot. If 0, libcryptsetup's default is used.
+	IterationTimeMs uint64
+}
+
+// Token describes a LUKS2 token to add to the provisioned device.
+type Token struct {
+	JSON string
+	// KeyslotIndexes are the indexes, in Spec.Keyslots, of the keyslots assigned to the token.
+	KeyslotIndexes []int
+}
+
+// Spec describes how a disk should be provisioned.
+type Spec struct {
+	DevicePath    string
+	GenericParams cryptsetup.GenericParams
+	LUKS2         cryptsetup.LUKS2
+	Keyslots      []Keyslot
+	Tokens        []Token
+	// ActivationFlags are CRYPT_ACTIVATE_* flags stored in the header, and applied on every activation.
+	ActivationFlags uint32
+	// WipeSize is the size of the area wiped at the start of the device before formatting, in bytes.
+	// If 0, DefaultWipeSize is used.
+	WipeSize uint64
+}
+
+// Report describes a provisioned device.
+type Report struct {
+	DevicePath string
+	UUID       string
+	// Keyslots are the keyslots used, in the same order as Spec.Keyslots.
+	Keyslots []int
+	// Tokens are the token slots used, in the same order as Spec.Tokens.
+	Tokens          []int
+	ActivationFlags uint32
+	// DataOffset is the offset of the encrypted data, in 512 byte sectors.
+	DataOffset uint64
+	// PayloadSize is the space left for the decrypted data, in bytes.
+	PayloadSize uint64
+}
+
+// ProvisionEncryptedDisk checks the disk is not in use, wipes its header area, formats it as LUKS2, adds the keyslots and tokens,
+// verifies each passphrase and stores the persistent activation flags.
+// Unset GenericParams and LUKS2 parameters are filled with the application-wide defaults.
+// Returns the report on success, or the report of the steps completed so far along with an error otherwise.
+func ProvisionEncryptedDisk(spec Spec) (Report, error) {
+	report := Report{DevicePath: spec.DevicePath}
+
+	if len(spec.Keyslots) == 0 {
+		return report, fmt.Errorf("at least one keyslot is required to provision '%s'", spec.DevicePath)
+	}
+
+	device, err := cryptsetup.Init(spec.DevicePath)
+	if err != nil {
+		return report, err
+	}
+	defer device.Free()
+
+	if err = device.CheckNotInUse(); err != nil {
+		return report, err
+	}
+	if err = wipeHeaderArea(device, spec.WipeSize); err != nil {
+		return report, err
+	}
+
+	genericParams, luks2 := spec.GenericParams, spec.LUKS2
+	genericParams.FillDefaultValues()
+	luks2.FillDefaultValues()
+	if err = device.Format(luks2, genericParams); err != nil {
+		return report, err
+	}
+	report.UUID = device.UUID()
+	report.DataOffset = device.DataOffset()
+
+	volumeKey := cryptsetup.VolumeKey{VolumeKey: genericParams.VolumeKey}
+	for _, keyslot := range spec.Keyslots {
+		slot, err := addKeyslot(device, volumeKey, keyslot)
+		if err != nil {
+			return report, err
+		}
+		report.Keyslots = append(report.Keyslots, slot)
+
+		if _, err = device.CheckPassphrase(slot, keyslot.Passphrase); err != nil {
+			return report, err
+		}
+	}
+
+	for _, token := range spec.Tokens {
+		tokenID, err := device.TokenJSONSet(cryptsetup.CRYPT_ANY_TOKEN, token.JSON)
+		if err != nil {
+			return report, err
+		}
+		report.Tokens = append(report.Tokens, tokenID)
+
+		for _, index := range token.KeyslotIndexes {
+			if index < 0 || index >= len(report.Keyslots) {
+				return report, fmt.Errorf("token keyslot index %d is out of range", index)
+			}
+			if err = device.TokenAssignKeyslot(tokenID, report.Keyslots[index]); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if spec.ActivationFlags != 0 {
+		if err = device.SetPersistentFlags(cryptsetup.CRYPT_FLAGS_ACTIVATION, spec.ActivationFlags); err != nil {
+			return report, err
+		}
+		report.ActivationFlags = spec.ActivationFlags
+	}
+
+	if report.PayloadSize, err = device.PayloadSize(); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// wipeHeaderArea zeroes the start of the device, so that stale signatures and keyslots do not survive formatting.
+// The wiped area is capped at the size of the device.
+func wipeHeaderArea(device *cryptsetup.Device, wipeSize uint64) error {
+	if wipeSize == 0 {
+		wipeSize = DefaultWipeSize
+	}
+
+	info, err := device.DataDeviceInfo()
+	if err != nil {
+		return err
+	}
+	if wipeSize > info.Size {
+		wipeSize = info.Size
+	}
+
+	return device.Wipe("", cryptsetup.CRYPT_WIPE_ZERO, 0, wipeSize, 0)
+}
+
+// addKeyslot adds a keyslot holding the passphrase, pinning its iteration time if requested.
+func addKeyslot(device *cryptsetup.Device, volumeKey cryptsetup.VolumeKey, keyslot Keyslot) (int, error) {
+	passphrase := []byte(keyslot.Passphrase)
+	defer cryptsetup.WipeBytes(passphrase)
+
+	if keyslot.IterationTimeMs == 0 {
+		return volumeKey.KeyslotAdd(device, keyslot.Keyslot, passphrase)
+	}
+
+	var slot int
+	err := device.WithIterationTime(keyslot.IterationTimeMs, func() error {
+		var err error
+		slot, err = volumeKey.KeyslotAdd(device, keyslot.Keyslot, passphrase)
+		return err
+	})
+	return slot, err
+}
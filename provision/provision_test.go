@@ -0,0 +1,70 @@
+package provision
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+
+	"cryptsetup"
+)
+
+const devicePath string = "testDevice"
+
+func Test_ProvisionEncryptedDisk(test *testing.T) {
+	report, err := ProvisionEncryptedDisk(Spec{
+		DevicePath:    devicePath,
+		GenericParams: cryptsetup.GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8},
+		LUKS2:         cryptsetup.LUKS2{SectorSize: 512},
+		Keyslots: []Keyslot{
+			{Keyslot: cryptsetup.CRYPT_ANY_SLOT, Passphrase: "userPassphrase"},
+			{Keyslot: 7, Passphrase: "recoveryPassphrase", IterationTimeMs: 10},
+		},
+		Tokens:          []Token{{JSON: `{"type":"go-cryptsetup-test","keyslots":[]}`, KeyslotIndexes: []int{1}}},
+		ActivationFlags: cryptsetup.CRYPT_ACTIVATE_ALLOW_DISCARDS,
+	})
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	if report.UUID == "" || len(report.Keyslots) != 2 || report.Keyslots[1] != 7 || len(report.Tokens) != 1 {
+		test.Errorf("Unexpected report: %+v", report)
+	}
+
+	if report.PayloadSize != 64*1024*1024-report.DataOffset*512 {
+		test.Errorf("Unexpected payload size: %d", report.PayloadSize)
+	}
+
+	device, err := cryptsetup.Init(devicePath)
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer device.Free()
+
+	if err = device.Load(); err != nil {
+		test.Fatal(err)
+	}
+
+	if flags, err := device.PersistentFlags(cryptsetup.CRYPT_FLAGS_ACTIVATION); err != nil || flags != cryptsetup.CRYPT_ACTIVATE_ALLOW_DISCARDS {
+		test.Errorf("Unexpected persistent activation flags '%#x': %v", flags, err)
+	}
+
+	tokens, err := device.Tokens()
+	if err != nil || len(tokens) != 1 || len(tokens[0].Keyslots) != 1 || tokens[0].Keyslots[0] != 7 {
+		test.Errorf("Unexpected tokens %+v: %v", tokens, err)
+	}
+}
+
+func Test_ProvisionEncryptedDisk_Fails_Without_Keyslots(test *testing.T) {
+	_, err := ProvisionEncryptedDisk(Spec{DevicePath: devicePath})
+	if err == nil {
+		test.Error("Provisioning without keyslots should have failed.")
+	}
+}
+
+func TestMain(m *testing.M) {
+	exec.Command("/bin/dd", "if=/dev/zero", fmt.Sprintf("of=%s", devicePath), "bs=64M", "count=1").Run()
+	result := m.Run()
+	exec.Command("/bin/rm", "-f", devicePath).Run()
+	os.Exit(result)
+}
@@ -0,0 +1,137 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MismatchError is returned by Reconcile when an active mapping doesn't match the header the device was loaded from.
+type MismatchError struct {
+	Name       string
+	Mismatches []string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("mapping '%s' does not match the header: %s.", e.Name, strings.Join(e.Mismatches, ", "))
+}
+
+// Reconcile verifies that the kernel's dm-crypt table of the active mapping the device was initialized from,
+// by InitByName or InitByNameAndHeader, matches its header: cipher, volume key size, data and IV offsets,
+// and that the mapping's volume key is the one protected by the header's keyslots.
+// This detects a detached header passed for the wrong data device, which libcryptsetup accepts silently.
+// Volume keys held in the kernel keyring are matched through their description, which holds the header's UUID.
+// Returns nil if the mapping matches the header, a *MismatchError if it doesn't, or an error otherwise.
+func (device *Device) Reconcile() error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	if device.name == "" {
+		return errors.New("device was not initialized from an active mapping")
+	}
+
+	var mismatches []string
+	err := dmTableStatus(device.name, func(targets []DMTarget) error {
+		var err error
+		mismatches, err = device.reconcileTargets(targets)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(mismatches) > 0 {
+		return &MismatchError{Name: device.name, Mismatches: mismatches}
+	}
+	return nil
+}
+
+// reconcileTargets compares the 'targets' of a mapping, read by dmTableStatus, with the device's header, and returns the mismatches found.
+func (device *Device) reconcileTargets(targets []DMTarget) ([]string, error) {
+	mismatches := make([]string, 0)
+	cryptTargets := 0
+
+	for _, target := range targets {
+		if target.Type != "crypt" {
+			continue
+		}
+		cryptTargets++
+
+		crypt, _, err := parseDMCryptParams(target.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		cipher := C.GoString(C.crypt_get_cipher(device.cryptDevice)) + "-" + C.GoString(C.crypt_get_cipher_mode(device.cryptDevice))
+		if !strings.HasPrefix(crypt.Cipher, "capi:") && crypt.Cipher != cipher {
+			mismatches = append(mismatches, fmt.Sprintf("cipher is '%s' instead of '%s'", crypt.Cipher, cipher))
+		}
+		if crypt.KeySize != device.VolumeKeySize() {
+			mismatches = append(mismatches, fmt.Sprintf("volume key size is %d instead of %d", crypt.KeySize, device.VolumeKeySize()))
+		}
+		if crypt.Offset != device.DataOffset() {
+			mismatches = append(mismatches, fmt.Sprintf("data offset is %d instead of %d", crypt.Offset, device.DataOffset()))
+		}
+		if crypt.IVOffset != device.IVOffset() {
+			mismatches = append(mismatches, fmt.Sprintf("IV offset is %d instead of %d", crypt.IVOffset, device.IVOffset()))
+		}
+
+		if crypt.KeyType != "hex" {
+			if !strings.Contains(crypt.KeyDescription, device.UUID()) {
+				mismatches = append(mismatches, "keyring volume key does not belong to the header")
+			}
+			continue
+		}
+
+		keyStart, keyEnd := dmCryptKey(target.rawParams)
+		if keyStart < 0 {
+			return nil, errors.New("invalid dm-crypt parameters")
+		}
+		matches, err := device.verifyHexVolumeKey(target.rawParams[keyStart:keyEnd])
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			mismatches = append(mismatches, "volume key is not protected by the header")
+		}
+	}
+
+	if cryptTargets == 0 {
+		return nil, errors.New("mapping has no crypt target")
+	}
+
+	return mismatches, nil
+}
+
+// verifyHexVolumeKey reports whether the hex encoded 'key' is the volume key of the header, decoding it into locked memory.
+// C equivalent: crypt_volume_key_verify
+func (device *Device) verifyHexVolumeKey(key []byte) (bool, error) {
+	size := hex.DecodedLen(len(key))
+	memory := safeAlloc(size)
+	if memory == nil {
+		return false, &Error{functionName: "crypt_safe_alloc", code: int(ENOMEM)}
+	}
+	cVolumeKey := (*C.char)(memory)
+	defer safeFree(cVolumeKey)
+
+	buffer := (*[1 << 30]byte)(memory)[:size:size]
+	if _, err := hex.Decode(buffer, key); err != nil {
+		return false, errors.New("invalid dm-crypt volume key")
+	}
+
+	result := C.crypt_volume_key_verify(device.cryptDevice, cVolumeKey, C.size_t(size))
+	if result == C.int(EPERM) {
+		return false, nil
+	}
+	if result < 0 {
+		return false, device.newError("crypt_volume_key_verify", int(result), "reconcile "+device.name)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,55 @@
+package cryptsetup
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_Device_Reconcile_Fails_If_Not_Initialized_By_Name(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	testWrapper.AssertError(device.Reconcile())
+}
+
+func Test_Device_reconcileTargets(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	volumeKey := strings.Repeat("k", 64)
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKey: volumeKey, VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	target := func(key string, offset uint64) []DMTarget {
+		params := []byte(fmt.Sprintf("aes-xts-plain64 %s 0 7:0 %d", hex.EncodeToString([]byte(key)), offset))
+		return []DMTarget{{
+			Type:      "crypt",
+			Params:    redactDMCryptKey(params),
+			rawParams: params,
+		}}
+	}
+
+	mismatches, err := device.reconcileTargets(target(volumeKey, device.DataOffset()))
+	testWrapper.AssertNoError(err)
+	if len(mismatches) != 0 {
+		test.Errorf("Expected no mismatch, but got: %v", mismatches)
+	}
+
+	mismatches, err = device.reconcileTargets(target(strings.Repeat("x", 64), device.DataOffset()+8))
+	testWrapper.AssertNoError(err)
+	if len(mismatches) != 2 {
+		test.Errorf("Expected the data offset and volume key to mismatch, but got: %v", mismatches)
+	}
+
+	_, err = device.reconcileTargets([]DMTarget{{Type: "linear", Params: "7:0 0", rawParams: []byte("7:0 0")}})
+	testWrapper.AssertError(err)
+}
@@ -0,0 +1,74 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// modhexAlphabet is the keyboard layout independent alphabet used to encode recovery keys, as popularized by YubiKey and systemd-cryptenroll.
+const modhexAlphabet = "cbdefghijklnrtuv"
+
+const (
+	recoveryKeyBytes      = 32
+	recoveryKeyGroupBytes = 4
+)
+
+// GenerateRecoveryKey generates a random, human-readable recovery key.
+// The key holds 256 bits of entropy, encoded as 8 dash-separated groups of 8 modhex characters.
+// The random bytes are read from crypto/rand, unless WithRandom is given.
+// Returns the recovery key on success, or an error otherwise.
+func GenerateRecoveryKey(optionFuncs ...Option) (string, error) {
+	options := newOptions(optionFuncs)
+
+	key := make([]byte, recoveryKeyBytes)
+	defer WipeBytes(key)
+	if _, err := io.ReadFull(options.random, key); err != nil {
+		return "", err
+	}
+
+	groups := make([]string, 0, recoveryKeyBytes/recoveryKeyGroupBytes)
+	for offset := 0; offset < len(key); offset += recoveryKeyGroupBytes {
+		var group strings.Builder
+		for _, b := range key[offset : offset+recoveryKeyGroupBytes] {
+			group.WriteByte(modhexAlphabet[b>>4])
+			group.WriteByte(modhexAlphabet[b&0x0f])
+		}
+		groups = append(groups, group.String())
+	}
+
+	return strings.Join(groups, "-"), nil
+}
+
+// AddRecoveryKey generates a recovery key and stores it in the last free keyslot,
+// keeping the lower keyslots available for regular passphrases.
+// 'credential' must already unlock the device and implement KeyslotAdder, such as Passphrase or VolumeKey.
+// 'optionFuncs' are passed to GenerateRecoveryKey.
+// Returns the recovery key and the number of the keyslot it was stored in on success, or an error otherwise.
+func (device *Device) AddRecoveryKey(credential Credential, optionFuncs ...Option) (string, int, error) {
+	if err := device.checkUsable(); err != nil {
+		return "", 0, err
+	}
+
+	adder, ok := credential.(KeyslotAdder)
+	if !ok {
+		return "", 0, fmt.Errorf("credential of type '%T' cannot be used to add keyslots", credential)
+	}
+
+	keyslot, err := device.NextFreeKeyslot(KeyslotPriorityRecovery)
+	if err != nil {
+		return "", 0, err
+	}
+
+	recoveryKey, err := GenerateRecoveryKey(optionFuncs...)
+	if err != nil {
+		return "", 0, err
+	}
+
+	keyslot, err = adder.KeyslotAdd(device, keyslot, stringBytes(recoveryKey))
+	if err != nil {
+		return "", 0, err
+	}
+
+	return recoveryKey, keyslot, nil
+}
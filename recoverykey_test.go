@@ -0,0 +1,79 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_GenerateRecoveryKey(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	recoveryKey, err := GenerateRecoveryKey()
+	testWrapper.AssertNoError(err)
+
+	if !regexp.MustCompile(`^[cbdefghijklnrtuv]{8}(-[cbdefghijklnrtuv]{8}){7}$`).MatchString(recoveryKey) {
+		test.Errorf("Invalid recovery key format: %s", recoveryKey)
+	}
+
+	otherRecoveryKey, err := GenerateRecoveryKey()
+	testWrapper.AssertNoError(err)
+
+	if recoveryKey == otherRecoveryKey {
+		test.Error("Recovery keys should be random.")
+	}
+}
+
+func Test_GenerateRecoveryKey_WithRandom(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	recoveryKey, err := GenerateRecoveryKey(WithRandom(bytes.NewReader(make([]byte, recoveryKeyBytes))))
+	testWrapper.AssertNoError(err)
+
+	if expected := strings.Repeat("-cccccccc", 8)[1:]; recoveryKey != expected {
+		test.Errorf("Recovery key should have been '%s', but was: %s", expected, recoveryKey)
+	}
+
+	_, err = GenerateRecoveryKey(WithRandom(bytes.NewReader(make([]byte, recoveryKeyBytes-1))))
+	testWrapper.AssertError(err)
+}
+
+func Test_Device_AddRecoveryKey(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	recoveryKey, keyslot, err := device.AddRecoveryKey(Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "testPassphrase"})
+	testWrapper.AssertNoError(err)
+
+	if keyslot != 7 {
+		test.Errorf("Recovery key should have been stored in keyslot 7, but was stored in: %d", keyslot)
+	}
+
+	unlockedKeyslot, err := device.CheckPassphrase(CRYPT_ANY_SLOT, recoveryKey)
+	testWrapper.AssertNoError(err)
+	if unlockedKeyslot != keyslot {
+		test.Errorf("Recovery key should have unlocked keyslot %d, but unlocked: %d", keyslot, unlockedKeyslot)
+	}
+}
+
+func Test_Device_AddRecoveryKey_Fails_If_Credential_Cannot_Add_Keyslots(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	_, _, err = device.AddRecoveryKey(KeyringKey{Keyslot: CRYPT_ANY_SLOT, KeyDescription: "testKeyDescription"})
+	testWrapper.AssertError(err)
+}
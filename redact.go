@@ -0,0 +1,73 @@
+package cryptsetup
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// redactedPrefix starts the values anonymized by DumpRedacted.
+const redactedPrefix = "redacted:"
+
+// DumpRedacted is like DumpLUKS2, but anonymizes the salts, the digests, the UUID, the label and the subsystem,
+// so header metadata can be attached to bug reports without leaking material useful to attackers.
+// Each value is replaced by a prefix of its HMAC-SHA-256 under a random key drawn for each call, so identical values
+// in a dump, such as a salt reused across keyslots, can still be told apart from distinct ones, while the values
+// cannot be guessed by hashing candidates, nor matched across dumps. Keyslot, digest and segment layouts are kept as is.
+// The key is read from crypto/rand, unless WithRandom is given.
+// Returns the anonymized metadata on success, or an error otherwise.
+func (device *Device) DumpRedacted(optionFuncs ...Option) (LUKS2Dump, error) {
+	dump, err := device.DumpLUKS2()
+	if err != nil {
+		return dump, err
+	}
+
+	key := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(newOptions(optionFuncs).random, key); err != nil {
+		return LUKS2Dump{}, err
+	}
+
+	return dump.redacted(key), nil
+}
+
+// redacted returns a copy of the dump with its identifying and secret-derived values anonymized under 'key'.
+func (dump LUKS2Dump) redacted(key []byte) LUKS2Dump {
+	redactValue := func(value string) string {
+		return redactValueWithKey(key, value)
+	}
+
+	dump.UUID = redactValue(dump.UUID)
+	dump.Label = redactValue(dump.Label)
+	dump.Subsystem = redactValue(dump.Subsystem)
+	// The metadata read from the header holds the values in the clear, along with tokens, so it is dropped.
+	dump.metadata = nil
+
+	keyslots := make([]LUKS2KeyslotInfo, len(dump.Keyslots))
+	for index, keyslot := range dump.Keyslots {
+		keyslot.Salt = redactValue(keyslot.Salt)
+		keyslots[index] = keyslot
+	}
+	dump.Keyslots = keyslots
+
+	digests := make([]LUKS2DigestInfo, len(dump.Digests))
+	for index, digest := range dump.Digests {
+		digest.Salt = redactValue(digest.Salt)
+		digest.Digest = redactValue(digest.Digest)
+		digests[index] = digest
+	}
+	dump.Digests = digests
+
+	return dump
+}
+
+// redactValueWithKey replaces 'value' by a prefix of its HMAC-SHA-256 under 'key'. Empty values are kept empty.
+func redactValueWithKey(key []byte, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return redactedPrefix + hex.EncodeToString(mac.Sum(nil)[:8])
+}
@@ -0,0 +1,67 @@
+package cryptsetup
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_Device_DumpRedacted(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS2{SectorSize: 512, Label: "secretLabel", PBKDFType: &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK}}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	dump, err := device.DumpLUKS2()
+	testWrapper.AssertNoError(err)
+	if dump.UUID != device.UUID() || dump.Label != "secretLabel" {
+		test.Fatalf("Unexpected UUID '%s' or label '%s'", dump.UUID, dump.Label)
+	}
+
+	redacted, err := device.DumpRedacted()
+	testWrapper.AssertNoError(err)
+
+	if !strings.HasPrefix(redacted.UUID, redactedPrefix) || !strings.HasPrefix(redacted.Label, redactedPrefix) || redacted.Subsystem != "" {
+		test.Errorf("Unexpected UUID '%s', label '%s' or subsystem '%s'", redacted.UUID, redacted.Label, redacted.Subsystem)
+	}
+	if len(redacted.Keyslots) != 1 || redacted.Keyslots[0].Salt == dump.Keyslots[0].Salt || !strings.HasPrefix(redacted.Keyslots[0].Salt, redactedPrefix) {
+		test.Errorf("Unexpected keyslots: %+v", redacted.Keyslots)
+	}
+	if len(redacted.Digests) != 1 || !strings.HasPrefix(redacted.Digests[0].Digest, redactedPrefix) || !strings.HasPrefix(redacted.Digests[0].Salt, redactedPrefix) {
+		test.Errorf("Unexpected digests: %+v", redacted.Digests)
+	}
+	if dump.Keyslots[0].Salt == redacted.Keyslots[0].Salt {
+		test.Error("Redacting should not modify the original dump.")
+	}
+
+	encoded, err := json.Marshal(redacted)
+	testWrapper.AssertNoError(err)
+	if strings.Contains(string(encoded), dump.Keyslots[0].Salt) || strings.Contains(string(encoded), dump.Digests[0].Digest) {
+		test.Errorf("The JSON of a redacted dump should not hold the original values: %s", encoded)
+	}
+
+	again, err := device.DumpRedacted()
+	testWrapper.AssertNoError(err)
+	if again.UUID == redacted.UUID {
+		test.Error("Each dump should be redacted under a new key.")
+	}
+}
+
+func Test_redactValueWithKey(test *testing.T) {
+	key, otherKey := []byte("key"), []byte("otherKey")
+	if redactValueWithKey(key, "") != "" {
+		test.Error("Empty values should be kept empty.")
+	}
+	if redactValueWithKey(key, "salt") != redactValueWithKey(key, "salt") || redactValueWithKey(key, "salt") == redactValueWithKey(key, "other") {
+		test.Error("Redacted values should be stable, and distinct for distinct values.")
+	}
+	if redactValueWithKey(key, "salt") == redactValueWithKey(otherKey, "salt") {
+		test.Error("Redacted values should differ between keys.")
+	}
+}
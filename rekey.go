@@ -0,0 +1,158 @@
+package cryptsetup
+
+/*
+#cgo pkg-config: libcryptsetup
+#include <errno.h>
+#include <libcryptsetup.h>
+#include <stdlib.h>
+
+// crypt_reencrypt_init_by_passphrase and crypt_reencrypt were added in libcryptsetup 2.2, which is also when
+// CRYPT_REENCRYPT_INITIALIZE_ONLY was defined. Older versions cannot reencrypt.
+static int reencrypt_supported(void)
+{
+#ifdef CRYPT_REENCRYPT_INITIALIZE_ONLY
+	return 1;
+#else
+	return 0;
+#endif
+}
+
+// reencrypt_init starts reencrypting the device to the volume key of 'keyslot_new', keeping its cipher and sector size.
+// The parameters are built in C, as their structs do not exist before libcryptsetup 2.2.
+static int reencrypt_init(struct crypt_device *cd, const char *name, const char *passphrase, size_t passphrase_size,
+	int keyslot_old, int keyslot_new, const char *resilience, const char *hash)
+{
+#ifdef CRYPT_REENCRYPT_INITIALIZE_ONLY
+	struct crypt_params_luks2 luks2 = { .sector_size = crypt_get_sector_size(cd) };
+	struct crypt_params_reencrypt params = {
+		.mode = CRYPT_REENCRYPT_REENCRYPT,
+		.direction = CRYPT_REENCRYPT_FORWARD,
+		.resilience = resilience,
+		.hash = hash,
+		.luks2 = &luks2,
+	};
+
+	return crypt_reencrypt_init_by_passphrase(cd, name, passphrase, passphrase_size, keyslot_old, keyslot_new,
+		crypt_get_cipher(cd), crypt_get_cipher_mode(cd), &params);
+#else
+	return -ENOTSUP;
+#endif
+}
+
+// crypt_reencrypt_run was added in libcryptsetup 2.4, which is also when CRYPT_TOKEN_ABI_VERSION1 was defined.
+// Older versions only have crypt_reencrypt, which 2.4 deprecated in its favour.
+static int reencrypt_run(struct crypt_device *cd)
+{
+#if defined(CRYPT_TOKEN_ABI_VERSION1)
+	return crypt_reencrypt_run(cd, NULL, NULL);
+#elif defined(CRYPT_REENCRYPT_INITIALIZE_ONLY)
+	return crypt_reencrypt(cd, NULL);
+#else
+	return -ENOTSUP;
+#endif
+}
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// rekeyResilience and rekeyHash protect the area being reencrypted against crashes, like `cryptsetup reencrypt` does by default.
+const (
+	rekeyResilience = "checksum"
+	rekeyHash       = "sha256"
+)
+
+// Rekey replaces the volume key of the LUKS2 device with a new random one, reencrypting all the data with it,
+// so rotating the volume key is a single operation. The cipher, the key size and the sector size are kept.
+// The passphrase of 'credential' is moved to a new keyslot protected by 'newPBKDF', or by the PBKDF parameters set for
+// new keyslots if it is nil.
+// Every other keyslot holds the old volume key, and is removed once the reencryption completes: passphrases, key files,
+// keyslots bound to tokens and escrowed recovery keys alike. Callers must opt in by setting 'destroyOthers' to true;
+// otherwise Rekey fails without changing anything if the device has other keyslots.
+// If the device was initialized by InitByName, its active mapping is reencrypted online; otherwise the device must not be active.
+// An interrupted reencryption is recorded in the header, and can be resumed by `cryptsetup reencrypt --resume-only`.
+// It fails with -ENOTSUP if libcryptsetup is older than 2.2.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_reencrypt_init_by_passphrase, followed by crypt_reencrypt_run
+func (device *Device) Rekey(credential Passphrase, newPBKDF *PbkdfType, destroyOthers bool) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	if device.Type() != TypeLUKS2 {
+		return fmt.Errorf("device '%s' is not a LUKS2 device, and cannot be rekeyed", device.DevicePath())
+	}
+
+	if C.reencrypt_supported() == 0 {
+		return device.newError("crypt_reencrypt_init_by_passphrase", int(ENOTSUP), "rekey")
+	}
+
+	passphrase := NormalizePassphrase(credential.Passphrase, credential.Normalizers...)
+	oldKeyslot, err := device.CheckPassphrase(credential.Keyslot, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if others := device.otherBoundKeyslots(oldKeyslot); len(others) > 0 && !destroyOthers {
+		return fmt.Errorf("rekeying device '%s' would destroy keyslots %v, which hold the old volume key", device.DevicePath(), others)
+	}
+
+	if newPBKDF != nil {
+		previous := device.PBKDFType()
+		device.restorePBKDFType(newPBKDF)
+		defer device.restorePBKDFType(previous)
+	}
+
+	unlock, err := device.lockKeyslots()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	before := device.keyslotsInUse()
+	cPassphrase := bytesPointer(stringBytes(passphrase))
+
+	newKeyslot := C.crypt_keyslot_add_by_key(device.cryptDevice, CRYPT_ANY_SLOT, nil, C.size_t(device.VolumeKeySize()),
+		cPassphrase, C.size_t(len(passphrase)), C.CRYPT_VOLUME_KEY_NO_SEGMENT)
+	if newKeyslot < 0 {
+		return device.newError("crypt_keyslot_add_by_key", int(newKeyslot), "rekey")
+	}
+
+	var cName *C.char = nil
+	if device.name != "" {
+		cName = C.CString(device.name)
+		defer C.free(unsafe.Pointer(cName))
+	}
+
+	cResilience := C.CString(rekeyResilience)
+	defer C.free(unsafe.Pointer(cResilience))
+	cHash := C.CString(rekeyHash)
+	defer C.free(unsafe.Pointer(cHash))
+
+	result := C.reencrypt_init(device.cryptDevice, cName, cPassphrase, C.size_t(len(passphrase)), C.int(oldKeyslot), newKeyslot, cResilience, cHash)
+	if result < 0 {
+		C.crypt_keyslot_destroy(device.cryptDevice, newKeyslot)
+		return device.newError("crypt_reencrypt_init_by_passphrase", int(result), "rekey", keyslotDetail(oldKeyslot))
+	}
+
+	if result := C.reencrypt_run(device.cryptDevice); result < 0 {
+		return device.newError("crypt_reencrypt_run", int(result), "rekey")
+	}
+
+	emitEvent(&KeyslotAdded{Device: device.DevicePath(), Keyslot: int(newKeyslot)})
+	device.emitKeyslotsDestroyed(before)
+	return nil
+}
+
+// otherBoundKeyslots returns the keyslots other than 'keyslot' holding the volume key, which reencryption removes.
+func (device *Device) otherBoundKeyslots(keyslot int) []int {
+	others := make([]int, 0)
+	for other := 0; other < device.KeyslotMax(); other++ {
+		if status := device.KeyslotStatus(other); other != keyslot && (status == CRYPT_SLOT_ACTIVE || status == CRYPT_SLOT_ACTIVE_LAST) {
+			others = append(others, other)
+		}
+	}
+	return others
+}
@@ -0,0 +1,65 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_Device_Rekey(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	pbkdfType := &PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK}
+	err = device.Format(LUKS2{SectorSize: 512, PBKDFType: pbkdfType}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "testPassphrase"))
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(1, "", "otherPassphrase"))
+
+	oldVolumeKey, _, err := device.VolumeKeyGet(0, "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	err = device.Rekey(Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "testPassphrase"}, pbkdfType, false)
+	testWrapper.AssertError(err)
+	if status := device.KeyslotStatus(1); status != CRYPT_SLOT_ACTIVE {
+		test.Errorf("Other keyslots should be kept unless destroying them was requested, but keyslot 1 has status %d.", status)
+	}
+
+	err = device.Rekey(Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "testPassphrase"}, pbkdfType, true)
+	testWrapper.AssertNoError(err)
+
+	keyslot, err := device.CheckPassphrase(CRYPT_ANY_SLOT, "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	newVolumeKey, _, err := device.VolumeKeyGet(keyslot, "testPassphrase")
+	testWrapper.AssertNoError(err)
+	if bytes.Equal(oldVolumeKey, newVolumeKey) || len(newVolumeKey) != 512/8 {
+		test.Error("The volume key should have been replaced by a new one of the same size.")
+	}
+
+	if status := device.KeyslotStatus(1); status != CRYPT_SLOT_INACTIVE {
+		test.Errorf("The keyslot bound to the old volume key should have been removed, but has status %d.", status)
+	}
+
+	dump, err := device.DumpLUKS2()
+	testWrapper.AssertNoError(err)
+	if dump.Reencrypting() || len(dump.Segments) != 1 {
+		test.Errorf("The reencryption should have completed: %+v", dump.Segments)
+	}
+}
+
+func Test_Device_Rekey_Fails(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	err = device.Rekey(Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "testPassphrase"}, nil, true)
+	testWrapper.AssertError(err)
+}
@@ -0,0 +1,57 @@
+package cryptsetup
+
+import "time"
+
+// RetryPolicy controls how ActivateWithRetry retries activations failing with transient errors.
+type RetryPolicy struct {
+	// Attempts is the maximum number of activation attempts. Values below 1 mean a single attempt.
+	Attempts int
+	// Backoff is the delay before the first retry, doubled after each further retry.
+	Backoff time.Duration
+	// Timeout bounds the total time spent retrying. Zero means no bound other than Attempts.
+	Timeout time.Duration
+}
+
+// ActivateWithRetry activates the device as 'deviceName' using 'credential' and the CRYPT_ACTIVATE_* 'flags', retrying according to 'policy'
+// while activation fails with EBUSY or ENODEV, as happens when udev is still settling after a device was hotplugged.
+// Other errors, such as a wrong passphrase, are returned right away.
+// Delays are measured with the system clock, unless WithClock is given.
+// Returns nil on success, or the error of the last attempt otherwise.
+func (device *Device) ActivateWithRetry(deviceName string, credential Credential, policy RetryPolicy, flags int, optionFuncs ...Option) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	clock := newOptions(optionFuncs).clock
+
+	var deadline time.Time
+	if policy.Timeout > 0 {
+		deadline = clock.Now().Add(policy.Timeout)
+	}
+
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := credential.Activate(device, deviceName, flags)
+		if err == nil || !isTransientActivationError(err) || attempt >= policy.Attempts {
+			return err
+		}
+
+		if !deadline.IsZero() && clock.Now().Add(backoff).After(deadline) {
+			return err
+		}
+
+		clock.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// isTransientActivationError reports whether 'err' may go away by itself, once udev has settled.
+func isTransientActivationError(err error) bool {
+	cryptErr, ok := err.(*Error)
+	if !ok {
+		return false
+	}
+
+	errno := cryptErr.Errno()
+	return errno == EBUSY || errno == ENODEV
+}
@@ -0,0 +1,111 @@
+package cryptsetup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type flakyCredential struct {
+	attempts *int
+	errs     []error
+	// flags records the flags of the last attempt, if it is not nil.
+	flags *int
+}
+
+func (credential flakyCredential) Activate(device *Device, deviceName string, flags int) error {
+	*credential.attempts++
+	if credential.flags != nil {
+		*credential.flags = flags
+	}
+	if *credential.attempts <= len(credential.errs) {
+		return credential.errs[*credential.attempts-1]
+	}
+	return nil
+}
+
+func Test_Device_ActivateWithRetry_Retries_Transient_Errors(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	attempts, flags := 0, 0
+	credential := flakyCredential{attempts: &attempts, flags: &flags, errs: []error{
+		&Error{functionName: "crypt_activate_by_passphrase", code: int(EBUSY)},
+		&Error{functionName: "crypt_activate_by_passphrase", code: int(ENODEV)},
+	}}
+
+	clock := &fakeClock{}
+	err = device.ActivateWithRetry("", credential, RetryPolicy{Attempts: 3, Backoff: time.Second}, CRYPT_ACTIVATE_READONLY, WithClock(clock))
+	testWrapper.AssertNoError(err)
+
+	if flags != CRYPT_ACTIVATE_READONLY {
+		test.Errorf("Activation flags should have been passed to the credential, but were: %#x", flags)
+	}
+
+	if attempts != 3 {
+		test.Errorf("Activation should have been attempted 3 times, but was attempted %d times.", attempts)
+	}
+
+	if expected := []time.Duration{time.Second, 2 * time.Second}; !reflect.DeepEqual(clock.sleeps, expected) {
+		test.Errorf("Backoff delays should have been %v, but were: %v", expected, clock.sleeps)
+	}
+}
+
+func Test_Device_ActivateWithRetry_Stops_After_Attempts(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	attempts := 0
+	busy := &Error{functionName: "crypt_activate_by_passphrase", code: int(EBUSY)}
+	credential := flakyCredential{attempts: &attempts, errs: []error{busy, busy, busy}}
+
+	err = device.ActivateWithRetry("", credential, RetryPolicy{Attempts: 2, Backoff: time.Millisecond}, 0)
+	testWrapper.AssertErrorCodeEquals(err, int(EBUSY))
+
+	if attempts != 2 {
+		test.Errorf("Activation should have been attempted 2 times, but was attempted %d times.", attempts)
+	}
+}
+
+func Test_Device_ActivateWithRetry_Does_Not_Retry_Other_Errors(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	attempts := 0
+	credential := flakyCredential{attempts: &attempts, errs: []error{&Error{functionName: "crypt_activate_by_passphrase", code: int(EPERM)}}}
+
+	err = device.ActivateWithRetry("", credential, RetryPolicy{Attempts: 3, Backoff: time.Millisecond}, 0)
+	testWrapper.AssertErrorCodeEquals(err, int(EPERM))
+
+	if attempts != 1 {
+		test.Errorf("Activation should have been attempted once, but was attempted %d times.", attempts)
+	}
+}
+
+func Test_Device_ActivateWithRetry_Stops_At_Timeout(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	attempts := 0
+	busy := &Error{functionName: "crypt_activate_by_passphrase", code: int(EBUSY)}
+	credential := flakyCredential{attempts: &attempts, errs: []error{busy, busy, busy}}
+
+	err = device.ActivateWithRetry("", credential, RetryPolicy{Attempts: 3, Backoff: time.Second, Timeout: 2 * time.Second}, 0, WithClock(&fakeClock{}))
+	testWrapper.AssertErrorCodeEquals(err, int(EBUSY))
+
+	if attempts != 2 {
+		test.Errorf("Activation should have been attempted twice, but was attempted %d times.", attempts)
+	}
+}
@@ -0,0 +1,118 @@
+package cryptsetup
+
+/*
+#cgo pkg-config: libcryptsetup
+#include <libcryptsetup.h>
+#include <stdlib.h>
+#include <sys/mman.h>
+
+// crypt_safe_alloc and crypt_safe_free were added to the API in libcryptsetup 2.3, which is also when CRYPT_BITLK was defined.
+// Older versions get the same locked and wiped memory from calloc and mlock, with the size kept ahead of the block.
+static void *safe_alloc(size_t size)
+{
+#ifdef CRYPT_BITLK
+	return crypt_safe_alloc(size);
+#else
+	size_t *block = calloc(1, sizeof(size_t) + size);
+
+	if (!block)
+		return NULL;
+	*block = size;
+	mlock(block, sizeof(size_t) + size);
+	return block + 1;
+#endif
+}
+
+static void safe_free(void *memory)
+{
+#ifdef CRYPT_BITLK
+	crypt_safe_free(memory);
+#else
+	size_t *block, total, index;
+	volatile unsigned char *bytes;
+
+	if (!memory)
+		return;
+	block = (size_t *)memory - 1;
+	total = sizeof(size_t) + *block;
+	bytes = (volatile unsigned char *)block;
+	for (index = 0; index < total; index++)
+		bytes[index] = 0;
+	munlock(block, total);
+	free(block);
+#endif
+}
+*/
+import "C"
+import (
+	"reflect"
+	"runtime"
+	"unsafe"
+)
+
+// safeAlloc allocates 'size' zeroed bytes with crypt_safe_alloc, which are locked in memory,
+// so they are neither written to swap nor included in core dumps.
+// Returns nil if the memory cannot be allocated. The memory must be released with safeFree, which wipes it.
+func safeAlloc(size int) unsafe.Pointer {
+	return C.safe_alloc(C.size_t(size))
+}
+
+// safeCString copies 's' to a NUL-terminated C string allocated by crypt_safe_alloc,
+// which is locked in memory, so it is neither written to swap nor included in core dumps.
+// Like C.CString, it panics if the memory cannot be allocated.
+// The string must be released with safeFree, which wipes it.
+func safeCString(s string) *C.char {
+	size := len(s) + 1
+	memory := safeAlloc(size)
+	if memory == nil {
+		panic("cryptsetup: crypt_safe_alloc failed")
+	}
+
+	buffer := (*[1 << 30]byte)(memory)[:size:size]
+	copy(buffer, s)
+	buffer[len(s)] = 0
+
+	return (*C.char)(memory)
+}
+
+// safeFree wipes and releases memory allocated by safeAlloc, such as strings returned by safeCString.
+func safeFree(memory *C.char) {
+	C.safe_free(unsafe.Pointer(memory))
+}
+
+// emptySecret is the NUL-terminated empty string passed to libcryptsetup for empty secrets.
+var emptySecret [1]byte
+
+// bytesPointer returns a pointer to the contents of 'buffer', to hand a passphrase or a key held by Go to libcryptsetup
+// for the duration of a call, without copying it to C memory. The contents must not hold any Go pointer.
+// An empty buffer is passed as an empty C string, as libcryptsetup rejects NULL passphrases.
+func bytesPointer(buffer []byte) *C.char {
+	if len(buffer) == 0 {
+		return (*C.char)(unsafe.Pointer(&emptySecret[0]))
+	}
+	return (*C.char)(unsafe.Pointer(&buffer[0]))
+}
+
+// stringBytes returns the contents of 's' without copying them, so the functions taking secrets as strings can hand them
+// to the byte slice paths. The result must neither be modified nor wiped, as Go strings are immutable.
+func stringBytes(s string) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+
+	var buffer []byte
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&buffer))
+	header.Data = (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+	header.Len = len(s)
+	header.Cap = len(s)
+	return buffer
+}
+
+// WipeBytes overwrites 'buffer' with zeroes, so secrets such as passphrases and volume keys
+// returned by this package do not linger in memory once they are no longer needed.
+func WipeBytes(buffer []byte) {
+	for index := range buffer {
+		buffer[index] = 0
+	}
+	runtime.KeepAlive(buffer)
+}
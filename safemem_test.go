@@ -0,0 +1,25 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_WipeBytes(test *testing.T) {
+	buffer := []byte("testPassphrase")
+	WipeBytes(buffer)
+
+	if !bytes.Equal(buffer, make([]byte, len("testPassphrase"))) {
+		test.Errorf("Buffer should have been wiped, but was: %v", buffer)
+	}
+}
+
+func Test_safeCString_safeFree(test *testing.T) {
+	for _, s := range []string{"", "testPassphrase"} {
+		cString := safeCString(s)
+		if cString == nil {
+			test.Fatal("safeCString should not have returned nil.")
+		}
+		safeFree(cString)
+	}
+}
@@ -0,0 +1,148 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import "unsafe"
+
+// The functions in this file take passphrases and volume keys as byte slices, which are handed to libcryptsetup in place,
+// so callers can wipe them with WipeBytes once done. Their string counterparts hand the contents of their strings
+// to the same paths without copying them, but Go strings cannot be wiped.
+
+// CheckPassphraseBytes is like CheckPassphrase, but takes the passphrase as a byte slice handed to libcryptsetup without copying it.
+// Returns the number of the keyslot that was unlocked on success, or an error otherwise.
+// C equivalent: crypt_activate_by_passphrase, with a NULL device name
+func (device *Device) CheckPassphraseBytes(keyslot int, passphrase []byte) (int, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
+	}
+
+	err := C.crypt_activate_by_passphrase(device.cryptDevice, nil, C.int(keyslot), bytesPointer(passphrase), C.size_t(len(passphrase)), 0)
+	if err < 0 {
+		return 0, device.newError("crypt_activate_by_passphrase", int(err), "check passphrase", keyslotDetail(keyslot))
+	}
+
+	return int(err), nil
+}
+
+// ActivateByPassphraseBytes is like ActivateByPassphrase, but takes the passphrase as a byte slice handed to libcryptsetup without copying it.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_activate_by_passphrase
+func (device *Device) ActivateByPassphraseBytes(deviceName string, keyslot int, passphrase []byte, flags int) error {
+	_, err := device.activateByPassphraseBytes(deviceName, keyslot, passphrase, flags)
+	return err
+}
+
+// activateByPassphraseBytes is like ActivateByPassphraseBytes, but also returns the number of the keyslot the passphrase unlocked.
+func (device *Device) activateByPassphraseBytes(deviceName string, keyslot int, passphrase []byte, flags int) (int, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
+	}
+
+	var cryptDeviceName *C.char = nil
+	if len(deviceName) > 0 {
+		cryptDeviceName = C.CString(deviceName)
+		defer C.free(unsafe.Pointer(cryptDeviceName))
+	}
+
+	defer device.trackLoopDevices()()
+	err := C.crypt_activate_by_passphrase(device.cryptDevice, cryptDeviceName, C.int(keyslot), bytesPointer(passphrase), C.size_t(len(passphrase)), C.uint32_t(flags))
+	if err < 0 {
+		return 0, device.newError("crypt_activate_by_passphrase", int(err), activationOperation(deviceName), keyslotDetail(keyslot))
+	}
+
+	device.emitActivated(deviceName, int(err))
+	return int(err), nil
+}
+
+// ActivateByVolumeKeyBytes is like ActivateByVolumeKey, but takes the volume key as a byte slice handed to libcryptsetup without copying it.
+// An empty 'volumeKey' behaves like an empty key given to ActivateByVolumeKey.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_activate_by_volume_key
+func (device *Device) ActivateByVolumeKeyBytes(deviceName string, volumeKey []byte, flags int) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	if len(volumeKey) == 0 {
+		return device.ActivateByVolumeKey(deviceName, "", 0, flags)
+	}
+
+	var cryptDeviceName *C.char = nil
+	if len(deviceName) > 0 {
+		cryptDeviceName = C.CString(deviceName)
+		defer C.free(unsafe.Pointer(cryptDeviceName))
+	}
+
+	defer device.trackLoopDevices()()
+	err := C.crypt_activate_by_volume_key(device.cryptDevice, cryptDeviceName, bytesPointer(volumeKey), C.size_t(len(volumeKey)), C.uint32_t(flags))
+	if err < 0 {
+		return device.newError("crypt_activate_by_volume_key", int(err), activationOperation(deviceName))
+	}
+
+	device.emitActivated(deviceName, -1)
+	return nil
+}
+
+// KeyslotAddByVolumeKeyBytes is like KeyslotAddByVolumeKey, but takes the volume key and the passphrase as byte slices
+// handed to libcryptsetup without copying them. An empty 'volumeKey' uses the volume key generated by Format.
+// Returns the number of the added keyslot on success, or an error otherwise.
+// C equivalent: crypt_keyslot_add_by_volume_key
+func (device *Device) KeyslotAddByVolumeKeyBytes(keyslot int, volumeKey []byte, passphrase []byte) (int, error) {
+	if err := device.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	unlock, lockErr := device.lockKeyslots()
+	if lockErr != nil {
+		return 0, lockErr
+	}
+	defer unlock()
+
+	return device.keyslotAddByVolumeKeyBytes(keyslot, volumeKey, passphrase)
+}
+
+// keyslotAddByVolumeKeyBytes adds a keyslot like KeyslotAddByVolumeKeyBytes. The keyslot lock must be held.
+func (device *Device) keyslotAddByVolumeKeyBytes(keyslot int, volumeKey []byte, passphrase []byte) (int, error) {
+	var cVolumeKey *C.char = nil
+	if len(volumeKey) > 0 {
+		cVolumeKey = bytesPointer(volumeKey)
+	}
+
+	err := C.crypt_keyslot_add_by_volume_key(device.cryptDevice, C.int(keyslot), cVolumeKey, C.size_t(len(volumeKey)), bytesPointer(passphrase), C.size_t(len(passphrase)))
+	if err < 0 {
+		return 0, device.newError("crypt_keyslot_add_by_volume_key", int(err), "add keyslot", keyslotDetail(keyslot))
+	}
+
+	emitEvent(&KeyslotAdded{Device: device.DevicePath(), Keyslot: int(err)})
+	return int(err), nil
+}
+
+// KeyslotAddByPassphraseBytes is like KeyslotAddByPassphrase, but takes the passphrases as byte slices
+// handed to libcryptsetup without copying them.
+// Returns the number of the added keyslot on success, or an error otherwise.
+// C equivalent: crypt_keyslot_add_by_passphrase
+func (device *Device) KeyslotAddByPassphraseBytes(keyslot int, currentPassphrase []byte, newPassphrase []byte) (int, error) {
+	if err := device.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	unlock, lockErr := device.lockKeyslots()
+	if lockErr != nil {
+		return 0, lockErr
+	}
+	defer unlock()
+
+	err := C.crypt_keyslot_add_by_passphrase(
+		device.cryptDevice, C.int(keyslot),
+		bytesPointer(currentPassphrase), C.size_t(len(currentPassphrase)),
+		bytesPointer(newPassphrase), C.size_t(len(newPassphrase)),
+	)
+	if err < 0 {
+		return 0, device.newError("crypt_keyslot_add_by_passphrase", int(err), "add keyslot", keyslotDetail(keyslot))
+	}
+
+	emitEvent(&KeyslotAdded{Device: device.DevicePath(), Keyslot: int(err)})
+	return int(err), nil
+}
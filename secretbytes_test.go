@@ -0,0 +1,42 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_Device_Bytes_Variants(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	volumeKey := []byte("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	passphrase := []byte("testPassphrase")
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKey: string(volumeKey), VolumeKeySize: len(volumeKey)})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	keyslot, err := device.KeyslotAddByVolumeKeyBytes(CRYPT_ANY_SLOT, volumeKey, passphrase)
+	testWrapper.AssertNoError(err)
+
+	unlocked, err := device.CheckPassphraseBytes(CRYPT_ANY_SLOT, passphrase)
+	testWrapper.AssertNoError(err)
+	if unlocked != keyslot {
+		test.Errorf("Passphrase should have unlocked keyslot %d, but unlocked %d.", keyslot, unlocked)
+	}
+
+	err = device.ActivateByPassphraseBytes("", keyslot, passphrase, 0)
+	testWrapper.AssertNoError(err)
+
+	err = device.ActivateByPassphraseBytes("", keyslot, []byte{}, 0)
+	testWrapper.AssertErrorCodeEquals(err, -1)
+
+	err = device.ActivateByVolumeKeyBytes("", volumeKey, 0)
+	testWrapper.AssertNoError(err)
+
+	if !bytes.Equal(passphrase, []byte("testPassphrase")) {
+		test.Errorf("The caller's passphrase should not have been modified, but was: %q", passphrase)
+	}
+}
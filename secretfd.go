@@ -0,0 +1,104 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// secretFdMaxSize is the largest secret read from a file descriptor, like the default key file size limit of libcryptsetup.
+const secretFdMaxSize = 8192 * 1024
+
+// ReadSecretFd reads the whole secret held by the file descriptor 'fd', such as a memfd or a pipe set up by a parent process
+// or a privileged broker, up to 8 MiB. The secret is returned as is, without trimming a trailing newline, like key files,
+// in a buffer to be wiped with WipeBytes once done: it is meant for the functions taking secrets as byte slices,
+// such as ActivateByPassphraseBytes.
+// Seekable descriptors, such as memfds, are read from their start without moving their offset, so they can be read again;
+// pipes are read until their end, and can only be read once.
+// The caller keeps ownership of 'fd', which is not closed.
+// Returns the secret on success, or an error otherwise.
+func ReadSecretFd(fd uintptr) ([]byte, error) {
+	file, err := duplicateFd(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if _, err := file.Seek(0, io.SeekCurrent); err == nil {
+		reader = io.NewSectionReader(file, 0, secretFdMaxSize+1)
+	}
+
+	return readSecret(reader, secretFdMaxSize)
+}
+
+// ReadCredential reads the secret 'name' passed by systemd, through LoadCredential= or SetCredential=,
+// from the directory named by $CREDENTIALS_DIRECTORY. The secret is returned as by ReadSecretFd.
+// Returns the secret on success, or an error otherwise.
+func ReadCredential(name string) ([]byte, error) {
+	directory := os.Getenv("CREDENTIALS_DIRECTORY")
+	if directory == "" {
+		return nil, fmt.Errorf("cannot read credential '%s': $CREDENTIALS_DIRECTORY is not set", name)
+	}
+	if name == "" || filepath.Base(name) != name {
+		return nil, fmt.Errorf("invalid credential name '%s'", name)
+	}
+
+	file, err := os.Open(filepath.Join(directory, name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readSecret(file, secretFdMaxSize)
+}
+
+// readSecret reads 'reader' until its end, wiping the previous buffers whenever the secret outgrows them.
+// Returns the secret on success, or an error if reading failed or the secret is larger than 'maxSize'.
+func readSecret(reader io.Reader, maxSize int) ([]byte, error) {
+	secret := make([]byte, 0, 512)
+
+	for {
+		if len(secret) == cap(secret) {
+			grown := make([]byte, len(secret), 2*cap(secret))
+			copy(grown, secret)
+			WipeBytes(secret)
+			secret = grown
+		}
+
+		count, err := reader.Read(secret[len(secret):cap(secret)])
+		secret = secret[:len(secret)+count]
+		if len(secret) > maxSize {
+			WipeBytes(secret)
+			return nil, fmt.Errorf("secret is larger than %d bytes", maxSize)
+		}
+		if err == io.EOF {
+			return secret, nil
+		}
+		if err != nil {
+			WipeBytes(secret)
+			return nil, err
+		}
+	}
+}
+
+// PassphraseFd is a Credential that activates a device using a passphrase read by ReadSecretFd from a file descriptor,
+// such as a memfd, handed to libcryptsetup without copying it and wiped once used.
+// Use CRYPT_ANY_SLOT as the Keyslot to try all keyslots.
+// Notice a pipe can only be read once: use a memfd for credentials used several times.
+type PassphraseFd struct {
+	Keyslot int
+	Fd      uintptr
+}
+
+// Activate activates a device using the passphrase read from the file descriptor.
+func (passphraseFd PassphraseFd) Activate(device *Device, deviceName string, flags int) error {
+	passphrase, err := ReadSecretFd(passphraseFd.Fd)
+	if err != nil {
+		return err
+	}
+	defer WipeBytes(passphrase)
+
+	return device.ActivateByPassphraseBytes(deviceName, passphraseFd.Keyslot, passphrase, flags)
+}
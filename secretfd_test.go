@@ -0,0 +1,112 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ReadSecretFd_Reads_Memfd_Repeatedly(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	file, _, err := newMemFile("secret")
+	testWrapper.AssertNoError(err)
+	defer file.Close()
+
+	secret := bytes.Repeat([]byte("testPassphrase\n"), 100)
+	_, err = file.Write(secret)
+	testWrapper.AssertNoError(err)
+
+	for i := 0; i < 2; i++ {
+		read, err := ReadSecretFd(file.Fd())
+		testWrapper.AssertNoError(err)
+		if !bytes.Equal(read, secret) {
+			test.Errorf("Unexpected secret of %d bytes", len(read))
+		}
+	}
+}
+
+func Test_ReadSecretFd_Reads_Pipe(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	reader, writer, err := os.Pipe()
+	testWrapper.AssertNoError(err)
+	defer reader.Close()
+
+	_, err = writer.Write([]byte("testPassphrase"))
+	testWrapper.AssertNoError(err)
+	testWrapper.AssertNoError(writer.Close())
+
+	secret, err := ReadSecretFd(reader.Fd())
+	testWrapper.AssertNoError(err)
+	if string(secret) != "testPassphrase" {
+		test.Errorf("Unexpected secret: %q", secret)
+	}
+}
+
+func Test_ReadSecretFd_Fails_For_Invalid_Input(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := ReadSecretFd(^uintptr(0))
+	testWrapper.AssertError(err)
+
+	_, err = readSecret(bytes.NewReader(make([]byte, 1025)), 1024)
+	testWrapper.AssertError(err)
+}
+
+func Test_ReadCredential(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	directory, err := ioutil.TempDir("", "credentials")
+	testWrapper.AssertNoError(err)
+	defer os.RemoveAll(directory)
+	testWrapper.AssertNoError(ioutil.WriteFile(filepath.Join(directory, "passphrase"), []byte("testPassphrase"), 0400))
+
+	previousDirectory, wasSet := os.LookupEnv("CREDENTIALS_DIRECTORY")
+	os.Setenv("CREDENTIALS_DIRECTORY", directory)
+	defer func() {
+		if wasSet {
+			os.Setenv("CREDENTIALS_DIRECTORY", previousDirectory)
+		} else {
+			os.Unsetenv("CREDENTIALS_DIRECTORY")
+		}
+	}()
+
+	secret, err := ReadCredential("passphrase")
+	testWrapper.AssertNoError(err)
+	if string(secret) != "testPassphrase" {
+		test.Errorf("Unexpected secret: %q", secret)
+	}
+
+	_, err = ReadCredential("../passphrase")
+	testWrapper.AssertError(err)
+	_, err = ReadCredential("missing")
+	testWrapper.AssertError(err)
+}
+
+func Test_PassphraseFd_Unlocks_Keyslot(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	device.restorePBKDFType(&PbkdfType{Type: CRYPT_KDF_PBKDF2, Hash: "sha256", Iterations: 1000, Flags: CRYPT_PBKDF_NO_BENCHMARK})
+	testWrapper.AssertNoError(device.KeyslotAddByVolumeKey(0, "", "testPassphrase"))
+
+	file, _, err := newMemFile("passphrase")
+	testWrapper.AssertNoError(err)
+	defer file.Close()
+	_, err = file.Write([]byte("testPassphrase"))
+	testWrapper.AssertNoError(err)
+
+	_, err = device.MeasureUnlockTime(0, PassphraseFd{Fd: file.Fd()})
+	testWrapper.AssertNoError(err)
+
+	testWrapper.AssertNoError(file.Truncate(0))
+	_, err = device.MeasureUnlockTime(0, PassphraseFd{Fd: file.Fd()})
+	testWrapper.AssertError(err)
+}
@@ -0,0 +1,179 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Signature is an on-disk signature of a filesystem, RAID member, partition table or crypto container,
+// identified by a magic string at a known offset, as reported by blkid or wipefs.
+type Signature struct {
+	// Type is the blkid name of the signature's owner, such as "ext4", "linux_raid_member" or "gpt".
+	Type string
+	// Usage is the signature's category: "filesystem", "raid", "partition_table", "crypto" or "other".
+	Usage string
+	// Offset is the position of the magic string on the device, in bytes.
+	Offset int64
+	// Magic is the magic string found at Offset.
+	Magic []byte
+}
+
+// signatureMagic describes a magic string identifying a signature.
+// A negative offset is relative to the end of the device.
+type signatureMagic struct {
+	kind   string
+	usage  string
+	offset int64
+	magic  []byte
+}
+
+// knownSignatures are the signatures looked for by ProbeSignatures, modeled on the blkid probes of util-linux.
+var knownSignatures = []signatureMagic{
+	{kind: "crypto_LUKS", usage: "crypto", offset: 0, magic: []byte("LUKS\xba\xbe")},
+	{kind: "BitLocker", usage: "crypto", offset: 3, magic: []byte("-FVE-FS-")},
+	{kind: "ntfs", usage: "filesystem", offset: 3, magic: []byte("NTFS    ")},
+	{kind: "vfat", usage: "filesystem", offset: 0x36, magic: []byte("FAT12   ")},
+	{kind: "vfat", usage: "filesystem", offset: 0x36, magic: []byte("FAT16   ")},
+	{kind: "vfat", usage: "filesystem", offset: 0x52, magic: []byte("FAT32   ")},
+	{kind: "xfs", usage: "filesystem", offset: 0, magic: []byte("XFSB")},
+	// ext2, ext3 and ext4 share their superblock magic.
+	{kind: "ext4", usage: "filesystem", offset: 0x438, magic: []byte{0x53, 0xef}},
+	{kind: "btrfs", usage: "filesystem", offset: 0x10040, magic: []byte("_BHRfS_M")},
+	{kind: "iso9660", usage: "filesystem", offset: 0x8001, magic: []byte("CD001")},
+	{kind: "swap", usage: "other", offset: 4096 - 10, magic: []byte("SWAPSPACE2")},
+	{kind: "swap", usage: "other", offset: 4096 - 10, magic: []byte("SWAP-SPACE")},
+	{kind: "LVM2_member", usage: "raid", offset: 512, magic: []byte("LABELONE")},
+	{kind: "linux_raid_member", usage: "raid", offset: 0, magic: []byte{0xfc, 0x4e, 0x2b, 0xa9}},
+	{kind: "linux_raid_member", usage: "raid", offset: 4096, magic: []byte{0xfc, 0x4e, 0x2b, 0xa9}},
+	{kind: "gpt", usage: "partition_table", offset: 512, magic: []byte("EFI PART")},
+	{kind: "gpt", usage: "partition_table", offset: -512, magic: []byte("EFI PART")},
+}
+
+// dosSignature is the boot signature ending MBR partition tables, and FAT and NTFS boot sectors.
+var dosSignature = signatureMagic{kind: "dos", usage: "partition_table", offset: 510, magic: []byte{0x55, 0xaa}}
+
+// luks2SecondaryHeaderOffsets are the offsets at which a LUKS2 secondary header may start, following libcryptsetup.
+var luks2SecondaryHeaderOffsets = []int64{0x4000, 0x8000, 0x10000, 0x20000, 0x40000, 0x80000, 0x100000, 0x200000, 0x400000}
+
+// ProbeSignatures reports the filesystem, RAID, partition table and crypto signatures found on the device holding the header,
+// so callers can refuse to Format a device still in use, or wipe them first with WipeSignatures or GenericParams.WipeSignatures.
+// Only the well-known magic strings probed by blkid are looked for, so an empty result doesn't prove the device holds no data.
+// Returns the signatures on success, or an error otherwise.
+func (device *Device) ProbeSignatures() ([]Signature, error) {
+	if err := device.checkUsable(); err != nil {
+		return nil, err
+	}
+
+	return probeSignatures(device.MetadataDevicePath())
+}
+
+// WipeSignatures zeroes the magic strings of the signatures reported by ProbeSignatures, like wipefs does,
+// so the device is no longer recognized as holding a filesystem, a RAID member, a partition table or a crypto container.
+// Only the magic strings are overwritten: the data they identified is left in place, but becomes unreachable through usual tools.
+// Returns the wiped signatures on success, or an error otherwise.
+func (device *Device) WipeSignatures() ([]Signature, error) {
+	if err := device.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	path := device.MetadataDevicePath()
+	signatures, err := probeSignatures(path)
+	if err != nil || len(signatures) == 0 {
+		return signatures, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	for _, signature := range signatures {
+		if _, err := file.WriteAt(make([]byte, len(signature.Magic)), signature.Offset); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		return nil, err
+	}
+
+	return signatures, nil
+}
+
+// probeSignatures reports the signatures found on the device or file at 'path'.
+func probeSignatures(path string) ([]Signature, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	magics := append([]signatureMagic{}, knownSignatures...)
+	for _, offset := range luks2SecondaryHeaderOffsets {
+		magics = append(magics, signatureMagic{kind: "crypto_LUKS", usage: "crypto", offset: offset, magic: []byte("SKUL\xba\xbe")})
+	}
+	if size >= 0x20000 {
+		// MD RAID 0.90 superblocks are stored in the last 64KiB aligned block, and 1.0 ones 8KiB from the end.
+		magics = append(magics,
+			signatureMagic{kind: "linux_raid_member", usage: "raid", offset: size&^0xffff - 0x10000, magic: []byte{0xfc, 0x4e, 0x2b, 0xa9}},
+			signatureMagic{kind: "linux_raid_member", usage: "raid", offset: (size - 0x2000) &^ 0xfff, magic: []byte{0xfc, 0x4e, 0x2b, 0xa9}},
+		)
+	}
+
+	signatures := []Signature{}
+	bootSector := false
+	for _, magic := range magics {
+		offset := magic.offset
+		if offset < 0 {
+			offset += size
+		}
+
+		signature, err := probeSignature(file, size, offset, magic)
+		if err != nil {
+			return nil, err
+		}
+		if signature == nil {
+			continue
+		}
+		if offset < 512 {
+			bootSector = true
+		}
+		signatures = append(signatures, *signature)
+	}
+
+	if !bootSector {
+		signature, err := probeSignature(file, size, dosSignature.offset, dosSignature)
+		if err != nil {
+			return nil, err
+		}
+		if signature != nil {
+			signatures = append(signatures, *signature)
+		}
+	}
+
+	return signatures, nil
+}
+
+// probeSignature returns the signature described by 'magic' if it is found at 'offset' in 'file', or nil otherwise.
+func probeSignature(file *os.File, size int64, offset int64, magic signatureMagic) (*Signature, error) {
+	if offset < 0 || offset+int64(len(magic.magic)) > size {
+		return nil, nil
+	}
+
+	buffer := make([]byte, len(magic.magic))
+	if _, err := file.ReadAt(buffer, offset); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(buffer, magic.magic) {
+		return nil, nil
+	}
+
+	return &Signature{Type: magic.kind, Usage: magic.usage, Offset: offset, Magic: buffer}, nil
+}
@@ -0,0 +1,114 @@
+package cryptsetup
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_probeSignatures(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	file, err := ioutil.TempFile("", "signatures")
+	testWrapper.AssertNoError(err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	err = file.Truncate(1024 * 1024)
+	testWrapper.AssertNoError(err)
+
+	_, err = file.WriteAt([]byte{0x53, 0xef}, 0x438)
+	testWrapper.AssertNoError(err)
+	_, err = file.WriteAt([]byte("EFI PART"), 512)
+	testWrapper.AssertNoError(err)
+
+	signatures, err := probeSignatures(file.Name())
+	testWrapper.AssertNoError(err)
+
+	if len(signatures) != 2 || signatures[0].Type != "ext4" || signatures[0].Usage != "filesystem" || signatures[0].Offset != 0x438 ||
+		signatures[1].Type != "gpt" || signatures[1].Offset != 512 {
+		test.Errorf("Unexpected signatures: %+v", signatures)
+	}
+}
+
+func Test_probeSignatures_Empty_Device(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	file, err := ioutil.TempFile("", "signatures")
+	testWrapper.AssertNoError(err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	err = file.Truncate(1024 * 1024)
+	testWrapper.AssertNoError(err)
+
+	signatures, err := probeSignatures(file.Name())
+	testWrapper.AssertNoError(err)
+
+	if len(signatures) != 0 {
+		test.Errorf("An empty device should have no signatures, but had: %+v", signatures)
+	}
+}
+
+func Test_Device_ProbeSignatures_LUKS1(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	signatures, err := device.ProbeSignatures()
+	testWrapper.AssertNoError(err)
+
+	if len(signatures) == 0 || signatures[0].Type != "crypto_LUKS" || signatures[0].Offset != 0 {
+		test.Errorf("Unexpected signatures: %+v", signatures)
+	}
+}
+
+func Test_Device_Format_WipeSignatures(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	for _, wipeSignatures := range []bool{false, true} {
+		file, err := os.OpenFile(DevicePath, os.O_WRONLY, 0)
+		testWrapper.AssertNoError(err)
+		size, err := file.Seek(0, io.SeekEnd)
+		testWrapper.AssertNoError(err)
+		_, err = file.WriteAt([]byte("EFI PART"), size-512)
+		testWrapper.AssertNoError(err)
+		file.Close()
+
+		device, err := Init(DevicePath)
+		testWrapper.AssertNoError(err)
+		err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8, WipeSignatures: wipeSignatures})
+		testWrapper.AssertNoError(err)
+
+		signatures, err := device.ProbeSignatures()
+		testWrapper.AssertNoError(err)
+		device.Free()
+
+		found := false
+		for _, signature := range signatures {
+			found = found || signature.Type == "gpt"
+		}
+		if found == wipeSignatures {
+			test.Errorf("With WipeSignatures set to %v, the GPT signature was found: %v", wipeSignatures, found)
+		}
+	}
+}
+
+func Test_Device_WipeSignatures_Fails_If_Device_Is_Read_Only(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := InitReadOnly(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	_, err = device.WipeSignatures()
+	if err != ErrReadOnly {
+		test.Errorf("WipeSignatures should have failed with ErrReadOnly, but got: %v", err)
+	}
+}
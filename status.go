@@ -0,0 +1,84 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import "unsafe"
+
+// ActiveDevice is the status of a mapping, as shown by `cryptsetup status`.
+// Its JSON encoding has stable field names, so inventory tooling can consume it.
+type ActiveDevice struct {
+	// Name is the name of the mapping.
+	Name string `json:"name"`
+	// Status is "active", "busy" if the mapping is in use, "inactive" or "invalid".
+	Status string `json:"status"`
+	// Type is the device type, such as "LUKS2".
+	Type Type `json:"type,omitempty"`
+	// Cipher is the cipher specification, such as "aes-xts-plain64".
+	Cipher string `json:"cipher,omitempty"`
+	// KeySize is the size of the volume key, in bytes.
+	KeySize int `json:"key_size,omitempty"`
+	// Device is the path of the data device.
+	Device string `json:"device,omitempty"`
+	// Header is the path of the device holding the header, if it is detached from the data device.
+	Header string `json:"header,omitempty"`
+	// Offset is the offset of the encrypted data on the data device, in 512 byte sectors.
+	Offset uint64 `json:"offset"`
+	// IVOffset is the IV offset, in 512 byte sectors.
+	IVOffset uint64 `json:"iv_offset"`
+	// Size is the size of the mapping, in 512 byte sectors.
+	Size uint64 `json:"size"`
+	// Flags are the CRYPT_ACTIVATE_* flags the mapping is active with.
+	Flags uint32 `json:"flags"`
+}
+
+// statusNames maps the crypt_status_info values to the names used by ActiveDevice.
+var statusNames = map[C.crypt_status_info]string{
+	C.CRYPT_INVALID:  "invalid",
+	C.CRYPT_INACTIVE: "inactive",
+	C.CRYPT_ACTIVE:   "active",
+	C.CRYPT_BUSY:     "busy",
+}
+
+// Status returns the status of the mapping named 'name'.
+// Inactive mappings are reported with only their Name and Status set.
+// Returns the status on success, or an error otherwise.
+// C equivalent: crypt_status, followed by crypt_get_active_device
+func (device *Device) Status(name string) (ActiveDevice, error) {
+	if err := device.checkUsable(); err != nil {
+		return ActiveDevice{}, err
+	}
+
+	active := ActiveDevice{Name: name}
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	status := C.crypt_status(device.cryptDevice, cName)
+	active.Status = statusNames[status]
+	if status != C.CRYPT_ACTIVE && status != C.CRYPT_BUSY {
+		return active, nil
+	}
+
+	var cActiveDevice C.struct_crypt_active_device
+	if err := C.crypt_get_active_device(device.cryptDevice, cName, &cActiveDevice); err < 0 {
+		return active, device.newError("crypt_get_active_device", int(err), "get status of "+name)
+	}
+
+	active.Type = device.Type()
+	if cipher := C.GoString(C.crypt_get_cipher(device.cryptDevice)); cipher != "" {
+		active.Cipher = cipher + "-" + C.GoString(C.crypt_get_cipher_mode(device.cryptDevice))
+	}
+	active.KeySize = device.VolumeKeySize()
+	active.Device = device.DevicePath()
+	if detached, err := device.HeaderIsDetached(); err == nil && detached {
+		active.Header = device.MetadataDevicePath()
+	}
+	active.Offset = uint64(cActiveDevice.offset)
+	active.IVOffset = uint64(cActiveDevice.iv_offset)
+	active.Size = uint64(cActiveDevice.size)
+	active.Flags = uint32(cActiveDevice.flags)
+
+	return active, nil
+}
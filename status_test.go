@@ -0,0 +1,36 @@
+package cryptsetup
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_Device_Status_Of_Inactive_Mapping(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	active, err := device.Status(DeviceName)
+	testWrapper.AssertNoError(err)
+
+	// without device-mapper, libcryptsetup cannot tell whether the mapping exists
+	if active.Status != "inactive" && active.Status != "invalid" {
+		test.Errorf("Expected the mapping to be inactive, but got: %s", active.Status)
+	}
+
+	encoded, err := json.Marshal(active)
+	testWrapper.AssertNoError(err)
+
+	var fields map[string]interface{}
+	testWrapper.AssertNoError(json.Unmarshal(encoded, &fields))
+	for _, field := range []string{"name", "status", "offset", "iv_offset", "size", "flags"} {
+		if _, found := fields[field]; !found {
+			test.Errorf("Field '%s' is missing from: %s", field, encoded)
+		}
+	}
+	if _, found := fields["cipher"]; found {
+		test.Errorf("Inactive mappings should have no cipher: %s", encoded)
+	}
+}
@@ -0,0 +1,129 @@
+package cryptsetup
+
+/*
+#cgo pkg-config: libcryptsetup
+#include <errno.h>
+#include <libcryptsetup.h>
+#include <stdlib.h>
+
+// crypt_resume_by_volume_key was added in libcryptsetup 2.3, which is also when CRYPT_BITLK was defined.
+static int resume_by_volume_key(struct crypt_device *cd, const char *name, const char *volume_key, size_t volume_key_size)
+{
+#ifdef CRYPT_BITLK
+	return crypt_resume_by_volume_key(cd, name, volume_key, volume_key_size);
+#else
+	return -ENOTSUP;
+#endif
+}
+*/
+import "C"
+import "unsafe"
+
+// Suspend suspends the active mapping named 'deviceName', like `cryptsetup luksSuspend`: I/O to the mapping is blocked,
+// and its volume key is wiped from kernel memory until the mapping is resumed with ResumeByPassphrase or ResumeByVolumeKey.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_suspend
+func (device *Device) Suspend(deviceName string) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	cDeviceName := C.CString(deviceName)
+	defer C.free(unsafe.Pointer(cDeviceName))
+
+	if err := C.crypt_suspend(device.cryptDevice, cDeviceName); err < 0 {
+		return device.newError("crypt_suspend", int(err), "suspend "+deviceName)
+	}
+
+	return nil
+}
+
+// ResumeByPassphrase resumes the mapping named 'deviceName' suspended by Suspend, unlocking its volume key with 'passphrase'.
+// Use CRYPT_ANY_SLOT as the keyslot to try all keyslots.
+// Returns the number of the keyslot that was unlocked on success, or an error otherwise.
+// C equivalent: crypt_resume_by_passphrase
+func (device *Device) ResumeByPassphrase(deviceName string, keyslot int, passphrase string) (int, error) {
+	return device.ResumeByPassphraseBytes(deviceName, keyslot, stringBytes(passphrase))
+}
+
+// ResumeByPassphraseBytes is like ResumeByPassphrase, but takes the passphrase as a byte slice handed to libcryptsetup without copying it.
+// Returns the number of the keyslot that was unlocked on success, or an error otherwise.
+// C equivalent: crypt_resume_by_passphrase
+func (device *Device) ResumeByPassphraseBytes(deviceName string, keyslot int, passphrase []byte) (int, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, err
+	}
+
+	cDeviceName := C.CString(deviceName)
+	defer C.free(unsafe.Pointer(cDeviceName))
+
+	err := C.crypt_resume_by_passphrase(device.cryptDevice, cDeviceName, C.int(keyslot), bytesPointer(passphrase), C.size_t(len(passphrase)))
+	if err < 0 {
+		return 0, device.newError("crypt_resume_by_passphrase", int(err), "resume "+deviceName, keyslotDetail(keyslot))
+	}
+
+	return int(err), nil
+}
+
+// ResumeByVolumeKey resumes the mapping named 'deviceName' suspended by Suspend, using its volume key.
+// It fails with -ENOTSUP if libcryptsetup is older than 2.3.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_resume_by_volume_key
+func (device *Device) ResumeByVolumeKey(deviceName string, volumeKey string) error {
+	return device.ResumeByVolumeKeyBytes(deviceName, stringBytes(volumeKey))
+}
+
+// ResumeByVolumeKeyBytes is like ResumeByVolumeKey, but takes the volume key as a byte slice handed to libcryptsetup without copying it.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_resume_by_volume_key
+func (device *Device) ResumeByVolumeKeyBytes(deviceName string, volumeKey []byte) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	cDeviceName := C.CString(deviceName)
+	defer C.free(unsafe.Pointer(cDeviceName))
+
+	if err := C.resume_by_volume_key(device.cryptDevice, cDeviceName, bytesPointer(volumeKey), C.size_t(len(volumeKey))); err < 0 {
+		return device.newError("crypt_resume_by_volume_key", int(err), "resume "+deviceName)
+	}
+
+	return nil
+}
+
+// FreezeHook is invoked by SuspendWithFreeze around suspending a mapping, so callers can quiesce what uses it.
+type FreezeHook interface {
+	// Freeze is called before the mapping named 'deviceName' is suspended. An error cancels the suspension.
+	Freeze(deviceName string) error
+	// Thaw is called once the mapping named 'deviceName' was resumed, or if suspending it failed.
+	Thaw(deviceName string) error
+}
+
+// SuspendWithFreeze is like Suspend, but calls 'hook' to freeze what uses the mapping first, such as with FSFreezer,
+// which freezes the file system mounted on it like `fsfreeze --freeze` does, so hibernate tooling suspends consistent data.
+// A nil hook uses FSFreezer.
+// The returned function calls the hook's Thaw, and must be called once the mapping was resumed: thawing a file system
+// on a suspended mapping would block. If suspending fails, the hook's Thaw is called before returning.
+// Returns the function thawing the mapping on success, or an error otherwise.
+func (device *Device) SuspendWithFreeze(deviceName string, hook FreezeHook) (func() error, error) {
+	if err := device.checkUsable(); err != nil {
+		return nil, err
+	}
+
+	if hook == nil {
+		hook = FSFreezer{}
+	}
+
+	if err := hook.Freeze(deviceName); err != nil {
+		return nil, err
+	}
+
+	if err := device.Suspend(deviceName); err != nil {
+		hook.Thaw(deviceName)
+		return nil, err
+	}
+
+	return func() error {
+		return hook.Thaw(deviceName)
+	}, nil
+}
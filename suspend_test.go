@@ -0,0 +1,69 @@
+package cryptsetup
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type recordingFreezeHook struct {
+	calls     []string
+	freezeErr error
+}
+
+func (hook *recordingFreezeHook) Freeze(deviceName string) error {
+	hook.calls = append(hook.calls, "freeze "+deviceName)
+	return hook.freezeErr
+}
+
+func (hook *recordingFreezeHook) Thaw(deviceName string) error {
+	hook.calls = append(hook.calls, "thaw "+deviceName)
+	return nil
+}
+
+func Test_Device_Suspend_Fails_If_Device_Is_Not_Active(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	testWrapper.AssertError(device.Suspend(DeviceName))
+	_, err = device.ResumeByPassphrase(DeviceName, CRYPT_ANY_SLOT, "testPassphrase")
+	testWrapper.AssertError(err)
+}
+
+func Test_Device_SuspendWithFreeze_Thaws_If_Suspend_Fails(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	hook := &recordingFreezeHook{}
+	thaw, err := device.SuspendWithFreeze(DeviceName, hook)
+	testWrapper.AssertError(err)
+	if thaw != nil || !reflect.DeepEqual(hook.calls, []string{"freeze " + DeviceName, "thaw " + DeviceName}) {
+		test.Errorf("Unexpected hook calls: %v", hook.calls)
+	}
+
+	frozen := errors.New("cannot freeze")
+	hook = &recordingFreezeHook{freezeErr: frozen}
+	if _, err := device.SuspendWithFreeze(DeviceName, hook); err != frozen {
+		test.Errorf("Expected the error of the hook, got: %v", err)
+	}
+	if !reflect.DeepEqual(hook.calls, []string{"freeze " + DeviceName}) {
+		test.Errorf("Unexpected hook calls: %v", hook.calls)
+	}
+}
+
+func Test_FSFreezer_Fails_If_Mapping_Does_Not_Exist(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	testWrapper.AssertError(FSFreezer{}.Freeze("nonExistingDeviceName"))
+	testWrapper.AssertError(FSFreezer{}.Thaw("nonExistingDeviceName"))
+}
@@ -0,0 +1,192 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// TCrypt is the struct used to load TrueCrypt and VeraCrypt devices.
+// TCRYPT devices cannot be formatted: their header is only ever loaded, using LoadTCrypt.
+type TCrypt struct {
+	Passphrase string
+	// Keyfiles are the paths of the keyfiles combined with the passphrase.
+	Keyfiles []string
+	// Hash, Cipher and Mode restrict the header decryption to a single algorithm. If empty, all known ones are tried.
+	Hash   string
+	Cipher string
+	Mode   string
+	// KeySize is the size of the header key, in bytes. If 0, the size required by the cipher is used.
+	KeySize int
+	// Flags are CRYPT_TCRYPT_* flags, such as CRYPT_TCRYPT_VERA_MODES or CRYPT_TCRYPT_HIDDEN_HEADER.
+	Flags uint32
+	// VeraCryptPIM is the VeraCrypt Personal Iteration Multiplier. If 0, the default iteration count is used.
+	VeraCryptPIM uint32
+}
+
+// Name returns the TCRYPT device type name as a string.
+func (tcrypt TCrypt) Name() string {
+	return C.CRYPT_TCRYPT
+}
+
+// Unmanaged is used to specialize TCrypt.
+func (tcrypt TCrypt) Unmanaged() (unsafe.Pointer, func()) {
+	deallocations := make([]func(), 0)
+	deallocate := func() {
+		for index := 0; index < len(deallocations); index++ {
+			deallocations[index]()
+		}
+	}
+
+	cParams := (*C.struct_crypt_params_tcrypt)(C.calloc(1, C.sizeof_struct_crypt_params_tcrypt))
+	deallocations = append(deallocations, func() {
+		C.free(unsafe.Pointer(cParams))
+	})
+
+	if tcrypt.Passphrase != "" {
+		cPassphrase := safeCString(tcrypt.Passphrase)
+		deallocations = append(deallocations, func() {
+			safeFree(cPassphrase)
+		})
+
+		cParams.passphrase = cPassphrase
+		cParams.passphrase_size = C.size_t(len(tcrypt.Passphrase))
+	}
+
+	if len(tcrypt.Keyfiles) > 0 {
+		cKeyfiles := (**C.char)(C.calloc(C.size_t(len(tcrypt.Keyfiles)), C.size_t(unsafe.Sizeof((*C.char)(nil)))))
+		keyfiles := (*[1 << 20]*C.char)(unsafe.Pointer(cKeyfiles))[:len(tcrypt.Keyfiles):len(tcrypt.Keyfiles)]
+		for index, keyfile := range tcrypt.Keyfiles {
+			keyfiles[index] = C.CString(keyfile)
+		}
+		deallocations = append(deallocations, func() {
+			for _, cKeyfile := range keyfiles {
+				C.free(unsafe.Pointer(cKeyfile))
+			}
+			C.free(unsafe.Pointer(cKeyfiles))
+		})
+
+		cParams.keyfiles = cKeyfiles
+		cParams.keyfiles_count = C.uint(len(tcrypt.Keyfiles))
+	}
+
+	for _, field := range []struct {
+		value  string
+		target **C.char
+	}{
+		{tcrypt.Hash, &cParams.hash_name},
+		{tcrypt.Cipher, &cParams.cipher},
+		{tcrypt.Mode, &cParams.mode},
+	} {
+		if field.value != "" {
+			cValue := C.CString(field.value)
+			*field.target = cValue
+			deallocations = append(deallocations, func() {
+				C.free(unsafe.Pointer(cValue))
+			})
+		}
+	}
+
+	cParams.key_size = C.size_t(tcrypt.KeySize)
+	cParams.flags = C.uint32_t(tcrypt.Flags)
+	cParams.veracrypt_pim = C.uint32_t(tcrypt.VeraCryptPIM)
+
+	return unsafe.Pointer(cParams), deallocate
+}
+
+// LoadTCrypt decrypts the TrueCrypt or VeraCrypt header of the device using the passphrase and keyfiles in 'tcrypt'.
+// The device can then be activated by ActivateByVolumeKey, with an empty volume key.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_load, with CRYPT_TCRYPT
+func (device *Device) LoadTCrypt(tcrypt TCrypt) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	cType := C.CString(tcrypt.Name())
+	defer C.free(unsafe.Pointer(cType))
+
+	cParams, freeCParams := tcrypt.Unmanaged()
+	defer freeCParams()
+
+	err := C.crypt_load(device.cryptDevice, cType, cParams)
+	if err < 0 {
+		return device.newError("crypt_load", int(err), "load "+tcrypt.Name())
+	}
+
+	return nil
+}
+
+// ActivateTCryptProtected activates the outer volume loaded by LoadTCrypt as 'deviceName', with the data area of the hidden
+// volume it contains left out of the mapping, like VeraCrypt's "protect hidden volume" option does,
+// so writes to the outer volume cannot overwrite the hidden volume.
+// 'hidden' holds the credentials of the hidden volume, whose header is loaded to locate its data area:
+// CRYPT_TCRYPT_HIDDEN_HEADER is always added to its flags.
+// libcryptsetup has no such option, so the outer volume is mapped as a PLAIN device using its volume key,
+// which only supports volumes encrypted with a single cipher, not cascades such as "aes-twofish".
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_activate_by_volume_key, on a PLAIN device
+func (device *Device) ActivateTCryptProtected(deviceName string, hidden TCrypt, flags int) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	if device.Type() != TypeTCrypt {
+		return fmt.Errorf("device '%s' has no loaded TCRYPT header", device.DevicePath())
+	}
+
+	cipher := C.GoString(C.crypt_get_cipher(device.cryptDevice))
+	if strings.Contains(cipher, "-") {
+		return fmt.Errorf("hidden volume protection is not supported for cipher cascade '%s'", cipher)
+	}
+
+	hiddenDevice, err := Init(device.DevicePath())
+	if err != nil {
+		return err
+	}
+	defer hiddenDevice.Free()
+
+	hidden.Flags |= CRYPT_TCRYPT_HIDDEN_HEADER
+	if err = hiddenDevice.LoadTCrypt(hidden); err != nil {
+		return err
+	}
+
+	size, err := protectedOuterSize(device.DataOffset(), hiddenDevice.DataOffset())
+	if err != nil {
+		return err
+	}
+
+	volumeKey, _, err := device.VolumeKeyGet(CRYPT_ANY_SLOT, "")
+	if err != nil {
+		return err
+	}
+	defer WipeBytes(volumeKey)
+
+	outerDevice, err := Init(device.DevicePath())
+	if err != nil {
+		return err
+	}
+	defer outerDevice.Free()
+
+	plain := Plain{Offset: device.DataOffset(), Skip: device.IVOffset(), Size: size}
+	genericParams := GenericParams{Cipher: cipher, CipherMode: C.GoString(C.crypt_get_cipher_mode(device.cryptDevice)), VolumeKeySize: len(volumeKey)}
+	if err = outerDevice.Format(plain, genericParams); err != nil {
+		return err
+	}
+
+	return outerDevice.ActivateByVolumeKeyBytes(deviceName, volumeKey, flags)
+}
+
+// protectedOuterSize returns the size, in 512 byte sectors, of an outer volume starting at 'outerOffset'
+// that ends where the hidden volume starting at 'hiddenOffset' begins.
+func protectedOuterSize(outerOffset uint64, hiddenOffset uint64) (uint64, error) {
+	if hiddenOffset <= outerOffset {
+		return 0, fmt.Errorf("hidden volume at sector %d does not lie within the outer volume at sector %d", hiddenOffset, outerOffset)
+	}
+
+	return hiddenOffset - outerOffset, nil
+}
@@ -0,0 +1,39 @@
+package cryptsetup
+
+import "testing"
+
+func Test_TCrypt_LoadTCrypt_Fails_Without_Header(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.LoadTCrypt(TCrypt{Passphrase: "testPassphrase", Keyfiles: []string{DevicePath}, Hash: "sha512", Flags: CRYPT_TCRYPT_VERA_MODES})
+	testWrapper.AssertError(err)
+}
+
+func Test_TCrypt_ActivateTCryptProtected_Fails_If_Device_Is_Not_TCRYPT(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	err = device.ActivateTCryptProtected(DeviceName, TCrypt{Passphrase: "hiddenPassphrase"}, 0)
+	testWrapper.AssertError(err)
+}
+
+func Test_protectedOuterSize(test *testing.T) {
+	size, err := protectedOuterSize(256, 4096)
+	if err != nil || size != 3840 {
+		test.Errorf("Expected a size of 3840 sectors, but got %d: %v", size, err)
+	}
+
+	if _, err = protectedOuterSize(4096, 256); err == nil {
+		test.Error("A hidden volume before the outer volume should have been rejected.")
+	}
+}
@@ -0,0 +1,90 @@
+package cryptsetup
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// operationTimeout is the default timeout of RunWithTimeout, in nanoseconds.
+var operationTimeout int64
+
+// SetOperationTimeout sets the timeout of operations run by RunWithTimeout without a WithTimeout option.
+// A 'timeout' of 0, the default, lets operations run for as long as they take.
+func SetOperationTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&operationTimeout, int64(timeout))
+}
+
+// OperationTimeout returns the timeout set by SetOperationTimeout.
+func OperationTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&operationTimeout))
+}
+
+// RunWithTimeout runs 'operation', such as a call to Load or ActivateByPassphrase, and returns ErrTimeout if it hasn't
+// returned within the timeout set by SetOperationTimeout, or by WithTimeout, so a hung ioctl on a dead iSCSI or NBD backend
+// doesn't block the calling goroutine forever.
+// libcryptsetup calls cannot be interrupted: a timed out operation keeps blocking its own goroutine and OS thread
+// until the kernel gives up. Until then, the device is unusable: methods returning an error, including RunWithTimeout,
+// return ErrTimeout right away, and the other methods return zero values, such as TypeNone or an empty UUID.
+// Free may still be called: the device is released once the operation returns.
+// Returns the error returned by 'operation', or ErrTimeout.
+func (device *Device) RunWithTimeout(operation func() error, optionFuncs ...Option) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	if device.pendingOperation() != nil {
+		return ErrTimeout
+	}
+
+	timeout := newOptions(optionFuncs).timeout
+	if timeout <= 0 {
+		return operation()
+	}
+
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err = operation()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return err
+	case <-timer.C:
+		device.pendingLock.Lock()
+		device.pending = done
+		device.pendingLock.Unlock()
+		return ErrTimeout
+	}
+}
+
+// checkUsable returns ErrTimeout while an operation that timed out in RunWithTimeout is still running.
+func (device *Device) checkUsable() error {
+	if device.pendingOperation() != nil {
+		return ErrTimeout
+	}
+	return nil
+}
+
+// pendingOperation returns the channel closed once the operation that timed out in RunWithTimeout returns,
+// or nil if there is none, or it has returned since.
+func (device *Device) pendingOperation() chan struct{} {
+	device.pendingLock.Lock()
+	defer device.pendingLock.Unlock()
+
+	if device.pending == nil {
+		return nil
+	}
+
+	select {
+	case <-device.pending:
+		device.pending = nil
+		return nil
+	default:
+		return device.pending
+	}
+}
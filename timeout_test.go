@@ -0,0 +1,87 @@
+package cryptsetup
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_SetOperationTimeout(test *testing.T) {
+	defer SetOperationTimeout(0)
+
+	SetOperationTimeout(time.Second)
+	if OperationTimeout() != time.Second {
+		test.Errorf("Expected operation timeout to be 1s, but got %v", OperationTimeout())
+	}
+	if timeout := newOptions([]Option{WithTimeout(time.Minute)}).timeout; timeout != time.Minute {
+		test.Errorf("WithTimeout should override the operation timeout, but got %v", timeout)
+	}
+}
+
+func Test_Device_RunWithTimeout(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	failure := errors.New("failure")
+	if err = device.RunWithTimeout(func() error { return failure }, WithTimeout(time.Second)); err != failure {
+		test.Errorf("RunWithTimeout should have returned the operation's error, but returned: %v", err)
+	}
+
+	unblock := make(chan struct{})
+	err = device.RunWithTimeout(func() error {
+		<-unblock
+		return nil
+	}, WithTimeout(10*time.Millisecond))
+	if err != ErrTimeout {
+		test.Errorf("RunWithTimeout should have timed out, but returned: %v", err)
+	}
+
+	if err = device.RunWithTimeout(func() error { return nil }); err != ErrTimeout {
+		test.Errorf("RunWithTimeout should refuse operations while one is still blocked, but returned: %v", err)
+	}
+	if err = device.Load(); err != ErrTimeout {
+		test.Errorf("Load should fail while an operation is still blocked, but returned: %v", err)
+	}
+	if err = device.KeyslotDestroy(0); err != ErrTimeout {
+		test.Errorf("KeyslotDestroy should fail while an operation is still blocked, but returned: %v", err)
+	}
+	if device.DevicePath() != "" {
+		test.Errorf("DevicePath should be empty while an operation is still blocked, but is: %s", device.DevicePath())
+	}
+
+	close(unblock)
+	<-device.pending
+
+	testWrapper.AssertNoError(device.RunWithTimeout(func() error { return nil }))
+	if device.DevicePath() != DevicePath {
+		test.Errorf("DevicePath should be available again once the operation returned, but is: %s", device.DevicePath())
+	}
+}
+
+func Test_Device_Free_Waits_For_Timed_Out_Operation(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+
+	unblock := make(chan struct{})
+	released := make(chan struct{})
+	err = device.RunWithTimeout(func() error {
+		<-unblock
+		close(released)
+		return nil
+	}, WithTimeout(10*time.Millisecond))
+	if err != ErrTimeout {
+		test.Fatalf("RunWithTimeout should have timed out, but returned: %v", err)
+	}
+
+	if !device.Free() {
+		test.Error("Free should have freed the device.")
+	}
+
+	close(unblock)
+	<-released
+}
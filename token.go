@@ -0,0 +1,270 @@
+package cryptsetup
+
+/*
+#cgo pkg-config: libcryptsetup
+#include <errno.h>
+#include <libcryptsetup.h>
+#include <stdlib.h>
+#include <string.h>
+
+// crypt_token_max was added in libcryptsetup 2.4, which is also when CRYPT_TOKEN_ABI_VERSION1 was defined.
+// Older versions support tokens on LUKS2 only, with a fixed 32 token slots.
+static int token_max(const char *type)
+{
+#ifdef CRYPT_TOKEN_ABI_VERSION1
+	return crypt_token_max(type);
+#else
+	return type && !strcmp(type, CRYPT_LUKS2) ? 32 : -EINVAL;
+#endif
+}
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unsafe"
+)
+
+// TokenInfo describes a LUKS2 token slot in use.
+type TokenInfo struct {
+	// ID is the token's number.
+	ID int
+	// Status is one of the CRYPT_TOKEN_* status constants.
+	Status int
+	// Type is the token's type, such as "systemd-tpm2" or "luks2-keyring".
+	Type string
+	// Keyslots are the keyslots assigned to the token.
+	Keyslots []int
+}
+
+// TokenMax returns the number of token slots supported by the device's type.
+// Returns a negative number if the device's type has no token slots.
+// C equivalent: crypt_token_max
+func (device *Device) TokenMax() int {
+	if device.checkUsable() != nil {
+		return int(ETIMEDOUT)
+	}
+	return int(C.token_max(C.crypt_get_type(device.cryptDevice)))
+}
+
+// TokenStatus returns the status of a token slot, as one of the CRYPT_TOKEN_* constants, and the token's type.
+// The type is an empty string if the token slot is not in use.
+// C equivalent: crypt_token_status
+func (device *Device) TokenStatus(token int) (int, string) {
+	if device.checkUsable() != nil {
+		return CRYPT_TOKEN_INVALID, ""
+	}
+	var cType *C.char
+	status := C.crypt_token_status(device.cryptDevice, C.int(token), &cType)
+	return int(status), C.GoString(cType)
+}
+
+// Tokens lists the device's tokens with their types and assigned keyslots, so management UIs can show
+// what unlock methods exist on a volume.
+// Returns the tokens on success, or an error otherwise.
+func (device *Device) Tokens() ([]TokenInfo, error) {
+	if err := device.checkUsable(); err != nil {
+		return nil, err
+	}
+
+	tokenMax := device.TokenMax()
+	if tokenMax < 0 {
+		return nil, device.newError("crypt_token_max", tokenMax, "list tokens")
+	}
+
+	tokens := make([]TokenInfo, 0)
+	for token := 0; token < tokenMax; token++ {
+		status, tokenType := device.TokenStatus(token)
+		if status == CRYPT_TOKEN_INVALID || status == CRYPT_TOKEN_INACTIVE {
+			continue
+		}
+
+		keyslots := make([]int, 0)
+		for keyslot := 0; keyslot < device.KeyslotMax(); keyslot++ {
+			if C.crypt_token_is_assigned(device.cryptDevice, C.int(token), C.int(keyslot)) == 0 {
+				keyslots = append(keyslots, keyslot)
+			}
+		}
+
+		tokens = append(tokens, TokenInfo{ID: token, Status: status, Type: tokenType, Keyslots: keyslots})
+	}
+
+	return tokens, nil
+}
+
+// TokenJSONGet gets the JSON representation of a token.
+// Returns the JSON on success, or an error otherwise.
+// C equivalent: crypt_token_json_get
+func (device *Device) TokenJSONGet(token int) (string, error) {
+	if err := device.checkUsable(); err != nil {
+		return "", err
+	}
+
+	var cJSON *C.char
+
+	err := C.crypt_token_json_get(device.cryptDevice, C.int(token), &cJSON)
+	if err < 0 {
+		return "", device.newError("crypt_token_json_get", int(err), "get token", tokenDetail(token))
+	}
+
+	return C.GoString(cJSON), nil
+}
+
+// TokenJSONSet stores the JSON representation of a token in a specific token slot.
+// Use CRYPT_ANY_TOKEN to store the token in the first free token slot.
+// Returns the number of the token slot that was used on success, or an error otherwise.
+// C equivalent: crypt_token_json_set
+func (device *Device) TokenJSONSet(token int, json string) (int, error) {
+	if err := device.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	cJSON := C.CString(json)
+	defer C.free(unsafe.Pointer(cJSON))
+
+	unlock, lockErr := device.lockKeyslots()
+	if lockErr != nil {
+		return 0, lockErr
+	}
+	defer unlock()
+
+	err := C.crypt_token_json_set(device.cryptDevice, C.int(token), cJSON)
+	if err < 0 {
+		return 0, device.newError("crypt_token_json_set", int(err), "set token", tokenDetail(token))
+	}
+
+	emitEvent(&TokenSet{Device: device.DevicePath(), Token: int(err)})
+	return int(err), nil
+}
+
+// TokenReplace updates the JSON representation of the token in its token slot, in a single header write,
+// so a token can be rotated, such as when updating a TPM PCR policy, without a window where the volume has no token.
+// The new JSON must keep the token's type, and hold a "keyslots" array.
+// libcryptsetup additionally validates it with the handler registered for the token's type, if any.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_token_json_set
+func (device *Device) TokenReplace(token int, json string) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	status, tokenType := device.TokenStatus(token)
+	if status == CRYPT_TOKEN_INVALID || status == CRYPT_TOKEN_INACTIVE {
+		return fmt.Errorf("token %d is not in use", token)
+	}
+
+	if err := validateTokenReplacement(token, tokenType, json); err != nil {
+		return err
+	}
+
+	cJSON := C.CString(json)
+	defer C.free(unsafe.Pointer(cJSON))
+
+	unlock, lockErr := device.lockKeyslots()
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlock()
+
+	err := C.crypt_token_json_set(device.cryptDevice, C.int(token), cJSON)
+	if err < 0 {
+		return device.newError("crypt_token_json_set", int(err), "replace token", tokenDetail(token))
+	}
+
+	emitEvent(&TokenSet{Device: device.DevicePath(), Token: token})
+	return nil
+}
+
+// validateTokenReplacement checks that 'tokenJSON' keeps the type 'tokenType' of the token it replaces, and holds a "keyslots" array.
+func validateTokenReplacement(token int, tokenType string, tokenJSON string) error {
+	var fields struct {
+		Type     string          `json:"type"`
+		Keyslots json.RawMessage `json:"keyslots"`
+	}
+	if err := json.Unmarshal([]byte(tokenJSON), &fields); err != nil {
+		return fmt.Errorf("invalid JSON for token %d: %v", token, err)
+	}
+
+	if fields.Type != tokenType {
+		return fmt.Errorf("token %d has type '%s', and cannot be replaced by a token of type '%s'", token, tokenType, fields.Type)
+	}
+
+	if len(fields.Keyslots) == 0 || fields.Keyslots[0] != '[' {
+		return fmt.Errorf("JSON for token %d has no keyslots array", token)
+	}
+
+	return nil
+}
+
+// TokenRemove removes a token from its token slot.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_token_json_set
+func (device *Device) TokenRemove(token int) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	unlock, lockErr := device.lockKeyslots()
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlock()
+
+	err := C.crypt_token_json_set(device.cryptDevice, C.int(token), nil)
+	if err < 0 {
+		return device.newError("crypt_token_json_set", int(err), "remove token", tokenDetail(token))
+	}
+
+	emitEvent(&TokenRemoved{Device: device.DevicePath(), Token: token})
+	return nil
+}
+
+// TokenAssignKeyslot assigns a keyslot to a token.
+// Use CRYPT_ANY_SLOT to assign all active keyslots to the token.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_token_assign_keyslot
+func (device *Device) TokenAssignKeyslot(token int, keyslot int) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	unlock, lockErr := device.lockKeyslots()
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlock()
+
+	err := C.crypt_token_assign_keyslot(device.cryptDevice, C.int(token), C.int(keyslot))
+	if err < 0 {
+		return device.newError("crypt_token_assign_keyslot", int(err), "assign token", tokenDetail(token), keyslotDetail(keyslot))
+	}
+
+	emitEvent(&TokenKeyslotAssigned{Device: device.DevicePath(), Token: token, Keyslot: keyslot})
+	return nil
+}
+
+// TokenImport reads the JSON representation of a token from the file in 'path', and stores it in the first free token slot,
+// like `cryptsetup token import` does.
+// Returns the number of the token slot that was used on success, or an error otherwise.
+func (device *Device) TokenImport(path string) (int, error) {
+	json, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return device.TokenJSONSet(CRYPT_ANY_TOKEN, strings.TrimSpace(string(json)))
+}
+
+// TokenExport writes the JSON representation of a token to the file in 'path', like `cryptsetup token export` does,
+// so that it can be imported into another device by TokenImport.
+// Returns nil on success, or an error otherwise.
+func (device *Device) TokenExport(token int, path string) error {
+	json, err := device.TokenJSONGet(token)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, []byte(json+"\n"), 0600)
+}
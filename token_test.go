@@ -0,0 +1,162 @@
+package cryptsetup
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Token_Tokens(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	tokens, err := device.Tokens()
+	testWrapper.AssertNoError(err)
+	if len(tokens) != 0 {
+		test.Errorf("A freshly formatted device should have no tokens, but had: %+v", tokens)
+	}
+
+	err = device.KeyslotAddByVolumeKey(1, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	token, err := device.TokenJSONSet(CRYPT_ANY_TOKEN, `{"type":"go-cryptsetup-test","keyslots":[]}`)
+	testWrapper.AssertNoError(err)
+
+	err = device.TokenAssignKeyslot(token, 1)
+	testWrapper.AssertNoError(err)
+
+	tokens, err = device.Tokens()
+	testWrapper.AssertNoError(err)
+
+	expectedTokens := []TokenInfo{{ID: token, Status: CRYPT_TOKEN_EXTERNAL_UNKNOWN, Type: "go-cryptsetup-test", Keyslots: []int{1}}}
+	if !reflect.DeepEqual(tokens, expectedTokens) {
+		test.Errorf("Unexpected tokens: %+v", tokens)
+	}
+
+	json, err := device.TokenJSONGet(token)
+	testWrapper.AssertNoError(err)
+	if json == "" {
+		test.Error("Token JSON should not be empty.")
+	}
+}
+
+func Test_Token_Tokens_Fails_For_LUKS1(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	_, err = device.Tokens()
+	testWrapper.AssertError(err)
+}
+
+func Test_Token_TokenExport_TokenImport(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const tokenPath = "testToken.json"
+	defer os.Remove(tokenPath)
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	token, err := device.TokenJSONSet(CRYPT_ANY_TOKEN, `{"type":"go-cryptsetup-test","keyslots":[]}`)
+	testWrapper.AssertNoError(err)
+
+	err = device.TokenExport(token, tokenPath)
+	testWrapper.AssertNoError(err)
+
+	imported, err := device.TokenImport(tokenPath)
+	testWrapper.AssertNoError(err)
+
+	if imported == token {
+		test.Errorf("Imported token should have been stored in a new token slot, but was stored in: %d", imported)
+	}
+
+	if status, tokenType := device.TokenStatus(imported); status != CRYPT_TOKEN_EXTERNAL_UNKNOWN || tokenType != "go-cryptsetup-test" {
+		test.Errorf("Unexpected imported token status '%d' and type '%s'.", status, tokenType)
+	}
+}
+
+func Test_Token_TokenImport_Fails_If_File_Is_Not_Found(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	_, err = device.TokenImport("nonExistingTokenPath")
+	testWrapper.AssertError(err)
+}
+
+func Test_Token_TokenReplace(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	token, err := device.TokenJSONSet(CRYPT_ANY_TOKEN, `{"type":"go-cryptsetup-test","keyslots":[],"policy":"old"}`)
+	testWrapper.AssertNoError(err)
+
+	err = device.TokenReplace(token, `{"type":"go-cryptsetup-test","keyslots":[],"policy":"new"}`)
+	testWrapper.AssertNoError(err)
+
+	json, err := device.TokenJSONGet(token)
+	testWrapper.AssertNoError(err)
+	if !strings.Contains(json, `"new"`) {
+		test.Errorf("Token should have been replaced, but was: %s", json)
+	}
+
+	tokens, err := device.Tokens()
+	testWrapper.AssertNoError(err)
+	if len(tokens) != 1 {
+		test.Errorf("Replacing a token should not have added a token, but tokens were: %+v", tokens)
+	}
+}
+
+func Test_Token_TokenReplace_Fails_For_Invalid_Replacements(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	token, err := device.TokenJSONSet(CRYPT_ANY_TOKEN, `{"type":"go-cryptsetup-test","keyslots":[]}`)
+	testWrapper.AssertNoError(err)
+
+	err = device.TokenReplace(token+1, `{"type":"go-cryptsetup-test","keyslots":[]}`)
+	testWrapper.AssertError(err)
+
+	err = device.TokenReplace(token, `{"type":"go-cryptsetup-other","keyslots":[]}`)
+	testWrapper.AssertError(err)
+
+	err = device.TokenReplace(token, `{"type":"go-cryptsetup-test"}`)
+	testWrapper.AssertError(err)
+
+	err = device.TokenReplace(token, `not json`)
+	testWrapper.AssertError(err)
+}
@@ -0,0 +1,101 @@
+package cryptsetup
+
+/*
+#cgo pkg-config: libcryptsetup
+#include <libcryptsetup.h>
+#include <stdlib.h>
+
+// BITLK was added in libcryptsetup 2.3, and FVAULT2 in 2.6. Older versions fail to load them as unknown types.
+#ifndef CRYPT_BITLK
+#define CRYPT_BITLK "BITLK"
+#endif
+#ifndef CRYPT_FVAULT2
+#define CRYPT_FVAULT2 "FVAULT2"
+#endif
+
+// crypt_get_default_type was added in libcryptsetup 2.1, which defines no feature macro:
+// CRYPT_REENCRYPT_INITIALIZE_ONLY, defined since 2.2, is checked instead. Older versions default to LUKS1.
+static const char *get_default_type(void)
+{
+#ifdef CRYPT_REENCRYPT_INITIALIZE_ONLY
+	return crypt_get_default_type();
+#else
+	return CRYPT_LUKS1;
+#endif
+}
+*/
+import "C"
+import "unsafe"
+
+// Type is the type of a device context, as returned by Device.Type.
+type Type string
+
+const (
+	// TypeNone is the type of devices whose header was neither loaded nor formatted, or has no recognized header.
+	TypeNone Type = ""
+
+	TypeBitLK     Type = C.CRYPT_BITLK
+	TypeFVault2   Type = C.CRYPT_FVAULT2
+	TypeIntegrity Type = C.CRYPT_INTEGRITY
+	TypeLoopAES   Type = C.CRYPT_LOOPAES
+	TypeLUKS1     Type = C.CRYPT_LUKS1
+	TypeLUKS2     Type = C.CRYPT_LUKS2
+	TypePlain     Type = C.CRYPT_PLAIN
+	TypeTCrypt    Type = C.CRYPT_TCRYPT
+	TypeVerity    Type = C.CRYPT_VERITY
+)
+
+// loadAnyTypes are the types LoadAny tries to load once no LUKS header was found, in order.
+// TCRYPT headers are encrypted, and cannot be detected without their passphrase.
+var loadAnyTypes = []Type{TypeVerity, TypeBitLK, TypeFVault2, TypeIntegrity}
+
+// DefaultType returns the LUKS version libcryptsetup formats and loads first by default, as chosen when it was built.
+// C equivalent: crypt_get_default_type
+func DefaultType() Type {
+	return Type(C.GoString(C.get_default_type()))
+}
+
+// IsLUKS reports whether the type is LUKS1 or LUKS2.
+func (deviceType Type) IsLUKS() bool {
+	return deviceType == TypeLUKS1 || deviceType == TypeLUKS2
+}
+
+// IsLUKS reports whether the device context holds a LUKS1 or LUKS2 header.
+func (device *Device) IsLUKS() bool {
+	return device.Type().IsLUKS()
+}
+
+// IsFormatted reports whether the device context has a type, because its header was loaded or formatted.
+// Devices without a recognized header are not formatted.
+func (device *Device) IsFormatted() bool {
+	return device.Type() != TypeNone
+}
+
+// LoadAny loads the on-disk header whatever its type, so callers don't have to try every type in sequence.
+// LUKS headers are tried first, the default LUKS version before the other, then VERITY, BITLK, FVAULT2 and INTEGRITY superblocks.
+// TCRYPT headers cannot be detected, and are loaded by LoadTCrypt.
+// Returns the detected type on success, or the error of loading a LUKS header otherwise.
+// C equivalent: crypt_load, with a NULL type first
+func (device *Device) LoadAny() (Type, error) {
+	if err := device.checkUsable(); err != nil {
+		return TypeNone, err
+	}
+
+	err := device.Load()
+	if err == nil {
+		return device.Type(), nil
+	}
+
+	for _, deviceType := range loadAnyTypes {
+		cType := C.CString(string(deviceType))
+		result := C.crypt_load(device.cryptDevice, cType, nil)
+		C.free(unsafe.Pointer(cType))
+
+		if result >= 0 {
+			device.observeKeyslots()
+			return device.Type(), nil
+		}
+	}
+
+	return TypeNone, err
+}
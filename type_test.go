@@ -0,0 +1,87 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_Device_IsLUKS_IsFormatted(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	if device.Type() != TypeNone || device.IsFormatted() || device.IsLUKS() {
+		test.Errorf("A device without a loaded header should have no type, but has: %q", device.Type())
+	}
+
+	err = device.Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "cbc-essiv:sha256", VolumeKeySize: 256 / 8})
+	testWrapper.AssertNoError(err)
+	if device.Type() != TypePlain || !device.IsFormatted() || device.IsLUKS() {
+		test.Errorf("Unexpected type for a PLAIN device: %q", device.Type())
+	}
+
+	device.Free()
+
+	device, err = Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	if device.Type() != TypeLUKS1 || !device.IsLUKS() {
+		test.Errorf("Unexpected type for a LUKS1 device: %q", device.Type())
+	}
+}
+
+func Test_DefaultType(test *testing.T) {
+	if defaultType := DefaultType(); !defaultType.IsLUKS() {
+		test.Errorf("The default type should have been a LUKS version, but is: %q", defaultType)
+	}
+}
+
+func Test_Device_LoadAny(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	for _, deviceType := range []DeviceType{LUKS1{Hash: "sha256"}, LUKS2{SectorSize: 512}} {
+		device, err := Init(DevicePath)
+		testWrapper.AssertNoError(err)
+		err = device.Format(deviceType, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+		testWrapper.AssertNoError(err)
+		device.Free()
+
+		device, err = Init(DevicePath)
+		testWrapper.AssertNoError(err)
+
+		loadedType, err := device.LoadAny()
+		testWrapper.AssertNoError(err)
+		if string(loadedType) != deviceType.Name() || device.Type() != loadedType {
+			test.Errorf("The %s header should have been detected, but %q was loaded.", deviceType.Name(), loadedType)
+		}
+		device.Free()
+	}
+}
+
+func Test_Device_LoadAny_Fails_If_Device_Has_No_Header(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	image, err := ioutil.TempFile("", "loadany")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.Remove(image.Name())
+	testWrapper.AssertNoError(image.Truncate(8 * 1024 * 1024))
+	image.Close()
+
+	device, err := Init(image.Name())
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	loadedType, err := device.LoadAny()
+	testWrapper.AssertError(err)
+	if loadedType != TypeNone || device.IsFormatted() {
+		test.Errorf("No header should have been loaded, but %q was.", loadedType)
+	}
+}
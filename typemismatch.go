@@ -0,0 +1,105 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// ErrTypeMismatch is matched, through errors.Is, by the *TypeMismatchError returned when loading a header of another type
+// than the requested one.
+var ErrTypeMismatch = errors.New("header type does not match the requested type")
+
+// TypeMismatchError is returned by LoadType and Reload when the device holds a header of another type than the requested one,
+// such as a LUKS2 header when LUKS1 was requested, so callers supporting several versions can tell it from a damaged header.
+type TypeMismatchError struct {
+	path      string
+	requested Type
+	detected  Type
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("cryptsetup: device '%s' holds a %s header, not a %s one", e.path, e.detected, e.requested)
+}
+
+// Is reports whether 'target' is ErrTypeMismatch.
+func (e *TypeMismatchError) Is(target error) bool {
+	return target == ErrTypeMismatch
+}
+
+// Requested returns the type that was requested.
+func (e *TypeMismatchError) Requested() Type {
+	return e.requested
+}
+
+// Detected returns the type of the header found on the device.
+func (e *TypeMismatchError) Detected() Type {
+	return e.detected
+}
+
+// LoadType loads the on-disk header, which must be of type 'deviceType', such as TypeLUKS1 or TypeLUKS2.
+// Returns nil on success, a *TypeMismatchError if the device holds a LUKS header of another version,
+// or another error otherwise.
+// C equivalent: crypt_load
+func (device *Device) LoadType(deviceType Type) error {
+	if err := device.loadType(deviceType, "load "+string(deviceType)); err != nil {
+		return err
+	}
+
+	device.observeKeyslots()
+	return nil
+}
+
+// loadType loads the on-disk header of type 'deviceType', reporting a failure as a *TypeMismatchError if the device
+// holds a LUKS header of another version.
+func (device *Device) loadType(deviceType Type, operation string) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	cType := C.CString(string(deviceType))
+	defer C.free(unsafe.Pointer(cType))
+
+	err := C.crypt_load(device.cryptDevice, cType, nil)
+	if err >= 0 {
+		return nil
+	}
+
+	path := device.MetadataDevicePath()
+	if detected := detectLUKSType(path); detected != TypeNone && detected != deviceType {
+		return &TypeMismatchError{path: path, requested: deviceType, detected: detected}
+	}
+
+	return device.newError("crypt_load", int(err), operation)
+}
+
+// detectLUKSType reads the version of the LUKS header at the start of 'path'.
+// Returns TypeLUKS1 or TypeLUKS2, or TypeNone if no LUKS header of a known version could be read.
+func detectLUKSType(path string) Type {
+	file, err := os.Open(path)
+	if err != nil {
+		return TypeNone
+	}
+	defer file.Close()
+
+	header := make([]byte, len(luks2PrimaryMagic)+2)
+	if _, err := file.ReadAt(header, 0); err != nil || !bytes.Equal(header[:len(luks2PrimaryMagic)], luks2PrimaryMagic) {
+		return TypeNone
+	}
+
+	switch binary.BigEndian.Uint16(header[len(luks2PrimaryMagic):]) {
+	case 1:
+		return TypeLUKS1
+	case 2:
+		return TypeLUKS2
+	default:
+		return TypeNone
+	}
+}
@@ -0,0 +1,78 @@
+package cryptsetup
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_Device_LoadType(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	loaded, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer loaded.Free()
+
+	err = loaded.LoadType(TypeLUKS1)
+	if !errors.Is(err, ErrTypeMismatch) {
+		test.Fatalf("Expected ErrTypeMismatch, got: %v", err)
+	}
+	var mismatch *TypeMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Requested() != TypeLUKS1 || mismatch.Detected() != TypeLUKS2 {
+		test.Errorf("Unexpected error: %v", err)
+	}
+
+	testWrapper.AssertNoError(loaded.LoadType(TypeLUKS2))
+	if loaded.Type() != TypeLUKS2 {
+		test.Errorf("Unexpected type: %s", loaded.Type())
+	}
+}
+
+func Test_Device_LoadType_Fails_Without_Header(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	image, err := ioutil.TempFile("", "loadtype")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.Remove(image.Name())
+	testWrapper.AssertNoError(image.Truncate(8 * 1024 * 1024))
+	image.Close()
+
+	device, err := Init(image.Name())
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.LoadType(TypeLUKS1)
+	testWrapper.AssertError(err)
+	if errors.Is(err, ErrTypeMismatch) {
+		test.Errorf("A device without header should not report a type mismatch: %v", err)
+	}
+}
+
+func Test_Device_Reload_Fails_If_Header_Changed_Version(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	other, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer other.Free()
+	err = other.Format(LUKS2{SectorSize: 512}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	if err := device.Reload(); !errors.Is(err, ErrTypeMismatch) {
+		test.Errorf("Expected ErrTypeMismatch, got: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+import "C"
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// udevPollInterval is how often the device-mapper directory is checked while waiting for udev.
+const udevPollInterval = 10 * time.Millisecond
+
+// WithUdevWait makes ActivateEx, ActivateByPassphraseEx and Deactivate wait up to 'timeout' for udev to create
+// or remove the mapping's device node, so callers don't race the node appearing or disappearing.
+// A 'timeout' of 0, the default, doesn't wait.
+func WithUdevWait(timeout time.Duration) Option {
+	return func(options *options) {
+		options.udevWait = timeout
+	}
+}
+
+// MapperNodePath returns the path of the device node of a mapping named 'deviceName', usually in /dev/mapper.
+// C equivalent: crypt_get_dir
+func MapperNodePath(deviceName string) string {
+	return filepath.Join(C.GoString(C.crypt_get_dir()), deviceName)
+}
+
+// WaitForMapperNode waits until udev has created the device node of a mapping named 'deviceName',
+// so callers don't race the node appearing right after activation.
+// libcryptsetup already waits for udev when it was built with udev support;
+// this covers environments where it wasn't, or where udev rules create the node asynchronously.
+// Activation methods taking options wait for the node themselves when given WithUdevWait.
+// Returns the node's path on success, or ErrTimeout if it didn't appear within 'timeout'.
+func WaitForMapperNode(deviceName string, timeout time.Duration) (string, error) {
+	path := MapperNodePath(deviceName)
+
+	err := waitFor(timeout, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// WaitForMapperNodeRemoval waits until udev has removed the device node of a mapping named 'deviceName' after deactivation.
+// Returns nil on success, or ErrTimeout if the node was still present after 'timeout'.
+func WaitForMapperNodeRemoval(deviceName string, timeout time.Duration) error {
+	path := MapperNodePath(deviceName)
+
+	return waitFor(timeout, func() bool {
+		_, err := os.Lstat(path)
+		return os.IsNotExist(err)
+	})
+}
+
+// waitFor polls 'condition' until it holds, or until 'timeout' elapses.
+func waitFor(timeout time.Duration, condition func() bool) error {
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+		time.Sleep(udevPollInterval)
+	}
+	return nil
+}
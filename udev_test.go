@@ -0,0 +1,76 @@
+package cryptsetup
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_MapperNodePath(test *testing.T) {
+	if path := MapperNodePath(DeviceName); path != "/dev/mapper/"+DeviceName {
+		test.Errorf("Unexpected mapper node path: %s", path)
+	}
+}
+
+func Test_WaitForMapperNode_Activate_Deactivate(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.ActivateByPassphrase(DeviceName, 0, PassKey, CRYPT_ACTIVATE_READONLY)
+	testWrapper.AssertNoError(err)
+
+	path, err := WaitForMapperNode(DeviceName, 5*time.Second)
+	testWrapper.AssertNoError(err)
+	if path != MapperNodePath(DeviceName) {
+		test.Errorf("Unexpected mapper node path: %s", path)
+	}
+
+	err = device.Deactivate(DeviceName)
+	testWrapper.AssertNoError(err)
+
+	err = WaitForMapperNodeRemoval(DeviceName, 5*time.Second)
+	testWrapper.AssertNoError(err)
+}
+
+func Test_ActivateEx_Deactivate_WithUdevWait(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	activation, err := device.ActivateEx(DeviceName, Passphrase{Passphrase: PassKey}, CRYPT_ACTIVATE_READONLY, WithUdevWait(5*time.Second))
+	testWrapper.AssertNoError(err)
+	if activation.MapperPath != MapperNodePath(DeviceName) {
+		test.Errorf("Unexpected mapper node path: %s", activation.MapperPath)
+	}
+	if _, err := os.Stat(activation.MapperPath); err != nil {
+		test.Errorf("The mapper node should have been created before returning: %v", err)
+	}
+
+	err = device.Deactivate(DeviceName, WithUdevWait(5*time.Second))
+	testWrapper.AssertNoError(err)
+	if _, err := os.Lstat(activation.MapperPath); !os.IsNotExist(err) {
+		test.Errorf("The mapper node should have been removed before returning: %v", err)
+	}
+}
+
+func Test_WaitForMapperNode_Fails_If_Node_Does_Not_Appear(test *testing.T) {
+	_, err := WaitForMapperNode("nonExistingDeviceName", 50*time.Millisecond)
+	if err != ErrTimeout {
+		test.Errorf("WaitForMapperNode() should have returned ErrTimeout, but returned: %v", err)
+	}
+}
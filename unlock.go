@@ -0,0 +1,68 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UnlockAttempt records the failure of one credential tried by Unlock.
+type UnlockAttempt struct {
+	Credential Credential
+	Err        error
+}
+
+// UnlockError is returned by Unlock when no credential could activate the device.
+type UnlockError struct {
+	Attempts []UnlockAttempt
+}
+
+func (e *UnlockError) Error() string {
+	if len(e.Attempts) == 0 {
+		return "no credential was provided to unlock the device."
+	}
+
+	failures := make([]string, 0, len(e.Attempts))
+	for _, attempt := range e.Attempts {
+		failures = append(failures, fmt.Sprintf("%T: %v", attempt.Credential, attempt.Err))
+	}
+	return fmt.Sprintf("all %d credentials failed to unlock the device: %s", len(e.Attempts), strings.Join(failures, "; "))
+}
+
+// Unlock activates the device as 'deviceName' using the first credential that succeeds, mirroring systemd-cryptsetup's fallback behavior.
+// Tokens are tried first, then key files, then passphrases and any other credentials, keeping the given order within each group.
+// Returns nil on success, or an *UnlockError collecting the error of every attempt otherwise.
+func (device *Device) Unlock(deviceName string, credentials []Credential) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	ordered := make([]Credential, len(credentials))
+	copy(ordered, credentials)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return unlockPriority(ordered[i]) < unlockPriority(ordered[j])
+	})
+
+	unlockError := &UnlockError{}
+	for _, credential := range ordered {
+		err := credential.Activate(device, deviceName, 0)
+		if err == nil {
+			return nil
+		}
+		unlockError.Attempts = append(unlockError.Attempts, UnlockAttempt{Credential: credential, Err: err})
+	}
+
+	return unlockError
+}
+
+// unlockPriority ranks credentials in the order Unlock tries them.
+func unlockPriority(credential Credential) int {
+	switch credential.(type) {
+	case Token, *Token:
+		return 0
+	case Keyfile, *Keyfile:
+		return 1
+	default:
+		return 2
+	}
+}
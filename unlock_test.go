@@ -0,0 +1,85 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type recordingCredential struct {
+	name  string
+	order *[]string
+	err   error
+}
+
+func (credential recordingCredential) Activate(device *Device, deviceName string, flags int) error {
+	*credential.order = append(*credential.order, credential.name)
+	return credential.err
+}
+
+func Test_Device_Unlock_Tries_Key_Files_Before_Passphrases(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const keyfilePath = "testKeyfile"
+	defer os.Remove(keyfilePath)
+	err := ioutil.WriteFile(keyfilePath, []byte("keyfilePassphrase"), 0600)
+	testWrapper.AssertNoError(err)
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "keyfilePassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	order := make([]string, 0)
+	err = device.Unlock("", []Credential{
+		recordingCredential{name: "passphrase", order: &order},
+		Keyfile{Keyslot: CRYPT_ANY_SLOT, Path: keyfilePath},
+	})
+	testWrapper.AssertNoError(err)
+
+	if len(order) != 0 {
+		test.Errorf("The passphrase should not have been tried, but was: %v", order)
+	}
+}
+
+func Test_Device_Unlock_Collects_Errors(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	order := make([]string, 0)
+	err = device.Unlock("", []Credential{
+		Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "wrongPassphrase"},
+		recordingCredential{name: "recorded", order: &order, err: ErrTimeout},
+		Keyfile{Keyslot: CRYPT_ANY_SLOT, Path: "nonExistingKeyfilePath"},
+	})
+	testWrapper.AssertError(err)
+
+	unlockError, ok := err.(*UnlockError)
+	if !ok {
+		test.Fatalf("Expected *UnlockError, but got: %T", err)
+	}
+
+	if len(unlockError.Attempts) != 3 {
+		test.Fatalf("Expected 3 attempts, but got: %+v", unlockError.Attempts)
+	}
+
+	if _, ok := unlockError.Attempts[0].Credential.(Keyfile); !ok {
+		test.Errorf("The key file should have been tried first, but was: %T", unlockError.Attempts[0].Credential)
+	}
+
+	if unlockError.Attempts[2].Err != ErrTimeout || len(order) != 1 {
+		test.Errorf("Unexpected last attempt: %+v", unlockError.Attempts[2])
+	}
+}
@@ -0,0 +1,180 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import "unsafe"
+
+// defaultVerityFECRoots is the number of Reed-Solomon parity bytes used when a FEC device is set without FECRoots,
+// like `veritysetup --fec-device` does.
+const defaultVerityFECRoots = 2
+
+// VerityParams is the struct used to manipulate VERITY devices, whose data device is checked against a hash tree
+// stored on the device the Device was initialized with, like `veritysetup` does.
+// With a FEC device, corrupted blocks are repaired using Reed-Solomon forward error correction, like Android verity images are.
+// The root hash, computed by Format, is the volume key of VERITY devices: ActivateByVolumeKeyBytes activates the device with it,
+// and checks the hash tree's root hash against it when 'deviceName' is empty.
+// The CRYPT_ACTIVATE_*_CORRUPTION, CRYPT_ACTIVATE_IGNORE_ZERO_BLOCKS and CRYPT_ACTIVATE_CHECK_AT_MOST_ONCE flags
+// set how the mapping handles blocks that cannot be corrected.
+type VerityParams struct {
+	// HashName is the hash of the hash tree, such as "sha256".
+	HashName string
+	// DataDevice is the path of the device holding the verified data.
+	DataDevice string
+	// FECDevice is the path of the device holding the FEC parity data, or empty to disable error correction.
+	// It may be the hash device, with FECAreaOffset past the hash area.
+	FECDevice string
+	Salt      []byte
+	// HashType is the on-disk format version: 1 for the normal format, 0 for the original Chrome OS one.
+	HashType      uint32
+	DataBlockSize uint32
+	HashBlockSize uint32
+	// DataSize is the size of the verified data, in data blocks. If 0, the whole data device is verified.
+	DataSize uint64
+	// HashAreaOffset is the offset of the hash area on the hash device, in bytes.
+	HashAreaOffset uint64
+	// FECAreaOffset is the offset of the FEC parity data on the FEC device, in bytes.
+	FECAreaOffset uint64
+	// FECRoots is the number of parity bytes of each Reed-Solomon codeword, between 2 and 24.
+	// If 0 while FECDevice is set, 2 roots are used.
+	FECRoots uint32
+	// Flags are the CRYPT_VERITY_* flags.
+	Flags uint32
+}
+
+// Name returns the VERITY device type name as a string.
+func (verity VerityParams) Name() string {
+	return C.CRYPT_VERITY
+}
+
+// Unmanaged is used to specialize VerityParams.
+func (verity VerityParams) Unmanaged() (unsafe.Pointer, func()) {
+	deallocations := make([]func(), 0, 4)
+	deallocate := func() {
+		for index := 0; index < len(deallocations); index++ {
+			deallocations[index]()
+		}
+	}
+
+	var cParams C.struct_crypt_params_verity
+
+	cParams.hash_name = nil
+	if verity.HashName != "" {
+		cParams.hash_name = C.CString(verity.HashName)
+		deallocations = append(deallocations, func() {
+			C.free(unsafe.Pointer(cParams.hash_name))
+		})
+	}
+
+	cParams.data_device = nil
+	if verity.DataDevice != "" {
+		cParams.data_device = C.CString(verity.DataDevice)
+		deallocations = append(deallocations, func() {
+			C.free(unsafe.Pointer(cParams.data_device))
+		})
+	}
+
+	cParams.fec_device = nil
+	if verity.FECDevice != "" {
+		cParams.fec_device = C.CString(verity.FECDevice)
+		deallocations = append(deallocations, func() {
+			C.free(unsafe.Pointer(cParams.fec_device))
+		})
+	}
+
+	cParams.salt = nil
+	if len(verity.Salt) > 0 {
+		cSalt := (*C.char)(C.CBytes(verity.Salt))
+		cParams.salt = cSalt
+		deallocations = append(deallocations, func() {
+			C.free(unsafe.Pointer(cSalt))
+		})
+	}
+	cParams.salt_size = C.uint32_t(len(verity.Salt))
+
+	cParams.hash_type = C.uint32_t(verity.HashType)
+	cParams.data_block_size = C.uint32_t(verity.DataBlockSize)
+	cParams.hash_block_size = C.uint32_t(verity.HashBlockSize)
+	cParams.data_size = C.uint64_t(verity.DataSize)
+	cParams.hash_area_offset = C.uint64_t(verity.HashAreaOffset)
+	cParams.fec_area_offset = C.uint64_t(verity.FECAreaOffset)
+	cParams.flags = C.uint32_t(verity.Flags)
+
+	cParams.fec_roots = C.uint32_t(verity.FECRoots)
+	if verity.FECDevice != "" && verity.FECRoots == 0 {
+		cParams.fec_roots = defaultVerityFECRoots
+	}
+
+	return unsafe.Pointer(&cParams), deallocate
+}
+
+// LoadVerity loads the VERITY superblock of the hash device, checking 'verity.DataDevice' against it,
+// and repairing it using 'verity.FECDevice', if set. Parameters stored in the superblock are read from it,
+// but the FEC parameters are not stored there, and must be given as they were to Format.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_load
+func (device *Device) LoadVerity(verity VerityParams) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	cType := C.CString(verity.Name())
+	defer C.free(unsafe.Pointer(cType))
+
+	cParams, freeCParams := verity.Unmanaged()
+	defer freeCParams()
+
+	err := C.crypt_load(device.cryptDevice, cType, cParams)
+	if err < 0 {
+		return device.newError("crypt_load", int(err), "load "+verity.Name())
+	}
+
+	return nil
+}
+
+// VerityInfo returns the parameters of the VERITY device formatted or loaded, including its FEC parameters.
+// Returns the parameters on success, or an error otherwise.
+// C equivalent: crypt_get_verity_info
+func (device *Device) VerityInfo() (VerityParams, error) {
+	if err := device.checkUsable(); err != nil {
+		return VerityParams{}, err
+	}
+
+	var verity VerityParams
+	var cParams C.struct_crypt_params_verity
+
+	err := C.crypt_get_verity_info(device.cryptDevice, &cParams)
+	if err < 0 {
+		return verity, device.newError("crypt_get_verity_info", int(err), "get verity info")
+	}
+
+	verity.HashName = C.GoString(cParams.hash_name)
+	verity.DataDevice = C.GoString(cParams.data_device)
+	verity.FECDevice = C.GoString(cParams.fec_device)
+	if cParams.salt != nil && cParams.salt_size > 0 {
+		verity.Salt = C.GoBytes(unsafe.Pointer(cParams.salt), C.int(cParams.salt_size))
+	}
+	verity.HashType = uint32(cParams.hash_type)
+	verity.DataBlockSize = uint32(cParams.data_block_size)
+	verity.HashBlockSize = uint32(cParams.hash_block_size)
+	verity.DataSize = uint64(cParams.data_size)
+	verity.HashAreaOffset = uint64(cParams.hash_area_offset)
+	verity.FECAreaOffset = uint64(cParams.fec_area_offset)
+	verity.FECRoots = uint32(cParams.fec_roots)
+	verity.Flags = uint32(cParams.flags)
+
+	return verity, nil
+}
+
+// VerityRootHash returns the root hash of the hash tree computed by Format, which activating the device requires.
+// Returns the root hash on success, or an error otherwise.
+// C equivalent: crypt_volume_key_get
+func (device *Device) VerityRootHash() ([]byte, error) {
+	rootHash, _, err := device.VolumeKeyGet(CRYPT_ANY_SLOT, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return rootHash, nil
+}
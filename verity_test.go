@@ -0,0 +1,85 @@
+package cryptsetup
+
+import (
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// verityImages writes a data image of 'blocks' random 4096 byte blocks, and empty hash and FEC images, in a temporary directory.
+// Returns the paths of the images, and a function removing them.
+func verityImages(blocks int, test *testing.T) (string, string, string, func()) {
+	directory, err := ioutil.TempDir("", "verity")
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	data := make([]byte, blocks*4096)
+	if _, err := rand.Read(data); err != nil {
+		test.Fatal(err)
+	}
+
+	dataPath, hashPath, fecPath := filepath.Join(directory, "data"), filepath.Join(directory, "hash"), filepath.Join(directory, "fec")
+	for path, content := range map[string][]byte{dataPath: data, hashPath: make([]byte, 1024*1024), fecPath: make([]byte, 1024*1024)} {
+		if err := ioutil.WriteFile(path, content, 0600); err != nil {
+			test.Fatal(err)
+		}
+	}
+
+	return dataPath, hashPath, fecPath, func() { os.RemoveAll(directory) }
+}
+
+func Test_Verity_Format_With_FEC(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	dataPath, hashPath, fecPath, remove := verityImages(256, test)
+	defer remove()
+
+	device, err := Init(hashPath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	verity := VerityParams{
+		HashName:      "sha256",
+		DataDevice:    dataPath,
+		FECDevice:     fecPath,
+		Salt:          []byte("0123456789abcdef"),
+		HashType:      1,
+		DataBlockSize: 4096,
+		HashBlockSize: 4096,
+		FECRoots:      4,
+		Flags:         CRYPT_VERITY_CREATE_HASH,
+	}
+	err = device.Format(verity, GenericParams{})
+	testWrapper.AssertNoError(err)
+
+	rootHash, err := device.VerityRootHash()
+	testWrapper.AssertNoError(err)
+	if len(rootHash) != 32 {
+		test.Errorf("The root hash should be a sha256 digest, but has %d bytes.", len(rootHash))
+	}
+
+	device.Free()
+
+	device, err = Init(hashPath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.LoadVerity(VerityParams{DataDevice: dataPath, FECDevice: fecPath, FECRoots: 4, Flags: CRYPT_VERITY_CHECK_HASH})
+	testWrapper.AssertNoError(err)
+
+	info, err := device.VerityInfo()
+	testWrapper.AssertNoError(err)
+	if info.HashName != "sha256" || info.DataSize != 256 || info.FECDevice != fecPath || info.FECRoots != 4 || string(info.Salt) != "0123456789abcdef" {
+		test.Errorf("Unexpected verity parameters: %+v", info)
+	}
+
+	err = device.ActivateByVolumeKeyBytes("", rootHash, 0)
+	testWrapper.AssertNoError(err)
+
+	rootHash[0] ^= 0xff
+	err = device.ActivateByVolumeKeyBytes("", rootHash, 0)
+	testWrapper.AssertError(err)
+}
@@ -0,0 +1,107 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Volume is an activated crypto device.
+// It wraps the Init, Load and Activate steps behind a single call to Open.
+type Volume struct {
+	device *Device
+	path   string
+	name   string
+}
+
+// Open initializes the device backed by 'devicePath', loads its on-disk header and activates it using 'credential'.
+// The mapping is named "luks-<UUID>", or after the device path if the header provides no UUID.
+// Returns a pointer to the newly activated Volume or any error encountered.
+func Open(devicePath string, credential Credential) (*Volume, error) {
+	device, err := Init(devicePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = device.Load(); err != nil {
+		device.Free()
+		return nil, err
+	}
+
+	name := filepath.Base(devicePath)
+	if uuid := device.UUID(); uuid != "" {
+		name = "luks-" + uuid
+	}
+
+	if err = credential.Activate(device, name, 0); err != nil {
+		device.Free()
+		return nil, err
+	}
+
+	return &Volume{device: device, path: devicePath, name: name}, nil
+}
+
+// Device returns the Device backing the volume.
+func (volume *Volume) Device() *Device {
+	return volume.device
+}
+
+// Path returns the path of the device backing the volume.
+func (volume *Volume) Path() string {
+	return volume.path
+}
+
+// Name returns the name the volume was activated with.
+func (volume *Volume) Name() string {
+	return volume.name
+}
+
+// MapperPath returns the path of the volume's device node, usually in /dev/mapper.
+func (volume *Volume) MapperPath() string {
+	return MapperNodePath(volume.name)
+}
+
+// Close deactivates the volume and releases its backing Device.
+// Returns nil on success, or an error otherwise.
+func (volume *Volume) Close() error {
+	if err := volume.device.Deactivate(volume.name); err != nil {
+		return err
+	}
+
+	volume.device.Free()
+	return nil
+}
+
+// OpenWithPrompter opens the device backed by 'devicePath' like Open, using a passphrase obtained from 'prompter'.
+// Returns a pointer to the newly activated Volume or any error encountered.
+func OpenWithPrompter(devicePath string, prompter Prompter) (*Volume, error) {
+	passphrase, err := prompter.Prompt(fmt.Sprintf("Enter passphrase for %s: ", devicePath), false)
+	if err != nil {
+		return nil, err
+	}
+	defer WipeBytes(passphrase)
+
+	return Open(devicePath, PassphraseBytes{Keyslot: CRYPT_ANY_SLOT, Passphrase: passphrase})
+}
+
+// OpenPlain activates the PLAIN device backed by 'devicePath' as 'name' using 'credential', like `cryptsetup create` does,
+// so legacy dm-crypt setups using a data offset or an IV offset can be reproduced through Plain.Offset and Plain.Skip.
+// Nothing is written to the device, as PLAIN devices have no header.
+// Returns a pointer to the newly activated Volume or any error encountered.
+func OpenPlain(devicePath string, name string, plain Plain, genericParams GenericParams, credential Credential) (*Volume, error) {
+	device, err := Init(devicePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = device.Format(plain, genericParams); err != nil {
+		device.Free()
+		return nil, err
+	}
+
+	if err = credential.Activate(device, name, 0); err != nil {
+		device.Free()
+		return nil, err
+	}
+
+	return &Volume{device: device, path: devicePath, name: name}, nil
+}
@@ -0,0 +1,87 @@
+package cryptsetup
+
+import (
+	"testing"
+)
+
+func Test_Volume_Open_Close(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+	uuid := device.UUID()
+	device.Free()
+
+	volume, err := Open(DevicePath, Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "testPassphrase"})
+	testWrapper.AssertNoError(err)
+	if err != nil {
+		return
+	}
+
+	if volume.Name() != "luks-"+uuid {
+		test.Errorf("Volume name should have been 'luks-%s', but was: %s", uuid, volume.Name())
+	}
+
+	if volume.MapperPath() != "/dev/mapper/luks-"+uuid {
+		test.Errorf("Unexpected mapper path: %s", volume.MapperPath())
+	}
+
+	err = volume.Close()
+	testWrapper.AssertNoError(err)
+}
+
+func Test_Volume_Open_Fails_If_Device_Is_Not_Found(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := Open("nonExistingDevicePath", Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "testPassphrase"})
+	testWrapper.AssertError(err)
+	testWrapper.AssertErrorCodeEquals(err, -15)
+}
+
+func Test_Volume_Open_Fails_If_Passphrase_Is_Wrong(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+	device.Free()
+
+	_, err = Open(DevicePath, Passphrase{Keyslot: 0, Passphrase: "wrongPassphrase"})
+	testWrapper.AssertError(err)
+}
+
+func Test_Volume_OpenPlain_Close(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	plain := Plain{Hash: "sha256", Offset: 8, Skip: 16}
+	volume, err := OpenPlain(DevicePath, DeviceName, plain, GenericParams{Cipher: "aes", CipherMode: "cbc-essiv:sha256", VolumeKeySize: 256 / 8}, Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "testPassphrase"})
+	testWrapper.AssertNoError(err)
+	if err != nil {
+		return
+	}
+
+	if volume.Device().IVOffset() != 16 || volume.Device().DataOffset() != 8 {
+		test.Errorf("Unexpected IV offset '%d' and data offset '%d'.", volume.Device().IVOffset(), volume.Device().DataOffset())
+	}
+
+	err = volume.Close()
+	testWrapper.AssertNoError(err)
+}
+
+func Test_Volume_OpenPlain_Fails_For_Invalid_Cipher(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	_, err := OpenPlain(DevicePath, DeviceName, Plain{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-nonexisting", VolumeKeySize: 512 / 8}, Passphrase{Keyslot: CRYPT_ANY_SLOT, Passphrase: "testPassphrase"})
+	testWrapper.AssertError(err)
+}
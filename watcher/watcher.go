@@ -0,0 +1,345 @@
+// Package watcher monitors block devices being plugged and unplugged, and probes new devices for LUKS headers,
+// so auto-unlock daemons can be written on top of the cryptsetup package alone.
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"cryptsetup"
+)
+
+// EventType is the kind of change an Event reports.
+type EventType int
+
+const (
+	// DeviceAdded reports a block device that appeared, such as a hotplugged disk or a new partition.
+	DeviceAdded EventType = iota
+	// DeviceRemoved reports a block device that disappeared.
+	DeviceRemoved
+)
+
+func (eventType EventType) String() string {
+	switch eventType {
+	case DeviceAdded:
+		return "add"
+	case DeviceRemoved:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// Event reports a block device that was added or removed.
+type Event struct {
+	Type EventType
+	// DevicePath is the path of the device node, such as "/dev/sdb1".
+	DevicePath string
+	// DevType is the kernel's device type, such as "disk" or "partition".
+	DevType string
+	// LUKS reports whether an added device holds a LUKS header. It is always false for removed devices.
+	LUKS bool
+	// LUKSType is the type of the header, TypeLUKS1 or TypeLUKS2, if LUKS is true.
+	LUKSType cryptsetup.Type
+	// UUID is the UUID of the header, if LUKS is true.
+	UUID string
+	// Err holds the error encountered while probing an added device, other than the device not holding a LUKS header.
+	Err error
+}
+
+// ProbeTimeout is how long an added device's node is waited for before probing it, as udev creates it asynchronously.
+var ProbeTimeout = 2 * time.Second
+
+// probePollInterval is how often the device node of an added device is checked for while waiting for it.
+const probePollInterval = 10 * time.Millisecond
+
+// kernelUeventGroup is the netlink multicast group on which the kernel broadcasts uevents.
+const kernelUeventGroup = 1
+
+// ueventBufferSize is large enough for any uevent, which the kernel limits to 2KiB of environment.
+const ueventBufferSize = 64 * 1024
+
+// pendingEvents is how many events may be waiting to be probed and delivered before uevents stop being read.
+// Once the socket's receive buffer overflows too, the kernel drops uevents, and the Watcher re-syncs.
+const pendingEvents = 256
+
+// readErrorBackoff and maxReadErrorBackoff bound how long reading uevents is paused after an unexpected error.
+const (
+	readErrorBackoff    = 10 * time.Millisecond
+	maxReadErrorBackoff = time.Second
+)
+
+// sysClassBlockPath lists the block devices present, which the Watcher re-syncs from after uevents were dropped.
+var sysClassBlockPath = "/sys/class/block"
+
+// Watcher delivers an Event for every block device added or removed, listening to the kernel's uevents over netlink.
+type Watcher struct {
+	socket    *os.File
+	events    chan Event
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// pendingEvent is an event waiting to be delivered, once the device it reports was probed.
+type pendingEvent struct {
+	event  Event
+	probed chan struct{}
+}
+
+// New returns a Watcher listening to block device uevents. Call Close to stop it.
+// Returns the watcher on success, or an error otherwise.
+func New() (*Watcher, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: kernelUeventGroup}); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	watcher := &Watcher{socket: os.NewFile(uintptr(fd), "uevent"), events: make(chan Event), done: make(chan struct{})}
+	pending := make(chan *pendingEvent, pendingEvents)
+	go watcher.run(pending)
+	go watcher.deliver(pending)
+	return watcher, nil
+}
+
+// Events returns the channel on which events are delivered. It is closed once the Watcher is closed.
+// If the kernel dropped uevents because they were not received fast enough, a DeviceAdded event is delivered again
+// for every block device present, so consumers must handle repeated additions of the same device.
+// Removals dropped by the kernel are not reported.
+func (watcher *Watcher) Events() <-chan Event {
+	return watcher.events
+}
+
+// Close stops the Watcher. No events are delivered afterwards, and the Events channel is closed shortly.
+// Returns nil on success, or an error otherwise.
+func (watcher *Watcher) Close() error {
+	err := os.ErrClosed
+	watcher.closeOnce.Do(func() {
+		close(watcher.done)
+		err = watcher.socket.Close()
+	})
+	return err
+}
+
+// closed reports whether Close was called.
+func (watcher *Watcher) closed() bool {
+	select {
+	case <-watcher.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// run reads uevents until the Watcher is closed, queueing events for block devices to be probed and delivered.
+// Devices are probed concurrently, so a slow device doesn't hold up reading uevents.
+func (watcher *Watcher) run(pending chan<- *pendingEvent) {
+	defer close(pending)
+
+	buffer := make([]byte, ueventBufferSize)
+	backoff := readErrorBackoff
+	for {
+		count, err := watcher.socket.Read(buffer)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) || watcher.closed() {
+				return
+			}
+			if errors.Is(err, syscall.ENOBUFS) {
+				for _, event := range presentDevices() {
+					if !watcher.queue(pending, event) {
+						return
+					}
+				}
+				continue
+			}
+
+			select {
+			case <-time.After(backoff):
+			case <-watcher.done:
+				return
+			}
+			if backoff *= 2; backoff > maxReadErrorBackoff {
+				backoff = maxReadErrorBackoff
+			}
+			continue
+		}
+		backoff = readErrorBackoff
+
+		event, ok := parseUevent(buffer[:count])
+		if !ok {
+			continue
+		}
+		if !watcher.queue(pending, event) {
+			return
+		}
+	}
+}
+
+// queue starts probing an added device, and queues its event for delivery.
+// Returns false if the Watcher was closed meanwhile.
+func (watcher *Watcher) queue(pending chan<- *pendingEvent, event Event) bool {
+	entry := &pendingEvent{event: event, probed: make(chan struct{})}
+	if event.Type == DeviceAdded {
+		go func() {
+			probe(&entry.event)
+			close(entry.probed)
+		}()
+	} else {
+		close(entry.probed)
+	}
+
+	select {
+	case pending <- entry:
+		return true
+	case <-watcher.done:
+		return false
+	}
+}
+
+// deliver sends the queued events in the order their uevents were received, once probed, until the Watcher is closed.
+func (watcher *Watcher) deliver(pending <-chan *pendingEvent) {
+	defer close(watcher.events)
+
+	for entry := range pending {
+		select {
+		case <-entry.probed:
+		case <-watcher.done:
+			return
+		}
+
+		select {
+		case watcher.events <- entry.event:
+		case <-watcher.done:
+			return
+		}
+	}
+}
+
+// presentDevices returns a DeviceAdded event for every block device listed in sysfs, to re-sync after uevents were dropped.
+func presentDevices() []Event {
+	entries, err := ioutil.ReadDir(sysClassBlockPath)
+	if err != nil {
+		return nil
+	}
+
+	events := make([]Event, 0, len(entries))
+	for _, entry := range entries {
+		content, err := ioutil.ReadFile(filepath.Join(sysClassBlockPath, entry.Name(), "uevent"))
+		if err != nil {
+			continue
+		}
+
+		environment := map[string]string{"ACTION": "add", "SUBSYSTEM": "block"}
+		for _, line := range strings.Split(string(content), "\n") {
+			if separator := strings.IndexByte(line, '='); separator > 0 {
+				environment[line[:separator]] = line[separator+1:]
+			}
+		}
+		if event, ok := eventFromEnvironment(environment); ok {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// parseUevent parses a kernel uevent: an "action@devpath" header followed by NUL-separated KEY=value pairs.
+// Returns the event, and whether it reports a block device being added or removed.
+func parseUevent(message []byte) (Event, bool) {
+	fields := bytes.Split(message, []byte{0})
+	if len(fields) < 2 || !bytes.Contains(fields[0], []byte("@")) {
+		return Event{}, false
+	}
+
+	environment := make(map[string]string)
+	for _, field := range fields[1:] {
+		if separator := bytes.IndexByte(field, '='); separator > 0 {
+			environment[string(field[:separator])] = string(field[separator+1:])
+		}
+	}
+
+	return eventFromEnvironment(environment)
+}
+
+// eventFromEnvironment builds the event reported by the KEY=value pairs of a uevent.
+// Returns the event, and whether it reports a block device being added or removed.
+func eventFromEnvironment(environment map[string]string) (Event, bool) {
+	if environment["SUBSYSTEM"] != "block" || environment["DEVNAME"] == "" {
+		return Event{}, false
+	}
+
+	event := Event{DevType: environment["DEVTYPE"]}
+	switch environment["ACTION"] {
+	case "add":
+		event.Type = DeviceAdded
+	case "remove":
+		event.Type = DeviceRemoved
+	default:
+		return Event{}, false
+	}
+
+	event.DevicePath = environment["DEVNAME"]
+	if !filepath.IsAbs(event.DevicePath) {
+		event.DevicePath = filepath.Join("/dev", event.DevicePath)
+	}
+
+	return event, true
+}
+
+// probe waits for the node of an added device, and fills the event with its LUKS header's type and UUID, if it has one.
+func probe(event *Event) {
+	deadline := time.Now().Add(ProbeTimeout)
+	for {
+		_, err := os.Stat(event.DevicePath)
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) || time.Now().After(deadline) {
+			event.Err = err
+			return
+		}
+		time.Sleep(probePollInterval)
+	}
+
+	luksType, uuid, err := ProbeLUKS(event.DevicePath)
+	if err != nil {
+		event.Err = err
+		return
+	}
+
+	event.LUKS = luksType != cryptsetup.TypeNone
+	event.LUKSType, event.UUID = luksType, uuid
+}
+
+// ProbeLUKS checks whether the device at 'devicePath' holds a LUKS header.
+// Returns the header's type and UUID, or TypeNone and an empty string if the device holds no LUKS header, or an error otherwise.
+func ProbeLUKS(devicePath string) (cryptsetup.Type, string, error) {
+	device, err := cryptsetup.Init(devicePath)
+	if err != nil {
+		return cryptsetup.TypeNone, "", err
+	}
+	defer device.Free()
+
+	if err := device.Load(); err != nil {
+		if cryptErr, ok := err.(*cryptsetup.Error); ok && cryptErr.Errno() == cryptsetup.EINVAL {
+			return cryptsetup.TypeNone, "", nil
+		}
+		return cryptsetup.TypeNone, "", err
+	}
+
+	if !device.IsLUKS() {
+		return cryptsetup.TypeNone, "", nil
+	}
+
+	return device.Type(), device.UUID(), nil
+}
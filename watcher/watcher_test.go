@@ -0,0 +1,132 @@
+package watcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cryptsetup"
+)
+
+const devicePath string = "testDevice"
+
+func uevent(fields ...string) []byte {
+	return []byte(strings.Join(fields, "\x00") + "\x00")
+}
+
+func Test_parseUevent(test *testing.T) {
+	event, ok := parseUevent(uevent("add@/devices/virtual/block/loop0/loop0p1", "ACTION=add", "DEVPATH=/devices/virtual/block/loop0/loop0p1",
+		"SUBSYSTEM=block", "DEVNAME=loop0p1", "DEVTYPE=partition", "SEQNUM=4242"))
+	if !ok || event.Type != DeviceAdded || event.DevicePath != "/dev/loop0p1" || event.DevType != "partition" {
+		test.Errorf("Unexpected event %+v: %v", event, ok)
+	}
+
+	event, ok = parseUevent(uevent("remove@/devices/virtual/block/loop0", "ACTION=remove", "SUBSYSTEM=block", "DEVNAME=loop0", "DEVTYPE=disk"))
+	if !ok || event.Type != DeviceRemoved || event.DevicePath != "/dev/loop0" {
+		test.Errorf("Unexpected event %+v: %v", event, ok)
+	}
+}
+
+func Test_parseUevent_Ignores_Other_Events(test *testing.T) {
+	messages := [][]byte{
+		uevent("add@/devices/virtual/net/lo", "ACTION=add", "SUBSYSTEM=net", "INTERFACE=lo"),
+		uevent("change@/devices/virtual/block/loop0", "ACTION=change", "SUBSYSTEM=block", "DEVNAME=loop0"),
+		uevent("add@/devices/virtual/block/loop0", "ACTION=add", "SUBSYSTEM=block"),
+		[]byte("libudev\x00garbage"),
+		{},
+	}
+
+	for _, message := range messages {
+		if event, ok := parseUevent(message); ok {
+			test.Errorf("Message %q should have been ignored, but was parsed as: %+v", message, event)
+		}
+	}
+}
+
+func Test_presentDevices(test *testing.T) {
+	directory, err := ioutil.TempDir("", "sysclassblock")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	for name, content := range map[string]string{
+		"loop0":   "MAJOR=7\nMINOR=0\nDEVNAME=loop0\nDEVTYPE=disk\n",
+		"loop0p1": "MAJOR=259\nMINOR=0\nDEVNAME=loop0p1\nDEVTYPE=partition\nPARTN=1\n",
+		"broken":  "MAJOR=1\n",
+	} {
+		if err := os.Mkdir(filepath.Join(directory, name), 0755); err != nil {
+			test.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(directory, name, "uevent"), []byte(content), 0644); err != nil {
+			test.Fatal(err)
+		}
+	}
+
+	defer func(path string) { sysClassBlockPath = path }(sysClassBlockPath)
+	sysClassBlockPath = directory
+
+	events := presentDevices()
+	if len(events) != 2 {
+		test.Fatalf("Expected 2 devices, got %+v", events)
+	}
+	if events[0].Type != DeviceAdded || events[0].DevicePath != "/dev/loop0" || events[0].DevType != "disk" {
+		test.Errorf("Unexpected event %+v", events[0])
+	}
+	if events[1].Type != DeviceAdded || events[1].DevicePath != "/dev/loop0p1" || events[1].DevType != "partition" {
+		test.Errorf("Unexpected event %+v", events[1])
+	}
+}
+
+func Test_ProbeLUKS(test *testing.T) {
+	luksType, uuid, err := ProbeLUKS(devicePath)
+	if err != nil || luksType != "" || uuid != "" {
+		test.Errorf("A blank device should not have been detected as LUKS, but got '%s' '%s': %v", luksType, uuid, err)
+	}
+
+	device, err := cryptsetup.Init(devicePath)
+	if err != nil {
+		test.Fatal(err)
+	}
+	err = device.Format(cryptsetup.LUKS1{Hash: "sha256"}, cryptsetup.GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	expectedUUID := device.UUID()
+	device.Free()
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	luksType, uuid, err = ProbeLUKS(devicePath)
+	if err != nil || luksType != cryptsetup.CRYPT_LUKS1 || uuid != expectedUUID {
+		test.Errorf("Unexpected probe result '%s' '%s': %v", luksType, uuid, err)
+	}
+
+	_, _, err = ProbeLUKS("nonExistingDevicePath")
+	if err == nil {
+		test.Error("Probing a non existing device should have failed.")
+	}
+}
+
+func Test_Watcher_New_Close(test *testing.T) {
+	watcher, err := New()
+	if err != nil {
+		test.Skipf("Uevents are not available in this environment: %v", err)
+	}
+
+	if err := watcher.Close(); err != nil {
+		test.Error(err)
+	}
+
+	for range watcher.Events() {
+	}
+}
+
+func TestMain(m *testing.M) {
+	exec.Command("/bin/dd", "if=/dev/zero", fmt.Sprintf("of=%s", devicePath), "bs=64M", "count=1").Run()
+	result := m.Run()
+	exec.Command("/bin/rm", "-f", devicePath).Run()
+	os.Exit(result)
+}
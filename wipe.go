@@ -0,0 +1,129 @@
+package cryptsetup
+
+// #cgo pkg-config: libcryptsetup
+// #include <libcryptsetup.h>
+// #include <stdlib.h>
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Wipe overwrites 'length' bytes of 'devicePath' starting at 'offset' using 'pattern', one of the CRYPT_WIPE_* patterns.
+// An empty 'devicePath' means the device's data device.
+// Fails with ErrReadOnly if the device was initialized read-only, or its header was write-protected.
+// Returns nil on success, or an error otherwise.
+// C equivalent: crypt_wipe
+func (device *Device) Wipe(devicePath string, pattern int, offset uint64, length uint64, flags uint32) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	var cDevicePath *C.char = nil
+	if len(devicePath) > 0 {
+		cDevicePath = C.CString(devicePath)
+		defer C.free(unsafe.Pointer(cDevicePath))
+	}
+
+	err := C.crypt_wipe(device.cryptDevice, cDevicePath, C.crypt_wipe_pattern(pattern), C.uint64_t(offset), C.uint64_t(length), 0, C.uint32_t(flags), nil, nil)
+	if err < 0 {
+		return device.newError("crypt_wipe", int(err), "wipe")
+	}
+
+	return nil
+}
+
+// KeyslotArea returns the offset and the length, in bytes, of the area holding the key material of 'keyslot'.
+// Returns the area on success, or an error otherwise.
+// C equivalent: crypt_keyslot_area
+func (device *Device) KeyslotArea(keyslot int) (uint64, uint64, error) {
+	if err := device.checkUsable(); err != nil {
+		return 0, 0, err
+	}
+
+	var offset, length C.uint64_t
+
+	err := C.crypt_keyslot_area(device.cryptDevice, C.int(keyslot), &offset, &length)
+	if err < 0 {
+		return 0, 0, device.newError("crypt_keyslot_area", int(err), "get keyslot area", keyslotDetail(keyslot))
+	}
+
+	return uint64(offset), uint64(length), nil
+}
+
+// WipeKeyslotArea overwrites the key material of 'keyslot' with random data, leaving the rest of the header and the data intact.
+// The keyslot cannot be unlocked afterwards, so this destroys the key material it protects without wiping the whole device.
+// Returns nil on success, or an error otherwise.
+func (device *Device) WipeKeyslotArea(keyslot int) error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	offset, length, err := device.KeyslotArea(keyslot)
+	if err != nil {
+		return err
+	}
+
+	return device.Wipe(device.MetadataDevicePath(), CRYPT_WIPE_RANDOM, offset, length, 0)
+}
+
+// WipeHeader overwrites the whole header area, including all keyslot areas, with random data.
+// The device's data becomes unrecoverable without a header backup, while only the header area is written.
+// With a detached header, the header device is wiped, and the data device is left untouched.
+// Returns nil on success, or an error otherwise.
+func (device *Device) WipeHeader() error {
+	if err := device.checkWritable(); err != nil {
+		return err
+	}
+
+	length, err := device.headerAreaLength()
+	if err != nil {
+		return err
+	}
+	if length == 0 {
+		return fmt.Errorf("device '%s' has no header area to wipe", device.MetadataDevicePath())
+	}
+
+	if err := device.Wipe(device.MetadataDevicePath(), CRYPT_WIPE_RANDOM, 0, length, 0); err != nil {
+		return err
+	}
+
+	emitEvent(&HeaderWiped{Device: device.DevicePath()})
+	return nil
+}
+
+// headerAreaLength returns the length, in bytes, of the header area at the start of the metadata device.
+// For LUKS2, it spans both metadata copies and the keyslots area. For LUKS1, it spans up to the payload
+// when the header is on the data device, or up to the end of the last keyslot area when it is detached.
+func (device *Device) headerAreaLength() (uint64, error) {
+	switch device.Type() {
+	case TypeLUKS2:
+		metadataSize, keyslotsSize, err := device.MetadataSize()
+		if err != nil {
+			return 0, err
+		}
+		return 2*metadataSize + keyslotsSize, nil
+	case TypeLUKS1:
+		detached, err := device.HeaderIsDetached()
+		if err != nil {
+			return 0, err
+		}
+		if !detached {
+			return uint64(C.crypt_get_data_offset(device.cryptDevice)) * 512, nil
+		}
+
+		var length uint64
+		for keyslot := 0; keyslot < device.KeyslotMax(); keyslot++ {
+			offset, areaLength, err := device.KeyslotArea(keyslot)
+			if err != nil {
+				return 0, err
+			}
+			if offset+areaLength > length {
+				length = offset + areaLength
+			}
+		}
+		return length, nil
+	default:
+		return 0, fmt.Errorf("device '%s' has no LUKS header to wipe, but is %s", device.MetadataDevicePath(), device.Type())
+	}
+}
@@ -0,0 +1,129 @@
+package cryptsetup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func Test_Device_WipeKeyslotArea(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "firstPassphrase")
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(1, "", "secondPassphrase")
+	testWrapper.AssertNoError(err)
+
+	defer device.Free()
+
+	err = device.WipeKeyslotArea(0)
+	testWrapper.AssertNoError(err)
+
+	_, err = device.CheckPassphrase(0, "firstPassphrase")
+	testWrapper.AssertError(err)
+
+	_, err = device.CheckPassphrase(1, "secondPassphrase")
+	testWrapper.AssertNoError(err)
+}
+
+func Test_Device_WipeHeader(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+	testWrapper.AssertNoError(err)
+
+	err = device.WipeHeader()
+	testWrapper.AssertNoError(err)
+	device.Free()
+
+	device, err = Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Load()
+	testWrapper.AssertError(err)
+}
+
+func Test_Device_WipeHeader_Detached(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	const headerPath = "testHeader"
+	defer os.Remove(headerPath)
+
+	for _, deviceType := range []DeviceType{LUKS1{Hash: "sha256"}, LUKS2{SectorSize: 512}} {
+		exec.Command("/bin/dd", "if=/dev/zero", fmt.Sprintf("of=%s", headerPath), "bs=1M", "count=16").Run()
+
+		device, err := InitDataDevice(headerPath, DevicePath)
+		testWrapper.AssertNoError(err)
+		err = device.Format(deviceType, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+		testWrapper.AssertNoError(err)
+		err = device.KeyslotAddByVolumeKey(0, "", "testPassphrase")
+		testWrapper.AssertNoError(err)
+
+		dataMD5 := getFileMD5(DevicePath, test)
+
+		err = device.WipeHeader()
+		testWrapper.AssertNoError(err)
+		device.Free()
+
+		if getFileMD5(DevicePath, test) != dataMD5 {
+			test.Errorf("Wiping a detached %s header should not have written to the data device.", deviceType.Name())
+		}
+
+		device, err = InitDataDevice(headerPath, DevicePath)
+		testWrapper.AssertNoError(err)
+		err = device.Load()
+		testWrapper.AssertError(err)
+		device.Free()
+	}
+}
+
+func Test_Device_WipeKeyslotArea_Fails_If_Device_Is_Read_Only(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+	device.Free()
+
+	device, err = InitReadOnly(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.WipeKeyslotArea(0)
+	if err != ErrReadOnly {
+		test.Errorf("Expected ErrReadOnly, but got: %v", err)
+	}
+}
+
+func Test_Device_Wipe_Fails_If_Header_Is_Read_Only(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	device.SetHeaderReadOnly(true)
+	if err = device.Wipe(device.MetadataDevicePath(), CRYPT_WIPE_ZERO, 0, 4096, 0); err != ErrReadOnly {
+		test.Errorf("Expected ErrReadOnly, but got: %v", err)
+	}
+	if err = device.WipeContext(context.Background(), "", CRYPT_WIPE_ZERO, 0, 4096, 0, nil); err != ErrReadOnly {
+		test.Errorf("Expected ErrReadOnly, but got: %v", err)
+	}
+
+	testWrapper.AssertNoError(device.Load())
+}
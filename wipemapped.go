@@ -0,0 +1,72 @@
+package cryptsetup
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WithRateLimit makes WipeMappedDevice write at most 'bytesPerSecond' bytes per second, so wiping a large device
+// doesn't starve the I/O of other workloads. A rate of 0 removes the limit.
+func WithRateLimit(bytesPerSecond uint64) Option {
+	return func(options *options) {
+		options.rateLimit = bytesPerSecond
+	}
+}
+
+// WipeMappedDevice overwrites the whole active mapping named 'deviceName' using 'pattern', one of the CRYPT_WIPE_* patterns,
+// writing through dm-crypt, so the plaintext left on the underlying device before it was encrypted is replaced by ciphertext.
+// CRYPT_WIPE_ZERO is enough, and the fastest: zeroes written through the mapping reach the disk encrypted.
+// Everything stored on the mapping is destroyed: it fails if the mapping is in use, such as mounted, unless WithForce is given.
+// Its progress is reported to 'progress', if it is not nil, and it stops once 'ctx' is done; WithRateLimit throttles it.
+// Delays are measured with the system clock, unless WithClock is given.
+// Returns nil on success, the context's error if it was canceled, or an error otherwise.
+// C equivalent: crypt_wipe, on the device node of the mapping
+func (device *Device) WipeMappedDevice(ctx context.Context, deviceName string, pattern int, progress ProgressFunc, optionFuncs ...Option) error {
+	if err := device.checkUsable(); err != nil {
+		return err
+	}
+
+	options := newOptions(optionFuncs)
+
+	active, err := device.Status(deviceName)
+	if err != nil {
+		return err
+	}
+	if active.Status != "active" && active.Status != "busy" {
+		return fmt.Errorf("mapping '%s' is %s", deviceName, active.Status)
+	}
+
+	if !options.force {
+		stats, err := MappingStats(deviceName)
+		if err != nil {
+			return err
+		}
+		if stats.Busy() {
+			return &DeviceInUseError{path: MapperNodePath(deviceName), reason: fmt.Sprintf("%s is open %d times", deviceName, stats.OpenCount)}
+		}
+	}
+
+	if options.rateLimit > 0 {
+		progress = rateLimitedProgress(progress, options.rateLimit, options.clock)
+	}
+
+	return device.WipeContext(ctx, MapperNodePath(deviceName), pattern, 0, active.Size*512, 0, progress)
+}
+
+// rateLimitedProgress wraps 'progress', which may be nil, to sleep whenever the wipe gets ahead of 'bytesPerSecond',
+// since libcryptsetup waits for the progress callback before writing the next block.
+func rateLimitedProgress(progress ProgressFunc, bytesPerSecond uint64, clock Clock) ProgressFunc {
+	start := clock.Now()
+
+	return func(size uint64, offset uint64) {
+		if progress != nil {
+			progress(size, offset)
+		}
+
+		expected := time.Duration(float64(offset) / float64(bytesPerSecond) * float64(time.Second))
+		if elapsed := clock.Now().Sub(start); expected > elapsed {
+			clock.Sleep(expected - elapsed)
+		}
+	}
+}
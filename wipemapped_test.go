@@ -0,0 +1,74 @@
+package cryptsetup
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_rateLimitedProgress_Sleeps_When_Ahead_Of_Rate(test *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+
+	var offsets []uint64
+	progress := rateLimitedProgress(func(size uint64, offset uint64) {
+		offsets = append(offsets, offset)
+	}, 1024*1024, clock)
+
+	progress(4*1024*1024, 1024*1024)
+	progress(4*1024*1024, 2*1024*1024)
+	clock.now = clock.now.Add(5 * time.Second)
+	progress(4*1024*1024, 3*1024*1024)
+
+	if !reflect.DeepEqual(clock.sleeps, []time.Duration{time.Second, time.Second}) {
+		test.Errorf("Unexpected sleeps: %v", clock.sleeps)
+	}
+	if !reflect.DeepEqual(offsets, []uint64{1024 * 1024, 2 * 1024 * 1024, 3 * 1024 * 1024}) {
+		test.Errorf("Unexpected progress: %v", offsets)
+	}
+}
+
+func Test_Device_WipeMappedDevice_Fails_If_Device_Is_Not_Active(test *testing.T) {
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	err = device.WipeMappedDevice(context.Background(), DeviceName, CRYPT_WIPE_ZERO, nil, WithRateLimit(1024*1024))
+	testWrapper.AssertError(err)
+}
+
+func Test_Device_WipeMappedDevice(test *testing.T) {
+	requirePrivileges(test)
+
+	testWrapper := TestWrapper{test}
+
+	device, err := Init(DevicePath)
+	testWrapper.AssertNoError(err)
+	defer device.Free()
+	err = device.Format(LUKS1{Hash: "sha256"}, GenericParams{Cipher: "aes", CipherMode: "xts-plain64", VolumeKeySize: 512 / 8})
+	testWrapper.AssertNoError(err)
+
+	testWrapper.AssertNoError(device.ActivateByVolumeKey(DeviceName, "", 512/8, 0))
+	defer device.Deactivate(DeviceName)
+
+	var size, reached uint64
+	err = device.WipeMappedDevice(context.Background(), DeviceName, CRYPT_WIPE_ZERO, func(total uint64, offset uint64) {
+		size, reached = total, offset
+	})
+	testWrapper.AssertNoError(err)
+	if size == 0 || reached == 0 || reached > size {
+		test.Errorf("Unexpected progress: %d of %d bytes", reached, size)
+	}
+
+	contents, err := ioutil.ReadFile(MapperNodePath(DeviceName))
+	testWrapper.AssertNoError(err)
+	if uint64(len(contents)) != size || !bytes.Equal(contents, make([]byte, len(contents))) {
+		test.Errorf("The mapping should have been zeroed.")
+	}
+}
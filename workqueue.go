@@ -0,0 +1,179 @@
+package cryptsetup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WorkqueueReport describes the storage and crypto driver under an active dm-crypt mapping,
+// and whether bypassing dm-crypt's workqueues with CRYPT_ACTIVATE_NO_READ_WORKQUEUE and CRYPT_ACTIVATE_NO_WRITE_WORKQUEUE
+// would likely reduce its latency.
+type WorkqueueReport struct {
+	// Name is the name of the mapping.
+	Name string
+	// BackingDevice is the name of the block device under the mapping in sysfs, such as "nvme0n1p2".
+	BackingDevice string
+	// Rotational reports whether the backing device is backed by rotational media.
+	Rotational bool
+	// Algorithm is the kernel crypto API name of the mapping's cipher, such as "xts(aes)".
+	Algorithm string
+	// Driver is the kernel crypto driver with the highest priority for Algorithm, such as "xts-aes-aesni".
+	// It is empty if the algorithm is not listed in /proc/crypto.
+	Driver string
+	// Async reports whether Driver processes requests asynchronously, such as hardware offload engines or cryptd wrappers.
+	Async bool
+	// ReadWorkqueue and WriteWorkqueue report whether the mapping currently uses dm-crypt's read and write workqueues.
+	ReadWorkqueue  bool
+	WriteWorkqueue bool
+	// Reasons explain the recommendation.
+	Reasons []string
+}
+
+// Recommended reports whether bypassing the workqueues would likely help: it is the case for fast, non-rotational devices
+// whose cipher is processed synchronously on the CPU, where queuing adds latency without improving throughput.
+// It is never recommended if libcryptsetup is older than 2.3.4, which cannot bypass the workqueues.
+func (report WorkqueueReport) Recommended() bool {
+	return workqueueFlagsDefined() && !report.Rotational && report.Driver != "" && !report.Async
+}
+
+// workqueueFlagsDefined reports whether the libcryptsetup the package was built against defines the workqueue flags.
+func workqueueFlagsDefined() bool {
+	return CRYPT_ACTIVATE_NO_READ_WORKQUEUE != 0 && CRYPT_ACTIVATE_NO_WRITE_WORKQUEUE != 0
+}
+
+// Flags returns the activation flags bypassing the workqueues if Recommended, or 0 otherwise,
+// to be passed to the activation methods or stored with SetPersistentFlags.
+func (report WorkqueueReport) Flags() int {
+	if !report.Recommended() {
+		return 0
+	}
+	return CRYPT_ACTIVATE_NO_READ_WORKQUEUE | CRYPT_ACTIVATE_NO_WRITE_WORKQUEUE
+}
+
+// WorkqueueReport inspects the active dm-crypt mapping named 'name' through its device-mapper table, sysfs and /proc/crypto,
+// to guide the choice of the CRYPT_ACTIVATE_NO_READ_WORKQUEUE and CRYPT_ACTIVATE_NO_WRITE_WORKQUEUE activation flags.
+// The report is a heuristic: benchmarking, such as with BenchmarkMapping, remains the way to confirm a change helps.
+// Returns the report on success, or an error otherwise.
+func (device *Device) WorkqueueReport(name string) (WorkqueueReport, error) {
+	targets, err := device.DMTable(name)
+	if err != nil {
+		return WorkqueueReport{Name: name}, err
+	}
+
+	for _, target := range targets {
+		if target.Crypt != nil {
+			return workqueueReport(name, target.Crypt)
+		}
+	}
+
+	return WorkqueueReport{Name: name}, fmt.Errorf("mapping '%s' has no crypt target", name)
+}
+
+// workqueueReport builds the report for the mapping named 'name', from the parameters of its crypt target.
+func workqueueReport(name string, crypt *DMCryptParams) (WorkqueueReport, error) {
+	report := WorkqueueReport{Name: name, ReadWorkqueue: true, WriteWorkqueue: true}
+
+	for _, option := range crypt.Options {
+		switch option {
+		case "no_read_workqueue":
+			report.ReadWorkqueue = false
+		case "no_write_workqueue":
+			report.WriteWorkqueue = false
+		}
+	}
+
+	var major, minor uint32
+	if _, err := fmt.Sscanf(crypt.Device, "%d:%d", &major, &minor); err != nil {
+		return report, fmt.Errorf("unexpected backing device '%s'", crypt.Device)
+	}
+	devicePath, err := filepath.EvalSymlinks(filepath.Join(sysfsPath, "dev", "block", fmt.Sprintf("%d:%d", major, minor)))
+	if err != nil {
+		return report, err
+	}
+	report.BackingDevice = filepath.Base(devicePath)
+
+	queuePath := filepath.Join(devicePath, "queue")
+	if _, err := os.Stat(queuePath); os.IsNotExist(err) {
+		// partitions share the queue of their parent disk
+		queuePath = filepath.Join(filepath.Dir(devicePath), "queue")
+	}
+	if rotational, err := readSysfsUint(filepath.Join(queuePath, "rotational")); err == nil {
+		report.Rotational = rotational == 1
+	}
+
+	report.Algorithm = kernelCipherAlgorithm(crypt.Cipher)
+	report.Driver, report.Async, err = kernelCryptoDriver(report.Algorithm)
+	if err != nil {
+		return report, err
+	}
+
+	switch {
+	case !workqueueFlagsDefined():
+		report.Reasons = append(report.Reasons, "libcryptsetup is older than 2.3.4, and cannot bypass the workqueues")
+	case report.Rotational:
+		report.Reasons = append(report.Reasons, "the backing device is rotational, so its latency dominates the queuing delay")
+	case report.Driver == "":
+		report.Reasons = append(report.Reasons, fmt.Sprintf("no kernel crypto driver is loaded for '%s'", report.Algorithm))
+	case report.Async:
+		report.Reasons = append(report.Reasons, fmt.Sprintf("the crypto driver '%s' is asynchronous, and benefits from the workqueues", report.Driver))
+	default:
+		report.Reasons = append(report.Reasons, fmt.Sprintf("the backing device is not rotational and the crypto driver '%s' is synchronous", report.Driver))
+	}
+
+	return report, nil
+}
+
+// kernelCipherAlgorithm converts a dm-crypt cipher specification, such as "aes-xts-plain64" or "capi:xts(aes)-plain64",
+// to the name of the kernel crypto API algorithm it uses, such as "xts(aes)".
+func kernelCipherAlgorithm(cipher string) string {
+	if strings.HasPrefix(cipher, "capi:") {
+		spec := strings.TrimPrefix(cipher, "capi:")
+		if index := strings.LastIndex(spec, ")-"); index >= 0 {
+			return spec[:index+1]
+		}
+		return spec
+	}
+
+	parts := strings.SplitN(cipher, "-", 3)
+	if len(parts) < 2 {
+		return cipher
+	}
+	return fmt.Sprintf("%s(%s)", parts[1], parts[0])
+}
+
+// kernelCryptoDriver returns the driver with the highest priority implementing 'algorithm' in /proc/crypto,
+// and whether it is asynchronous. The driver is an empty string if none implements it.
+func kernelCryptoDriver(algorithm string) (string, bool, error) {
+	content, err := ioutil.ReadFile(procCryptoPath)
+	if err != nil {
+		return "", false, err
+	}
+
+	var driver string
+	var async bool
+	bestPriority := -1
+	for _, entry := range strings.Split(string(content), "\n\n") {
+		fields := make(map[string]string)
+		for _, line := range strings.Split(entry, "\n") {
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) == 2 {
+				fields[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			}
+		}
+
+		if fields["name"] != algorithm || fields["type"] != "skcipher" {
+			continue
+		}
+		priority, err := strconv.Atoi(fields["priority"])
+		if err != nil || priority <= bestPriority {
+			continue
+		}
+		driver, async, bestPriority = fields["driver"], fields["async"] == "yes", priority
+	}
+
+	return driver, async, nil
+}
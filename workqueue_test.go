@@ -0,0 +1,90 @@
+package cryptsetup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testProcCrypto = `name         : xts(aes)
+driver       : xts(ecb(aes-generic))
+module       : kernel
+priority     : 100
+type         : skcipher
+async        : no
+
+name         : xts(aes)
+driver       : xts-aes-aesni
+module       : aesni_intel
+priority     : 401
+type         : skcipher
+async        : no
+
+name         : cbc(aes)
+driver       : cbc-aes-qat
+module       : qat
+priority     : 4001
+type         : skcipher
+async        : yes
+`
+
+func setupWorkqueueSysfs(test *testing.T, rotational string) func() {
+	testWrapper := TestWrapper{test}
+
+	directory, err := ioutil.TempDir("", "workqueue")
+	testWrapper.AssertNoError(err)
+
+	previousSysfsPath, previousProcCryptoPath := sysfsPath, procCryptoPath
+	sysfsPath, procCryptoPath = directory, filepath.Join(directory, "crypto")
+
+	testWrapper.AssertNoError(ioutil.WriteFile(procCryptoPath, []byte(testProcCrypto), 0644))
+	testWrapper.AssertNoError(os.MkdirAll(filepath.Join(directory, "devices", "nvme0n1", "queue"), 0755))
+	testWrapper.AssertNoError(os.MkdirAll(filepath.Join(directory, "devices", "nvme0n1", "nvme0n1p2"), 0755))
+	testWrapper.AssertNoError(ioutil.WriteFile(filepath.Join(directory, "devices", "nvme0n1", "queue", "rotational"), []byte(rotational+"\n"), 0644))
+	testWrapper.AssertNoError(os.MkdirAll(filepath.Join(directory, "dev", "block"), 0755))
+	testWrapper.AssertNoError(os.Symlink(filepath.Join(directory, "devices", "nvme0n1", "nvme0n1p2"), filepath.Join(directory, "dev", "block", "259:2")))
+
+	return func() {
+		sysfsPath, procCryptoPath = previousSysfsPath, previousProcCryptoPath
+		os.RemoveAll(directory)
+	}
+}
+
+func Test_workqueueReport_Recommends_Bypass_For_Synchronous_Driver_On_SSD(test *testing.T) {
+	testWrapper := TestWrapper{test}
+	defer setupWorkqueueSysfs(test, "0")()
+
+	report, err := workqueueReport("test", &DMCryptParams{Cipher: "aes-xts-plain64", Device: "259:2", Options: []string{"no_read_workqueue"}})
+	testWrapper.AssertNoError(err)
+
+	if report.BackingDevice != "nvme0n1p2" || report.Rotational || report.Algorithm != "xts(aes)" || report.Driver != "xts-aes-aesni" || report.Async {
+		test.Errorf("Unexpected report: %+v", report)
+	}
+	if report.ReadWorkqueue || !report.WriteWorkqueue {
+		test.Errorf("Unexpected workqueue state: %+v", report)
+	}
+	if !workqueueFlagsDefined() {
+		test.Skip("libcryptsetup is too old to bypass the workqueues")
+	}
+	if !report.Recommended() || report.Flags() != CRYPT_ACTIVATE_NO_READ_WORKQUEUE|CRYPT_ACTIVATE_NO_WRITE_WORKQUEUE {
+		test.Errorf("Bypassing the workqueues should have been recommended: %v", report.Reasons)
+	}
+}
+
+func Test_workqueueReport_Does_Not_Recommend_Bypass(test *testing.T) {
+	testWrapper := TestWrapper{test}
+	defer setupWorkqueueSysfs(test, "1")()
+
+	report, err := workqueueReport("test", &DMCryptParams{Cipher: "aes-xts-plain64", Device: "259:2"})
+	testWrapper.AssertNoError(err)
+	if !report.Rotational || report.Recommended() {
+		test.Errorf("Bypassing the workqueues should not have been recommended for rotational devices: %+v", report)
+	}
+
+	report, err = workqueueReport("test", &DMCryptParams{Cipher: "capi:cbc(aes)-essiv:sha256", Device: "259:2"})
+	testWrapper.AssertNoError(err)
+	if report.Algorithm != "cbc(aes)" || !report.Async || report.Recommended() {
+		test.Errorf("Bypassing the workqueues should not have been recommended for asynchronous drivers: %+v", report)
+	}
+}